@@ -0,0 +1,257 @@
+//
+// Unlock Agent
+// ============
+//
+// Like ssh-agent, the unlock agent keeps our keypair decrypted so that the passphrase is not needed for
+// every start of Sasayaki (the subcommands, `sasayaki -cli`):
+//
+//	sasayaki agent start [--idle 15m]   (asks for the passphrase, and serves our keypair until it locks)
+//	sasayaki agent stop                 (locks the agent now)
+//
+// It listens on ~/.sasayaki/agent/agent.sock, a Unix socket created in a directory only we can open, so that
+// nobody else can connect to it even before its permissions are set. The uid of the process connecting is also
+// checked (SO_PEERCRED, see agent_linux.go): the agent only runs on Linux. A request is a single JSON object:
+//
+//	{"op": "get_keypair"} -> {"private_key": "<hex>"}
+//	{"op": "lock"}        -> {"success": true}
+//	{"op": "status"}      -> {"success": true}
+//
+// The agent locks itself (it forgets the keypair and exits) when it hasn't been used for a while.
+// It also locks when we change our key (see rotation.go). The database is not encrypted at rest yet:
+// when it is, its key will be held by the agent too.
+//
+// The client side is in init.go (initSasayakiFromAgent)
+//
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+const (
+	agentDefaultIdleTimeout = 15 * time.Minute
+	agentRequestTimeout     = 5 * time.Second
+)
+
+// agentRequest is what a client sends to the agent
+type agentRequest struct {
+	Op string `json:"op"` // "get_keypair", "lock" or "status"
+}
+
+// agentResponse is what the agent answers
+type agentResponse struct {
+	PrivateKey string `json:"private_key,omitempty"`
+	Success    bool   `json:"success,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// unlockAgent holds our decrypted keypair
+type unlockAgent struct {
+	keyPair     *disco.KeyPair
+	idleTimeout time.Duration
+	lastUse     time.Time
+	listener    net.Listener
+
+	mutex    sync.Mutex
+	requests sync.WaitGroup // the requests being answered, when the agent stops
+}
+
+// agentSocketPath returns where the agent listens (~/.sasayaki/agent/agent.sock)
+func agentSocketPath() string {
+	return filepath.Join(sasayakiFolder(), "agent", "agent.sock")
+}
+
+// runAgent serves our keypair until the agent is locked
+func runAgent(keyPair *disco.KeyPair, idleTimeout time.Duration) error {
+	path := agentSocketPath()
+	// only one agent at a time, but a socket can be left behind by an agent that crashed
+	if _, err := agentQuery("status"); err == nil {
+		return errors.New("ssyk: an agent is already running")
+	}
+	// the directory may already exist with other permissions
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := os.Chmod(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+	agent := &unlockAgent{
+		keyPair:     keyPair,
+		idleTimeout: idleTimeout,
+		lastUse:     time.Now(),
+		listener:    listener,
+	}
+	go agent.lockWhenIdle()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			break // the agent has been locked
+		}
+		agent.requests.Add(1)
+		go agent.handle(conn)
+	}
+	agent.requests.Wait()
+	os.Remove(path)
+	return nil
+}
+
+// lockWhenIdle locks the agent once it hasn't been used for idleTimeout
+func (agent *unlockAgent) lockWhenIdle() {
+	for {
+		time.Sleep(agent.idleTimeout / 10)
+		agent.mutex.Lock()
+		idle := time.Since(agent.lastUse) > agent.idleTimeout
+		agent.mutex.Unlock()
+		if idle {
+			log.Println("agent: locked after", agent.idleTimeout, "of inactivity")
+			agent.lock()
+			return
+		}
+	}
+}
+
+// lock forgets our keypair, and stops the agent
+func (agent *unlockAgent) lock() {
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+	if agent.keyPair == nil {
+		return
+	}
+	for i := range agent.keyPair.PrivateKey {
+		agent.keyPair.PrivateKey[i] = 0
+	}
+	agent.keyPair = nil
+	agent.listener.Close()
+}
+
+// handle answers the request of a client
+func (agent *unlockAgent) handle(conn net.Conn) {
+	defer agent.requests.Done()
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(agentRequestTimeout))
+	encoder := json.NewEncoder(conn)
+	// only we can use the agent
+	if err := checkPeerCredentials(conn); err != nil {
+		log.Println("agent: refused a connection:", err)
+		encoder.Encode(agentResponse{Error: err.Error()})
+		return
+	}
+	var req agentRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		encoder.Encode(agentResponse{Error: "ssyk: malformed request"})
+		return
+	}
+	switch req.Op {
+	case "status":
+		encoder.Encode(agentResponse{Success: true})
+	case "get_keypair":
+		agent.mutex.Lock()
+		if agent.keyPair == nil {
+			agent.mutex.Unlock()
+			encoder.Encode(agentResponse{Error: "ssyk: the agent is locked"})
+			return
+		}
+		agent.lastUse = time.Now()
+		privateKey := hex.EncodeToString(agent.keyPair.PrivateKey[:])
+		agent.mutex.Unlock()
+		encoder.Encode(agentResponse{PrivateKey: privateKey})
+	case "lock":
+		agent.lock()
+		encoder.Encode(agentResponse{Success: true})
+	default:
+		encoder.Encode(agentResponse{Error: "ssyk: unknown request"})
+	}
+}
+
+// agentQuery sends a request to the agent, if one is running
+func agentQuery(op string) (*agentResponse, error) {
+	conn, err := net.DialTimeout("unix", agentSocketPath(), agentRequestTimeout)
+	if err != nil {
+		return nil, errors.New("ssyk: no agent running")
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(agentRequestTimeout))
+	if err := json.NewEncoder(conn).Encode(agentRequest{Op: op}); err != nil {
+		return nil, err
+	}
+	var res agentResponse
+	if err := json.NewDecoder(conn).Decode(&res); err != nil {
+		return nil, errors.New("ssyk: the agent sent a malformed response")
+	}
+	if res.Error != "" {
+		return nil, errors.New(res.Error)
+	}
+	return &res, nil
+}
+
+// lockAgent tells the agent, if one is running, to forget our keypair
+func lockAgent() error {
+	_, err := agentQuery("lock")
+	return err
+}
+
+// sasayaki agent (start [--idle <duration>] | stop)
+func agentCommand(args []string) int {
+	usage := errors.New("usage: sasayaki agent (start [--idle <duration>] | stop)")
+	if len(args) == 0 {
+		return commandOutput{}.fail(exitUsage, usage)
+	}
+	flags, jsonOutput, passphraseFd := commandFlags("agent " + args[0])
+	idleTimeout := flags.Duration("idle", agentDefaultIdleTimeout, "lock the agent after this long without being used")
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	out := commandOutput{*jsonOutput}
+	if flags.NArg() != 0 || *idleTimeout <= 0 {
+		return out.fail(exitUsage, usage)
+	}
+	switch args[0] {
+	case "start":
+		if err := checkAgentSupported(); err != nil {
+			return out.fail(exitFailure, err)
+		}
+		if _, err := os.Stat(keyPairLocation()); os.IsNotExist(err) {
+			return out.fail(exitNotConfigured, errors.New("ssyk: no key yet, run sasayaki -cli first"))
+		}
+		passphrase, err := readPassphrase(*passphraseFd)
+		if err != nil {
+			return out.fail(exitLocked, err)
+		}
+		keyPair, err := initKeyPair(passphrase)
+		if err != nil {
+			return out.fail(exitLocked, err)
+		}
+		fmt.Fprintln(os.Stderr, "agent: listening on", agentSocketPath())
+		if err := runAgent(keyPair, *idleTimeout); err != nil {
+			return out.fail(exitFailure, err)
+		}
+	case "stop":
+		if err := lockAgent(); err != nil {
+			return out.fail(exitFailure, err)
+		}
+	default:
+		return out.fail(exitUsage, usage)
+	}
+	out.print(map[string]string{"success": "true"}, "")
+	return exitOK
+}
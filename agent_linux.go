@@ -0,0 +1,46 @@
+//
+// Unlock Agent: peer credentials (Linux)
+// ======================================
+//
+// The agent checks that the process connecting to its socket runs as the same user (see agent.go)
+//
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+// checkAgentSupported returns an error if the agent can't run on this platform
+func checkAgentSupported() error {
+	return nil
+}
+
+// checkPeerCredentials refuses the connections from other users
+func checkPeerCredentials(conn net.Conn) error {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.New("ssyk: not a unix socket")
+	}
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return err
+	}
+	if credErr != nil {
+		return credErr
+	}
+	if int(cred.Uid) != os.Getuid() {
+		return errors.New("ssyk: the agent only serves its own user")
+	}
+	return nil
+}
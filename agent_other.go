@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+//
+// Unlock Agent: peer credentials
+// ==============================
+//
+// Outside of Linux, we can't check who connects to the socket of the agent, and the permissions of a Unix
+// socket are not enforced everywhere: the agent doesn't start (see agent.go)
+//
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+var errAgentUnsupported = errors.New("ssyk: the unlock agent is only available on Linux, where it can check who connects to it")
+
+// checkAgentSupported refuses to start the agent
+func checkAgentSupported() error {
+	return errAgentUnsupported
+}
+
+// checkPeerCredentials refuses the connections from other users
+func checkPeerCredentials(conn net.Conn) error {
+	return errAgentUnsupported
+}
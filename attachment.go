@@ -0,0 +1,164 @@
+//
+// Attachments
+// ===========
+//
+// Messages are limited in size, so files are shared differently:
+//
+// 1. the file is encrypted under a fresh random key (see crypto.go)
+// 2. the ciphertext is split in chunks that are uploaded to the blob store of the contact's Hub (see hubpool.go)
+// 3. a message containing the ids of the chunks, the key and the hash of the file is sent to the contact
+//
+// The contact can then download the chunks, decrypt and verify the file, and store it under ~/.sasayaki/attachments
+//
+// Hubs don't forward blobs to the Hubs they federate with (see server/delivery.go), so attachments can't be sent
+// to the <key>@<hub> contacts of a peer Hub: the chunks would be on a Hub the contact can't reach.
+//
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	attachmentMaxSize   = 10 * 1024 * 1024 // 10MB
+	attachmentChunkSize = 32 * 1024        // must be smaller than the hub's blobMaxSize
+)
+
+// sendAttachment uploads an encrypted file to the hub of the contact, then sends a message referencing it
+// it returns the id of the message
+func (ss sasayakiState) sendAttachment(convoId, bobAddress, name string, file []byte) (string, error) {
+	if convoId == "" {
+		return "", errors.New("ssyk: attachments must be sent in an existing conversation")
+	}
+	if len(file) == 0 || len(file) > attachmentMaxSize {
+		return "", errors.New("ssyk: attachment is empty or too large")
+	}
+	if _, peer := splitAddress(bobAddress); peer != "" {
+		return "", errors.New("ssyk: attachments can't be sent to the contacts of a peer Hub, it doesn't forward them")
+	}
+	name = sanitizeFileName(name)
+
+	// encrypt the file
+	key, hash, ciphertext := e2e.encryptAttachment(file)
+
+	// upload it in chunks
+	storage.queryMutex.Lock()
+	bobHub := hubs.of(bobAddress)
+	var blobs []string
+	for len(ciphertext) > 0 {
+		size := attachmentChunkSize
+		if len(ciphertext) < size {
+			size = len(ciphertext)
+		}
+		id, err := bobHub.uploadBlob(ciphertext[:size])
+		if err != nil {
+			storage.queryMutex.Unlock()
+			return "", err
+		}
+		blobs = append(blobs, id)
+		ciphertext = ciphertext[size:]
+	}
+	storage.queryMutex.Unlock()
+
+	// send the message containing the key
+	msg := &plaintextMsg{
+		ConvoId:     convoId,
+		FromAddress: ss.myAddress,
+		ToAddress:   bobAddress,
+		Type:        attachmentMsg,
+		Content:     name,
+		Attachment: &attachment{
+			Name:  name,
+			Size:  uint64(len(file)),
+			Key:   key,
+			Hash:  hash,
+			Blobs: blobs,
+		},
+	}
+	if _, err := ss.sendMessage(msg); err != nil {
+		return "", err
+	}
+
+	// keep a copy of what we sent
+	location := filepath.Join(sasayakiFolder(), "attachments", msg.Id+"-"+name)
+	if err := ioutil.WriteFile(location, file, 0600); err != nil {
+		return "", err
+	}
+	storage.queryMutex.Lock()
+	storage.updateAttachmentPath(convoId, msg.Id, location)
+	storage.queryMutex.Unlock()
+
+	//
+	return msg.Id, nil
+}
+
+// downloadAttachment downloads, verifies and decrypts an attachment, then stores it under ~/.sasayaki/attachments
+// it returns the location of the file
+func (ss sasayakiState) downloadAttachment(convoId, msgId string) (string, error) {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+
+	// do we know about this attachment?
+	att, location, err := storage.getAttachment(convoId, msgId)
+	if err != nil {
+		return "", err
+	}
+	// already downloaded?
+	if location != "" {
+		return location, nil
+	}
+
+	// download all the chunks, from the Hub of the contact who sent them
+	sender, _ := storage.getConvoPeer(convoId)
+	if _, peer := splitAddress(sender); peer != "" {
+		return "", errors.New("ssyk: attachments can't be received from the contacts of a peer Hub, it doesn't forward them")
+	}
+	senderHub := hubs.of(sender)
+	var ciphertext []byte
+	for _, id := range att.Blobs {
+		chunk, err := senderHub.downloadBlob(id)
+		if err != nil {
+			return "", err
+		}
+		// the hub's blob store is content-addressed
+		hash := sha256.Sum256(chunk)
+		if hex.EncodeToString(hash[:]) != id {
+			return "", errors.New("ssyk: the hub returned an incorrect chunk")
+		}
+		ciphertext = append(ciphertext, chunk...)
+	}
+
+	// decrypt and verify
+	file, err := e2e.decryptAttachment(att.Key, att.Hash, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if uint64(len(file)) != att.Size {
+		return "", errors.New("ssyk: attachment does not have the expected size")
+	}
+
+	// store
+	location = filepath.Join(sasayakiFolder(), "attachments", msgId+"-"+sanitizeFileName(att.Name))
+	if err := ioutil.WriteFile(location, file, 0600); err != nil {
+		return "", err
+	}
+	storage.updateAttachmentPath(convoId, msgId, location)
+
+	//
+	return location, nil
+}
+
+// sanitizeFileName makes sure that a file name received from someone else can't be used to write outside of
+// the attachments folder
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.Replace(name, "\\", "/", -1))
+	if name == "." || name == "/" || name == ".." {
+		return "attachment"
+	}
+	return name
+}
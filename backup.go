@@ -0,0 +1,163 @@
+//
+// Backups
+// =======
+//
+// Everything we need to recover our identity, contacts and history is under ~/.sasayaki: our keypair (and the
+// keys we have archived), the database and the configuration. `sasayaki -export-backup <file>` puts them in a
+// single archive encrypted under a passphrase, and `sasayaki -import-backup <file>` restores them:
+//
+//	"SSYKBACKUP" | version (1 byte) | salt (16 bytes) | disco.Encrypt(key, Backup)
+//
+// The key is derived from the passphrase with Argon2id. The encrypted Backup (see messages.proto) repeats the
+// version and the salt, so that the header is authenticated, and contains the hash of every file.
+//
+// Warning: the session keys of our conversations and our thread states are ratcheted with every message.
+// Restoring a stale backup brings back old states: messages sent or received since the backup was made
+// can't be decrypted anymore, and these conversations (or contacts) have to be created again.
+//
+// The attachments we have downloaded are not part of the backup.
+//
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+	"golang.org/x/crypto/argon2"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+const (
+	backupMagic    = "SSYKBACKUP"
+	backupVersion  = 1
+	backupSaltSize = 16
+)
+
+// backupFiles returns the files of ~/.sasayaki that are part of a backup, relative to it
+func backupFiles() []string {
+	files := []string{"configuration.json", "database.db", filepath.Join("keys", "keypair")}
+	archived, _ := filepath.Glob(filepath.Join(sasayakiFolder(), "keys", "archive", "*.keypair"))
+	for _, path := range archived {
+		files = append(files, filepath.Join("keys", "archive", filepath.Base(path)))
+	}
+	return files
+}
+
+// backupKey derives the key encrypting a backup from a passphrase
+func backupKey(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, 3, 64*1024, 4, 32)
+}
+
+// exportBackup writes an encrypted backup of ~/.sasayaki to a file
+func exportBackup(location, passphrase string) error {
+	if passphrase == "" {
+		return errors.New("ssyk: the backup needs a passphrase")
+	}
+	salt := make([]byte, backupSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+	backup := &s.Backup{
+		Version:   backupVersion,
+		Salt:      salt,
+		CreatedAt: time.Now().Unix(),
+	}
+	for _, name := range backupFiles() {
+		content, err := ioutil.ReadFile(filepath.Join(sasayakiFolder(), name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		backup.Files = append(backup.Files, &s.BackupFile{
+			Name:    filepath.ToSlash(name),
+			Content: content,
+			Hash:    disco.Hash(content, 32),
+		})
+	}
+	serialized, err := proto.Marshal(backup)
+	if err != nil {
+		panic(err)
+	}
+	// header | ciphertext
+	archive := append([]byte(backupMagic), backupVersion)
+	archive = append(archive, salt...)
+	archive = append(archive, disco.Encrypt(backupKey(passphrase, salt), serialized)...)
+	return ioutil.WriteFile(location, archive, 0600)
+}
+
+// readBackup decrypts a backup and verifies its integrity
+func readBackup(location, passphrase string) (*s.Backup, error) {
+	archive, err := ioutil.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := len(backupMagic) + 1 + backupSaltSize
+	if len(archive) < headerSize || !bytes.Equal(archive[:len(backupMagic)], []byte(backupMagic)) {
+		return nil, errors.New("ssyk: this file is not a Sasayaki backup")
+	}
+	if archive[len(backupMagic)] != backupVersion {
+		return nil, errors.New("ssyk: this backup was made by another version of Sasayaki")
+	}
+	salt := archive[len(backupMagic)+1 : headerSize]
+	serialized, err := disco.Decrypt(backupKey(passphrase, salt), archive[headerSize:])
+	if err != nil {
+		return nil, errors.New("ssyk: wrong passphrase, or the backup has been corrupted")
+	}
+	backup := &s.Backup{}
+	if err := proto.Unmarshal(serialized, backup); err != nil {
+		return nil, errors.New("ssyk: the backup is malformed")
+	}
+	if backup.GetVersion() != backupVersion || !bytes.Equal(backup.GetSalt(), salt) {
+		return nil, errors.New("ssyk: the header of the backup has been modified")
+	}
+	for _, file := range backup.GetFiles() {
+		name := filepath.Clean(filepath.FromSlash(file.GetName()))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return nil, errors.New("ssyk: the backup contains a file outside of the Sasayaki folder")
+		}
+		if subtle.ConstantTimeCompare(disco.Hash(file.GetContent(), 32), file.GetHash()) != 1 {
+			return nil, errors.New("ssyk: the backup has been corrupted (" + file.GetName() + ")")
+		}
+	}
+	return backup, nil
+}
+
+// importBackup restores ~/.sasayaki from an encrypted backup. The current folder is not deleted but moved
+// next to it, and the date of the backup is returned so that the user can be warned if it is stale
+func importBackup(location, passphrase string) (time.Time, error) {
+	backup, err := readBackup(location, passphrase)
+	if err != nil {
+		return time.Time{}, err
+	}
+	home := sasayakiFolder()
+	if _, err := os.Stat(home); err == nil {
+		previous := fmt.Sprintf("%s.before-restore-%d", home, time.Now().Unix())
+		if err := os.Rename(home, previous); err != nil {
+			return time.Time{}, err
+		}
+		fmt.Println("ssyk: the previous Sasayaki folder has been moved to", previous)
+	}
+	initSasayakiFolder()
+	for _, file := range backup.GetFiles() {
+		path := filepath.Join(home, filepath.Clean(filepath.FromSlash(file.GetName())))
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil { // user | group | all
+			return time.Time{}, err
+		}
+		if err := ioutil.WriteFile(path, file.GetContent(), 0600); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(backup.GetCreatedAt(), 0), nil
+}
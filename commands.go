@@ -0,0 +1,401 @@
+//
+// Subcommands
+// ===========
+//
+// Besides the web UI and the terminal UI, the client can be scripted with one-shot commands:
+//
+//	sasayaki whoami
+//	sasayaki send --to <address> --convo <id> <message>   (or - to read the message from stdin)
+//	sasayaki send --to <address> --title <title> <message> (starts a new conversation)
+//	sasayaki fetch
+//	sasayaki contacts list
+//	sasayaki contacts add [--hub <name>] <address> <name>
+//	sasayaki contacts accept <address> <name>
+//	sasayaki device link                      (on a new device, shows a one-time code and waits to be linked)
+//	sasayaki device approve <code> <name>     (on our first device, links the device that shows the code)
+//	sasayaki device list
+//	sasayaki device remove <address>
+//
+// Flags come before the arguments. With --json, the result (or the error) is written to stdout as a
+// single JSON document. The passphrase is read from the terminal, or from a file descriptor with
+// --passphrase-fd (e.g. `sasayaki fetch --json --passphrase-fd 3 3<passphrase.txt`), unless the unlock
+// agent is running (see agent.go). --profile selects another profile than the default one (see profile.go).
+//
+// The exit codes are stable, so that bots and alerting can rely on them:
+//
+// * 0: success
+// * 1: the command failed (the Hub is unreachable, the contact is unknown, etc.)
+// * 2: the command or its arguments are wrong
+// * 3: the passphrase is missing or wrong
+// * 4: Sasayaki is not set up yet (run `sasayaki -cli` once to create a key and configure a Hub)
+// * 5: fetch failed after fetching some messages, they are written with the error (with --json, as an object
+// with the messages, the error and the code, instead of the list of messages)
+//
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	exitOK            = 0
+	exitFailure       = 1
+	exitUsage         = 2
+	exitLocked        = 3
+	exitNotConfigured = 4
+	exitPartial       = 5
+
+	maxFetchedMessages = 100
+)
+
+// subcommands are the one-shot commands, they return an exit code
+var subcommands = map[string]func(args []string) int{
+	"whoami":   whoamiCommand,
+	"send":     sendCommand,
+	"fetch":    fetchCommand,
+	"contacts": contactsCommand,
+	"agent":    agentCommand,
+	"device":   deviceCommand,
+}
+
+// isSubcommand returns true if the first argument of the program is a subcommand
+func isSubcommand(name string) bool {
+	_, ok := subcommands[name]
+	return ok
+}
+
+// runSubcommand runs a subcommand and returns its exit code
+func runSubcommand(args []string) int {
+	return subcommands[args[0]](args[1:])
+}
+
+// commandOutput writes the result of a subcommand, as text or as JSON
+type commandOutput struct {
+	json bool
+}
+
+// print writes the result of a command
+func (out commandOutput) print(result interface{}, text string) {
+	if out.json {
+		json.NewEncoder(os.Stdout).Encode(result)
+	} else if text != "" {
+		fmt.Println(text)
+	}
+}
+
+// fail writes an error and returns the exit code
+func (out commandOutput) fail(code int, err error) int {
+	if out.json {
+		json.NewEncoder(os.Stdout).Encode(map[string]interface{}{"error": err.Error(), "code": code})
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return code
+}
+
+// commandFlags returns the flags shared by all the subcommands
+func commandFlags(name string) (*flag.FlagSet, *bool, *int) {
+	flags := flag.NewFlagSet("sasayaki "+name, flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "write the result as JSON")
+	passphraseFd := flags.Int("passphrase-fd", -1, "read the passphrase from this file descriptor instead of the terminal")
+	flags.Var(profileFlag{}, "profile", "the profile to use (see profile.go)")
+	return flags, jsonOutput, passphraseFd
+}
+
+// readPassphrase reads the passphrase from a file descriptor (up to the first newline), or from the terminal
+func readPassphrase(fd int) (string, error) {
+	if fd >= 0 {
+		line, err := bufio.NewReader(os.NewFile(uintptr(fd), "passphrase")).ReadString('\n')
+		if err != nil && line == "" {
+			return "", errors.New("ssyk: couldn't read the passphrase from the file descriptor")
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("ssyk: no passphrase, use --passphrase-fd")
+	}
+	fmt.Fprintln(os.Stderr, "Please enter your passphrase:")
+	passphrase, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", err
+	}
+	return string(passphrase), nil
+}
+
+// unlock gets our keypair from the agent (see agent.go) or decrypts it, and initializes the core if needHub
+// is true. It returns an exit code on error
+func unlock(passphraseFd int, needHub bool) (*sasayakiState, int, error) {
+	// the commands don't create a new key
+	if _, err := os.Stat(keyPairLocation()); os.IsNotExist(err) {
+		return nil, exitNotConfigured, errors.New("ssyk: no key yet, run sasayaki -cli first")
+	}
+	config, keyPair, err := initSasayakiFromAgent()
+	if err != nil {
+		passphrase, err := readPassphrase(passphraseFd)
+		if err != nil {
+			return nil, exitLocked, err
+		}
+		config, keyPair, err = initSasayaki(passphrase)
+		if err != nil {
+			return nil, exitLocked, err
+		}
+	}
+	ssyk.keyPair = keyPair
+	if !needHub {
+		myIdentity = config.Identity
+		return &sasayakiState{myAddress: keyPair.ExportPublicKey()}, exitOK, nil
+	}
+	if config.HubAddress == "" || config.HubPublicKey == "" {
+		return nil, exitNotConfigured, errors.New("ssyk: no Hub configured yet, run sasayaki -cli first")
+	}
+	ss, err := initSasayakiState(keyPair, config)
+	if err != nil {
+		return nil, exitNotConfigured, err
+	}
+	return ss, exitOK, nil
+}
+
+// sasayaki whoami
+func whoamiCommand(args []string) int {
+	flags, jsonOutput, passphraseFd := commandFlags("whoami")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	out := commandOutput{*jsonOutput}
+	if flags.NArg() != 0 {
+		return out.fail(exitUsage, errors.New("usage: sasayaki whoami"))
+	}
+	ss, code, err := unlock(*passphraseFd, false)
+	if err != nil {
+		return out.fail(code, err)
+	}
+	out.print(map[string]string{"address": ss.myAddress, "identity": ss.identity()}, ss.myAddress)
+	return exitOK
+}
+
+// sasayaki send --to <address> (--convo <id> | --title <title>) <message>
+func sendCommand(args []string) int {
+	flags, jsonOutput, passphraseFd := commandFlags("send")
+	to := flags.String("to", "", "the address of the contact")
+	convoId := flags.String("convo", "", "the conversation")
+	title := flags.String("title", "", "start a new conversation with this title instead")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	out := commandOutput{*jsonOutput}
+	if flags.NArg() != 1 || *to == "" || (*convoId == "") == (*title == "") {
+		return out.fail(exitUsage, errors.New("usage: sasayaki send --to <address> (--convo <id> | --title <title>) <message or ->"))
+	}
+	content := flags.Arg(0)
+	if content == "-" {
+		stdin, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return out.fail(exitFailure, err)
+		}
+		content = strings.TrimRight(string(stdin), "\n")
+	}
+	ss, code, err := unlock(*passphraseFd, true)
+	if err != nil {
+		return out.fail(code, err)
+	}
+	// a new conversation is created by sending its title
+	if *convoId == "" {
+		*convoId, err = ss.sendMessage(&plaintextMsg{FromAddress: ss.myAddress, ToAddress: *to, Type: textMsg, Content: *title})
+		if err != nil {
+			return out.fail(exitFailure, err)
+		}
+	}
+	if _, err := ss.sendMessage(&plaintextMsg{
+		ConvoId:     *convoId,
+		FromAddress: ss.myAddress,
+		ToAddress:   *to,
+		Type:        textMsg,
+		Content:     content,
+	}); err != nil {
+		return out.fail(exitFailure, err)
+	}
+	out.print(map[string]string{"convo_id": *convoId}, *convoId)
+	return exitOK
+}
+
+// sasayaki fetch
+func fetchCommand(args []string) int {
+	flags, jsonOutput, passphraseFd := commandFlags("fetch")
+	max := flags.Int("max", maxFetchedMessages, "the maximum number of messages to fetch")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	out := commandOutput{*jsonOutput}
+	if flags.NArg() != 0 {
+		return out.fail(exitUsage, errors.New("usage: sasayaki fetch [--max <n>]"))
+	}
+	ss, code, err := unlock(*passphraseFd, true)
+	if err != nil {
+		return out.fail(code, err)
+	}
+	messages := []*plaintextMsg{}
+	text := []string{}
+	for len(messages) < *max {
+		msg, err := ss.getNextMessage()
+		if err == errNoNewMessages {
+			break
+		} else if err != nil {
+			if len(messages) == 0 {
+				return out.fail(exitFailure, err)
+			}
+			// the messages we already fetched are not on the Hub anymore, they must not be lost
+			out.print(map[string]interface{}{"messages": messages, "error": err.Error(), "code": exitPartial}, strings.Join(text, "\n"))
+			if !out.json {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			return exitPartial
+		}
+		// handshake messages update our contacts, they are not returned
+		if msg == nil {
+			continue
+		}
+		messages = append(messages, msg)
+		text = append(text, fmt.Sprintf("[%s] %s: %s", msg.ConvoId, msg.FromAddress, msg.Content))
+	}
+	out.print(messages, strings.Join(text, "\n"))
+	return exitOK
+}
+
+// sasayaki contacts (list | add <address> <name> | accept <address> <name>)
+func contactsCommand(args []string) int {
+	if len(args) == 0 {
+		return commandOutput{}.fail(exitUsage, errors.New("usage: sasayaki contacts (list | add <address> <name> | accept <address> <name>)"))
+	}
+	action := args[0]
+	flags, jsonOutput, passphraseFd := commandFlags("contacts " + action)
+	hubName := flags.String("hub", "", "with add, the name of the partner Hub the contact is on (see hubpool.go)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	out := commandOutput{*jsonOutput}
+	switch {
+	case action == "list" && flags.NArg() == 0:
+	case (action == "add" || action == "accept") && flags.NArg() == 2:
+	default:
+		return out.fail(exitUsage, errors.New("usage: sasayaki contacts (list | add <address> <name> | accept <address> <name>)"))
+	}
+	ss, code, err := unlock(*passphraseFd, true)
+	if err != nil {
+		return out.fail(code, err)
+	}
+	switch action {
+	case "list":
+		contacts := ss.getContacts()
+		text := []string{}
+		for _, contact := range contacts {
+			line := fmt.Sprintf("%s %s (%s)", contact.Address, contact.Name, contact.State)
+			if contact.Hub != "" {
+				line += " on hub " + contact.Hub
+			}
+			text = append(text, line)
+		}
+		out.print(contacts, strings.Join(text, "\n"))
+		return exitOK
+	case "add":
+		err = ss.aliceAddContactOnHub(flags.Arg(0), flags.Arg(1), *hubName)
+	case "accept":
+		err = ss.bobAcceptContact(flags.Arg(0), flags.Arg(1))
+	}
+	if err != nil {
+		return out.fail(exitFailure, err)
+	}
+	out.print(map[string]string{"success": "true"}, "")
+	return exitOK
+}
+
+// sasayaki device (link | approve <code> <name> | list | remove <address>)
+func deviceCommand(args []string) int {
+	usage := errors.New("usage: sasayaki device (link | approve <code> <name> | list | remove <address>)")
+	if len(args) == 0 {
+		return commandOutput{}.fail(exitUsage, usage)
+	}
+	action := args[0]
+	flags, jsonOutput, passphraseFd := commandFlags("device " + action)
+	timeout := flags.Duration("timeout", deviceLinkTimeout, "how long to wait for the other device")
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	out := commandOutput{*jsonOutput}
+	switch {
+	case (action == "link" || action == "list") && flags.NArg() == 0:
+	case action == "approve" && flags.NArg() == 2:
+	case action == "remove" && flags.NArg() == 1:
+	default:
+		return out.fail(exitUsage, usage)
+	}
+	ss, code, err := unlock(*passphraseFd, true)
+	if err != nil {
+		return out.fail(code, err)
+	}
+	switch action {
+	case "link":
+		linkCode, err := ss.startDeviceLink()
+		if err != nil {
+			return out.fail(exitFailure, err)
+		}
+		fmt.Fprintln(os.Stderr, "Enter this code on your first device with `sasayaki device approve <code> <name>`:")
+		fmt.Fprintln(os.Stderr, linkCode)
+		if err := waitForDevice(ss, *timeout, ss.isLinked); err != nil {
+			return out.fail(exitFailure, err)
+		}
+		out.print(map[string]string{"identity": ss.identity()}, "this device is now linked to "+ss.identity())
+	case "approve":
+		device, err := ss.approveDevice(flags.Arg(0), flags.Arg(1))
+		if err != nil {
+			return out.fail(exitFailure, err)
+		}
+		done := func() bool { return !ss.isApprovalPending(device) }
+		if err := waitForDevice(ss, *timeout, done); err != nil {
+			return out.fail(exitFailure, err)
+		}
+		out.print(map[string]string{"device": device}, "device "+device+" is now linked")
+	case "list":
+		devices := ss.getMyDevices()
+		text := []string{}
+		for _, device := range devices {
+			text = append(text, fmt.Sprintf("%s %s", device.Address, device.Name))
+		}
+		out.print(devices, strings.Join(text, "\n"))
+	case "remove":
+		if err := ss.removeDevice(flags.Arg(0)); err != nil {
+			return out.fail(exitFailure, err)
+		}
+		out.print(map[string]string{"success": "true"}, "")
+	}
+	return exitOK
+}
+
+// waitForDevice fetches our messages until done returns true, so that the handshake with the other device
+// can go through
+func waitForDevice(ss *sasayakiState, timeout time.Duration, done func() bool) error {
+	deadline := time.Now().Add(timeout)
+	for !done() {
+		if time.Now().After(deadline) {
+			return errors.New("ssyk: the other device didn't answer in time")
+		}
+		_, err := ss.getNextMessage()
+		if err == errNoNewMessages {
+			time.Sleep(time.Second)
+		} else if err != nil {
+			log.Println(err)
+			time.Sleep(time.Second)
+		}
+	}
+	return nil
+}
@@ -21,9 +21,12 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 
+	"github.com/golang/protobuf/proto"
 	s "github.com/mimoo/sasayaki/serialization"
 
 	"github.com/mimoo/StrobeGo/strobe"
@@ -65,7 +68,7 @@ func (e2e encryptionState) encryptMessage(strobeState []byte, msg *plaintextMsg)
 	if err != nil {
 		return nil, nil, err
 	}
-	bobPubKey, err := hex.DecodeString(msg.ToAddress)
+	bobPubKey, err := decodeAddress(msg.ToAddress)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -73,9 +76,14 @@ func (e2e encryptionState) encryptMessage(strobeState []byte, msg *plaintextMsg)
 	copy(toAuthenticate[0:16], convoId)
 	copy(toAuthenticate[16:16+32], e2e.keyPair.PublicKey[:])
 	copy(toAuthenticate[16+32:16+32+32], bobPubKey)
+	// serialize the message
+	serializedPayload, err := serializePayload(msg)
+	if err != nil {
+		return nil, nil, err
+	}
 	// TODO: use disco.symmetric encrypt with nonce-based (so no need to update the state after)
 	// encrypt message
-	ciphertext := s1.Send_AEAD([]byte(msg.Content), toAuthenticate)
+	ciphertext := s1.Send_AEAD(serializedPayload, toAuthenticate)
 	// create return value
 	encryptedMessage := &s.Request_Message{
 		ToAddress: msg.ToAddress,
@@ -98,7 +106,7 @@ func (e2e encryptionState) decryptMessage(strobeState []byte, encryptedMsg *s.Re
 	if err != nil {
 		return nil, nil, err
 	}
-	bobPubKey, err := hex.DecodeString(encryptedMsg.GetFromAddress())
+	bobPubKey, err := decodeAddress(encryptedMsg.GetFromAddress())
 	if err != nil {
 		return nil, nil, err
 	}
@@ -113,17 +121,108 @@ func (e2e encryptionState) decryptMessage(strobeState []byte, encryptedMsg *s.Re
 		return nil, nil, errors.New("ssyk: impossible to decrypt incoming message")
 		// TODO: this should completely kill the thread
 	}
-	// return plaintext
-	msg := &plaintextMsg{
-		ConvoId:     encryptedMsg.GetConvoId(),
-		FromAddress: encryptedMsg.GetFromAddress(),
-		ToAddress:   e2e.keyPair.ExportPublicKey(),
-		Content:     string(plaintext),
+	// parse the payload
+	msg, err := parsePayload(plaintext)
+	if err != nil {
+		return nil, nil, err
 	}
+	msg.ConvoId = encryptedMsg.GetConvoId()
+	msg.FromAddress = encryptedMsg.GetFromAddress()
+	msg.ToAddress = e2e.keyPair.ExportPublicKey()
 	//
 	return msg, s2.Serialize(), nil
 }
 
+// serializePayload serializes what needs to be encrypted in a message
+func serializePayload(msg *plaintextMsg) ([]byte, error) {
+	payload := &s.Payload{
+		PayloadType: s.Payload_PayloadType(msg.Type),
+		Id:          msg.Id,
+		Content:     msg.Content,
+		Reference:   msg.Reference,
+		TreeHead:    msg.TreeHead,
+		KeyRotation: msg.Rotation,
+		DeviceLink:  msg.DeviceLink,
+		Peer:        msg.Peer,
+	}
+	if msg.Attachment != nil {
+		payload.Attachment = &s.Payload_File{
+			Name:  msg.Attachment.Name,
+			Size:  msg.Attachment.Size,
+			Key:   msg.Attachment.Key,
+			Hash:  msg.Attachment.Hash,
+			Blobs: msg.Attachment.Blobs,
+		}
+	}
+	if msg.Group != nil {
+		payload.Group = &s.Payload_Group{
+			Id:         msg.Group.Id,
+			Title:      msg.Group.Title,
+			Members:    msg.Group.Members,
+			Member:     msg.Group.Member,
+			SenderKey:  msg.Group.SenderKey,
+			Generation: msg.Group.Generation,
+			Channel:    msg.Group.Channel,
+		}
+	}
+	if msg.Issue != nil {
+		payload.Issue = &s.Payload_Issue{
+			Id:        msg.Issue.Id,
+			State:     msg.Issue.State,
+			Labels:    msg.Issue.Labels,
+			Assignees: msg.Issue.Assignees,
+		}
+	}
+	return proto.Marshal(payload)
+}
+
+// parsePayload parses a decrypted payload, addresses and convoId are left for the caller to fill
+func parsePayload(plaintext []byte) (*plaintextMsg, error) {
+	payload := &s.Payload{}
+	if err := proto.Unmarshal(plaintext, payload); err != nil {
+		return nil, errors.New("ssyk: message received is incorrectly formed")
+	}
+	msg := &plaintextMsg{
+		Id:         payload.GetId(),
+		Type:       msgType(payload.GetPayloadType()),
+		Reference:  payload.GetReference(),
+		Content:    payload.GetContent(),
+		TreeHead:   payload.GetTreeHead(),
+		Rotation:   payload.GetKeyRotation(),
+		DeviceLink: payload.GetDeviceLink(),
+		Peer:       payload.GetPeer(),
+	}
+	if att := payload.GetAttachment(); att != nil {
+		msg.Attachment = &attachment{
+			Name:  att.GetName(),
+			Size:  att.GetSize(),
+			Key:   att.GetKey(),
+			Hash:  att.GetHash(),
+			Blobs: att.GetBlobs(),
+		}
+	}
+	if group := payload.GetGroup(); group != nil {
+		msg.Group = &groupControl{
+			Id:         group.GetId(),
+			Title:      group.GetTitle(),
+			Members:    group.GetMembers(),
+			Member:     group.GetMember(),
+			SenderKey:  group.GetSenderKey(),
+			Generation: group.GetGeneration(),
+			Channel:    group.GetChannel(),
+		}
+	}
+	if issue := payload.GetIssue(); issue != nil {
+		msg.Issue = &issueInfo{
+			Id:        issue.GetId(),
+			State:     issue.GetState(),
+			Labels:    issue.GetLabels(),
+			Assignees: issue.GetAssignees(),
+		}
+	}
+	return msg, nil
+}
+
 // createNewConvo returns the new threadState (after ratcheting) and the two session keys created for the thread
 func (e2e encryptionState) createNewConvo(threadState []byte, msg *plaintextMsg) ([]byte, []byte, []byte) {
 	// recover state
@@ -167,6 +266,162 @@ func (e2e encryptionState) createConvoFromMessage(threadState []byte) ([]byte, [
 	return ts.Serialize(), s1.Serialize(), s2.Serialize()
 }
 
+// migrateConvo derives new session keys for an existing conversation from a thread state, without ratcheting it.
+// This is used to move a conversation to the new key of a contact (see rotation.go)
+func (e2e encryptionState) migrateConvo(threadState []byte, convoId string) ([]byte, []byte) {
+	// recover state
+	ts := strobe.RecoverState(threadState)
+	ts.AD(true, []byte("migratedThread"))
+	ts.AD(true, []byte(convoId))
+
+	// create the session keys for the convo (following disco spec)
+	s1 := ts.Clone()
+	s2 := ts.Clone()
+
+	s1.AD(true, []byte("initiatorThread"))
+	s1.RATCHET(32)
+
+	s2.AD(true, []byte("responderThread"))
+	s2.RATCHET(32)
+
+	//
+	return s1.Serialize(), s2.Serialize()
+}
+
+//
+// Group Messaging
+// ===============
+//
+// Each member of a group has its own sender key (a strobe state) that it uses to encrypt its messages to the group.
+// Sender keys are distributed to the other members in their pairwise conversations, and are rotated
+// (with an incremented generation) every time a member is removed from the group.
+//
+
+// newSenderKey creates a fresh sender key for us in a group
+func (e2e encryptionState) newSenderKey(groupId string, generation uint32) []byte {
+	// generate a fresh key
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	// bind it to the group, the sender and the generation
+	senderKey := strobe.InitStrobe("SasayakiSenderKey", 128)
+	senderKey.AD(false, []byte(groupId))
+	senderKey.AD(false, e2e.keyPair.PublicKey[:])
+	senderKey.AD(false, []byte{byte(generation >> 24), byte(generation >> 16), byte(generation >> 8), byte(generation)})
+	senderKey.KEY(key)
+	//
+	return senderKey.Serialize()
+}
+
+// encryptGroupMessage encrypts a message once for all the members of a group with our sender key.
+// The content is [generation(4), ciphertext(...)]
+func (e2e encryptionState) encryptGroupMessage(senderKey []byte, generation uint32, msg *plaintextMsg) ([]byte, []byte, error) {
+	// check for arbitrary 1000 bytes of room for headers and protobuff structure
+	if len(msg.Content) > 65535-1000 {
+		return nil, nil, errors.New("ssyk: message to send is too large")
+	}
+	// data to authenticate [groupId(16), sendPubKey(32), generation(4)]
+	groupId, err := hex.DecodeString(msg.ConvoId)
+	if err != nil || len(groupId) != 16 {
+		return nil, nil, errors.New("ssyk: group id is malformed")
+	}
+	header := []byte{byte(generation >> 24), byte(generation >> 16), byte(generation >> 8), byte(generation)}
+	toAuthenticate := make([]byte, 16+32+4)
+	copy(toAuthenticate[0:16], groupId)
+	copy(toAuthenticate[16:16+32], e2e.keyPair.PublicKey[:])
+	copy(toAuthenticate[16+32:], header)
+	// serialize the message
+	serializedPayload, err := serializePayload(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	// encrypt
+	s1 := strobe.RecoverState(senderKey)
+	ciphertext := s1.Send_AEAD(serializedPayload, toAuthenticate)
+	//
+	return append(header, ciphertext...), s1.Serialize(), nil
+}
+
+// decryptGroupMessage decrypts a group message with the sender key of the member who sent it
+func (e2e encryptionState) decryptGroupMessage(senderKey []byte, generation uint32, encryptedMsg *s.ResponseMessage) (*plaintextMsg, []byte, error) {
+	// check
+	content := encryptedMsg.GetContent()
+	if len(content) < 4 {
+		return nil, nil, errors.New("ssyk: message received is incorrectly formed")
+	}
+	header := content[:4]
+	if uint32(header[0])<<24|uint32(header[1])<<16|uint32(header[2])<<8|uint32(header[3]) != generation {
+		return nil, nil, errors.New("ssyk: group message encrypted with an unknown sender key")
+	}
+	// data to authenticate [groupId(16), sendPubKey(32), generation(4)]
+	groupId, err := hex.DecodeString(encryptedMsg.GetConvoId())
+	if err != nil || len(groupId) != 16 {
+		return nil, nil, errors.New("ssyk: group id is malformed")
+	}
+	senderPubKey, err := decodeAddress(encryptedMsg.GetFromAddress())
+	if err != nil {
+		return nil, nil, errors.New("ssyk: sender address is malformed")
+	}
+	toAuthenticate := make([]byte, 16+32+4)
+	copy(toAuthenticate[0:16], groupId)
+	copy(toAuthenticate[16:16+32], senderPubKey)
+	copy(toAuthenticate[16+32:], header)
+	// decrypt
+	s2 := strobe.RecoverState(senderKey)
+	plaintext, ok := s2.Recv_AEAD(content[4:], toAuthenticate)
+	if !ok {
+		return nil, nil, errors.New("ssyk: impossible to decrypt incoming group message")
+	}
+	// parse the payload
+	msg, err := parsePayload(plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg.ConvoId = encryptedMsg.GetConvoId()
+	msg.FromAddress = encryptedMsg.GetFromAddress()
+	msg.ToAddress = e2e.keyPair.ExportPublicKey()
+	//
+	return msg, s2.Serialize(), nil
+}
+
+//
+// Attachments
+// ===========
+//
+// Files are encrypted under a fresh random key, which is then sent in a message.
+// The hash of the file lets the receiver verify what it downloaded from the Hub.
+//
+
+// encryptAttachment returns the random key used to encrypt the file, the hash of the file, and the ciphertext
+func (e2e encryptionState) encryptAttachment(file []byte) ([]byte, []byte, []byte) {
+	// generate a fresh key
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	// hash + encrypt
+	hash := disco.Hash(file, 32)
+	ciphertext := disco.Encrypt(key, file)
+	//
+	return key, hash, ciphertext
+}
+
+// decryptAttachment decrypts an attachment and verifies it against the hash received in the message
+func (e2e encryptionState) decryptAttachment(key, hash, ciphertext []byte) ([]byte, error) {
+	// decrypt
+	file, err := disco.Decrypt(key, ciphertext)
+	if err != nil {
+		return nil, errors.New("ssyk: impossible to decrypt attachment")
+	}
+	// verify
+	if subtle.ConstantTimeCompare(disco.Hash(file, 32), hash) != 1 {
+		return nil, errors.New("ssyk: attachment does not match its hash")
+	}
+	//
+	return file, nil
+}
+
 //
 // Contact Management
 // ==================
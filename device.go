@@ -0,0 +1,575 @@
+//
+// Devices
+// =======
+//
+// Someone can use Sasayaki on several devices. Each device has its own Disco key, and the key of their first
+// device (their identity) certifies the others (see serialization/device.go). The certificates are published
+// to the Hub, so that the contacts of the identity find its devices.
+//
+// Every pair of devices has its own contact (IK handshake) and its own session keys: a conversation keeps its
+// convoId, but there is one row per device in the conversations table. When we send a message, we also send a
+// copy to each device of the contact and to each of our other devices (with the contact as `peer`, so that they
+// know who the conversation is with). Messages received from a device are attributed to its identity.
+//
+// The devices of our contacts (and ours) are fetched from the Hub every few minutes. We start the handshake
+// with a new device if our key is the smallest of the two, and accept the contact requests of the devices
+// certified by our contacts (or by us) without asking the user.
+//
+// A device key countersigns its certificate: otherwise a contact could claim the key of another contact as one
+// of its devices, receive a copy of what we send to the other contact, and have its messages attributed to it.
+// We also refuse the certificates naming the key of one of our contacts, or of an identity we know, as a device
+// of someone else.
+//
+// Linking a new device: the new device (which has its own fresh key) shows a one-time code, made of its key and
+// of a random secret. The code is entered on our first device, which certifies the new key, publishes this
+// request (the Hub only shows it to the new device) and sends a contact request to the new device. The new
+// device accepts it, since the request is signed by the requester. Our first device then sends a DeviceLink in a
+// new conversation: the certificate, the secret of the code (the new device only accepts the link of whoever saw
+// the code) and our contacts. The new device countersigns the certificate and publishes it.
+//
+// TODO: contacts added after the link are not synchronized between our devices, nor are groups
+// TODO: an identity that changes its key (see rotation.go) has to link its devices again
+//
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+const (
+	devicesRefreshInterval = 5 * time.Minute
+	deviceLinkTimeout      = 10 * time.Minute
+	deviceLinkSecretSize   = 16
+	deviceLinkPrefix       = "ssyk-link:"
+)
+
+// deviceLinkCode is the one-time code we displayed, while we wait to be linked
+type deviceLinkCode struct {
+	secret    []byte
+	expiresAt time.Time
+}
+
+// deviceApproval is a device we have certified, that hasn't received its DeviceLink yet
+type deviceApproval struct {
+	secret      []byte
+	certificate []byte
+}
+
+var (
+	myIdentity       string                        // the key of our first device, if we are a linked device (protected by storage.queryMutex)
+	devicesFetchedAt time.Time                     // the last time we fetched the devices of our contacts (protected by storage.queryMutex)
+	pendingLink      *deviceLinkCode               // protected by storage.queryMutex
+	pendingApprovals = map[string]deviceApproval{} // device -> approval (protected by storage.queryMutex)
+)
+
+// identity returns our identity key: the key of our first device
+func (ss sasayakiState) identity() string {
+	if myIdentity != "" {
+		return myIdentity
+	}
+	return ss.myAddress
+}
+
+// identityOf returns the identity a key belongs to (the key itself if it is not a device we know).
+// storage.queryMutex must be held
+func (ss sasayakiState) identityOf(address string) string {
+	if identity, ok := storage.getDeviceIdentity(address); ok {
+		return identity
+	}
+	return address
+}
+
+// isOurs returns true if a key is ours, or one of our other devices. storage.queryMutex must be held
+func (ss sasayakiState) isOurs(address string) bool {
+	return address == ss.myAddress || ss.identityOf(address) == ss.identity()
+}
+
+// knownIdentities returns the identities whose devices we talk to, with their names: our contacts and us.
+// storage.queryMutex must be held
+func (ss sasayakiState) knownIdentities() map[string]string {
+	identities := map[string]string{ss.identity(): "me"}
+	for _, contact := range storage.getIdentityContacts() {
+		identities[contact.Address] = contact.Name
+	}
+	for _, contact := range storage.getContacts() {
+		if contact.State != "added" || ss.isOurs(contact.Address) {
+			continue
+		}
+		if _, isDevice := storage.getDeviceIdentity(contact.Address); !isDevice {
+			identities[contact.Address] = contact.Name
+		}
+	}
+	return identities
+}
+
+// refreshDevices fetches the devices of our contacts and ours, if we haven't recently, and starts or accepts
+// the handshakes with the new ones. storage.queryMutex must be held
+func (ss sasayakiState) refreshDevices() {
+	if time.Since(devicesFetchedAt) < devicesRefreshInterval {
+		return
+	}
+	identities := ss.knownIdentities()
+	keys := []string{ss.myAddress}
+	for identity := range identities {
+		keys = append(keys, identity)
+	}
+	// the devices of each identity are published on its Hub. A Hub that fails doesn't prevent the others from
+	// updating their identities, the devices we know on it are kept until the next refresh
+	var serialized [][]byte
+	unreachable := map[string]bool{}
+	for current, identityKeys := range hubs.group(keys) {
+		certificates, err := current.getDevices(identityKeys)
+		if err != nil {
+			log.Println("couldn't fetch the devices of our contacts from", current.hubAddress+":", err)
+			for _, key := range identityKeys {
+				unreachable[key] = true
+			}
+			continue
+		}
+		serialized = append(serialized, certificates...)
+	}
+	devicesFetchedAt = time.Now()
+	// verify the certificates, and group them by identity
+	devices := map[string][]deviceInfo{}
+	certificates := map[string][][]byte{}
+	linkedBy := "" // the identity certifying our key, while we wait to be linked
+	for _, certificate := range serialized {
+		device, err := parseDeviceCertificate(certificate)
+		if err != nil {
+			// the request of the identity linking us isn't countersigned yet
+			if request, requestErr := parseDeviceRequest(certificate); requestErr == nil && request.Address == ss.myAddress {
+				linkedBy = request.Identity
+				continue
+			}
+			log.Println("hub sent an invalid device certificate:", err)
+			continue
+		}
+		if device.Address == ss.myAddress {
+			linkedBy = device.Identity
+			continue
+		}
+		if ss.isClaimedKey(device, identities) {
+			log.Println("hub sent a device certificate for the key of one of our contacts:", device.Address)
+			continue
+		}
+		if _, ok := identities[device.Identity]; ok {
+			devices[device.Identity] = append(devices[device.Identity], device)
+			certificates[device.Identity] = append(certificates[device.Identity], certificate)
+		}
+	}
+	for identity := range identities {
+		if !unreachable[identity] {
+			storage.replaceDevices(identity, devices[identity], certificates[identity])
+		}
+	}
+	// start the handshakes with the new devices, and accept their contact requests
+	for identity, name := range identities {
+		if unreachable[identity] {
+			continue
+		}
+		targets := map[string]string{identity: name}
+		for _, device := range devices[identity] {
+			targets[device.Address] = name
+			if identity == ss.identity() {
+				targets[device.Address] = "me (" + device.Name + ")"
+			}
+		}
+		for target, name := range targets {
+			if target == ss.myAddress {
+				continue
+			}
+			var err error
+			switch _, status := storage.getStateContact(target); status {
+			case noContact:
+				if ss.myAddress < target {
+					err = ss.startDeviceHandshake(target, name)
+				}
+			case waitingToAccept:
+				err = ss.acceptContactRequest(target, name)
+			}
+			if err != nil {
+				log.Println("couldn't start a session with a device:", err)
+			}
+		}
+	}
+	// the device linking us is the only one we accept while we wait to be linked
+	if pendingLink != nil && linkedBy != "" {
+		if _, status := storage.getStateContact(linkedBy); status == waitingToAccept {
+			if err := ss.acceptContactRequest(linkedBy, "me"); err != nil {
+				log.Println("couldn't accept the contact request of our first device:", err)
+			}
+		}
+	}
+}
+
+// isClaimedKey returns true if a device certificate names one of the identities we know, or one of our
+// contacts that isn't already a device of this identity. storage.queryMutex must be held
+func (ss sasayakiState) isClaimedKey(device deviceInfo, identities map[string]string) bool {
+	if _, ok := identities[device.Address]; ok {
+		return true
+	}
+	switch _, status := storage.getStateContact(device.Address); status {
+	case noContact, waitingToAccept:
+		return false
+	}
+	identity, ok := storage.getDeviceIdentity(device.Address)
+	return !ok || identity != device.Identity
+}
+
+// parseDeviceCertificate verifies a serialized DeviceCertificate, countersigned by the device
+func parseDeviceCertificate(serialized []byte) (deviceInfo, error) {
+	return parseDevice(serialized, s.VerifyDeviceCertificate)
+}
+
+// parseDeviceRequest verifies a serialized DeviceCertificate that the device hasn't countersigned yet
+func parseDeviceRequest(serialized []byte) (deviceInfo, error) {
+	return parseDevice(serialized, s.VerifyDeviceRequest)
+}
+
+// parseDevice parses a serialized DeviceCertificate, and checks its signatures with verify
+func parseDevice(serialized []byte, verify func(*s.DeviceCertificate) error) (deviceInfo, error) {
+	certificate := &s.DeviceCertificate{}
+	if err := proto.Unmarshal(serialized, certificate); err != nil {
+		return deviceInfo{}, errors.New("ssyk: device certificate is malformed")
+	}
+	if err := verify(certificate); err != nil {
+		return deviceInfo{}, err
+	}
+	return deviceInfo{
+		Identity: hex.EncodeToString(certificate.GetIdentityKey()),
+		Address:  hex.EncodeToString(certificate.GetDeviceKey()),
+		Name:     certificate.GetName(),
+		Date:     certificate.GetDate(),
+	}, nil
+}
+
+// startDeviceHandshake sends a contact request to a device. storage.queryMutex must be held
+func (ss sasayakiState) startDeviceHandshake(device, name string) error {
+	devicePubKey, err := hex.DecodeString(device)
+	if err != nil || len(devicePubKey) != 32 {
+		return errors.New("ssyk: device's address is malformed")
+	}
+	bob := &disco.KeyPair{}
+	copy(bob.PublicKey[:], devicePubKey)
+	firstHandshakeMessage, serializedHandshakeState, err := e2e.addContact(bob, name)
+	if err != nil {
+		return err
+	}
+	storage.addContact(device, name, serializedHandshakeState)
+	return hubs.sendMessage(&s.Request_Message{
+		ToAddress: device,
+		ConvoId:   newRandomId(),
+		Content:   firstHandshakeMessage,
+	})
+}
+
+//
+// Sending to every device
+//
+
+// fanOut sends a copy of a message we've just sent to the other devices of the contact, and to our
+// other devices. For a new conversation, it only creates the conversation with each device.
+// storage.queryMutex must be held
+func (ss sasayakiState) fanOut(msg *plaintextMsg, newConvo bool) {
+	// only what is displayed is copied
+	switch msg.Type {
+	case textMsg, editMsg, deleteMsg, attachmentMsg:
+	default:
+		return
+	}
+	peer := ss.identityOf(msg.ToAddress)
+	storage.setConvoPeer(msg.ConvoId, peer)
+	targets := map[string]bool{peer: true, ss.identity(): true}
+	for _, device := range append(storage.getDevices(peer), storage.getDevices(ss.identity())...) {
+		targets[device.Address] = true
+	}
+	for target := range targets {
+		if target == ss.myAddress || target == msg.ToAddress {
+			continue
+		}
+		if _, status := storage.getStateContact(target); status != contactAdded {
+			continue
+		}
+		deviceMsg := *msg
+		deviceMsg.ToAddress = target
+		if ss.isOurs(target) {
+			deviceMsg.Peer = peer
+		}
+		if err := ss.sendToDevice(&deviceMsg, newConvo); err != nil {
+			log.Println("couldn't send a message to a device:", err)
+		}
+	}
+}
+
+// sendToDevice sends a copy of a message to a device, in the same conversation. storage.queryMutex must be held
+func (ss sasayakiState) sendToDevice(msg *plaintextMsg, newConvo bool) error {
+	if !storage.hasSession(msg.ConvoId, msg.ToAddress) {
+		if err := ss.openDeviceConvo(msg.ConvoId, msg.ToAddress, msg.Peer); err != nil {
+			return err
+		}
+	}
+	if newConvo {
+		return nil
+	}
+	s1, _, err := storage.getSessionKeys(msg.ConvoId, msg.ToAddress)
+	if err != nil {
+		return err
+	}
+	encryptedMessage, strobeState, err := e2e.encryptMessage(s1, msg)
+	if err != nil {
+		return err
+	}
+	storage.updateSessionKeys(msg.ConvoId, msg.ToAddress, strobeState, nil)
+	return hubs.sendMessage(encryptedMessage)
+}
+
+// openDeviceConvo creates an existing conversation with a device, by sending it the title of the conversation.
+// storage.queryMutex must be held
+func (ss sasayakiState) openDeviceConvo(convoId, device, peer string) error {
+	t1, _, err := storage.getThreadRatchetStates(device)
+	if err != nil {
+		return err
+	}
+	title := &plaintextMsg{
+		ConvoId:     convoId,
+		Id:          newRandomId(),
+		FromAddress: ss.myAddress,
+		ToAddress:   device,
+		Type:        textMsg,
+		Content:     storage.getConvoTitle(convoId),
+		Peer:        peer,
+	}
+	threadState, s1, s2 := e2e.createNewConvo(t1, title)
+	storage.updateThreadRatchetStates(device, threadState, nil)
+	storage.createConvo(convoId, device, title.Content, s1, s2)
+	encryptedMessage, s1, err := e2e.encryptMessage(s1, title)
+	if err != nil {
+		return err
+	}
+	storage.updateSessionKeys(convoId, device, s1, nil)
+	return hubs.sendMessage(encryptedMessage)
+}
+
+// receivedFromDevice records who a new conversation is with, and attributes a message received from a
+// device to its identity (or to us, if it was sent by one of our other devices). storage.queryMutex must be held
+func (ss sasayakiState) receivedFromDevice(msg *plaintextMsg, newConvo bool) {
+	if ss.isOurs(msg.FromAddress) {
+		if newConvo && msg.Peer != "" {
+			storage.setConvoPeer(msg.ConvoId, msg.Peer)
+		}
+		msg.FromAddress = ss.myAddress
+		return
+	}
+	msg.FromAddress = ss.identityOf(msg.FromAddress)
+	if newConvo {
+		storage.setConvoPeer(msg.ConvoId, msg.FromAddress)
+	}
+}
+
+//
+// Linking a new device
+//
+
+// startDeviceLink returns the one-time code to enter on our first device
+func (ss sasayakiState) startDeviceLink() (string, error) {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	if myIdentity != "" {
+		return "", errors.New("ssyk: this device is already linked")
+	}
+	if len(storage.getContacts()) != 0 {
+		return "", errors.New("ssyk: only a new device, without contacts, can be linked")
+	}
+	secret := make([]byte, deviceLinkSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	pendingLink = &deviceLinkCode{secret: secret, expiresAt: time.Now().Add(deviceLinkTimeout)}
+	devicesFetchedAt = time.Time{}
+	return deviceLinkPrefix + ss.myAddress + ":" + hex.EncodeToString(secret), nil
+}
+
+// isLinked returns true once we have been linked to our first device
+func (ss sasayakiState) isLinked() bool {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	return myIdentity != ""
+}
+
+// approveDevice certifies the device that displayed a one-time code, and sends it a contact request
+func (ss sasayakiState) approveDevice(code, name string) (string, error) {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	if myIdentity != "" {
+		return "", errors.New("ssyk: only our first device can link new devices")
+	}
+	parts := strings.Split(strings.TrimSpace(code), ":")
+	if len(parts) != 3 || parts[0]+":" != deviceLinkPrefix {
+		return "", errors.New("ssyk: this is not a device link code")
+	}
+	deviceKey, err := hex.DecodeString(parts[1])
+	if err != nil || len(deviceKey) != 32 {
+		return "", errors.New("ssyk: the device link code is malformed")
+	}
+	secret, err := hex.DecodeString(parts[2])
+	if err != nil || len(secret) != deviceLinkSecretSize {
+		return "", errors.New("ssyk: the device link code is malformed")
+	}
+	device := parts[1]
+	if device == ss.myAddress {
+		return "", errors.New("ssyk: a device can't link itself")
+	}
+	if _, status := storage.getStateContact(device); status != noContact {
+		return "", errors.New("ssyk: this key is already one of our contacts")
+	}
+	if _, ok := ss.knownIdentities()[device]; ok {
+		return "", errors.New("ssyk: this key is the identity of one of our contacts")
+	}
+	// certify the device, it countersigns the certificate once linked
+	certificate := &s.DeviceCertificate{
+		IdentityKey: e2e.keyPair.PublicKey[:],
+		DeviceKey:   deviceKey,
+		Name:        name,
+		Date:        time.Now().Unix(),
+	}
+	s.SignDeviceCertificate(e2e.keyPair.PrivateKey, certificate)
+	serialized, err := proto.Marshal(certificate)
+	if err != nil {
+		panic(err)
+	}
+	if err := hubs.publish(func(current *hubState) error { return current.publishDevice(serialized) }); err != nil {
+		return "", err
+	}
+	storage.addDevice(deviceInfo{Identity: ss.myAddress, Address: device, Name: name, Date: certificate.Date}, serialized)
+	pendingApprovals[device] = deviceApproval{secret: secret, certificate: serialized}
+	return device, ss.startDeviceHandshake(device, "me ("+name+")")
+}
+
+// isApprovalPending returns true while a device we approved hasn't received its DeviceLink
+func (ss sasayakiState) isApprovalPending(device string) bool {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	_, ok := pendingApprovals[device]
+	return ok
+}
+
+// finishDeviceLink sends its DeviceLink to a device we approved, once it has accepted our contact request.
+// storage.queryMutex must be held
+func (ss sasayakiState) finishDeviceLink(device string) {
+	approval, ok := pendingApprovals[device]
+	if !ok {
+		return
+	}
+	link := &s.DeviceLink{
+		Certificate: approval.certificate,
+		Secret:      approval.secret,
+	}
+	for identity, name := range ss.knownIdentities() {
+		if identity != ss.myAddress {
+			link.Contacts = append(link.Contacts, &s.DeviceLink_Contact{Address: identity, Name: name})
+		}
+	}
+	serialized, err := proto.Marshal(link)
+	if err != nil {
+		panic(err)
+	}
+	_, err = ss.send(&plaintextMsg{
+		FromAddress: ss.myAddress,
+		ToAddress:   device,
+		Type:        deviceLinkMsg,
+		Content:     "linked devices",
+		DeviceLink:  serialized,
+	})
+	if err != nil {
+		log.Println("couldn't send its link to our new device:", err)
+		return
+	}
+	delete(pendingApprovals, device)
+}
+
+// handleDeviceLink makes us a device of the identity that sent us our DeviceLink, if it knows the secret of
+// the code we displayed. storage.queryMutex must be held
+func (ss sasayakiState) handleDeviceLink(msg *plaintextMsg) error {
+	if pendingLink == nil || time.Now().After(pendingLink.expiresAt) {
+		return errors.New("ssyk: received a device link we didn't ask for")
+	}
+	link := &s.DeviceLink{}
+	if err := proto.Unmarshal(msg.DeviceLink, link); err != nil {
+		return errors.New("ssyk: device link is malformed")
+	}
+	if subtle.ConstantTimeCompare(link.GetSecret(), pendingLink.secret) != 1 {
+		return errors.New("ssyk: device link doesn't match our code")
+	}
+	certificate := &s.DeviceCertificate{}
+	if err := proto.Unmarshal(link.GetCertificate(), certificate); err != nil {
+		return errors.New("ssyk: device link is malformed")
+	}
+	if err := s.VerifyDeviceRequest(certificate); err != nil {
+		return err
+	}
+	device := hex.EncodeToString(certificate.GetDeviceKey())
+	identity := hex.EncodeToString(certificate.GetIdentityKey())
+	if device != ss.myAddress || identity != msg.FromAddress {
+		return errors.New("ssyk: device link is for another device")
+	}
+	// we agree to be a device of this identity
+	s.CountersignDeviceCertificate(e2e.keyPair.PrivateKey, certificate)
+	serialized, err := proto.Marshal(certificate)
+	if err != nil {
+		panic(err)
+	}
+	if err := hubs.publish(func(current *hubState) error { return current.publishDevice(serialized) }); err != nil {
+		return err
+	}
+	config := initConfiguration()
+	config.Identity = identity
+	config.DeviceCertificate = hex.EncodeToString(serialized)
+	config.updateConfiguration()
+	myIdentity = identity
+	pendingLink = nil
+	for _, contact := range link.GetContacts() {
+		if len(contact.GetAddress()) == 64 && contact.GetAddress() != ss.myAddress {
+			storage.storeIdentityContact(contact.GetAddress(), contact.GetName())
+		}
+	}
+	devicesFetchedAt = time.Time{}
+	return nil
+}
+
+// getMyDevices returns our devices (other than this one)
+func (ss sasayakiState) getMyDevices() []deviceInfo {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	devicesFetchedAt = time.Time{}
+	ss.refreshDevices()
+	return storage.getDevices(ss.identity())
+}
+
+// removeDevice removes one of our devices: our contacts stop sending it our messages
+func (ss sasayakiState) removeDevice(device string) error {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	if myIdentity != "" {
+		return errors.New("ssyk: only our first device can remove devices")
+	}
+	if identity, ok := storage.getDeviceIdentity(device); !ok || identity != ss.myAddress {
+		return errors.New("ssyk: this is not one of our devices")
+	}
+	if err := hubs.publish(func(current *hubState) error { return current.removeDevice(device) }); err != nil {
+		return err
+	}
+	storage.deleteDevice(device)
+	delete(pendingApprovals, device)
+	return nil
+}
@@ -0,0 +1,531 @@
+//
+// Group Conversations
+// ===================
+//
+// A group is a conversation between more than two peers. Its messages are encrypted once, and sent to
+// every member with the `Group` kind and the group id as convo id. How the members agree on the keys
+// depends on the engine chosen when the group is created:
+//
+// * sender keys (this file): every member distributes its own key in pairwise conversations. Simple, but every removal makes every member send its new key to every other member.
+// * MLS (see mls.go): the members share a TreeKEM ratchet tree, and adding or removing members is a single commit encrypted once for the whole group. This is the engine to use for large groups.
+//
+// Sender Keys
+// -----------
+//
+// * every member has its own sender key (see crypto.go), used to encrypt its messages once for the whole group
+// * sender keys are distributed in pairwise conversations, created from the thread ratchets of each contact
+// * every time a member is removed, the remaining members rotate their sender keys
+//
+// The control messages, sent in the pairwise conversations, are:
+//
+// * GroupInvite: here is the group as I see it (title, members) and my sender key
+// * GroupSenderKey: I have rotated my sender key, here is the new one
+// * GroupAddMember: someone has been added, the receiver sends them an invite with its own sender key
+// * GroupRemoveMember: someone has been removed, the receiver rotates its sender key (or leaves if it is them)
+//
+// Note that the members of a sender keys group must have added each other as contacts.
+//
+package main
+
+import (
+	"errors"
+	"log"
+
+	s "github.com/mimoo/sasayaki/serialization"
+)
+
+const (
+	senderKeysEngine = "sender_keys"
+	mlsEngine        = "mls"
+)
+
+// groupEngine agrees on the keys of a group, and uses them to encrypt and decrypt its messages.
+// It is always called with the storage lock held.
+type groupEngine interface {
+	// create stores a new group and adds the members to it
+	create(groupId, title, channel string, members []string) error
+	// send encrypts a message once and sends it to all the members
+	send(msg *plaintextMsg) error
+	add(groupId, member string) error
+	// remove removes a member, or makes us leave the group if the member is us
+	remove(groupId, member string) error
+	// receive decrypts a message sent to the group
+	receive(encryptedMsg *s.ResponseMessage) (*plaintextMsg, error)
+}
+
+// newGroupEngine returns one of the group engines
+func (ss sasayakiState) newGroupEngine(engine string) (groupEngine, error) {
+	switch engine {
+	case senderKeysEngine:
+		return senderKeyGroups{ss}, nil
+	case mlsEngine:
+		return mlsGroups{ss}, nil
+	}
+	return nil, errors.New("ssyk: unknown group engine")
+}
+
+// engineForGroup returns the engine of one of our groups
+func (ss sasayakiState) engineForGroup(groupId string) (groupEngine, error) {
+	engine, err := storage.getGroupEngine(groupId)
+	if err != nil {
+		return nil, err
+	}
+	return ss.newGroupEngine(engine)
+}
+
+// createGroup creates a new group (a chat or an issue tracker channel) with one of the engines and adds the members,
+// it returns the id of the group
+func (ss sasayakiState) createGroup(title string, members []string, engineName, channel string) (string, error) {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	if title == "" || len(members) == 0 {
+		return "", errors.New("ssyk: a group needs a title and members")
+	}
+	for _, member := range members {
+		if member == ss.myAddress {
+			return "", errors.New("ssyk: we are already part of the group")
+		}
+	}
+	if channel != chatChannel && channel != issuesChannel {
+		return "", errors.New("ssyk: unknown type of channel")
+	}
+	engine, err := ss.newGroupEngine(engineName)
+	if err != nil {
+		return "", err
+	}
+	// create the group
+	groupId := newRandomId()
+	if err := engine.create(groupId, title, channel, members); err != nil {
+		return "", err
+	}
+	//
+	return groupId, nil
+}
+
+// sendGroupMessage encrypts a message and sends it to all the members of the group
+// msg.ConvoId must be the group id, and the message can be a text, an attachment, an edit or a deletion
+// (or a message about an issue in issue tracker channels). It returns the id of the message
+func (ss sasayakiState) sendGroupMessage(msg *plaintextMsg) (string, error) {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	return ss.sendToGroup(msg)
+}
+
+// sendToGroup is sendGroupMessage without the lock, so that it can be used by other functions of the core
+func (ss sasayakiState) sendToGroup(msg *plaintextMsg) (string, error) {
+	engine, err := ss.engineForGroup(msg.ConvoId)
+	if err != nil {
+		return "", err
+	}
+	msg.Id = newRandomId()
+	if err := ss.checkGroupMessage(msg); err != nil {
+		return "", err
+	}
+	// encrypt once for everyone
+	if err := engine.send(msg); err != nil {
+		return "", err
+	}
+	// store in database
+	ss.applyGroupMessage(msg)
+	//
+	return msg.Id, nil
+}
+
+// checkGroupMessage makes sure that a message sent to a group can be applied. Control messages are never
+// sent to the whole group, and the messages of issue tracker channels are about issues.
+func (ss sasayakiState) checkGroupMessage(msg *plaintextMsg) error {
+	if msg.Type.isGroupControl() {
+		return errors.New("ssyk: control messages cannot be sent to a group")
+	}
+	channel, err := storage.getGroupChannel(msg.ConvoId)
+	if err != nil {
+		return err
+	}
+	if channel == issuesChannel {
+		return ss.checkIssueMessage(msg)
+	}
+	if msg.Issue != nil || msg.Type == issueOpenMsg || msg.Type == issueUpdateMsg {
+		return errors.New("ssyk: this group is not an issue tracker")
+	}
+	return ss.checkReference(msg)
+}
+
+// applyGroupMessage stores a message sent to a group (or applies it).
+// the message must have been checked with checkGroupMessage first
+func (ss sasayakiState) applyGroupMessage(msg *plaintextMsg) {
+	if msg.Issue != nil {
+		ss.applyIssueMessage(msg)
+		return
+	}
+	ss.applyMessage(msg)
+}
+
+// addGroupMember adds someone to a group
+func (ss sasayakiState) addGroupMember(groupId, newMember string) error {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	engine, err := ss.engineForGroup(groupId)
+	if err != nil {
+		return err
+	}
+	if newMember == ss.myAddress {
+		return errors.New("ssyk: already a member of the group")
+	}
+	if err := ss.checkRevocation(newMember); err != nil {
+		return err
+	}
+	return engine.add(groupId, newMember)
+}
+
+// removeGroupMember removes a member from a group, if the member is us we leave the group.
+func (ss sasayakiState) removeGroupMember(groupId, oldMember string) error {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	engine, err := ss.engineForGroup(groupId)
+	if err != nil {
+		return err
+	}
+	return engine.remove(groupId, oldMember)
+}
+
+// handleGroupMessage handles the messages received for a group: welcomes and handshakes of MLS groups,
+// and messages encrypted to the group
+func (ss sasayakiState) handleGroupMessage(encryptedMsg *s.ResponseMessage) (*plaintextMsg, error) {
+	if len(encryptedMsg.GetConvoId()) != 32 {
+		return nil, errors.New("ssyk: message received malformed")
+	}
+	switch encryptedMsg.GetKind() {
+	case s.MessageKind_Welcome:
+		return nil, mlsGroups{ss}.receiveWelcome(encryptedMsg)
+	case s.MessageKind_Handshake:
+		return nil, mlsGroups{ss}.receiveHandshake(encryptedMsg)
+	}
+	// we must be in the group
+	engine, err := ss.engineForGroup(encryptedMsg.GetConvoId())
+	if err != nil {
+		return nil, err
+	}
+	// remove encryption
+	msg, err := engine.receive(encryptedMsg)
+	if err != nil {
+		return nil, err
+	}
+	// store message (or apply the edit/deletion)
+	if err := ss.checkGroupMessage(msg); err != nil {
+		return nil, err
+	}
+	ss.applyGroupMessage(msg)
+	//
+	return msg, nil
+}
+
+//
+// Sender Keys
+// ===========
+//
+
+// senderKeyGroups is the sender keys group engine
+type senderKeyGroups struct {
+	ss sasayakiState
+}
+
+// create creates the group with a fresh sender key, and invites all the members
+func (engine senderKeyGroups) create(groupId, title, channel string, members []string) error {
+	// all members must be contacts
+	for _, member := range members {
+		if _, status := storage.getStateContact(member); status != contactAdded {
+			return errors.New("ssyk: all members of a group must be contacts")
+		}
+	}
+	// create the group with a fresh sender key
+	storage.createGroup(groupId, title, senderKeysEngine, channel, 0, e2e.newSenderKey(groupId, 0))
+	for _, member := range members {
+		storage.addGroupMember(groupId, member)
+	}
+	// invite everyone
+	for _, member := range members {
+		if err := engine.ss.sendGroupControl(groupId, member, groupInviteMsg, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send encrypts a message with our sender key and sends it to all the members of the group
+func (engine senderKeyGroups) send(msg *plaintextMsg) error {
+	// get our sender key
+	_, senderKey, generation, err := storage.getGroup(msg.ConvoId)
+	if err != nil {
+		return err
+	}
+	// encrypt once for everyone
+	content, senderKey, err := e2e.encryptGroupMessage(senderKey, generation, msg)
+	if err != nil {
+		return err
+	}
+	storage.updateGroupSenderKey(msg.ConvoId, generation, senderKey)
+	// send to every member
+	for _, member := range storage.getGroupMembers(msg.ConvoId) {
+		encryptedMessage := &s.Request_Message{
+			ToAddress: member.publicKey,
+			ConvoId:   msg.ConvoId,
+			Content:   content,
+			Kind:      s.MessageKind_Group,
+		}
+		if err := hubs.sendMessage(encryptedMessage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add adds one of our contacts to a group
+func (engine senderKeyGroups) add(groupId, newMember string) error {
+	// checks
+	if _, status := storage.getStateContact(newMember); status != contactAdded {
+		return errors.New("ssyk: all members of a group must be contacts")
+	}
+	if _, err := storage.getGroupMember(groupId, newMember); err == nil {
+		return errors.New("ssyk: already a member of the group")
+	}
+	// tell the other members
+	members := storage.getGroupMembers(groupId)
+	for _, member := range members {
+		if err := engine.ss.sendGroupControl(groupId, member.publicKey, groupAddMemberMsg, newMember); err != nil {
+			return err
+		}
+	}
+	// invite the new member
+	storage.addGroupMember(groupId, newMember)
+	return engine.ss.sendGroupControl(groupId, newMember, groupInviteMsg, "")
+}
+
+// remove removes a member from a group, or leaves the group.
+// Our sender key is rotated so that the removed member can't read our next messages.
+func (engine senderKeyGroups) remove(groupId, oldMember string) error {
+	// checks
+	removed, err := storage.getGroupMember(groupId, oldMember)
+	if err != nil && oldMember != engine.ss.myAddress {
+		return err
+	}
+	// tell everyone (the removed member only if it has ever received anything from us, and never revoked keys)
+	for _, member := range storage.getGroupMembers(groupId) {
+		if (member.publicKey == oldMember && member.convoId == "") || storage.isRevoked(member.publicKey) {
+			continue
+		}
+		if err := engine.ss.sendGroupControl(groupId, member.publicKey, groupRemoveMemberMsg, oldMember); err != nil {
+			return err
+		}
+	}
+	// are we leaving?
+	if removed == nil {
+		storage.leaveGroup(groupId)
+		return nil
+	}
+	// remove and rotate
+	storage.removeGroupMember(groupId, oldMember)
+	return engine.ss.rotateSenderKey(groupId)
+}
+
+// receive decrypts a message sent to a group with the sender key of the member who sent it
+func (engine senderKeyGroups) receive(encryptedMsg *s.ResponseMessage) (*plaintextMsg, error) {
+	groupId := encryptedMsg.GetConvoId()
+	// the sender must be in the group
+	sender, err := storage.getGroupMember(groupId, encryptedMsg.GetFromAddress())
+	if err != nil {
+		return nil, err
+	}
+	if sender.senderKey == nil {
+		return nil, errors.New("ssyk: we haven't received the sender key of this member yet")
+	}
+	// remove encryption
+	msg, senderKey, err := e2e.decryptGroupMessage(sender.senderKey, sender.generation, encryptedMsg)
+	if err != nil {
+		return nil, err
+	}
+	storage.updateGroupMemberSenderKey(groupId, sender.publicKey, sender.generation, senderKey)
+	//
+	return msg, nil
+}
+
+// rotateSenderKey creates a new sender key for the group and sends it to every member
+func (ss sasayakiState) rotateSenderKey(groupId string) error {
+	if err := renewSenderKey(groupId); err != nil {
+		return err
+	}
+	for _, member := range storage.getGroupMembers(groupId) {
+		// revoked keys don't get our new sender key, they should be removed from the group
+		if storage.isRevoked(member.publicKey) {
+			continue
+		}
+		if err := ss.sendGroupControl(groupId, member.publicKey, groupSenderKeyMsg, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renewSenderKey replaces our sender key for a group with a fresh one of the next generation,
+// that the members need to read our next messages
+func renewSenderKey(groupId string) error {
+	_, _, generation, err := storage.getGroup(groupId)
+	if err != nil {
+		return err
+	}
+	generation++
+	storage.updateGroupSenderKey(groupId, generation, e2e.newSenderKey(groupId, generation))
+	return nil
+}
+
+// sendGroupControl sends a control message about a group to one of its members, in our pairwise conversation
+// with them. If we don't have a conversation with them yet, an invite is sent first to create it.
+func (ss sasayakiState) sendGroupControl(groupId, memberAddress string, t msgType, subject string) error {
+	title, senderKey, generation, err := storage.getGroup(groupId)
+	if err != nil {
+		return err
+	}
+	member, err := storage.getGroupMember(groupId, memberAddress)
+	if err != nil {
+		return err
+	}
+	// only invites can create a conversation
+	if member.convoId == "" && t != groupInviteMsg {
+		if err := ss.sendGroupControl(groupId, memberAddress, groupInviteMsg, ""); err != nil {
+			return err
+		}
+		return ss.sendGroupControl(groupId, memberAddress, t, subject)
+	}
+	channel, err := storage.getGroupChannel(groupId)
+	if err != nil {
+		return err
+	}
+	// our view of the group
+	control := &groupControl{
+		Id:      groupId,
+		Title:   title,
+		Members: []string{ss.myAddress},
+		Member:  subject,
+		Channel: channel,
+	}
+	for _, m := range storage.getGroupMembers(groupId) {
+		control.Members = append(control.Members, m.publicKey)
+	}
+	// only invites and rotations contain our sender key
+	if t == groupInviteMsg || t == groupSenderKeyMsg {
+		control.SenderKey = senderKey
+		control.Generation = generation
+	}
+	// send it in the pairwise conversation (or create it)
+	convoId, err := ss.send(&plaintextMsg{
+		ConvoId:     member.convoId,
+		FromAddress: ss.myAddress,
+		ToAddress:   memberAddress,
+		Type:        t,
+		Content:     title,
+		Group:       control,
+	})
+	if err != nil {
+		return err
+	}
+	if member.convoId == "" {
+		storage.updateGroupMemberConvo(groupId, memberAddress, convoId)
+	}
+	return nil
+}
+
+// handleGroupControl applies a control message received in a pairwise conversation
+func (ss sasayakiState) handleGroupControl(msg *plaintextMsg) error {
+	control := msg.Group
+	if control == nil || len(control.Id) != 32 {
+		return errors.New("ssyk: group control message is malformed")
+	}
+	groupId := control.Id
+
+	// an invite to a group we are not part of: join it
+	engine, err := storage.getGroupEngine(groupId)
+	if err != nil {
+		if msg.Type != groupInviteMsg {
+			return err
+		}
+		return ss.joinGroup(msg.FromAddress, control)
+	}
+	if engine != senderKeysEngine {
+		return errors.New("ssyk: group control message received for a group that doesn't use sender keys")
+	}
+
+	// otherwise the sender must be a member
+	sender, err := storage.getGroupMember(groupId, msg.FromAddress)
+	if err != nil {
+		return err
+	}
+
+	switch msg.Type {
+	case groupInviteMsg:
+		// a member sent us its sender key, send ours if we never did
+		storage.updateGroupMemberSenderKey(groupId, sender.publicKey, control.Generation, control.SenderKey)
+		if sender.convoId == "" {
+			return ss.sendGroupControl(groupId, sender.publicKey, groupInviteMsg, "")
+		}
+	case groupSenderKeyMsg:
+		// a member rotated its sender key
+		storage.updateGroupMemberSenderKey(groupId, sender.publicKey, control.Generation, control.SenderKey)
+	case groupAddMemberMsg:
+		// someone new, send them our sender key
+		if control.Member == ss.myAddress {
+			return nil
+		}
+		if _, status := storage.getStateContact(control.Member); status != contactAdded {
+			log.Println("ssyk: can't exchange keys with a new group member who is not a contact")
+			return nil
+		}
+		storage.addGroupMember(groupId, control.Member)
+		return ss.sendGroupControl(groupId, control.Member, groupInviteMsg, "")
+	case groupRemoveMemberMsg:
+		// are we the ones being removed?
+		if control.Member == ss.myAddress {
+			storage.leaveGroup(groupId)
+			return nil
+		}
+		// forget the member and rotate our sender key so that it can't read our next messages
+		if _, err := storage.getGroupMember(groupId, control.Member); err != nil {
+			return nil
+		}
+		storage.removeGroupMember(groupId, control.Member)
+		return ss.rotateSenderKey(groupId)
+	}
+
+	return nil
+}
+
+// joinGroup creates a group we have been invited to, and sends our sender key to all its members
+func (ss sasayakiState) joinGroup(inviter string, control *groupControl) error {
+	// create the group with a fresh sender key
+	if control.Channel != chatChannel && control.Channel != issuesChannel {
+		return errors.New("ssyk: invited to an unknown type of channel")
+	}
+	storage.createGroup(control.Id, control.Title, senderKeysEngine, control.Channel, 0, e2e.newSenderKey(control.Id, 0))
+	// add the members we can talk to
+	for _, member := range control.Members {
+		if member == ss.myAddress {
+			continue
+		}
+		if _, status := storage.getStateContact(member); status != contactAdded {
+			log.Println("ssyk: can't exchange keys with a group member who is not a contact")
+			continue
+		}
+		storage.addGroupMember(control.Id, member)
+	}
+	// the inviter must be part of the group
+	if _, err := storage.getGroupMember(control.Id, inviter); err != nil {
+		storage.leaveGroup(control.Id)
+		return errors.New("ssyk: invited to a group by someone who is not part of it")
+	}
+	storage.updateGroupMemberSenderKey(control.Id, inviter, control.Generation, control.SenderKey)
+	// send our sender key to everyone
+	for _, member := range storage.getGroupMembers(control.Id) {
+		if err := ss.sendGroupControl(control.Id, member.publicKey, groupInviteMsg, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
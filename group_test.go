@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	s "github.com/mimoo/sasayaki/serialization"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+// groupPeer is a member of a sender keys group, with the sender key of the creator as it received it
+type groupPeer struct {
+	e2e        encryptionState
+	address    string
+	senderKey  []byte
+	generation uint32
+}
+
+func newGroupPeer() *groupPeer {
+	keyPair := disco.GenerateKeypair(nil)
+	return &groupPeer{
+		e2e:     encryptionState{keyPair: keyPair},
+		address: hex.EncodeToString(keyPair.PublicKey[:]),
+	}
+}
+
+// receiveSenderKey gives our current sender key to a member, like an invite or a rotation does
+func (peer *groupPeer) receiveSenderKey(t *testing.T, groupId string) {
+	_, senderKey, generation, err := storage.getGroup(groupId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer.senderKey, peer.generation = senderKey, generation
+}
+
+// sendToGroupPeers encrypts a message once with our sender key, like senderKeyGroups.send does
+func sendToGroupPeers(t *testing.T, alice *groupPeer, groupId, content string) *s.ResponseMessage {
+	_, senderKey, generation, err := storage.getGroup(groupId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, senderKey, err := alice.e2e.encryptGroupMessage(senderKey, generation, &plaintextMsg{
+		ConvoId: groupId, Id: newRandomId(), FromAddress: alice.address, Type: textMsg, Content: content,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.updateGroupSenderKey(groupId, generation, senderKey)
+	return &s.ResponseMessage{FromAddress: alice.address, ConvoId: groupId, Content: ciphertext, Kind: s.MessageKind_Group}
+}
+
+// read decrypts a message of the group with the sender key we have
+func (peer *groupPeer) read(encryptedMsg *s.ResponseMessage) (string, error) {
+	msg, senderKey, err := peer.e2e.decryptGroupMessage(peer.senderKey, peer.generation, encryptedMsg)
+	if err != nil {
+		return "", err
+	}
+	peer.senderKey = senderKey
+	return msg.Content, nil
+}
+
+func TestSenderKeyGroups(t *testing.T) {
+	initTestStorage(t)
+	alice, bob, carol, dave := newGroupPeer(), newGroupPeer(), newGroupPeer(), newGroupPeer()
+	e2e = alice.e2e
+	ssyk.myAddress = alice.address
+
+	// create
+	groupId := newRandomId()
+	storage.createGroup(groupId, "team", senderKeysEngine, chatChannel, 0, e2e.newSenderKey(groupId, 0))
+	storage.addGroupMember(groupId, bob.address)
+	storage.addGroupMember(groupId, carol.address)
+	if members := storage.getGroupMembers(groupId); len(members) != 2 {
+		t.Fatalf("expected 2 members, got %d", len(members))
+	}
+	bob.receiveSenderKey(t, groupId)
+	carol.receiveSenderKey(t, groupId)
+
+	encryptedMsg := sendToGroupPeers(t, alice, groupId, "hello")
+	for _, peer := range []*groupPeer{bob, carol} {
+		if content, err := peer.read(encryptedMsg); err != nil || content != "hello" {
+			t.Fatalf("a member couldn't read the group: %q %v", content, err)
+		}
+	}
+
+	// add
+	storage.addGroupMember(groupId, dave.address)
+	if _, err := storage.getGroupMember(groupId, dave.address); err != nil {
+		t.Fatal(err)
+	}
+	dave.receiveSenderKey(t, groupId)
+	encryptedMsg = sendToGroupPeers(t, alice, groupId, "welcome dave")
+	for _, peer := range []*groupPeer{bob, carol, dave} {
+		if content, err := peer.read(encryptedMsg); err != nil || content != "welcome dave" {
+			t.Fatalf("a member couldn't read the group: %q %v", content, err)
+		}
+	}
+
+	// remove and rekey, carol doesn't receive the new sender key
+	storage.removeGroupMember(groupId, carol.address)
+	if _, err := storage.getGroupMember(groupId, carol.address); err == nil {
+		t.Fatal("carol is still a member")
+	}
+	if err := renewSenderKey(groupId); err != nil {
+		t.Fatal(err)
+	}
+	bob.receiveSenderKey(t, groupId)
+	dave.receiveSenderKey(t, groupId)
+	if bob.generation != 1 {
+		t.Fatalf("the sender key wasn't rotated, generation %d", bob.generation)
+	}
+
+	encryptedMsg = sendToGroupPeers(t, alice, groupId, "carol is gone")
+	for _, peer := range []*groupPeer{bob, dave} {
+		if content, err := peer.read(encryptedMsg); err != nil || content != "carol is gone" {
+			t.Fatalf("a member couldn't read the group after the rekey: %q %v", content, err)
+		}
+	}
+	if _, err := carol.read(encryptedMsg); err == nil {
+		t.Fatal("a removed member read a message sent after the rekey")
+	}
+	// even pretending to have the new generation
+	carol.generation = 1
+	if _, err := carol.read(encryptedMsg); err == nil {
+		t.Fatal("a removed member read a message sent after the rekey with its previous sender key")
+	}
+}
@@ -12,6 +12,8 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"net"
 
 	"github.com/golang/protobuf/proto"
@@ -23,10 +25,8 @@ import (
 
 const (
 	maxConnectionAttempts = 5
-)
-
-var (
-	rcvBuffer [10000]byte
+	rotationQueryKeys     = 180 // keys per GetKeyRotations request, the Hub refuses more (its responses must fit in 64KiB)
+	deviceQueryKeys       = 11  // keys per GetDevices request, the Hub refuses more
 )
 
 type hubState struct {
@@ -34,12 +34,14 @@ type hubState struct {
 
 	hubAddress   string
 	hubPublicKey []byte
+	certificate  []byte // our serialized membership certificate, if the Hub is dedicated to an organization
 }
 
-func initHubState(hubAddress string, hubPublicKey []byte) *hubState {
+func initHubState(hubAddress string, hubPublicKey, certificate []byte) *hubState {
 	hub := &hubState{
 		hubAddress:   hubAddress,
 		hubPublicKey: hubPublicKey,
+		certificate:  certificate,
 	}
 	return hub
 }
@@ -53,12 +55,12 @@ func (hub *hubState) isHubReady() error {
 	if hub.hubAddress == "" || hub.hubPublicKey == nil {
 		return errors.New("Hub not properly configured")
 	}
-	// config for IK handshake
+	// config for IK handshake (the certificate proves that we are part of the Hub's organization)
 	clientConfig := disco.Config{
 		KeyPair:              ssyk.keyPair,
 		HandshakePattern:     disco.Noise_IK,
 		RemoteKey:            hub.hubPublicKey,
-		StaticPublicKeyProof: []byte{},
+		StaticPublicKeyProof: hub.certificate,
 	}
 	// dial the Hub and set `conn`
 	var err error
@@ -70,24 +72,21 @@ func (hub *hubState) isHubReady() error {
 	return nil
 }
 
-// TODO: of course encrypt the message before sending it :)
-// TODO: needs a cryptoManager? or endToEndManager? or encryptionManager
-func (hub *hubState) sendMessage(encryptedMessage *s.Request_Message) error {
+// query sends a request to the hub and parses its response in `res`
+// the encoding on the wire is [length(2), data(...)] in both directions
+func (hub *hubState) query(req *s.Request, res proto.Message) error {
 	// do we have a connection working?
 	if err := hub.isHubReady(); err != nil {
 		return err
 	}
-	// proto structure
-	req := &s.Request{
-		RequestType: s.Request_SendMessage,
-		Message:     encryptedMessage,
-	}
-
 	// serialize
 	data, err := proto.Marshal(req)
 	if err != nil {
 		return err
 	}
+	if len(data) > 0xffff {
+		return errors.New("ssyk: request to the hub is too large")
+	}
 	// encode [length(2), data(...)]
 	data = append([]byte{byte(len(data) >> 8), byte(len(data))}, data...)
 	// send
@@ -97,26 +96,39 @@ func (hub *hubState) sendMessage(encryptedMessage *s.Request_Message) error {
 	}
 	// receive header
 	var header [2]byte
-	n, err := hub.conn.Read(header[:])
-	if err != nil || n != 2 {
+	if _, err := io.ReadFull(hub.conn, header[:]); err != nil {
 		hub.conn = nil
 		return err
 	}
-	length := (header[0] << 8) | header[1]
+	length := int(header[0])<<8 | int(header[1])
 	// receive
 	rcvBuffer := make([]byte, length)
-	n, err = hub.conn.Read(rcvBuffer)
-	if err != nil {
+	if _, err := io.ReadFull(hub.conn, rcvBuffer); err != nil {
 		hub.conn = nil
 		return err
 	}
 	// unserialize
+	return proto.Unmarshal(rcvBuffer, res)
+}
+
+// TODO: of course encrypt the message before sending it :)
+// TODO: needs a cryptoManager? or endToEndManager? or encryptionManager
+func (hub *hubState) sendMessage(encryptedMessage *s.Request_Message) error {
+	// proto structure
+	req := &s.Request{
+		RequestType: s.Request_SendMessage,
+		Message:     encryptedMessage,
+	}
+	// send it
 	res := &s.ResponseSuccess{}
-	if err = proto.Unmarshal(rcvBuffer[:n], res); err != nil {
+	if err := hub.query(req, res); err != nil {
 		return err
 	}
 
 	// return on failure
+	if res.GetCode() == s.ErrorCode_RateLimited {
+		return fmt.Errorf("ssyk: %s (retry in %ds)", res.GetError(), res.GetRetryAfter())
+	}
 	if !res.GetSuccess() {
 		return errors.New(res.GetError())
 	}
@@ -126,47 +138,329 @@ func (hub *hubState) sendMessage(encryptedMessage *s.Request_Message) error {
 
 // getNextMessage receives a protobuffer structure and returns a message type
 func (hub *hubState) getNextMessage() (*s.ResponseMessage, error) {
-	// do we have a connection?
-	if err := hub.isHubReady(); err != nil {
+	// create query
+	req := &s.Request{RequestType: s.Request_GetNextMessage}
+	// send it
+	res := &s.ResponseMessage{}
+	if err := hub.query(req, res); err != nil {
 		return nil, err
 	}
+
+	// TODO: make sure that fields are set here?
+
+	// return message
+	return res, nil
+}
+
+// uploadBlob stores a chunk of an encrypted attachment on the hub, and returns its id
+func (hub *hubState) uploadBlob(content []byte) (string, error) {
 	// create query
-	req := &s.Request{RequestType: s.Request_GetNextMessage}
-	// serialize
-	data, err := proto.Marshal(req)
-	if err != nil {
+	req := &s.Request{
+		RequestType: s.Request_UploadBlob,
+		Blob:        &s.Request_Blob{Content: content},
+	}
+	// send it
+	res := &s.ResponseBlob{}
+	if err := hub.query(req, res); err != nil {
+		return "", err
+	}
+	// return on failure
+	if !res.GetSuccess() {
+		return "", errors.New(res.GetError())
+	}
+	return res.GetId(), nil
+}
+
+// downloadBlob retrieves a chunk of an encrypted attachment from the hub
+func (hub *hubState) downloadBlob(id string) ([]byte, error) {
+	// create query
+	req := &s.Request{
+		RequestType: s.Request_DownloadBlob,
+		Blob:        &s.Request_Blob{Id: id},
+	}
+	// send it
+	res := &s.ResponseBlob{}
+	if err := hub.query(req, res); err != nil {
 		return nil, err
 	}
-	// encode [length(2), data(...)]
-	data = append([]byte{byte(len(data) >> 8), byte(len(data))}, data...)
-	// send
-	if _, err = hub.conn.Write(data); err != nil {
-		hub.conn = nil
+	// return on failure
+	if !res.GetSuccess() {
+		return nil, errors.New(res.GetError())
+	}
+	return res.GetContent(), nil
+}
+
+// publishKeyPackage publishes one of our key packages, so that we can be added to MLS groups
+func (hub *hubState) publishKeyPackage(content []byte) error {
+	// create query
+	req := &s.Request{
+		RequestType: s.Request_PublishKeyPackage,
+		KeyPackage:  &s.Request_KeyPackage{Content: content},
+	}
+	// send it
+	res := &s.ResponseSuccess{}
+	if err := hub.query(req, res); err != nil {
+		return err
+	}
+	// return on failure
+	if !res.GetSuccess() {
+		return errors.New(res.GetError())
+	}
+	return nil
+}
+
+// getKeyPackage fetches a key package of someone we want to add to an MLS group
+func (hub *hubState) getKeyPackage(owner string) ([]byte, error) {
+	// create query
+	req := &s.Request{
+		RequestType: s.Request_GetKeyPackage,
+		KeyPackage:  &s.Request_KeyPackage{Owner: owner},
+	}
+	// send it
+	res := &s.ResponseKeyPackage{}
+	if err := hub.query(req, res); err != nil {
 		return nil, err
 	}
-	// receive header
-	var header [2]byte
-	n, err := hub.conn.Read(header[:])
-	if err != nil || n != 2 {
-		hub.conn = nil
+	// return on failure
+	if !res.GetSuccess() {
+		return nil, errors.New(res.GetError())
+	}
+	return res.GetContent(), nil
+}
+
+// getOrganizationMembers searches the directory of the Hub's organization, it returns serialized
+// membership certificates and how many members match the query
+func (hub *hubState) getOrganizationMembers(query string, offset, limit uint32) ([][]byte, uint32, error) {
+	// create query
+	req := &s.Request{
+		RequestType: s.Request_GetOrganizationMembers,
+		Directory:   &s.Request_Directory{Query: query, Offset: offset, Limit: limit},
+	}
+	// send it
+	res := &s.ResponseOrganizationMembers{}
+	if err := hub.query(req, res); err != nil {
+		return nil, 0, err
+	}
+	// return on failure
+	if !res.GetSuccess() {
+		return nil, 0, errors.New(res.GetError())
+	}
+	return res.GetCertificates(), res.GetTotal(), nil
+}
+
+// getTreeHead returns the signed root of the transparency log of the Hub's organization
+func (hub *hubState) getTreeHead() (*s.TreeHead, error) {
+	// create query
+	req := &s.Request{
+		RequestType: s.Request_GetTreeHead,
+	}
+	// send it
+	res := &s.ResponseTreeHead{}
+	if err := hub.query(req, res); err != nil {
 		return nil, err
 	}
-	length := (header[0] << 8) | header[1]
-	// receive
-	rcvBuffer := make([]byte, length)
-	n, err = hub.conn.Read(rcvBuffer)
-	if err != nil {
-		hub.conn = nil
+	// return on failure
+	if !res.GetSuccess() || res.GetTreeHead() == nil {
+		return nil, errors.New(res.GetError())
+	}
+	return res.GetTreeHead(), nil
+}
+
+// getConsistencyProof returns a proof that the log of `first` entries is a prefix of the log of `second` entries
+func (hub *hubState) getConsistencyProof(first, second uint64) ([][]byte, error) {
+	// create query
+	req := &s.Request{
+		RequestType: s.Request_GetConsistencyProof,
+		Log:         &s.Request_Log{First: first, Second: second},
+	}
+	// send it
+	res := &s.ResponseConsistencyProof{}
+	if err := hub.query(req, res); err != nil {
 		return nil, err
 	}
-	// unserialize
-	res := &s.ResponseMessage{}
-	if err = proto.Unmarshal(rcvBuffer[:n], res); err != nil {
+	// return on failure
+	if !res.GetSuccess() {
+		return nil, errors.New(res.GetError())
+	}
+	return res.GetProof(), nil
+}
+
+// getLogEntries returns the entries of the log starting at `first`, with their inclusion proofs in the log
+// of `second` entries (the Hub might not return all of them at once)
+func (hub *hubState) getLogEntries(first, second uint64) ([]*s.LogEntryProof, error) {
+	// create query
+	req := &s.Request{
+		RequestType: s.Request_GetLogEntries,
+		Log:         &s.Request_Log{First: first, Second: second},
+	}
+	// send it
+	res := &s.ResponseLogEntries{}
+	if err := hub.query(req, res); err != nil {
 		return nil, err
 	}
+	// return on failure
+	if !res.GetSuccess() {
+		return nil, errors.New(res.GetError())
+	}
+	return res.GetEntries(), nil
+}
 
-	// TODO: make sure that fields are set here?
+// publishProof publishes one of our verifications (a serialized VerificationProof)
+func (hub *hubState) publishProof(proof []byte) error {
+	// create query
+	req := &s.Request{
+		RequestType: s.Request_PublishProof,
+		Proof:       &s.Request_Proof{Content: proof},
+	}
+	// send it
+	res := &s.ResponseSuccess{}
+	if err := hub.query(req, res); err != nil {
+		return err
+	}
+	// return on failure
+	if !res.GetSuccess() {
+		return errors.New(res.GetError())
+	}
+	return nil
+}
 
-	// return message
-	return res, nil
+// getProofsForMember returns the serialized VerificationProofs published for a key
+func (hub *hubState) getProofsForMember(member string) ([][]byte, error) {
+	// create query
+	req := &s.Request{
+		RequestType: s.Request_GetProofsForMember,
+		Proof:       &s.Request_Proof{Member: member},
+	}
+	// send it
+	res := &s.ResponseProofs{}
+	if err := hub.query(req, res); err != nil {
+		return nil, err
+	}
+	// return on failure
+	if !res.GetSuccess() {
+		return nil, errors.New(res.GetError())
+	}
+	return res.GetProofs(), nil
+}
+
+// publishKeyRotation publishes our new key, signed by our current key (a serialized KeyRotationStatement)
+func (hub *hubState) publishKeyRotation(statement []byte) error {
+	// create query
+	req := &s.Request{
+		RequestType: s.Request_PublishKeyRotation,
+		Rotation:    &s.Request_Rotation{Statement: statement},
+	}
+	// send it
+	res := &s.ResponseSuccess{}
+	if err := hub.query(req, res); err != nil {
+		return err
+	}
+	// return on failure
+	if !res.GetSuccess() {
+		return errors.New(res.GetError())
+	}
+	return nil
+}
+
+// getKeyRotations returns the serialized KeyRotationStatements published for some keys
+// (in several requests if there are many keys)
+func (hub *hubState) getKeyRotations(keys []string) ([][]byte, error) {
+	var statements [][]byte
+	for start := 0; start < len(keys); start += rotationQueryKeys {
+		end := start + rotationQueryKeys
+		if end > len(keys) {
+			end = len(keys)
+		}
+		// create query
+		req := &s.Request{
+			RequestType: s.Request_GetKeyRotations,
+			Rotation:    &s.Request_Rotation{Keys: keys[start:end]},
+		}
+		// send it
+		res := &s.ResponseKeyRotations{}
+		if err := hub.query(req, res); err != nil {
+			return nil, err
+		}
+		// return on failure
+		if !res.GetSuccess() {
+			return nil, errors.New(res.GetError())
+		}
+		statements = append(statements, res.GetStatements()...)
+	}
+	return statements, nil
+}
+
+// publishDevice publishes the serialized DeviceCertificate of one of our devices (or the request to link it,
+// without the signature of the device)
+func (hub *hubState) publishDevice(certificate []byte) error {
+	// create query
+	req := &s.Request{
+		RequestType: s.Request_PublishDevice,
+		Device:      &s.Request_Device{Certificate: certificate},
+	}
+	// send it
+	res := &s.ResponseSuccess{}
+	if err := hub.query(req, res); err != nil {
+		return err
+	}
+	// return on failure
+	if !res.GetSuccess() {
+		return errors.New(res.GetError())
+	}
+	return nil
+}
+
+// getDevices returns the serialized DeviceCertificates of the devices of some identities, or of some devices
+// (in several requests if there are many keys)
+func (hub *hubState) getDevices(keys []string) ([][]byte, error) {
+	var certificates [][]byte
+	for start := 0; start < len(keys); start += deviceQueryKeys {
+		end := start + deviceQueryKeys
+		if end > len(keys) {
+			end = len(keys)
+		}
+		// create query
+		req := &s.Request{
+			RequestType: s.Request_GetDevices,
+			Device:      &s.Request_Device{Keys: keys[start:end]},
+		}
+		// send it
+		res := &s.ResponseDevices{}
+		if err := hub.query(req, res); err != nil {
+			return nil, err
+		}
+		// return on failure
+		if !res.GetSuccess() {
+			return nil, errors.New(res.GetError())
+		}
+		certificates = append(certificates, res.GetCertificates()...)
+	}
+	return certificates, nil
+}
+
+// removeDevice removes one of our devices from the Hub
+func (hub *hubState) removeDevice(device string) error {
+	// create query
+	req := &s.Request{
+		RequestType: s.Request_RemoveDevice,
+		Device:      &s.Request_Device{Device: device},
+	}
+	// send it
+	res := &s.ResponseSuccess{}
+	if err := hub.query(req, res); err != nil {
+		return err
+	}
+	// return on failure
+	if !res.GetSuccess() {
+		return errors.New(res.GetError())
+	}
+	return nil
+}
+
+// disconnect closes our connection to the Hub, the next query reconnects with our current key
+func (hub *hubState) disconnect() {
+	if hub.conn != nil {
+		hub.conn.Close()
+		hub.conn = nil
+	}
 }
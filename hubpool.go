@@ -0,0 +1,212 @@
+//
+// Hub Pool
+// ========
+//
+// Besides the Hub of our organization, we can be on the Hubs of partner organizations (the `hubs` of
+// configuration.json). The pool keeps a connection to each of them.
+//
+// Every contact is bound to the Hub it is reachable on: the one we chose when adding it, or the one its
+// contact request came from. Contacts that aren't bound are on our Hub, and the devices of a contact are on
+// the Hub of that contact (see device.go). Messages are sent to the Hub of their recipient, and fetched from
+// every Hub in turn so that the UIs see a single inbox.
+//
+// A Hub can also forward messages to the Hubs it federates with (see server/delivery.go): a contact on one of
+// them has the address <key>@<hub>, hub being the name our Hub gives to its Hub, and is bound to the Hub we
+// reach it through like any other contact.
+//
+// What we publish about ourselves (key packages, key rotations, devices and verification proofs) goes to
+// every Hub, so that our contacts find it on theirs, and what we fetch about a contact comes from its Hub.
+// The chunks of an attachment are uploaded to the Hub of the recipient, and downloaded from the Hub of the
+// sender. Blobs are not forwarded between federated Hubs, so there are no attachments with <key>@<hub> contacts
+// (see attachment.go). The transparency log and the directory are those of our organization, on our Hub.
+//
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	s "github.com/mimoo/sasayaki/serialization"
+)
+
+type hubPool struct {
+	others map[string]*hubState // the Hubs besides ours, by public key in hex
+	names  map[string]string    // name in the configuration -> public key in hex
+	order  []string             // the public keys of the Hubs besides ours, in the order they are fetched from
+	next   int                  // the Hub to fetch from first, 0 being ours (protected by storage.queryMutex)
+}
+
+var (
+	hubs hubPool
+
+	peerNameRegexp = regexp.MustCompile(`^[a-z0-9.-]{1,64}$`) // the names Hubs give to their peers (see server/delivery.go)
+)
+
+// initHubPool sets the Hubs we use besides ours
+func initHubPool(configs []hubConfig) error {
+	pool := hubPool{
+		others: map[string]*hubState{},
+		names:  map[string]string{},
+	}
+	for _, config := range configs {
+		hubPublicKey, err := hex.DecodeString(config.PublicKey)
+		if err != nil || len(hubPublicKey) != 32 {
+			return errors.New("ssyk: incorrect public key for the hub " + config.Name)
+		}
+		certificate, err := hex.DecodeString(config.Certificate)
+		if err != nil {
+			return errors.New("ssyk: incorrect membership certificate for the hub " + config.Name)
+		}
+		key := hex.EncodeToString(hubPublicKey)
+		if config.Name == "" || config.Address == "" || pool.names[config.Name] != "" || pool.others[key] != nil {
+			return errors.New("ssyk: hubs need a unique name, an address and a unique public key")
+		}
+		pool.others[key] = initHubState(config.Address, hubPublicKey, certificate)
+		pool.names[config.Name] = key
+		pool.order = append(pool.order, key)
+	}
+	sort.Strings(pool.order)
+	for _, other := range hubs.others {
+		other.disconnect()
+	}
+	hubs = pool
+	return nil
+}
+
+// resolve returns the public key of a Hub from its name in the configuration, an empty name being our Hub
+func (pool *hubPool) resolve(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	key, ok := pool.names[name]
+	if !ok {
+		return "", errors.New("ssyk: unknown hub " + name)
+	}
+	return key, nil
+}
+
+// get returns a Hub from its public key, our Hub if it's empty or if we don't use that Hub anymore
+func (pool *hubPool) get(key string) *hubState {
+	if other, ok := pool.others[key]; ok {
+		return other
+	}
+	return &hub
+}
+
+// of returns the Hub an address is reachable on. storage.queryMutex must be held
+func (pool *hubPool) of(address string) *hubState {
+	key := storage.getContactHub(address)
+	if key == "" {
+		if identity, ok := storage.getDeviceIdentity(address); ok {
+			key = storage.getContactHub(identity)
+		}
+	}
+	return pool.get(key)
+}
+
+// each returns our Hub and the others
+func (pool *hubPool) each() []*hubState {
+	all := []*hubState{&hub}
+	for _, key := range pool.order {
+		all = append(all, pool.others[key])
+	}
+	return all
+}
+
+// publish publishes something on every Hub, and returns the first error. storage.queryMutex must be held
+func (pool *hubPool) publish(publication func(current *hubState) error) error {
+	var firstErr error
+	for _, current := range pool.each() {
+		if err := publication(current); err != nil {
+			log.Println("couldn't publish to the hub", current.hubAddress+":", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// group returns addresses grouped by the Hub they are reachable on. storage.queryMutex must be held
+func (pool *hubPool) group(addresses []string) map[*hubState][]string {
+	grouped := map[*hubState][]string{}
+	for _, address := range addresses {
+		current := pool.of(address)
+		grouped[current] = append(grouped[current], address)
+	}
+	return grouped
+}
+
+// splitAddress splits <key>@<hub> addresses, hub is empty for the clients of the Hub itself
+func splitAddress(address string) (string, string) {
+	if i := strings.Index(address, "@"); i >= 0 {
+		return address[:i], address[i+1:]
+	}
+	return address, ""
+}
+
+// decodeAddress returns the public key of an address, which can be on a peer Hub
+func decodeAddress(address string) ([]byte, error) {
+	key, peer := splitAddress(address)
+	publicKey, err := hex.DecodeString(key)
+	if err != nil || len(publicKey) != 32 || (strings.Contains(address, "@") && !peerNameRegexp.MatchString(peer)) {
+		return nil, errors.New("ssyk: contact's address is malformed")
+	}
+	return publicKey, nil
+}
+
+// isAddress returns true if an address is a public key in hex, followed by the name of a peer Hub or not
+func isAddress(address string) bool {
+	_, err := decodeAddress(address)
+	return err == nil
+}
+
+// sendMessage sends a message to the Hub of its recipient. storage.queryMutex must be held
+func (pool *hubPool) sendMessage(encryptedMessage *s.Request_Message) error {
+	return pool.of(encryptedMessage.GetToAddress()).sendMessage(encryptedMessage)
+}
+
+// getNextMessage fetches the next message from the first Hub that has one, starting after the Hub of the
+// last message received so that no Hub is starved. It also returns the public key of that Hub (empty for ours).
+// A Hub that can't be reached is skipped, unless none can be. storage.queryMutex must be held
+func (pool *hubPool) getNextMessage() (*s.ResponseMessage, string, error) {
+	var lastErr error
+	failures := 0
+	for i := 0; i <= len(pool.order); i++ {
+		index := (pool.next + i) % (len(pool.order) + 1)
+		key, current := "", &hub
+		if index > 0 {
+			key = pool.order[index-1]
+			current = pool.others[key]
+		}
+		encryptedMsg, err := current.getNextMessage()
+		if err != nil {
+			log.Println("couldn't fetch messages from the hub", current.hubAddress+":", err)
+			lastErr = err
+			failures++
+			continue
+		}
+		if encryptedMsg.GetFromAddress() == "" {
+			continue
+		}
+		pool.next = (index + 1) % (len(pool.order) + 1)
+		return encryptedMsg, key, nil
+	}
+	if failures == len(pool.order)+1 {
+		return nil, "", lastErr
+	}
+	// no address == no new message
+	return &s.ResponseMessage{}, "", nil
+}
+
+// disconnect closes the connections to every Hub
+func (pool *hubPool) disconnect() {
+	hub.disconnect()
+	for _, other := range pool.others {
+		other.disconnect()
+	}
+}
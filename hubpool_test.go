@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDecodeAddress(t *testing.T) {
+	key := strings.Repeat("ab", 32)
+	for _, address := range []string{key, key + "@partner", key + "@hub.example.org"} {
+		publicKey, err := decodeAddress(address)
+		if err != nil || !bytes.Equal(publicKey, bytes.Repeat([]byte{0xab}, 32)) {
+			t.Errorf("%s: got %x, %v", address, publicKey, err)
+		}
+	}
+	for _, address := range []string{"", key[:62], key + "ab", "zz" + key[2:], key + "@", key + "@Partner", key + "@a@b", "@partner"} {
+		if _, err := decodeAddress(address); err == nil {
+			t.Errorf("the malformed address %q was accepted", address)
+		}
+	}
+	if publicKey, peer := splitAddress(key + "@partner"); publicKey != key || peer != "partner" {
+		t.Errorf("%s@partner was split in %s and %s", key, publicKey, peer)
+	}
+}
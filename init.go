@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -24,9 +25,42 @@ func initSasayaki(passphrase string) (*configuration, *disco.KeyPair, error) {
 	return config, keyPair, nil
 }
 
+// initSasayakiFromAgent is initSasayaki with the keypair held by the unlock agent, if one is running
+// (see agent.go)
+func initSasayakiFromAgent() (*configuration, *disco.KeyPair, error) {
+	res, err := agentQuery("get_keypair")
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKey, err := hex.DecodeString(res.PrivateKey)
+	if err != nil || len(privateKey) != 32 {
+		return nil, nil, errors.New("ssyk: the agent sent a malformed keypair")
+	}
+	var key [32]byte
+	copy(key[:], privateKey)
+	initSasayakiFolder()
+	return initConfiguration(), disco.GenerateKeypair(&key), nil
+}
+
 type configuration struct {
-	HubAddress   string `json:"hub_address"`
-	HubPublicKey string `json:"hub_publickey"`
+	HubAddress      string `json:"hub_address"`
+	HubPublicKey    string `json:"hub_publickey"`
+	Certificate     string `json:"certificate,omitempty"`      // our membership certificate in hex (see the organization tool)
+	OrganizationKey string `json:"organization_key,omitempty"` // the public key of our organization in hex, to verify the directory
+	TrustDepth      int    `json:"trust_depth,omitempty"`      // how long a chain of verifications can be to trust a key (see trust.go)
+
+	Identity          string `json:"identity,omitempty"`           // the key of our first device in hex, if we are a linked device (see device.go)
+	DeviceCertificate string `json:"device_certificate,omitempty"` // our DeviceCertificate in hex, signed by our identity and by us
+
+	Hubs []hubConfig `json:"hubs,omitempty"` // the Hubs of partner organizations we are also on (see hubpool.go)
+}
+
+// hubConfig is a Hub we use besides ours
+type hubConfig struct {
+	Name        string `json:"name"` // how we refer to it when adding a contact
+	Address     string `json:"address"`
+	PublicKey   string `json:"publickey"`
+	Certificate string `json:"certificate,omitempty"` // our membership certificate in hex, if the Hub requires one
 }
 
 // read json file
@@ -99,12 +133,17 @@ func initSasayakiFolder() {
 		fmt.Println("ssyk: creating configuration folder at", home)
 		os.MkdirAll(keyFolder, 0770) // user | group | all
 	}
+	// create ~/.sasayaki/attachments if it doesn't exists
+	attachmentFolder := filepath.Join(home, "attachments")
+	if _, err := os.Stat(attachmentFolder); os.IsNotExist(err) {
+		os.MkdirAll(attachmentFolder, 0770) // user | group | all
+	}
 }
 
 // init keypair
 func initKeyPair(passphrase string) (*disco.KeyPair, error) {
 	// location
-	location := filepath.Join(sasayakiFolder(), "/keys/keypair")
+	location := keyPairLocation()
 	// create ~/.sasayaki/keys/keyPair
 	if _, err := os.Stat(location); os.IsNotExist(err) {
 		fmt.Println("ssyk: generating a keypair for new user")
@@ -118,8 +157,38 @@ func initKeyPair(passphrase string) (*disco.KeyPair, error) {
 	}
 }
 
-// get the ~ folder at runtime (os-dependent)
+// keyPairLocation returns where our keypair is stored (~/.sasayaki/keys/keypair)
+func keyPairLocation() string {
+	return filepath.Join(sasayakiFolder(), "/keys/keypair")
+}
+
+// archiveKeyPair moves a keypair we don't use anymore to ~/.sasayaki/keys/archive (see rotation.go)
+func archiveKeyPair(location, address string) error {
+	archiveFolder := filepath.Join(sasayakiFolder(), "keys", "archive")
+	if err := os.MkdirAll(archiveFolder, 0770); err != nil { // user | group | all
+		return err
+	}
+	return os.Rename(location, filepath.Join(archiveFolder, address+".keypair"))
+}
+
+// unarchiveKeyPair puts an archived keypair back in place of the current one
+func unarchiveKeyPair(location, address string) error {
+	return os.Rename(filepath.Join(sasayakiFolder(), "keys", "archive", address+".keypair"), location)
+}
+
+// sasayakiFolder returns the folder of the current profile (see profile.go)
 func sasayakiFolder() string {
+	if currentProfile == defaultProfile {
+		return sasayakiRoot()
+	}
+	return filepath.Join(sasayakiRoot(), "profiles", currentProfile)
+}
+
+// get the ~ folder at runtime (os-dependent), unless SASAYAKI_HOME is set
+func sasayakiRoot() string {
+	if root := os.Getenv("SASAYAKI_HOME"); root != "" {
+		return root
+	}
 	home := homeDir()
 	if runtime.GOOS == "windows" {
 		// what about using the previous home and doing this instead?
@@ -0,0 +1,192 @@
+//
+// Issue Trackers
+// ==============
+//
+// A group can be created as an issue tracker channel instead of a chat (like GitHub issues, for small teams).
+// Every issue is a conversation of its own, with a title, a state (open or closed), labels and assignees.
+//
+// Everything is sent to the group (see group.go), with the group id as convo id:
+//
+// * IssueOpen: opens an issue, the content is its title
+// * IssueUpdate: the new state, labels and assignees of an issue, and its new title if the content is not empty
+// * text messages, attachments, edits and deletions are comments on an issue
+//
+// All of them carry the id of the issue, which is also the id of the conversation where its comments are stored.
+//
+// Note that there is no conflict resolution: if two members update an issue at the same time, the members
+// might not agree on the last update.
+//
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	chatChannel   = "chat"
+	issuesChannel = "issues"
+
+	issueOpen   = "open"
+	issueClosed = "closed"
+
+	issueMaxLabels    = 20
+	issueMaxAssignees = 20
+	issueLabelMaxSize = 50
+)
+
+var (
+	publicKeyRegexp = regexp.MustCompile("^[0-9a-f]{64}$")
+)
+
+// openIssue opens a new issue in an issue tracker channel, it returns the id of the issue
+func (ss sasayakiState) openIssue(groupId, title string, labels, assignees []string) (string, error) {
+	issueId := newRandomId()
+	_, err := ss.sendGroupMessage(&plaintextMsg{
+		ConvoId:     groupId,
+		FromAddress: ss.myAddress,
+		Type:        issueOpenMsg,
+		Content:     title,
+		Issue: &issueInfo{
+			Id:        issueId,
+			State:     issueOpen,
+			Labels:    labels,
+			Assignees: assignees,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return issueId, nil
+}
+
+// updateIssue changes an issue with the update function, and sends the result to the group
+func (ss sasayakiState) updateIssue(groupId, issueId string, update func(issue *issueInfo)) error {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	issue, err := storage.getIssue(groupId, issueId)
+	if err != nil {
+		return err
+	}
+	title := issue.Title
+	update(issue)
+	// the title is only sent if it changed
+	if issue.Title == title {
+		issue.Title = ""
+	}
+	_, err = ss.sendToGroup(&plaintextMsg{
+		ConvoId:     groupId,
+		FromAddress: ss.myAddress,
+		Type:        issueUpdateMsg,
+		Content:     issue.Title,
+		Issue:       &issueInfo{Id: issue.Id, State: issue.State, Labels: issue.Labels, Assignees: issue.Assignees},
+	})
+	return err
+}
+
+// getIssues returns the issues of an issue tracker channel, filtered by state, label and assignee if they are not empty
+func (ss sasayakiState) getIssues(groupId, state, label, assignee string) ([]issueInfo, error) {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	if channel, err := storage.getGroupChannel(groupId); err != nil {
+		return nil, err
+	} else if channel != issuesChannel {
+		return nil, errors.New("ssyk: this group is not an issue tracker")
+	}
+	issues := []issueInfo{}
+	for _, issue := range storage.getIssues(groupId) {
+		if (state == "" || issue.State == state) && (label == "" || contains(issue.Labels, label)) &&
+			(assignee == "" || contains(issue.Assignees, assignee)) {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// checkIssueMessage makes sure that a message sent to an issue tracker channel is about an issue
+func (ss sasayakiState) checkIssueMessage(msg *plaintextMsg) error {
+	issue := msg.Issue
+	if issue == nil || len(issue.Id) != 32 {
+		return errors.New("ssyk: issue message is malformed")
+	}
+	_, err := storage.getIssue(msg.ConvoId, issue.Id)
+	switch msg.Type {
+	case issueOpenMsg:
+		if err == nil || storage.ConvoExist(issue.Id) {
+			return errors.New("ssyk: issue already exists")
+		}
+		if msg.Content == "" || issue.State != issueOpen {
+			return errors.New("ssyk: issue message is malformed")
+		}
+		return checkIssueLists(issue)
+	case issueUpdateMsg:
+		if err != nil {
+			return err
+		}
+		if issue.State != issueOpen && issue.State != issueClosed {
+			return errors.New("ssyk: issue message is malformed")
+		}
+		return checkIssueLists(issue)
+	default:
+		// comments
+		if err != nil {
+			return err
+		}
+		return ss.checkReference(issueComment(msg))
+	}
+}
+
+// checkIssueLists makes sure that the labels and the assignees of an issue can be stored
+func checkIssueLists(issue *issueInfo) error {
+	if len(issue.Labels) > issueMaxLabels || len(issue.Assignees) > issueMaxAssignees {
+		return errors.New("ssyk: too many labels or assignees")
+	}
+	for _, label := range issue.Labels {
+		if label == "" || len(label) > issueLabelMaxSize || strings.Contains(label, ",") {
+			return errors.New("ssyk: incorrect label")
+		}
+	}
+	for _, assignee := range issue.Assignees {
+		if !publicKeyRegexp.MatchString(assignee) {
+			return errors.New("ssyk: incorrect assignee")
+		}
+	}
+	return nil
+}
+
+// applyIssueMessage applies a message sent to an issue tracker channel.
+// the message must have been checked with checkIssueMessage first
+func (ss sasayakiState) applyIssueMessage(msg *plaintextMsg) {
+	issue := msg.Issue
+	switch msg.Type {
+	case issueOpenMsg:
+		// the issue is a conversation with the group
+		storage.createConvo(issue.Id, msg.ConvoId, msg.Content, nil, nil)
+		storage.createIssue(msg.ConvoId, msg.FromAddress, issue)
+	case issueUpdateMsg:
+		if msg.Content != "" {
+			storage.updateTitle(issue.Id, msg.ConvoId, msg.Content)
+		}
+		storage.updateIssue(msg.ConvoId, issue)
+	default:
+		ss.applyMessage(issueComment(msg))
+	}
+}
+
+// issueComment returns a copy of a comment, as it is stored in the conversation of its issue
+func issueComment(msg *plaintextMsg) *plaintextMsg {
+	comment := *msg
+	comment.ConvoId = msg.Issue.Id
+	return &comment
+}
+
+// contains returns true if the list contains the value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
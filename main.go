@@ -1,11 +1,14 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"time"
 
 	"golang.org/x/crypto/ssh/terminal"
 
@@ -15,33 +18,91 @@ import (
 var debug bool
 
 func main() {
+	// one-shot commands (see commands.go)
+	if len(os.Args) > 1 && isSubcommand(os.Args[1]) {
+		os.Exit(runSubcommand(os.Args[1:]))
+	}
+
 	// flags
 	CLIenabled := flag.Bool("cli", false, "run Sasayaki in the terminal")
 	// TODO: change to port 0?
 	addressUI := flag.String("port", "7473", "the address port of the web UI running on localhost (default 7474)")
 	debug := flag.Bool("debug", false, "debug")
+	rotateKey := flag.Bool("rotate_key", false, "replace your key with a new one, signed by the current one, and send it to your contacts (with -cli)")
+	exportBackupFile := flag.String("export-backup", "", "write an encrypted backup of your keys, contacts and history to this file")
+	importBackupFile := flag.String("import-backup", "", "restore your keys, contacts and history from this encrypted backup")
+	flag.Var(profileFlag{}, "profile", "the profile to use, each has its own keys, contacts and Hub (default \"default\")")
 	flag.Parse()
 	debug = *debug
 
-	if *CLIenabled {
-		fmt.Println("Welcome to Sasayaki.")
-		fmt.Println("In order to encrypt information at rest on your computer, please enter a passphrase:")
+	// backups (see backup.go)
+	if *exportBackupFile != "" || *importBackupFile != "" {
+		fmt.Println("Please enter the passphrase of the backup:")
 		passphrase, err := terminal.ReadPassword(int(os.Stdin.Fd()))
 		if err != nil {
 			fmt.Println(err)
 			return
 		}
+		if *exportBackupFile != "" {
+			// a typo would make the backup impossible to restore
+			fmt.Println("Please enter it again:")
+			again, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if string(again) != string(passphrase) {
+				fmt.Println("ssyk: the passphrases don't match")
+				return
+			}
+			if err := exportBackup(*exportBackupFile, string(passphrase)); err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println("ssyk: backup written to", *exportBackupFile)
+			return
+		}
+		createdAt, err := importBackup(*importBackupFile, string(passphrase))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println("ssyk: backup of", createdAt.Format(time.RFC1123), "restored")
+		fmt.Println("warning: conversations are ratcheted with every message. Messages sent or received after this backup",
+			"was made can't be decrypted with the restored keys, and these conversations (or contacts) have to be created again.")
+		return
+	}
 
-		// TODO: ideally, we would use the Hub as an OPRF here, so that our passphrase is not too weak
-		// (see PASS, OPAQUE, SPHINX, MAKWA, etc.)
-		// + rate-limit on the server-side
+	if *CLIenabled {
+		fmt.Println("Welcome to Sasayaki.")
 
-		// init ~/.sasayaki folder and fetch config + keypair
+		// the unlock agent holds our keypair if it is running (see agent.go), but changing our key needs the passphrase
 		var config *configuration
-		config, ssyk.keyPair, err = initSasayaki(string(passphrase))
+		var passphrase []byte
+		var err error
+		if *rotateKey {
+			err = errors.New("ssyk: the passphrase is needed to change our key")
+		} else {
+			config, ssyk.keyPair, err = initSasayakiFromAgent()
+		}
 		if err != nil {
-			fmt.Println(err)
-			return
+			fmt.Println("In order to encrypt information at rest on your computer, please enter a passphrase:")
+			passphrase, err = terminal.ReadPassword(int(os.Stdin.Fd()))
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+
+			// TODO: ideally, we would use the Hub as an OPRF here, so that our passphrase is not too weak
+			// (see PASS, OPAQUE, SPHINX, MAKWA, etc.)
+			// + rate-limit on the server-side
+
+			// init ~/.sasayaki folder and fetch config + keypair
+			config, ssyk.keyPair, err = initSasayaki(string(passphrase))
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
 		}
 
 		// if we don't have a hub address, we ask
@@ -77,6 +138,22 @@ func main() {
 		// init sasayakiState
 		initSasayakiState(keyPair, config)
 
+		// rotate our key if asked
+		if *rotateKey {
+			if err := ssyk.rotateKey(string(passphrase)); err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println("this is your new public key:", ssyk.myAddress)
+			fmt.Println("your previous key has been archived in", filepath.Join(sasayakiFolder(), "keys", "archive"))
+		}
+
+		// full-screen client (see tui.go)
+		if err := runTerminalUI(&ssyk); err != nil {
+			fmt.Println(err)
+			return
+		}
+
 	} else {
 
 		// set address for the web UI
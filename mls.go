@@ -0,0 +1,739 @@
+//
+// MLS Groups
+// ==========
+//
+// A group engine based on MLS (RFC 9420) for large groups. The members share a ratchet tree (see treekem.go),
+// so that adding or removing members is a single commit of logarithmic size, encrypted once for the whole
+// group, instead of a round of control messages between every pair of members.
+//
+// * key packages: every client publishes a few key packages on the Hub, so that it can be added to groups while it is offline
+// * welcomes: a new member receives the tree and the secrets of the epoch, encrypted to one of its key packages
+// * handshakes: proposals and commits are encrypted with the handshake key of the epoch, signed by their sender, and sent to every member
+// * application messages: encrypted with the chain key of the sender's leaf (ratcheted after each message) and signed by the sender
+//
+// All of these are routed by the Hub like any other message (see the `MessageKind`), and signatures are
+// XEdDSA signatures with the Disco key of the members.
+//
+// This is MLS-like, but not interoperable with RFC 9420:
+//
+// * secrets are derived with Strobe, and the init key of a key package is also the leaf key of the new member
+// * proposals are always sent by value in commits, and commits always contain a path
+// * members can only propose to leave the group, the member with the smallest leaf commits these proposals
+// * the Hub does not order handshakes: if two members commit during the same epoch, the group forks
+// * application messages from a previous epoch can't be decrypted
+//
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"log"
+
+	"github.com/golang/protobuf/proto"
+	disco "github.com/mimoo/disco/libdisco"
+	s "github.com/mimoo/sasayaki/serialization"
+	"github.com/mimoo/sasayaki/xeddsa"
+)
+
+const (
+	mlsKeyPackages        = 5    // key packages published when we start
+	mlsMaxKeyPackages     = 10   // key packages we keep per Hub (the Hub keeps as many)
+	mlsMaxSkippedMessages = 1000 // how far a sender chain can be ratcheted to decrypt a message
+	mlsMessageMaxSize     = 60000
+)
+
+// mlsGroups is the MLS group engine
+type mlsGroups struct {
+	ss sasayakiState
+}
+
+//
+// Key Packages
+// ============
+//
+
+// publishKeyPackages publishes a number of fresh key packages on each of our Hubs, keeping their private keys.
+// A key package is only used once, so each Hub gets its own. storage.queryMutex must be held
+func (ss sasayakiState) publishKeyPackages(count int) error {
+	all := hubs.each()
+	return hubs.publish(func(current *hubState) error {
+		for i := 0; i < count; i++ {
+			keyPair := disco.GenerateKeypair(nil)
+			keyPackage := &s.MLSKeyPackage{
+				Identity: e2e.keyPair.PublicKey[:],
+				InitKey:  keyPair.PublicKey[:],
+			}
+			keyPackage.Signature = xeddsa.Sign(e2e.keyPair.PrivateKey, keyPackageContent(keyPackage))
+			content, err := proto.Marshal(keyPackage)
+			if err != nil {
+				panic(err)
+			}
+			storage.storeKeyPackage(hex.EncodeToString(keyPair.PublicKey[:]), keyPair.PrivateKey[:], mlsMaxKeyPackages*len(all))
+			if err := current.publishKeyPackage(content); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// initKeyPackages publishes key packages when we start, so that we can be added to MLS groups while offline
+func (ss sasayakiState) initKeyPackages() error {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	return ss.publishKeyPackages(mlsKeyPackages)
+}
+
+// keyPackageContent returns what is signed in a key package
+func keyPackageContent(keyPackage *s.MLSKeyPackage) []byte {
+	content := []byte("MLSKeyPackage")
+	content = append(content, keyPackage.GetIdentity()...)
+	return append(content, keyPackage.GetInitKey()...)
+}
+
+// fetchKeyPackage obtains a key package of someone from its Hub, and verifies it. storage.queryMutex must be held
+func fetchKeyPackage(member string) (*s.MLSKeyPackage, error) {
+	if _, peer := splitAddress(member); peer != "" {
+		return nil, errors.New("ssyk: the key packages of the clients of a peer Hub can't be fetched, they can only be in sender keys groups")
+	}
+	content, err := hubs.of(member).getKeyPackage(member)
+	if err != nil {
+		return nil, err
+	}
+	keyPackage := &s.MLSKeyPackage{}
+	if err := proto.Unmarshal(content, keyPackage); err != nil {
+		return nil, errors.New("ssyk: key package received is malformed")
+	}
+	if hex.EncodeToString(keyPackage.GetIdentity()) != member || !verifyKeyPackage(keyPackage) {
+		return nil, errors.New("ssyk: key package received is invalid")
+	}
+	return keyPackage, nil
+}
+
+func verifyKeyPackage(keyPackage *s.MLSKeyPackage) bool {
+	if len(keyPackage.GetIdentity()) != 32 || len(keyPackage.GetInitKey()) != 32 {
+		return false
+	}
+	return xeddsa.Verify(keyPackage.GetIdentity(), keyPackageContent(keyPackage), keyPackage.GetSignature())
+}
+
+//
+// Group Engine
+// ============
+//
+
+// create creates a group where we are alone, then adds all the members with a single commit
+func (engine mlsGroups) create(groupId, title, channel string, members []string) error {
+	// fetch the key packages first
+	var proposals []*s.MLSProposal
+	for _, member := range members {
+		keyPackage, err := fetchKeyPackage(member)
+		if err != nil {
+			return err
+		}
+		proposals = append(proposals, &s.MLSProposal{ProposalType: s.MLSProposal_Add, KeyPackage: keyPackage})
+	}
+	// epoch 0: just us
+	leafKeyPair := disco.GenerateKeypair(nil)
+	epochSecret := make([]byte, 32)
+	if _, err := rand.Read(epochSecret); err != nil {
+		panic(err)
+	}
+	state := &s.MLSGroupState{
+		GroupId:     groupId,
+		Tree:        []*s.MLSNode{{PublicKey: leafKeyPair.PublicKey[:], Identity: e2e.keyPair.PublicKey[:]}},
+		PrivateKeys: [][]byte{leafKeyPair.PrivateKey[:]},
+		EpochSecret: epochSecret,
+	}
+	resetSenderChains(state)
+	storage.createGroup(groupId, title, mlsEngine, channel, 0, nil)
+	saveMLSState(state)
+	// epoch 1: everyone
+	if err := engine.commit(state, title, proposals); err != nil {
+		storage.leaveGroup(groupId)
+		return err
+	}
+	return nil
+}
+
+// send encrypts a message with the chain key of our leaf, and sends it to all the members of the group.
+// The content is [epoch(8), leaf(4), generation(4), ciphertext(...), signature(64)]
+func (engine mlsGroups) send(msg *plaintextMsg) error {
+	// check for arbitrary 1000 bytes of room for headers and protobuff structure
+	if len(msg.Content) > 65535-1000 {
+		return errors.New("ssyk: message to send is too large")
+	}
+	state, err := loadMLSState(msg.ConvoId)
+	if err != nil {
+		return err
+	}
+	// encrypt
+	generation := state.SenderGenerations[state.MyLeaf]
+	key, err := ratchetSenderChain(state, state.MyLeaf, generation)
+	if err != nil {
+		return err
+	}
+	serializedPayload, err := serializePayload(msg)
+	if err != nil {
+		return err
+	}
+	content := uint64ToBytes(state.Epoch)
+	content = append(content, uint32ToBytes(state.MyLeaf)...)
+	content = append(content, uint32ToBytes(generation)...)
+	content = append(content, disco.Encrypt(key, serializedPayload)...)
+	// sign
+	content = append(content, xeddsa.Sign(e2e.keyPair.PrivateKey, applicationContent(msg.ConvoId, content))...)
+	saveMLSState(state)
+	// send to every member
+	return sendToMLSGroup(state, content, s.MessageKind_Group)
+}
+
+// receive verifies and decrypts a message sent to the group
+func (engine mlsGroups) receive(encryptedMsg *s.ResponseMessage) (*plaintextMsg, error) {
+	state, err := loadMLSState(encryptedMsg.GetConvoId())
+	if err != nil {
+		return nil, err
+	}
+	// parse [epoch(8), leaf(4), generation(4), ciphertext(...), signature(64)]
+	content := encryptedMsg.GetContent()
+	if len(content) < 16+64 {
+		return nil, errors.New("ssyk: message received is incorrectly formed")
+	}
+	epoch := binary.BigEndian.Uint64(content[0:8])
+	leaf := binary.BigEndian.Uint32(content[8:12])
+	generation := binary.BigEndian.Uint32(content[12:16])
+	signed, signature := content[:len(content)-64], content[len(content)-64:]
+	if epoch != state.Epoch {
+		return nil, errors.New("ssyk: group message received for another epoch")
+	}
+	// verify the sender
+	if leaf >= leafCount(state) || isBlank(state, 2*leaf) || leaf == state.MyLeaf {
+		return nil, errors.New("ssyk: group message received from an unknown leaf")
+	}
+	identity := state.Tree[2*leaf].GetIdentity()
+	if hex.EncodeToString(identity) != encryptedMsg.GetFromAddress() {
+		return nil, errors.New("ssyk: group message received from someone else than the member")
+	}
+	if !xeddsa.Verify(identity, applicationContent(state.GroupId, signed), signature) {
+		return nil, errors.New("ssyk: group message received has an invalid signature")
+	}
+	// decrypt
+	key, err := ratchetSenderChain(state, leaf, generation)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := disco.Decrypt(key, signed[16:])
+	if err != nil {
+		return nil, errors.New("ssyk: impossible to decrypt incoming group message")
+	}
+	saveMLSState(state)
+	// parse the payload
+	msg, err := parsePayload(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	msg.ConvoId = encryptedMsg.GetConvoId()
+	msg.FromAddress = encryptedMsg.GetFromAddress()
+	msg.ToAddress = engine.ss.myAddress
+	//
+	return msg, nil
+}
+
+// add adds someone to the group with a commit, whether it is a contact or not
+func (engine mlsGroups) add(groupId, newMember string) error {
+	state, err := loadMLSState(groupId)
+	if err != nil {
+		return err
+	}
+	identity, err := hex.DecodeString(newMember)
+	if err != nil || len(identity) != 32 {
+		return errors.New("ssyk: incorrect member address")
+	}
+	if _, ok := findLeaf(state, identity); ok {
+		return errors.New("ssyk: already a member of the group")
+	}
+	keyPackage, err := fetchKeyPackage(newMember)
+	if err != nil {
+		return err
+	}
+	title, _, _, err := storage.getGroup(groupId)
+	if err != nil {
+		return err
+	}
+	return engine.commit(state, title, []*s.MLSProposal{{ProposalType: s.MLSProposal_Add, KeyPackage: keyPackage}})
+}
+
+// remove removes a member from the group with a commit. As we can't commit our own removal, we leave
+// a group by proposing our removal to the others.
+func (engine mlsGroups) remove(groupId, oldMember string) error {
+	state, err := loadMLSState(groupId)
+	if err != nil {
+		return err
+	}
+	// are we leaving?
+	if oldMember == engine.ss.myAddress {
+		handshake := &s.MLSHandshake{
+			HandshakeType: s.MLSHandshake_Proposal,
+			GroupId:       groupId,
+			Epoch:         state.Epoch,
+			Sender:        state.MyLeaf,
+			Proposal:      &s.MLSProposal{ProposalType: s.MLSProposal_Remove, Removed: state.MyLeaf},
+		}
+		if err := sendHandshake(state, handshake); err != nil {
+			return err
+		}
+		storage.leaveGroup(groupId)
+		return nil
+	}
+	// remove someone else
+	identity, err := hex.DecodeString(oldMember)
+	if err != nil {
+		return errors.New("ssyk: incorrect member address")
+	}
+	leaf, ok := findLeaf(state, identity)
+	if !ok {
+		return errors.New("ssyk: not a member of the group")
+	}
+	title, _, _, err := storage.getGroup(groupId)
+	if err != nil {
+		return err
+	}
+	return engine.commit(state, title, []*s.MLSProposal{{ProposalType: s.MLSProposal_Remove, Removed: leaf}})
+}
+
+//
+// Handshakes
+// ==========
+//
+
+// commit applies proposals (ours and the pending ones) with a fresh path, sends the commit to the members
+// of the current epoch and welcomes the new members to the next epoch
+func (engine mlsGroups) commit(state *s.MLSGroupState, title string, proposals []*s.MLSProposal) error {
+	for _, pending := range state.PendingProposals {
+		proposals = append(proposals, pending.GetProposal())
+	}
+	// create the next epoch
+	next := proto.Clone(state).(*s.MLSGroupState)
+	added, err := applyProposals(next, proposals, state.MyLeaf)
+	if err != nil {
+		return err
+	}
+	path, commitSecret, pathSecrets := createUpdatePath(next, added)
+	commit := &s.MLSCommit{Proposals: proposals, Path: path}
+	serializedCommit, err := proto.Marshal(commit)
+	if err != nil {
+		panic(err)
+	}
+	nextEpoch(next, commitSecret)
+	// send the commit to the current members (including the removed ones, so that they know)
+	handshake := &s.MLSHandshake{
+		HandshakeType:   s.MLSHandshake_Commit,
+		GroupId:         state.GroupId,
+		Epoch:           state.Epoch,
+		Sender:          state.MyLeaf,
+		Commit:          commit,
+		ConfirmationTag: confirmationTag(next, serializedCommit),
+	}
+	if err := sendHandshake(state, handshake); err != nil {
+		return err
+	}
+	saveMLSState(next)
+	syncMLSMembers(next)
+	// welcome the new members
+	for node := range added {
+		if err := engine.welcome(next, title, node/2, pathSecrets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receiveHandshake handles a proposal or a commit sent to the group
+func (engine mlsGroups) receiveHandshake(encryptedMsg *s.ResponseMessage) error {
+	groupId := encryptedMsg.GetConvoId()
+	if engineName, err := storage.getGroupEngine(groupId); err != nil || engineName != mlsEngine {
+		return errors.New("ssyk: handshake received for an unknown group")
+	}
+	state, err := loadMLSState(groupId)
+	if err != nil {
+		return err
+	}
+	// parse [epoch(8), ciphertext(...)]
+	content := encryptedMsg.GetContent()
+	if len(content) < 8 {
+		return errors.New("ssyk: handshake received is incorrectly formed")
+	}
+	if binary.BigEndian.Uint64(content[:8]) != state.Epoch {
+		return errors.New("ssyk: handshake received for another epoch")
+	}
+	serialized, err := disco.Decrypt(handshakeKey(state), content[8:])
+	if err != nil {
+		return errors.New("ssyk: impossible to decrypt incoming handshake")
+	}
+	handshake := &s.MLSHandshake{}
+	if err := proto.Unmarshal(serialized, handshake); err != nil {
+		return errors.New("ssyk: handshake received is incorrectly formed")
+	}
+	// verify the sender
+	sender := handshake.GetSender()
+	if handshake.GetGroupId() != groupId || handshake.GetEpoch() != state.Epoch ||
+		sender >= leafCount(state) || isBlank(state, 2*sender) || sender == state.MyLeaf {
+		return errors.New("ssyk: handshake received is incorrectly formed")
+	}
+	identity := state.Tree[2*sender].GetIdentity()
+	if hex.EncodeToString(identity) != encryptedMsg.GetFromAddress() {
+		return errors.New("ssyk: handshake received from someone else than the member")
+	}
+	signature := handshake.Signature
+	handshake.Signature = nil
+	if !xeddsa.Verify(identity, handshakeContent(handshake), signature) {
+		return errors.New("ssyk: handshake received has an invalid signature")
+	}
+
+	switch handshake.GetHandshakeType() {
+	case s.MLSHandshake_Proposal:
+		// members can only propose to leave
+		proposal := handshake.GetProposal()
+		if proposal == nil || proposal.GetProposalType() != s.MLSProposal_Remove || proposal.GetRemoved() != sender {
+			return errors.New("ssyk: proposal received is not supported")
+		}
+		for _, pending := range state.PendingProposals {
+			if pending.GetSender() == sender {
+				return nil
+			}
+		}
+		state.PendingProposals = append(state.PendingProposals, handshake)
+		saveMLSState(state)
+		// only one member commits, to avoid forking the group
+		if !isDesignatedCommitter(state) {
+			return nil
+		}
+		title, _, _, err := storage.getGroup(groupId)
+		if err != nil {
+			return err
+		}
+		return engine.commit(state, title, nil)
+	case s.MLSHandshake_Commit:
+		return engine.applyCommit(state, handshake)
+	}
+	return errors.New("ssyk: unknown handshake")
+}
+
+// applyCommit moves our state to the next epoch with a commit
+func (engine mlsGroups) applyCommit(state *s.MLSGroupState, handshake *s.MLSHandshake) error {
+	commit := handshake.GetCommit()
+	if commit == nil {
+		return errors.New("ssyk: commit received is incorrectly formed")
+	}
+	// are we removed?
+	for _, proposal := range commit.GetProposals() {
+		if proposal.GetProposalType() == s.MLSProposal_Remove && proposal.GetRemoved() == state.MyLeaf {
+			storage.leaveGroup(state.GroupId)
+			return nil
+		}
+	}
+	// apply the proposals and the path
+	next := proto.Clone(state).(*s.MLSGroupState)
+	added, err := applyProposals(next, commit.GetProposals(), handshake.GetSender())
+	if err != nil {
+		return err
+	}
+	commitSecret, err := applyUpdatePath(next, handshake.GetSender(), commit.GetPath(), added)
+	if err != nil {
+		return err
+	}
+	serializedCommit, err := proto.Marshal(commit)
+	if err != nil {
+		return err
+	}
+	nextEpoch(next, commitSecret)
+	// make sure we agree with the committer on the new epoch
+	if subtle.ConstantTimeCompare(confirmationTag(next, serializedCommit), handshake.GetConfirmationTag()) != 1 {
+		return errors.New("ssyk: commit received leads to a different state than the committer's")
+	}
+	saveMLSState(next)
+	syncMLSMembers(next)
+	return nil
+}
+
+// applyProposals applies removals then additions to the tree, and returns the nodes of the new members
+func applyProposals(state *s.MLSGroupState, proposals []*s.MLSProposal, committer uint32) (map[uint32]bool, error) {
+	for _, proposal := range proposals {
+		if proposal.GetProposalType() != s.MLSProposal_Remove {
+			continue
+		}
+		removed := proposal.GetRemoved()
+		if removed >= leafCount(state) || isBlank(state, 2*removed) || removed == committer {
+			return nil, errors.New("ssyk: commit removes an incorrect member")
+		}
+		removeLeaf(state, removed)
+	}
+	added := make(map[uint32]bool)
+	for _, proposal := range proposals {
+		if proposal.GetProposalType() != s.MLSProposal_Add {
+			continue
+		}
+		keyPackage := proposal.GetKeyPackage()
+		if keyPackage == nil || !verifyKeyPackage(keyPackage) {
+			return nil, errors.New("ssyk: commit adds a member with an invalid key package")
+		}
+		if _, ok := findLeaf(state, keyPackage.GetIdentity()); ok {
+			return nil, errors.New("ssyk: commit adds someone who is already a member")
+		}
+		leaf := addLeaf(state, &s.MLSNode{PublicKey: keyPackage.GetInitKey(), Identity: keyPackage.GetIdentity()})
+		added[2*leaf] = true
+	}
+	return added, nil
+}
+
+// isDesignatedCommitter returns true if we have the smallest leaf among the members who are not leaving
+func isDesignatedCommitter(state *s.MLSGroupState) bool {
+	leaving := make(map[uint32]bool)
+	for _, pending := range state.PendingProposals {
+		leaving[pending.GetProposal().GetRemoved()] = true
+	}
+	for leaf := uint32(0); leaf < leafCount(state); leaf++ {
+		if !isBlank(state, 2*leaf) && !leaving[leaf] {
+			return leaf == state.MyLeaf
+		}
+	}
+	return false
+}
+
+// handshakeContent returns what is signed in a handshake (the signature must be removed first)
+func handshakeContent(handshake *s.MLSHandshake) []byte {
+	serialized, err := proto.Marshal(handshake)
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte("MLSHandshake"), serialized...)
+}
+
+// sendHandshake signs a handshake, encrypts it with the handshake key of the epoch and sends it to all the
+// members of the group. The content is [epoch(8), ciphertext(...)]
+func sendHandshake(state *s.MLSGroupState, handshake *s.MLSHandshake) error {
+	handshake.Signature = xeddsa.Sign(e2e.keyPair.PrivateKey, handshakeContent(handshake))
+	serialized, err := proto.Marshal(handshake)
+	if err != nil {
+		panic(err)
+	}
+	content := append(uint64ToBytes(state.Epoch), disco.Encrypt(handshakeKey(state), serialized)...)
+	if len(content) > mlsMessageMaxSize {
+		return errors.New("ssyk: handshake is too large, add fewer members at once")
+	}
+	return sendToMLSGroup(state, content, s.MessageKind_Handshake)
+}
+
+//
+// Welcomes
+// ========
+//
+
+// welcomeContent returns what is signed in a welcome (the signature must be removed first)
+func welcomeContent(welcome *s.MLSWelcome) []byte {
+	serialized, err := proto.Marshal(welcome)
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte("MLSWelcome"), serialized...)
+}
+
+// welcome sends the state of the new epoch to a new member, encrypted to the init key of its key package.
+// The content is [initKey(32), ephemeral(32), ciphertext(...)]
+func (engine mlsGroups) welcome(state *s.MLSGroupState, title string, leaf uint32, pathSecrets map[uint32][]byte) error {
+	// the new member can derive the keys above our lowest common ancestor
+	var pathSecret []byte
+	path, _ := filteredDirectPath(state, state.MyLeaf)
+	for _, x := range path {
+		if isAncestor(x, 2*leaf) {
+			pathSecret = pathSecrets[x]
+			break
+		}
+	}
+	channel, err := storage.getGroupChannel(state.GroupId)
+	if err != nil {
+		return err
+	}
+	welcome := &s.MLSWelcome{
+		GroupId:     state.GroupId,
+		Title:       title,
+		Channel:     channel,
+		Epoch:       state.Epoch,
+		Tree:        state.Tree,
+		Leaf:        leaf,
+		Sender:      state.MyLeaf,
+		PathSecret:  pathSecret,
+		EpochSecret: state.EpochSecret,
+	}
+	welcome.Signature = xeddsa.Sign(e2e.keyPair.PrivateKey, welcomeContent(welcome))
+	serialized, err := proto.Marshal(welcome)
+	if err != nil {
+		panic(err)
+	}
+	// encrypt it to the key package
+	initKey := state.Tree[2*leaf].GetPublicKey()
+	content := append(append([]byte{}, initKey...), sealToPublicKey(initKey, serialized, []byte(state.GroupId))...)
+	if len(content) > mlsMessageMaxSize {
+		return errors.New("ssyk: welcome is too large, the group has too many members")
+	}
+	return hubs.sendMessage(&s.Request_Message{
+		ToAddress: hex.EncodeToString(state.Tree[2*leaf].GetIdentity()),
+		ConvoId:   state.GroupId,
+		Content:   content,
+		Kind:      s.MessageKind_Welcome,
+	})
+}
+
+// receiveWelcome joins a group we have been added to by one of our contacts
+func (engine mlsGroups) receiveWelcome(encryptedMsg *s.ResponseMessage) error {
+	groupId := encryptedMsg.GetConvoId()
+	if _, err := storage.getGroupEngine(groupId); err == nil {
+		return errors.New("ssyk: welcome received for a group we are already part of")
+	}
+	// only our contacts can add us to groups
+	if _, status := storage.getStateContact(encryptedMsg.GetFromAddress()); status != contactAdded {
+		return errors.New("ssyk: welcome received from someone who is not a contact")
+	}
+	// decrypt it with the key package it was encrypted to
+	content := encryptedMsg.GetContent()
+	if len(content) < 64 {
+		return errors.New("ssyk: welcome received is incorrectly formed")
+	}
+	initKey := hex.EncodeToString(content[:32])
+	privateKey, err := storage.getKeyPackage(initKey)
+	if err != nil {
+		return err
+	}
+	serialized, err := openWithPrivateKey(privateKey, content[32:], []byte(groupId))
+	if err != nil {
+		return errors.New("ssyk: impossible to decrypt incoming welcome")
+	}
+	welcome := &s.MLSWelcome{}
+	if err := proto.Unmarshal(serialized, welcome); err != nil {
+		return errors.New("ssyk: welcome received is incorrectly formed")
+	}
+	// check the tree
+	tree := welcome.GetTree()
+	leaves := uint32(len(tree)+1) / 2
+	if len(tree)%2 != 1 || leaves&(leaves-1) != 0 || welcome.GetGroupId() != groupId ||
+		welcome.GetLeaf() >= leaves || welcome.GetSender() >= leaves || len(welcome.GetEpochSecret()) != 32 {
+		return errors.New("ssyk: welcome received is incorrectly formed")
+	}
+	for _, node := range tree {
+		if (len(node.GetPublicKey()) != 0 && len(node.GetPublicKey()) != 32) ||
+			(len(node.GetIdentity()) != 0 && len(node.GetIdentity()) != 32) {
+			return errors.New("ssyk: welcome received is incorrectly formed")
+		}
+	}
+	me, sender := tree[2*welcome.GetLeaf()], tree[2*welcome.GetSender()]
+	if hex.EncodeToString(me.GetPublicKey()) != initKey || hex.EncodeToString(me.GetIdentity()) != engine.ss.myAddress {
+		return errors.New("ssyk: welcome received for another member")
+	}
+	if hex.EncodeToString(sender.GetIdentity()) != encryptedMsg.GetFromAddress() {
+		return errors.New("ssyk: welcome received from someone else than the member")
+	}
+	signature := welcome.Signature
+	welcome.Signature = nil
+	if !xeddsa.Verify(sender.GetIdentity(), welcomeContent(welcome), signature) {
+		return errors.New("ssyk: welcome received has an invalid signature")
+	}
+	if welcome.GetChannel() != chatChannel && welcome.GetChannel() != issuesChannel {
+		return errors.New("ssyk: welcome received for an unknown type of channel")
+	}
+	// create our state
+	state := &s.MLSGroupState{
+		GroupId:     groupId,
+		Epoch:       welcome.GetEpoch(),
+		Tree:        tree,
+		PrivateKeys: make([][]byte, len(tree)),
+		MyLeaf:      welcome.GetLeaf(),
+		EpochSecret: welcome.GetEpochSecret(),
+	}
+	state.PrivateKeys[2*state.MyLeaf] = privateKey
+	if err := applyWelcomePath(state, welcome.GetSender(), welcome.GetPathSecret()); err != nil {
+		return err
+	}
+	resetSenderChains(state)
+	// join the group
+	storage.createGroup(groupId, welcome.GetTitle(), mlsEngine, welcome.GetChannel(), 0, nil)
+	saveMLSState(state)
+	syncMLSMembers(state)
+	// the key package can't be used again, replace it
+	storage.deleteKeyPackage(initKey)
+	if err := engine.ss.publishKeyPackages(1); err != nil {
+		log.Println("ssyk: couldn't publish a new key package:", err)
+	}
+	return nil
+}
+
+//
+// Helpers
+// =======
+//
+
+// applicationContent returns what is signed in an application message
+func applicationContent(groupId string, content []byte) []byte {
+	return append([]byte("MLSApplication"+groupId), content...)
+}
+
+// sendToMLSGroup sends a copy of the same content to all the members of the group (except us)
+func sendToMLSGroup(state *s.MLSGroupState, content []byte, kind s.MessageKind) error {
+	for leaf := uint32(0); leaf < leafCount(state); leaf++ {
+		if leaf == state.MyLeaf || isBlank(state, 2*leaf) {
+			continue
+		}
+		encryptedMessage := &s.Request_Message{
+			ToAddress: hex.EncodeToString(state.Tree[2*leaf].GetIdentity()),
+			ConvoId:   state.GroupId,
+			Content:   content,
+			Kind:      kind,
+		}
+		if err := hubs.sendMessage(encryptedMessage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncMLSMembers updates the members of the group in the database with the members of the tree
+func syncMLSMembers(state *s.MLSGroupState) {
+	members := make(map[string]bool)
+	for leaf := uint32(0); leaf < leafCount(state); leaf++ {
+		if leaf != state.MyLeaf && !isBlank(state, 2*leaf) {
+			members[hex.EncodeToString(state.Tree[2*leaf].GetIdentity())] = true
+		}
+	}
+	for _, member := range storage.getGroupMembers(state.GroupId) {
+		if !members[member.publicKey] {
+			storage.removeGroupMember(state.GroupId, member.publicKey)
+		}
+	}
+	for member := range members {
+		storage.addGroupMember(state.GroupId, member)
+	}
+}
+
+// loadMLSState retrieves our state in an MLS group
+func loadMLSState(groupId string) (*s.MLSGroupState, error) {
+	serialized, err := storage.getMLSState(groupId)
+	if err != nil {
+		return nil, err
+	}
+	state := &s.MLSGroupState{}
+	if err := proto.Unmarshal(serialized, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// saveMLSState stores our state in an MLS group
+func saveMLSState(state *s.MLSGroupState) {
+	serialized, err := proto.Marshal(state)
+	if err != nil {
+		panic(err)
+	}
+	storage.updateMLSState(state.GroupId, serialized)
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+	"github.com/mimoo/sasayaki/xeddsa"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+func TestMLSTreeMath(t *testing.T) {
+	if level(0) != 0 || level(1) != 1 || level(3) != 2 || level(7) != 3 || level(11) != 2 {
+		t.Fatal("incorrect levels")
+	}
+	if root(1) != 0 || root(8) != 7 || left(7) != 3 || right(7) != 11 || left(1) != 0 || right(1) != 2 {
+		t.Fatal("incorrect children")
+	}
+	if parent(0) != 1 || parent(2) != 1 || parent(1) != 3 || parent(5) != 3 || parent(3) != 7 || parent(11) != 7 {
+		t.Fatal("incorrect parents")
+	}
+	if sibling(0) != 2 || sibling(3) != 11 || sibling(13) != 9 {
+		t.Fatal("incorrect siblings")
+	}
+	if fmt.Sprint(directPath(0, 8)) != "[1 3 7]" || fmt.Sprint(directPath(14, 8)) != "[13 11 7]" || len(directPath(0, 1)) != 0 {
+		t.Fatal("incorrect direct paths")
+	}
+	// in a tree of 16 leaves, every node is a child of its parent, and covered by its direct path only
+	for x := uint32(0); x < 31; x++ {
+		if x != root(16) {
+			if p := parent(x); left(p) != x && right(p) != x {
+				t.Fatalf("%d is not a child of its parent %d", x, p)
+			}
+			if sibling(sibling(x)) != x {
+				t.Fatalf("%d is not the sibling of its sibling", x)
+			}
+		}
+		onPath := map[uint32]bool{x: true}
+		for _, a := range directPath(x, 16) {
+			onPath[a] = true
+		}
+		for a := uint32(0); a < 31; a++ {
+			if isAncestor(a, x) != onPath[a] {
+				t.Fatalf("isAncestor(%d, %d) is %v", a, x, isAncestor(a, x))
+			}
+		}
+	}
+}
+
+// testLeaf returns a leaf node with a new key, and the identity of someone
+func testLeaf(identity byte) *s.MLSNode {
+	keyPair := disco.GenerateKeypair(nil)
+	return &s.MLSNode{PublicKey: keyPair.PublicKey[:], Identity: []byte{identity}}
+}
+
+func TestMLSTreeAddRemove(t *testing.T) {
+	state := &s.MLSGroupState{Tree: []*s.MLSNode{testLeaf(0)}, PrivateKeys: [][]byte{nil}}
+	// the tree doubles when it is full
+	for i, expected := range []uint32{2, 4, 4, 8} {
+		if leaf := addLeaf(state, testLeaf(byte(i+1))); leaf != uint32(i+1) || leafCount(state) != expected {
+			t.Fatalf("member %d added at leaf %d of %d leaves", i+1, leaf, leafCount(state))
+		}
+		if len(state.PrivateKeys) != len(state.Tree) {
+			t.Fatal("the private keys don't follow the tree")
+		}
+	}
+	// the direct path of a new leaf is blanked
+	state.Tree[5], state.Tree[7] = testLeaf(0), testLeaf(0)
+	addLeaf(state, testLeaf(5))
+	if !isBlank(state, 7) || isBlank(state, 5) {
+		t.Fatal("the direct path of the new leaf wasn't blanked")
+	}
+	removeLeaf(state, 5)
+
+	// the tree is truncated while its right half is empty
+	removeLeaf(state, 4)
+	if leafCount(state) != 4 || len(state.PrivateKeys) != 7 {
+		t.Fatalf("the tree wasn't truncated, %d leaves", leafCount(state))
+	}
+	removeLeaf(state, 2)
+	if leafCount(state) != 4 || !isBlank(state, 4) || !isBlank(state, 5) || !isBlank(state, 3) {
+		t.Fatal("the removed leaf or its direct path isn't blank")
+	}
+	if _, ok := findLeaf(state, []byte{3}); !ok {
+		t.Fatal("a member was lost")
+	}
+	// a new member takes the leftmost blank leaf
+	if leaf := addLeaf(state, testLeaf(6)); leaf != 2 {
+		t.Fatalf("the new member was added at leaf %d", leaf)
+	}
+	removeLeaf(state, 3)
+	removeLeaf(state, 2)
+	if leafCount(state) != 2 || len(state.Tree) != 3 {
+		t.Fatalf("the tree wasn't truncated, %d leaves", leafCount(state))
+	}
+	if _, ok := findLeaf(state, []byte{6}); ok {
+		t.Fatal("a removed member was found")
+	}
+}
+
+func TestMLSFilteredDirectPath(t *testing.T) {
+	state := &s.MLSGroupState{Tree: []*s.MLSNode{testLeaf(0)}, PrivateKeys: [][]byte{nil}}
+	addLeaf(state, testLeaf(1))
+	addLeaf(state, testLeaf(2))
+	// leaf 3 is blank: the parent of leaf 2 is skipped
+	path, copath := filteredDirectPath(state, 2)
+	if fmt.Sprint(path) != "[3]" || fmt.Sprint(copath) != "[1]" {
+		t.Fatalf("filtered direct path of leaf 2: %v %v", path, copath)
+	}
+	path, copath = filteredDirectPath(state, 0)
+	if fmt.Sprint(path) != "[1 3]" || fmt.Sprint(copath) != "[2 5]" {
+		t.Fatalf("filtered direct path of leaf 0: %v %v", path, copath)
+	}
+	// the resolution of a blank node are the non-blank nodes below it
+	if fmt.Sprint(resolution(state, 1, nil)) != "[0 2]" || fmt.Sprint(resolution(state, 1, map[uint32]bool{2: true})) != "[0]" {
+		t.Fatal("incorrect resolution")
+	}
+}
+
+// mlsPeer is a member of an MLS group, with its identity, its key package and its state
+type mlsPeer struct {
+	identity *disco.KeyPair
+	initKey  *disco.KeyPair
+	state    *s.MLSGroupState
+}
+
+func newMLSPeer() *mlsPeer {
+	return &mlsPeer{identity: disco.GenerateKeypair(nil)}
+}
+
+// keyPackage returns a new key package of the peer, signed like publishKeyPackages does
+func (peer *mlsPeer) keyPackage() *s.MLSKeyPackage {
+	peer.initKey = disco.GenerateKeypair(nil)
+	keyPackage := &s.MLSKeyPackage{Identity: peer.identity.PublicKey[:], InitKey: peer.initKey.PublicKey[:]}
+	keyPackage.Signature = xeddsa.Sign(peer.identity.PrivateKey, keyPackageContent(keyPackage))
+	return keyPackage
+}
+
+// createMLSGroup creates a group where the peer is alone, like mlsGroups.create does
+func (peer *mlsPeer) createMLSGroup() {
+	leafKeyPair := disco.GenerateKeypair(nil)
+	epochSecret := make([]byte, 32)
+	rand.Read(epochSecret)
+	peer.state = &s.MLSGroupState{
+		GroupId:     newRandomId(),
+		Tree:        []*s.MLSNode{{PublicKey: leafKeyPair.PublicKey[:], Identity: peer.identity.PublicKey[:]}},
+		PrivateKeys: [][]byte{leafKeyPair.PrivateKey[:]},
+		EpochSecret: epochSecret,
+	}
+	resetSenderChains(peer.state)
+}
+
+// testCommit is a commit as the members receive it
+type testCommit struct {
+	sender     uint32
+	serialized []byte
+	tag        []byte
+}
+
+// commit applies proposals with a fresh path, like mlsGroups.commit does, and welcomes the new members
+func (peer *mlsPeer) commit(t *testing.T, proposals []*s.MLSProposal, newMembers ...*mlsPeer) testCommit {
+	next := proto.Clone(peer.state).(*s.MLSGroupState)
+	added, err := applyProposals(next, proposals, peer.state.MyLeaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, commitSecret, pathSecrets := createUpdatePath(next, added)
+	serialized, err := proto.Marshal(&s.MLSCommit{Proposals: proposals, Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextEpoch(next, commitSecret)
+	commit := testCommit{sender: peer.state.MyLeaf, serialized: serialized, tag: confirmationTag(next, serialized)}
+	peer.state = next
+	for _, member := range newMembers {
+		leaf, ok := findLeaf(next, member.identity.PublicKey[:])
+		if !ok || !added[2*leaf] {
+			t.Fatal("the new member wasn't added")
+		}
+		if err := member.welcome(next, leaf, pathSecrets); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return commit
+}
+
+// welcome joins the state of the committer at a leaf, like mlsGroups.welcome and receiveWelcome do
+func (peer *mlsPeer) welcome(committer *s.MLSGroupState, leaf uint32, pathSecrets map[uint32][]byte) error {
+	var pathSecret []byte
+	path, _ := filteredDirectPath(committer, committer.MyLeaf)
+	for _, x := range path {
+		if isAncestor(x, 2*leaf) {
+			pathSecret = pathSecrets[x]
+			break
+		}
+	}
+	tree := proto.Clone(committer).(*s.MLSGroupState).Tree
+	if !bytes.Equal(tree[2*leaf].GetPublicKey(), peer.initKey.PublicKey[:]) {
+		return errors.New("ssyk: welcomed at the leaf of someone else")
+	}
+	peer.state = &s.MLSGroupState{
+		GroupId:     committer.GroupId,
+		Epoch:       committer.Epoch,
+		Tree:        tree,
+		PrivateKeys: make([][]byte, len(tree)),
+		MyLeaf:      leaf,
+		EpochSecret: committer.EpochSecret,
+	}
+	peer.state.PrivateKeys[2*leaf] = peer.initKey.PrivateKey[:]
+	if err := applyWelcomePath(peer.state, committer.MyLeaf, pathSecret); err != nil {
+		return err
+	}
+	resetSenderChains(peer.state)
+	return nil
+}
+
+// applyCommit moves the peer to the next epoch, like mlsGroups.applyCommit does (without leaving the
+// group when it is removed)
+func (peer *mlsPeer) applyCommit(commit testCommit) error {
+	received := &s.MLSCommit{}
+	if err := proto.Unmarshal(commit.serialized, received); err != nil {
+		return err
+	}
+	next := proto.Clone(peer.state).(*s.MLSGroupState)
+	added, err := applyProposals(next, received.GetProposals(), commit.sender)
+	if err != nil {
+		return err
+	}
+	commitSecret, err := applyUpdatePath(next, commit.sender, received.GetPath(), added)
+	if err != nil {
+		return err
+	}
+	nextEpoch(next, commitSecret)
+	if !bytes.Equal(confirmationTag(next, commit.serialized), commit.tag) {
+		return errors.New("ssyk: the commit leads to a different state")
+	}
+	peer.state = next
+	return nil
+}
+
+// testApplication is an application message as the members receive it
+type testApplication struct {
+	epoch      uint64
+	leaf       uint32
+	generation uint32
+	ciphertext []byte
+}
+
+// send encrypts an application message with the chain key of the peer's leaf, like mlsGroups.send does
+func (peer *mlsPeer) send(t *testing.T, content string) testApplication {
+	generation := peer.state.SenderGenerations[peer.state.MyLeaf]
+	key, err := ratchetSenderChain(peer.state, peer.state.MyLeaf, generation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testApplication{peer.state.Epoch, peer.state.MyLeaf, generation, disco.Encrypt(key, []byte(content))}
+}
+
+// read decrypts an application message, ignoring its epoch if the peer pretends to be in the same epoch
+func (peer *mlsPeer) read(msg testApplication, ignoreEpoch bool) (string, error) {
+	if msg.epoch != peer.state.Epoch && !ignoreEpoch {
+		return "", errors.New("ssyk: message received for another epoch")
+	}
+	state := proto.Clone(peer.state).(*s.MLSGroupState)
+	key, err := ratchetSenderChain(state, msg.leaf, msg.generation)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := disco.Decrypt(key, msg.ciphertext)
+	if err != nil {
+		return "", err
+	}
+	peer.state = state
+	return string(plaintext), nil
+}
+
+// checkMLSGroup checks that the members agree on the epoch, and can read each other
+func checkMLSGroup(t *testing.T, members ...*mlsPeer) {
+	first := members[0].state
+	for _, member := range members {
+		if member.state.Epoch != first.Epoch || !bytes.Equal(member.state.EpochSecret, first.EpochSecret) ||
+			!bytes.Equal(treeHash(member.state), treeHash(first)) {
+			t.Fatalf("the members don't share the epoch %d", first.Epoch)
+		}
+	}
+	for _, sender := range members {
+		msg := sender.send(t, "hello")
+		for _, member := range members {
+			if member == sender {
+				continue
+			}
+			if content, err := member.read(msg, false); err != nil || content != "hello" {
+				t.Fatalf("a member couldn't read the group: %q %v", content, err)
+			}
+		}
+	}
+}
+
+// checkRemoved checks that a removed member can't follow a commit, even with all the private keys it had
+func checkRemoved(t *testing.T, removed *mlsPeer, commit testCommit, msg testApplication) {
+	if err := removed.applyCommit(commit); err == nil {
+		t.Fatal("a removed member applied the commit that removes it")
+	}
+	received := &s.MLSCommit{}
+	if err := proto.Unmarshal(commit.serialized, received); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range received.GetPath().GetNodes() {
+		for _, encrypted := range node.GetEncryptedPathSecrets() {
+			for _, privateKey := range removed.state.PrivateKeys {
+				if len(privateKey) == 0 {
+					continue
+				}
+				if _, err := openWithPrivateKey(privateKey, encrypted, []byte(removed.state.GroupId)); err == nil {
+					t.Fatal("a removed member decrypted a path secret of the commit")
+				}
+			}
+		}
+	}
+	if _, err := removed.read(msg, true); err == nil {
+		t.Fatal("a removed member read a message sent after the commit")
+	}
+}
+
+func TestMLSGroup(t *testing.T) {
+	alice, bob, carol, dave, eve := newMLSPeer(), newMLSPeer(), newMLSPeer(), newMLSPeer(), newMLSPeer()
+
+	// create, and add three members with one commit
+	alice.createMLSGroup()
+	alice.commit(t, []*s.MLSProposal{
+		{ProposalType: s.MLSProposal_Add, KeyPackage: bob.keyPackage()},
+		{ProposalType: s.MLSProposal_Add, KeyPackage: carol.keyPackage()},
+		{ProposalType: s.MLSProposal_Add, KeyPackage: dave.keyPackage()},
+	}, bob, carol, dave)
+	if alice.state.Epoch != 1 || leafCount(alice.state) != 4 {
+		t.Fatalf("the group is at epoch %d with %d leaves", alice.state.Epoch, leafCount(alice.state))
+	}
+	checkMLSGroup(t, alice, bob, carol, dave)
+
+	// an update of bob
+	commit := bob.commit(t, nil)
+	for _, member := range []*mlsPeer{alice, carol, dave} {
+		if err := member.applyCommit(commit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkMLSGroup(t, alice, bob, carol, dave)
+
+	// bob removes carol
+	commit = bob.commit(t, []*s.MLSProposal{{ProposalType: s.MLSProposal_Remove, Removed: 2}})
+	for _, member := range []*mlsPeer{alice, dave} {
+		if err := member.applyCommit(commit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkMLSGroup(t, alice, bob, dave)
+	checkRemoved(t, carol, commit, dave.send(t, "carol is gone"))
+
+	// dave adds eve, at the leaf of carol
+	commit = dave.commit(t, []*s.MLSProposal{{ProposalType: s.MLSProposal_Add, KeyPackage: eve.keyPackage()}}, eve)
+	for _, member := range []*mlsPeer{alice, bob} {
+		if err := member.applyCommit(commit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if eve.state.MyLeaf != 2 {
+		t.Fatalf("eve was added at leaf %d", eve.state.MyLeaf)
+	}
+	checkMLSGroup(t, alice, bob, dave, eve)
+	if _, err := carol.read(eve.send(t, "hi"), true); err == nil {
+		t.Fatal("a removed member read a message of a later epoch")
+	}
+
+	// alice removes dave and eve, the tree is truncated
+	commit = alice.commit(t, []*s.MLSProposal{
+		{ProposalType: s.MLSProposal_Remove, Removed: 2},
+		{ProposalType: s.MLSProposal_Remove, Removed: 3},
+	})
+	if err := bob.applyCommit(commit); err != nil {
+		t.Fatal(err)
+	}
+	if leafCount(bob.state) != 2 {
+		t.Fatalf("the tree wasn't truncated, %d leaves", leafCount(bob.state))
+	}
+	checkMLSGroup(t, alice, bob)
+	checkRemoved(t, eve, commit, bob.send(t, "just us"))
+	checkRemoved(t, dave, commit, bob.send(t, "just us"))
+
+	// a commit can't remove its committer, or add a member twice
+	next := proto.Clone(alice.state).(*s.MLSGroupState)
+	if _, err := applyProposals(next, []*s.MLSProposal{{ProposalType: s.MLSProposal_Remove, Removed: 0}}, 0); err == nil {
+		t.Fatal("a commit removed its committer")
+	}
+	if _, err := applyProposals(next, []*s.MLSProposal{{ProposalType: s.MLSProposal_Add, KeyPackage: bob.keyPackage()}}, 0); err == nil {
+		t.Fatal("a commit added a member twice")
+	}
+}
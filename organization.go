@@ -0,0 +1,62 @@
+//
+// Organizations
+// =============
+//
+// If our Hub is dedicated to an organization, it serves a directory of the membership certificates
+// of its members (see docs/organizations.md). We don't trust the Hub with it: every certificate is
+// verified with the public key of the organization, which is part of our configuration.
+//
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+)
+
+const (
+	directoryPageSize = 20
+)
+
+// getOrganizationMembers searches the directory of our organization for a name or a team.
+// It returns a page of verified members, and how many members match the query on the Hub
+func (ss sasayakiState) getOrganizationMembers(query string, offset uint32) ([]organizationMember, uint32, error) {
+	if ss.organizationKey == nil {
+		return nil, 0, errors.New("ssyk: the public key of the organization is not configured")
+	}
+
+	storage.queryMutex.Lock()
+	certificates, total, err := hub.getOrganizationMembers(query, offset, directoryPageSize)
+	storage.queryMutex.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// only keep the certificates signed by our organization
+	members := []organizationMember{}
+	now := time.Now()
+	for _, serialized := range certificates {
+		certificate := &s.MembershipCertificate{}
+		if err := proto.Unmarshal(serialized, certificate); err != nil {
+			log.Println("hub sent a malformed certificate:", err)
+			continue
+		}
+		if err := s.VerifyCertificate(ss.organizationKey, certificate, nil, now); err != nil {
+			log.Println("hub sent an invalid certificate:", err)
+			continue
+		}
+		members = append(members, organizationMember{
+			PublicKey:    hex.EncodeToString(certificate.GetPublicKey()),
+			Name:         certificate.GetName(),
+			Organization: certificate.GetOrganization(),
+			Team:         certificate.GetTeam(),
+			ExpiresAt:    certificate.GetExpiresAt(),
+			Address:      certificate.GetName() + "@" + certificate.GetOrganization(),
+		})
+	}
+	return members, total, nil
+}
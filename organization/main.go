@@ -0,0 +1,140 @@
+//
+// Organization Tool
+// =================
+//
+// Manages the signing key of an organization, issues membership certificates to its members, and revokes their keys:
+//
+//	go run ./organization -gen_keypair
+//	go run ./organization -issue -member <public key> -name davidw -organization nccgroup -team cryptoservices
+//	go run ./organization -revoke -member <public key> -organization nccgroup -reason "lost laptop"
+//
+// The public key (organization.pub) is given to the Hub (-organization_key), and the certificate (in hex)
+// to the member, who adds it to its configuration. Revocations are appended to revocations.txt, which is
+// given to the Hub (-revocations).
+//
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+)
+
+const (
+	defaultKeyFile         = "organization.key"
+	defaultRevocationsFile = "revocations.txt"
+)
+
+func main() {
+	// Flags
+	genKeyPair := flag.Bool("gen_keypair", false, "generate the signing key of the organization")
+	keyFile := flag.String("key_file", defaultKeyFile, "sets the organization.key location (the public key is stored next to it, in a .pub file)")
+	issue := flag.Bool("issue", false, "issue a membership certificate")
+	member := flag.String("member", "", "the public key of the member (hex)")
+	name := flag.String("name", "", "the name of the member")
+	organization := flag.String("organization", "", "the name of the organization")
+	team := flag.String("team", "", "the team of the member")
+	days := flag.Int("days", 365, "how long the certificate is valid")
+	revoke := flag.Bool("revoke", false, "revoke the key of a member")
+	reason := flag.String("reason", "", "why the key of the member is revoked")
+	revocationsFile := flag.String("revocations_file", defaultRevocationsFile, "where revocations are appended (one per line, in hex)")
+
+	flag.Parse()
+
+	switch {
+	case *genKeyPair:
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(*keyFile, []byte(hex.EncodeToString(privateKey.Seed())), 0600); err != nil {
+			panic("cannot store the organization key")
+		}
+		if err := ioutil.WriteFile(publicKeyFile(*keyFile), []byte(hex.EncodeToString(publicKey)), 0644); err != nil {
+			panic("cannot store the organization public key")
+		}
+		fmt.Println("organization key generated at location", *keyFile)
+		fmt.Println("organization public key:", hex.EncodeToString(publicKey))
+	case *issue:
+		// checks
+		memberKey, err := hex.DecodeString(*member)
+		if err != nil || len(memberKey) != 32 || *name == "" || *organization == "" || *days <= 0 {
+			fmt.Println("a certificate needs the public key of the member, a name, an organization and a number of days")
+			return
+		}
+		// sign
+		now := time.Now()
+		certificate := &s.MembershipCertificate{
+			PublicKey:    memberKey,
+			Name:         *name,
+			Organization: *organization,
+			Team:         *team,
+			IssuedAt:     now.Unix(),
+			ExpiresAt:    now.AddDate(0, 0, *days).Unix(),
+		}
+		s.SignCertificate(loadKey(*keyFile), certificate)
+		serialized, err := proto.Marshal(certificate)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(hex.EncodeToString(serialized))
+	case *revoke:
+		// checks
+		memberKey, err := hex.DecodeString(*member)
+		if err != nil || len(memberKey) != 32 || *organization == "" {
+			fmt.Println("a revocation needs the public key of the member and an organization")
+			return
+		}
+		// sign
+		revocation := &s.Revocation{
+			PublicKey:    memberKey,
+			Organization: *organization,
+			RevokedAt:    time.Now().Unix(),
+			Reason:       *reason,
+		}
+		s.SignRevocation(loadKey(*keyFile), revocation)
+		serialized, err := proto.Marshal(revocation)
+		if err != nil {
+			panic(err)
+		}
+		// append it to the revocations given to the Hub
+		file, err := os.OpenFile(*revocationsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			panic("cannot open the revocations file")
+		}
+		defer file.Close()
+		if _, err := fmt.Fprintln(file, hex.EncodeToString(serialized)); err != nil {
+			panic("cannot store the revocation")
+		}
+		fmt.Println("key revoked, the revocation has been appended to", *revocationsFile)
+	default:
+		flag.PrintDefaults()
+	}
+}
+
+// loadKey reads the signing key of the organization (its seed in hex)
+func loadKey(keyFile string) ed25519.PrivateKey {
+	seed, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		panic("cannot load the organization key")
+	}
+	seed, err = hex.DecodeString(strings.TrimSpace(string(seed)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		panic("the organization key is malformed")
+	}
+	return ed25519.NewKeyFromSeed(seed)
+}
+
+// publicKeyFile returns where the public key is stored, next to the private key
+func publicKeyFile(keyFile string) string {
+	return strings.TrimSuffix(keyFile, ".key") + ".pub"
+}
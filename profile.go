@@ -0,0 +1,83 @@
+//
+// Profiles
+// ========
+//
+// A profile is a whole identity: its own keypair, database, configuration.json and Hub. Someone working for
+// two organizations keeps them apart with a profile for each:
+//
+//	sasayaki -profile acme -cli
+//	sasayaki send --profile acme --to <address> "hello"
+//
+// The "default" profile lives at the root of the Sasayaki folder (~/.sasayaki, or $SASAYAKI_HOME if set),
+// so that installations from before profiles keep working. The other profiles live in its profiles/ folder.
+// A profile is created the first time it is used.
+//
+// The web UI can switch profiles (see /switch_profile in webserver.go): the current one is closed, and the
+// passphrase of the next one is asked again.
+//
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	defaultProfile = "default"
+)
+
+var (
+	currentProfile = defaultProfile // the profile we are using, set before anything is initialized
+
+	profileNameRegexp = regexp.MustCompile("^[a-z0-9][a-z0-9_-]{0,31}$")
+)
+
+// setProfile selects the profile to use
+func setProfile(name string) error {
+	if !profileNameRegexp.MatchString(name) {
+		return errors.New("ssyk: a profile name is made of at most 32 lowercase letters, digits, - and _")
+	}
+	currentProfile = name
+	return nil
+}
+
+// listProfiles returns the profiles created so far, and the default one
+func listProfiles() []string {
+	profiles := []string{defaultProfile}
+	entries, _ := ioutil.ReadDir(filepath.Join(sasayakiRoot(), "profiles"))
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != defaultProfile && profileNameRegexp.MatchString(entry.Name()) {
+			profiles = append(profiles, entry.Name())
+		}
+	}
+	sort.Strings(profiles[1:])
+	return profiles
+}
+
+// profileFlag is the -profile flag, of the main command and of the subcommands (see commands.go)
+type profileFlag struct{}
+
+func (profileFlag) String() string        { return currentProfile }
+func (profileFlag) Set(name string) error { return setProfile(name) }
+
+// closeProfile disconnects from the Hub, closes the database and forgets what we cached about the profile,
+// so that another one can be initialized
+func closeProfile() {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	hubs.disconnect()
+	if storage.db != nil {
+		storage.db.Close()
+		storage.db = nil
+	}
+	myIdentity = ""
+	pendingLink = nil
+	pendingApprovals = map[string]deviceApproval{}
+	devicesFetchedAt = time.Time{}
+	revocationsFetchedAt = time.Time{}
+	keyRotationsFetchedAt = time.Time{}
+}
@@ -0,0 +1,94 @@
+//
+// Revocations
+// ===========
+//
+// If someone loses their keys, or leaves, our organization revokes their public key (see docs/organizations.md).
+// The Hub publishes the signed revocations in its transparency log (see transparency.go), we verify them with
+// the public key of our organization and store them.
+// All conversations with a revoked key are then blocked: we don't send to it, and we drop what it sends us.
+// The person can be added again as a contact under a new key.
+//
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+)
+
+const (
+	revocationsRefreshInterval = 5 * time.Minute
+)
+
+var (
+	revocationsFetchedAt time.Time // the last time we fetched the revocations (protected by storage.queryMutex)
+	logUpdateError       error     // why the log couldn't be updated, until it can (protected by storage.queryMutex)
+)
+
+// refreshRevocations fetches the new revocations of our organization from the transparency log of the Hub,
+// if we haven't recently. storage.queryMutex must be held
+func (ss sasayakiState) refreshRevocations() {
+	if ss.organizationKey == nil || time.Since(revocationsFetchedAt) < revocationsRefreshInterval {
+		return
+	}
+	if err := ss.updateLog(); err != nil {
+		// we keep going with the revocations we already have, and the UI warns that they may be out of date
+		log.Println("couldn't update the transparency log:", err)
+		logUpdateError = err
+		return
+	}
+	revocationsFetchedAt = time.Now()
+	logUpdateError = nil
+}
+
+// storeRevocation stores a serialized revocation found in the log, if it is signed by our organization
+func (ss sasayakiState) storeRevocation(serialized []byte) {
+	revocation := &s.Revocation{}
+	if err := proto.Unmarshal(serialized, revocation); err != nil {
+		log.Println("the log contains a malformed revocation:", err)
+		return
+	}
+	if err := s.VerifyRevocation(ss.organizationKey, revocation); err != nil {
+		log.Println("the log contains an invalid revocation:", err)
+		return
+	}
+	storage.storeRevocation(hex.EncodeToString(revocation.GetPublicKey()), revocation.GetRevokedAt(), revocation.GetReason())
+}
+
+// checkRevocation returns an error if the key of a contact has been revoked by our organization.
+// storage.queryMutex must be held
+func (ss sasayakiState) checkRevocation(bobAddress string) error {
+	ss.refreshRevocations()
+	if storage.isRevoked(bobAddress) {
+		return errors.New("ssyk: this key has been revoked by the organization, the conversation is blocked")
+	}
+	return nil
+}
+
+// getRevokedContacts returns our contacts whose keys have been revoked, so that the UI can mark them
+func (ss sasayakiState) getRevokedContacts() []revokedContact {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	ss.refreshRevocations()
+	return storage.getRevokedContacts()
+}
+
+// readdContact sends a contact request to the new key of a contact whose key has been revoked,
+// under the same name
+func (ss sasayakiState) readdContact(revokedAddress, newAddress string) error {
+	storage.queryMutex.Lock()
+	if !storage.isRevoked(revokedAddress) {
+		storage.queryMutex.Unlock()
+		return errors.New("ssyk: the key of this contact has not been revoked")
+	}
+	name, err := storage.getContactName(revokedAddress)
+	storage.queryMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return ss.aliceAddContact(newAddress, name)
+}
@@ -0,0 +1,320 @@
+//
+// Key Rotations
+// =============
+//
+// A contact who changes its key sends us, in one of our conversations, a statement signed by its previous key
+// (see serialization/rotation.go). It also publishes it to the Hub, for the contacts it doesn't share a
+// conversation with. A new key that is not signed this way is refused: adding a contact under the name of
+// another one fails, unless the other key has been revoked by our organization.
+//
+// A key change goes through three steps:
+//
+// * pending: we have verified the statement, the UI warns the user who has to accept the new key
+// * handshake: we have accepted the contact request (IK handshake) sent by the new key, or sent one to it
+// * done: the handshake is finished, our conversations have been moved to the new key and the previous key is deleted
+//
+// The session keys of the conversations moved are derived from the thread state of the new handshake, without
+// ratcheting it, so that both sides can move their conversations independently.
+//
+// When we change our own key (rotateKey), we do the same thing from the other side: we sign our new key, archive
+// our previous key and install the new one, send the statement to our contacts and to the Hub, and start a new
+// handshake with each contact. The members of an organization can't: the Hub of the organization only accepts
+// the key certified by the organization.
+//
+// TODO: groups are not migrated, the contact needs to be added again to the groups we share. Until then, the UI
+// lists them in its warning about the key change (see getKeyChanges)
+//
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+const (
+	keyChangePending   = "pending"
+	keyChangeHandshake = "handshake"
+	keyChangeDone      = "done"
+
+	keyRotationsRefreshInterval = 5 * time.Minute
+)
+
+var (
+	keyRotationsFetchedAt time.Time // the last time we fetched the key rotations of our contacts (protected by storage.queryMutex)
+)
+
+// handleKeyRotation verifies and stores the new key of a contact, received in a conversation.
+// storage.queryMutex must be held
+func (ss sasayakiState) handleKeyRotation(msg *plaintextMsg) error {
+	return ss.storeKeyRotation(msg.FromAddress, msg.Rotation)
+}
+
+// refreshKeyRotations fetches the key rotations of our contacts published to the Hub, if we haven't recently.
+// storage.queryMutex must be held
+func (ss sasayakiState) refreshKeyRotations() {
+	if time.Since(keyRotationsFetchedAt) < keyRotationsRefreshInterval {
+		return
+	}
+	contacts := storage.getAddedContacts()
+	if len(contacts) == 0 {
+		return
+	}
+	// each contact publishes its statement on its Hub
+	for current, keys := range hubs.group(contacts) {
+		statements, err := current.getKeyRotations(keys)
+		if err != nil {
+			log.Println("couldn't fetch the key rotations of our contacts:", err)
+			return
+		}
+		for _, serialized := range statements {
+			if err := ss.storeKeyRotation("", serialized); err != nil {
+				log.Println("hub sent an invalid key rotation:", err)
+			}
+		}
+	}
+	keyRotationsFetchedAt = time.Now()
+}
+
+// storeKeyRotation verifies and stores a serialized KeyRotationStatement, for the key of a contact.
+// If fromAddress is not empty, the statement must be for this key. storage.queryMutex must be held
+func (ss sasayakiState) storeKeyRotation(fromAddress string, serialized []byte) error {
+	statement := &s.KeyRotationStatement{}
+	if err := proto.Unmarshal(serialized, statement); err != nil {
+		return errors.New("ssyk: key rotation message is malformed")
+	}
+	if err := s.VerifyKeyRotation(statement); err != nil {
+		return err
+	}
+	oldAddress := hex.EncodeToString(statement.GetOldKey())
+	newAddress := hex.EncodeToString(statement.GetNewKey())
+	// only the contact can change its own key
+	if fromAddress != "" && oldAddress != fromAddress {
+		return errors.New("ssyk: key rotation statement is for another key")
+	}
+	if _, status := storage.getStateContact(oldAddress); status != contactAdded {
+		return errors.New("ssyk: key rotation statement is not for one of our contacts")
+	}
+	if newAddress == oldAddress || newAddress == ss.myAddress {
+		return errors.New("ssyk: key rotation statement is malformed")
+	}
+	if err := ss.checkRevocation(newAddress); err != nil {
+		return err
+	}
+	// a key can only be replaced once
+	if change, err := storage.getKeyRotation(oldAddress, false); err == nil {
+		if change.NewAddress == newAddress {
+			return nil
+		}
+		log.Println("contact signed two different new keys:", oldAddress)
+		return errors.New("ssyk: the contact has signed two different new keys")
+	}
+	storage.storeKeyRotation(oldAddress, newAddress, statement.GetDate(), serialized)
+	return nil
+}
+
+// checkKeyChange refuses to add a contact under the name of another contact, unless the other key has
+// signed the new one or has been revoked. storage.queryMutex must be held
+func (ss sasayakiState) checkKeyChange(bobAddress, bobName string) error {
+	if bobName == "" {
+		return nil
+	}
+	for _, otherAddress := range storage.getContactsByName(bobName) {
+		if otherAddress == bobAddress || storage.isRevoked(otherAddress) {
+			continue
+		}
+		if change, err := storage.getKeyRotation(otherAddress, false); err == nil && change.NewAddress == bobAddress {
+			continue
+		}
+		return errors.New("ssyk: this contact already has another key, and it hasn't signed the new one")
+	}
+	return nil
+}
+
+// getKeyChanges returns the key changes in progress, and the groups we share with the previous keys,
+// so that the UI can warn the user
+func (ss sasayakiState) getKeyChanges() []keyChange {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	ss.refreshKeyRotations()
+	changes := storage.getKeyRotations()
+	for i := range changes {
+		changes[i].Groups = storage.getGroupTitlesWithMember(changes[i].OldAddress)
+	}
+	return changes
+}
+
+// acceptKeyChange accepts the contact request sent by the new key of a contact, or sends one to it,
+// under the same name
+func (ss sasayakiState) acceptKeyChange(oldAddress string) error {
+	storage.queryMutex.Lock()
+	change, err := storage.getKeyRotation(oldAddress, false)
+	if err == nil && change.State != keyChangePending {
+		err = errors.New("ssyk: the new key of this contact has already been accepted")
+	}
+	if err != nil {
+		storage.queryMutex.Unlock()
+		return err
+	}
+	// the handshake can finish as soon as we accept the contact request
+	storage.updateKeyRotationState(oldAddress, keyChangeHandshake)
+	_, status := storage.getStateContact(change.NewAddress)
+	storage.queryMutex.Unlock()
+	if status == waitingToAccept {
+		err = ss.bobAcceptContact(change.NewAddress, change.Name)
+	} else {
+		err = ss.aliceAddContact(change.NewAddress, change.Name)
+	}
+	if err != nil {
+		storage.queryMutex.Lock()
+		defer storage.queryMutex.Unlock()
+		storage.updateKeyRotationState(oldAddress, keyChangePending)
+		return err
+	}
+	return nil
+}
+
+// finishKeyChange moves our conversations to the new key of a contact, once the handshake with it is done.
+// threadState is the thread state of the one who started the handshake (initiator is true if it was us).
+// storage.queryMutex must be held
+func (ss sasayakiState) finishKeyChange(newAddress string, threadState []byte, initiator bool) {
+	change, err := storage.getKeyRotation(newAddress, true)
+	if err != nil || change.State != keyChangeHandshake {
+		return
+	}
+	ss.migrateConversations(change.OldAddress, newAddress, threadState, initiator)
+	storage.updateKeyRotationState(change.OldAddress, keyChangeDone)
+}
+
+// migrateConversations moves our conversations from the previous key of a contact to its new key.
+// Both sides derive the same session keys from the thread state of the one who started the new handshake
+// (our c1 if it was us, our c2 otherwise). storage.queryMutex must be held
+func (ss sasayakiState) migrateConversations(oldAddress, newAddress string, threadState []byte, initiator bool) {
+	for _, convoId := range storage.getConvoIds(oldAddress) {
+		s1, s2 := e2e.migrateConvo(threadState, convoId)
+		if !initiator {
+			s1, s2 = s2, s1
+		}
+		storage.moveConvo(convoId, oldAddress, newAddress, s1, s2)
+	}
+	if oldAddress != newAddress {
+		storage.deleteRotatedContact(oldAddress)
+	}
+}
+
+// rotateKey replaces our key with a new one, signed by the current one. The passphrase is the one protecting
+// our current key, it also protects the new one. The new key is installed before the statement is sent, so that
+// our contacts never learn a key we don't have. It is refused on the Hubs of organizations, which only accept
+// the key of our membership certificate: the organization has to certify a new key instead
+func (ss *sasayakiState) rotateKey(passphrase string) error {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	for _, current := range hubs.each() {
+		if len(current.certificate) > 0 {
+			return errors.New("ssyk: our organization certifies our key, it has to sign a membership certificate for a new key")
+		}
+	}
+	// check the passphrase
+	location := keyPairLocation()
+	current, err := disco.LoadDiscoKeyPair(location, passphrase)
+	if err != nil || current.ExportPublicKey() != ss.myAddress {
+		return errors.New("ssyk: Cannot decrypt keyPair with given passphrase")
+	}
+	// generate our new key next to the current one, and sign it
+	newKeyPair, err := disco.GenerateAndSaveDiscoKeyPair(location+".new", passphrase)
+	if err != nil {
+		return err
+	}
+	statement := &s.KeyRotationStatement{
+		OldKey: current.PublicKey[:],
+		NewKey: newKeyPair.PublicKey[:],
+		Date:   time.Now().Unix(),
+	}
+	s.SignKeyRotation(current.PrivateKey, statement)
+	serialized, err := proto.Marshal(statement)
+	if err != nil {
+		panic(err)
+	}
+	// archive our current key (to decrypt what was encrypted to it), and install the new one
+	if err := archiveKeyPair(location, ss.myAddress); err != nil {
+		os.Remove(location + ".new")
+		return err
+	}
+	if err := os.Rename(location+".new", location); err != nil {
+		unarchiveKeyPair(location, ss.myAddress)
+		return err
+	}
+	// publish it to our Hubs, and send it to our contacts in one of our conversations (those we don't share a
+	// conversation with will find it on their Hub). We are still connected with our previous key, which signs
+	// the messages our contacts receive
+	if err := hubs.publish(func(current *hubState) error { return current.publishKeyRotation(serialized) }); err != nil {
+		// nobody knows the new key yet, we keep the current one
+		if err := unarchiveKeyPair(location, ss.myAddress); err != nil {
+			log.Println("couldn't restore our previous key:", err)
+		}
+		return err
+	}
+	contacts := storage.getAddedContacts()
+	for _, bobAddress := range contacts {
+		convoIds := storage.getConvoIds(bobAddress)
+		if len(convoIds) == 0 {
+			continue
+		}
+		_, err := ss.send(&plaintextMsg{
+			ConvoId:     convoIds[0],
+			FromAddress: ss.myAddress,
+			ToAddress:   bobAddress,
+			Type:        keyRotationMsg,
+			Rotation:    serialized,
+		})
+		if err != nil {
+			log.Println("couldn't send our new key to a contact:", err)
+		}
+	}
+	// switch to the new key, the Hubs authenticate us with it when we reconnect
+	ssyk.keyPair = newKeyPair
+	e2e.keyPair = newKeyPair
+	ss.myAddress = newKeyPair.ExportPublicKey()
+	hubs.disconnect()
+	// the unlock agent holds our previous key
+	lockAgent()
+	// start a new handshake with each contact, with our new key
+	for _, bobAddress := range contacts {
+		if err := ss.rekeyContact(bobAddress); err != nil {
+			log.Println("couldn't send a contact request with our new key:", err)
+		}
+	}
+	return nil
+}
+
+// rekeyContact sends a new contact request to a contact, after we changed our key.
+// storage.queryMutex must be held
+func (ss sasayakiState) rekeyContact(bobAddress string) error {
+	bobPubKey, err := decodeAddress(bobAddress)
+	if err != nil {
+		return err
+	}
+	bob := &disco.KeyPair{}
+	copy(bob.PublicKey[:], bobPubKey)
+	name, err := storage.getContactName(bobAddress)
+	if err != nil {
+		return err
+	}
+	firstHandshakeMessage, serializedHandshakeState, err := e2e.addContact(bob, name)
+	if err != nil {
+		return err
+	}
+	storage.rekeyContact(bobAddress, serializedHandshakeState)
+	return hubs.sendMessage(&s.Request_Message{
+		ToAddress: bobAddress,
+		ConvoId:   newRandomId(),
+		Content:   firstHandshakeMessage,
+	})
+}
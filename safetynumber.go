@@ -0,0 +1,99 @@
+//
+// Safety Numbers
+// ==============
+//
+// To check in person that we have the right key for a contact, both of us look at a safety number derived
+// from our two keys: it is the same on both sides (the order of the keys doesn't matter) and only changes
+// if one of the keys changes. Like Signal, each key is hashed into a 30-digit fingerprint:
+//
+//	hash = SHA-512(version || key), then hash = SHA-512(hash || key) 5200 times
+//
+// and each 5-byte chunk of the first 30 bytes gives 5 digits (as a big-endian integer, modulo 100000).
+// The safety number is the two fingerprints, smallest first.
+//
+// Instead of reading the 60 digits, one can scan the QR code of the other. Its payload contains both keys
+// (the one displaying the code first), and we check that it matches ours before marking the contact as
+// verified ("irl", see verification.go).
+//
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	safetyNumberVersion    = 0
+	safetyNumberIterations = 5200
+	safetyPayloadPrefix    = "sasayaki-verify:0:"
+)
+
+// fingerprint returns the 30 digits of a key in a safety number
+func fingerprint(publicKey []byte) string {
+	hash := sha512.Sum512(append([]byte{0, safetyNumberVersion}, publicKey...))
+	for i := 0; i < safetyNumberIterations; i++ {
+		hash = sha512.Sum512(append(hash[:], publicKey...))
+	}
+	digits := ""
+	for chunk := 0; chunk < 30; chunk += 5 {
+		var value uint64
+		for _, b := range hash[chunk : chunk+5] {
+			value = value<<8 | uint64(b)
+		}
+		digits += fmt.Sprintf("%05d", value%100000)
+	}
+	return digits
+}
+
+// safetyNumber returns the safety number of two keys, in groups of 5 digits
+func safetyNumber(aliceKey, bobKey []byte) string {
+	alice, bob := fingerprint(aliceKey), fingerprint(bobKey)
+	if bob < alice {
+		alice, bob = bob, alice
+	}
+	digits := alice + bob
+	groups := make([]string, 0, len(digits)/5)
+	for i := 0; i < len(digits); i += 5 {
+		groups = append(groups, digits[i:i+5])
+	}
+	return strings.Join(groups, " ")
+}
+
+// getSafetyNumber returns the safety number we share with a contact, and the payload of our QR code
+func (ss sasayakiState) getSafetyNumber(bobAddress string) (*safetyNumberInfo, error) {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	bobPublicKey, err := decodeAddress(bobAddress)
+	if err != nil {
+		return nil, err
+	}
+	name, err := storage.getContactName(bobAddress)
+	if err != nil {
+		return nil, err
+	}
+	info := &safetyNumberInfo{
+		Address:      bobAddress,
+		Name:         name,
+		SafetyNumber: safetyNumber(e2e.keyPair.PublicKey[:], bobPublicKey),
+		QRPayload:    safetyPayloadPrefix + ss.myAddress + ":" + hex.EncodeToString(bobPublicKey),
+	}
+	for _, v := range storage.getVerifications(bobAddress) {
+		if v.Who == ss.myAddress {
+			info.VerifiedByMe = true
+		}
+	}
+	return info, nil
+}
+
+// checkSafetyPayload checks the payload of the QR code displayed by a contact: it must contain
+// the key of the contact (without the Hub it is on), and then ours
+func (ss sasayakiState) checkSafetyPayload(bobAddress, payload string) error {
+	bobKey, _ := splitAddress(bobAddress)
+	if payload != safetyPayloadPrefix+bobKey+":"+ss.myAddress {
+		return errors.New("ssyk: the code scanned doesn't match the keys of this conversation")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestSafetyNumber(t *testing.T) {
+	aliceKey, bobKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+
+	// computed independently from the description in safetynumber.go
+	if fingerprint(aliceKey) != "653481277809870204720547886502" || fingerprint(bobKey) != "991742680087658988682691119784" {
+		t.Fatalf("unexpected fingerprints %s and %s", fingerprint(aliceKey), fingerprint(bobKey))
+	}
+	expected := "65348 12778 09870 20472 05478 86502 99174 26800 87658 98868 26911 19784"
+	if number := safetyNumber(aliceKey, bobKey); number != expected {
+		t.Fatalf("got the safety number %s, expected %s", number, expected)
+	}
+
+	// the same on both sides
+	if safetyNumber(bobKey, aliceKey) != expected {
+		t.Error("the safety number depends on the order of the keys")
+	}
+	// 12 groups of 5 digits
+	if !regexp.MustCompile(`^[0-9]{5}( [0-9]{5}){11}$`).MatchString(expected) {
+		t.Errorf("malformed safety number %q", expected)
+	}
+	// and it changes with any bit of either key
+	for i := 0; i < 32*8; i++ {
+		changedKey := append([]byte{}, bobKey...)
+		changedKey[i/8] ^= 1 << (i % 8)
+		if safetyNumber(aliceKey, changedKey) == expected || safetyNumber(changedKey, bobKey) == expected {
+			t.Fatalf("the safety number didn't change with the bit %d of a key", i)
+		}
+	}
+}
+
+func TestSafetyPayload(t *testing.T) {
+	ss := sasayakiState{myAddress: "aa"}
+	testCases := []struct {
+		payload string
+		ok      bool
+	}{
+		{safetyPayloadPrefix + "bb:aa", true},
+		{safetyPayloadPrefix + "aa:bb", false}, // our own code
+		{safetyPayloadPrefix + "cc:aa", false}, // another contact
+		{safetyPayloadPrefix + "bb:cc", false}, // another key for us
+		{"sasayaki-verify:1:bb:aa", false},
+		{"bb:aa", false},
+		{"", false},
+	}
+	for _, testCase := range testCases {
+		if err := ss.checkSafetyPayload("bb", testCase.payload); (err == nil) != testCase.ok {
+			t.Errorf("payload %q: checkSafetyPayload returned %v", testCase.payload, err)
+		}
+	}
+}
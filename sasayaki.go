@@ -14,66 +14,116 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"log"
 	"sync"
+	"time"
+
+	s "github.com/mimoo/sasayaki/serialization"
 
 	disco "github.com/mimoo/disco/libdisco"
 )
 
 type sasayakiState struct {
-	myAddress string // public key in hex form
+	myAddress string         // public key in hex form
+	keyPair   *disco.KeyPair // our keypair, the Hubs authenticate us with it (see hub.go)
 
 	queryMutex sync.Mutex // one query at a time
 
+	organizationKey ed25519.PublicKey // the key signing the members of our organization (can be nil)
+
 	e2e     *encryptionState
 	storage *storageState
 	hub     *hubState
+	hubs    *hubPool // our Hub and the Hubs of partner organizations (see hubpool.go)
 }
 
 var ssyk sasayakiState
 
+// errNoNewMessages is returned by getNextMessage when the Hub has nothing for us
+var errNoNewMessages = errors.New("ssyk: no new messages")
+
 func initSasayakiState(keyPair *disco.KeyPair, config *configuration) (*sasayakiState, error) {
 	// hub needs a public key
 	hubPublicKey, err := hex.DecodeString(config.HubPublicKey)
 	if err != nil || len(hubPublicKey) != 32 {
 		return nil, errors.New("ssyk: incorrect hub public key")
 	}
+	// and, for the Hubs of organizations, our membership certificate
+	certificate, err := hex.DecodeString(config.Certificate)
+	if err != nil {
+		return nil, errors.New("ssyk: incorrect membership certificate")
+	}
+	organizationKey, err := hex.DecodeString(config.OrganizationKey)
+	if err != nil || (len(organizationKey) != 0 && len(organizationKey) != ed25519.PublicKeySize) {
+		return nil, errors.New("ssyk: incorrect organization public key")
+	}
+	initTrustEngine(config.TrustDepth)
+	myIdentity = config.Identity
+	if err := initHubPool(config.Hubs); err != nil {
+		return nil, err
+	}
 	//
 	ssyk := &sasayakiState{
-		myAddress: keyPair.ExportPublicKey(),
-		e2e:       initEncryptionState(keyPair),
-		storage:   initStorageState(),
-		hub:       initHubState(config.hubAddress, hubPublicKey),
+		myAddress:       keyPair.ExportPublicKey(),
+		organizationKey: organizationKey,
+		e2e:             initEncryptionState(keyPair),
+		storage:         initStorageState(),
+		hub:             initHubState(config.hubAddress, hubPublicKey, certificate),
+		hubs:            &hubs,
 	}
 	return ssyk
 }
 
+// newRandomId generates a random 16-byte identifier in hex form (for conversations, messages, groups, etc.)
+func newRandomId() string {
+	var randomBytes [16]byte
+	if _, err := rand.Read(randomBytes[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(randomBytes[:])
+}
+
 // getNextMessage retrieves and decrypt a new message from the hub
 // message order is ensured by the server, otherwise it will break the thread
 // messages can also be contact requests, or contact acceptance
 func (ss sasayakiState) getNextMessage() (*plaintextMsg, error) {
 	storage.queryMutex.Lock()
 	defer storage.queryMutex.Unlock()
-	// obtain next message from hub
-	encryptedMsg, err := hub.getNextMessage()
+	// new devices of our contacts, or ours (see device.go)
+	ss.refreshDevices()
+	// obtain next message from one of our hubs
+	encryptedMsg, hubKey, err := hubs.getNextMessage()
 	if err != nil {
 		return nil, err
 	}
 
 	// no address == no new message
 	if encryptedMsg.GetFromAddress() == "" {
-		return nil, errors.New("ssyk: no new messages")
+		return nil, errNoNewMessages
+	}
+	// messages from revoked keys are dropped
+	if err := ss.checkRevocation(encryptedMsg.GetFromAddress()); err != nil {
+		return nil, err
 	}
 	// TODO: sanitize encryptedMsg? are addresses 32-byte hex?
 
+	// group messages are not encrypted with our contact's thread (and MLS groups can have members who are not contacts)
+	if encryptedMsg.GetKind() != s.MessageKind_Direct {
+		return ss.handleGroupMessage(encryptedMsg)
+	}
+
 	// checking if we're expecting a handshake message
 	switch _, status := storage.getStateContact(bobAddress); status {
 	case noContact: // first handshake message
 		addContactFromReq(encryptedMsg)
-		return nil, nil // TODO: what do we return here? (should we return an interface?)
-	case waitingForAccept: // second handshake message
+		storage.setContactHub(encryptedMsg.GetFromAddress(), hubKey) // we answer on the hub it came from
+		devicesFetchedAt = time.Time{}                               // it might be a device we know, see device.go
+		return nil, nil                                              // TODO: what do we return here? (should we return an interface?)
+	case waitingForAccept, waitingForRekey: // second handshake message
 		finalizeContact(encryptedMsg)
 		return nil, nil // TODO: what do we return here?
 	case waitingToAccept: // TODO: should we really handle this case or let the rest fail?
@@ -81,7 +131,7 @@ func (ss sasayakiState) getNextMessage() (*plaintextMsg, error) {
 		// TODO: idea: the getNextMessage request could also contain an ack for the previous
 		return nil, nil
 	case contactAdded:
-		return handleNewMessage(encryptedMsg)
+		return ss.handleNewMessage(encryptedMsg)
 	default:
 		panic("should not happen")
 	}
@@ -93,31 +143,39 @@ func (ss sasayakiState) handleNewMessage(encryptedMsg *s.ResponseMessage) (*plai
 		return nil, errors.New("ssyk: message received malformed")
 	}
 
-	// new convo? create it
-	if !storage.ConvoExist(encryptedMsg.GetConvoId()) {
+	// new convo? create it (a conversation can already exist with another device, see device.go)
+	if !storage.hasSession(encryptedMsg.GetConvoId(), encryptedMsg.GetFromAddress()) {
 
 		// get thread states for me -> bob
 		_, t2, err := storage.getThreadRatchetStates(encryptedMsg.GetFromAddress())
 		if err != nil {
-			return err
+			return nil, err
 		}
 		// create convo message
-		threadState, s1, s2 := e2e.createConvoFromMessage(t2, encryptedMsg)
-
-		// create the conversation with the current thread ratchet value
-		storage.createConvo(encryptedMsg.GetConvoId(), encryptedMsg.GetFromAddress(), "", s1, s2)
+		threadState, s1, s2 := e2e.createConvoFromMessage(t2)
 
 		// update the thread state
 		storage.updateThreadRatchetStates(encryptedMsg.GetFromAddress(), nil, threadState)
 
-		// decrypt the title
-		titleMessage, err := e2e.decryptMessage(s1, encryptedMsg)
+		// decrypt the title (bob is the initiator of the thread)
+		titleMessage, s1, err := e2e.decryptMessage(s1, encryptedMsg)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		// update the title
-		storage.updateTitle(titleMessage.ConvoId, titleMessage.FromAddress, titleMessage.Content)
+		// create the conversation with the current thread ratchet value
+		// (we send with the responder's session key and receive with the initiator's)
+		storage.createConvo(encryptedMsg.GetConvoId(), encryptedMsg.GetFromAddress(), titleMessage.Content, s2, s1)
+
+		// group invitations create a conversation with each member
+		if titleMessage.Type.isGroupControl() {
+			return nil, ss.handleGroupControl(titleMessage)
+		}
+		// a new device is linked with a conversation
+		if titleMessage.Type == deviceLinkMsg {
+			return nil, ss.handleDeviceLink(titleMessage)
+		}
+		ss.receivedFromDevice(titleMessage, true)
 
 		// TODO: nil means new convo???
 		return nil, nil
@@ -135,8 +193,25 @@ func (ss sasayakiState) handleNewMessage(encryptedMsg *s.ResponseMessage) (*plai
 	}
 	// store new state
 	storage.updateSessionKeys(encryptedMsg.GetConvoId(), encryptedMsg.GetFromAddress(), nil, strobeState)
-	// store message
-	storage.storeMessage(decryptedMessage)
+	// control messages for groups are not stored
+	if decryptedMessage.Type.isGroupControl() {
+		return nil, ss.handleGroupControl(decryptedMessage)
+	}
+	// neither are tree heads gossiped by our contacts
+	if decryptedMessage.Type == gossipMsg {
+		return nil, ss.handleGossip(decryptedMessage)
+	}
+	// or the new keys of our contacts
+	if decryptedMessage.Type == keyRotationMsg {
+		return nil, ss.handleKeyRotation(decryptedMessage)
+	}
+	// messages from the devices of a contact are from the contact, and ours from us
+	ss.receivedFromDevice(decryptedMessage, false)
+	// store message (or apply the edit/deletion)
+	if err := ss.checkReference(decryptedMessage); err != nil {
+		return nil, err
+	}
+	ss.applyMessage(decryptedMessage)
 
 	// TODO: tell the server it can safely delete tuple with {id, convoId, bobAddress}
 	// 			but isn't that going to be way too large messages? That could be sent on the notification channel
@@ -153,8 +228,46 @@ func (ss sasayakiState) handleNewMessage(encryptedMsg *s.ResponseMessage) (*plai
 func (ss sasayakiState) sendMessage(msg *plaintextMsg) (string, error) {
 	storage.queryMutex.Lock()
 	defer storage.queryMutex.Unlock()
+	// the conversation might have been started by another device (see device.go)
+	newConvo := msg.ConvoId == ""
+	if !newConvo && storage.ConvoExist(msg.ConvoId) && !storage.hasSession(msg.ConvoId, msg.ToAddress) {
+		if err := ss.openDeviceConvo(msg.ConvoId, msg.ToAddress, ""); err != nil {
+			return "", err
+		}
+	}
+	convoId, err := ss.send(msg)
+	if err != nil {
+		return "", err
+	}
+	// a copy goes to the other devices of the contact, and to ours
+	ss.fanOut(msg, newConvo)
+	// our messages carry our view of the transparency log
+	if msg.Type == textMsg && ss.organizationKey != nil {
+		if err := ss.gossipTreeHead(convoId, msg.ToAddress); err != nil {
+			log.Println("couldn't gossip our tree head:", err)
+		}
+	}
+	return convoId, nil
+}
+
+// send is sendMessage without the lock, so that it can be used by other functions of the core
+func (ss sasayakiState) send(msg *plaintextMsg) (string, error) {
+	// we don't talk to revoked keys
+	if err := ss.checkRevocation(msg.ToAddress); err != nil {
+		return "", err
+	}
+	// nor to contacts who haven't accepted our new key yet
+	if _, status := storage.getStateContact(msg.ToAddress); status == waitingForRekey {
+		return "", errors.New("ssyk: the contact hasn't accepted our new key yet")
+	}
+	// generate msgId
+	msg.Id = newRandomId()
 	// is it a new thread?
 	if msg.ConvoId == "" {
+		// only text messages, group invitations and device links can create a thread
+		if msg.Type != textMsg && msg.Type != groupInviteMsg && msg.Type != deviceLinkMsg {
+			return "", errors.New("ssyk: this message needs a conversation")
+		}
 		// generate convoId
 		var randomBytes [16]byte
 		if _, err := rand.Read(randomBytes[:]); err != nil {
@@ -164,14 +277,11 @@ func (ss sasayakiState) sendMessage(msg *plaintextMsg) (string, error) {
 
 		// get thread states for me -> bob
 		t1, _, err := storage.getThreadRatchetStates(msg.ToAddress)
-		if err != nil {
-			return nil, err
-		}
-		// create new convo
-		threadState, s1, s2, err := e2e.createNewConvo(t1, msg)
 		if err != nil {
 			return "", err
 		}
+		// create new convo
+		threadState, s1, s2 := e2e.createNewConvo(t1, msg)
 		// update the thread state
 		storage.updateThreadRatchetStates(msg.ToAddress, threadState, nil)
 		// create the conversation with the current thread ratchet value and a random convoId
@@ -182,21 +292,22 @@ func (ss sasayakiState) sendMessage(msg *plaintextMsg) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		// store message
-
-		// update strobeState // TODO: this should store msg as well
+		// update strobeState
 		storage.updateSessionKeys(msg.ConvoId, msg.ToAddress, s1, nil)
-		panic("todo")
 
 		// send to hub
-		if err := hub.sendMessage(encryptedMessage); err != nil {
+		if err := hubs.sendMessage(encryptedMessage); err != nil {
 			return "", err
 		}
 	} else { // nope, it's just a message
+		// edits and deletions can only target our own messages
+		if err := ss.checkReference(msg); err != nil {
+			return "", err
+		}
 		// get strobeState
 		s1, _, err := storage.getSessionKeys(msg.ConvoId, msg.ToAddress)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 		// add encryption
 		encryptedMessage, strobeState, err := e2e.encryptMessage(s1, msg)
@@ -205,29 +316,134 @@ func (ss sasayakiState) sendMessage(msg *plaintextMsg) (string, error) {
 		}
 		// update strobeState		// TODO: this should store the message as well
 		storage.updateSessionKeys(msg.ConvoId, msg.ToAddress, strobeState, nil)
-		panic("todo")
 		// send to hub
-		if err := hub.sendMessage(encryptedMessage); err != nil {
+		if err := hubs.sendMessage(encryptedMessage); err != nil {
 			return "", err
 		}
 		// store in database
-		storage.storeMessage(msg)
+		ss.applyMessage(msg)
 	}
 
 	//
 	return msg.ConvoId, nil
 }
 
+// editMessage replaces the content of one of our previous messages, for us and for bob
+func (ss sasayakiState) editMessage(convoId, bobAddress, msgId, content string) error {
+	_, err := ss.sendMessage(&plaintextMsg{
+		ConvoId:     convoId,
+		FromAddress: ss.myAddress,
+		ToAddress:   bobAddress,
+		Type:        editMsg,
+		Reference:   msgId,
+		Content:     content,
+	})
+	return err
+}
+
+// deleteMessage retracts one of our previous messages, for us and for bob
+func (ss sasayakiState) deleteMessage(convoId, bobAddress, msgId string) error {
+	_, err := ss.sendMessage(&plaintextMsg{
+		ConvoId:     convoId,
+		FromAddress: ss.myAddress,
+		ToAddress:   bobAddress,
+		Type:        deleteMsg,
+		Reference:   msgId,
+	})
+	return err
+}
+
+// checkReference makes sure that an edit or a deletion targets an existing message of the same
+// conversation, written by the same person
+func (ss sasayakiState) checkReference(msg *plaintextMsg) error {
+	switch msg.Type {
+	case textMsg:
+		return nil
+	case attachmentMsg:
+		att := msg.Attachment
+		if att == nil || len(att.Key) != 32 || len(att.Hash) != 32 || len(att.Blobs) == 0 {
+			return errors.New("ssyk: attachment received is malformed")
+		}
+		return nil
+	case groupInviteMsg, groupSenderKeyMsg, groupAddMemberMsg, groupRemoveMemberMsg:
+		if msg.Group == nil {
+			return errors.New("ssyk: group control message is malformed")
+		}
+		return nil
+	case gossipMsg:
+		if len(msg.TreeHead) == 0 {
+			return errors.New("ssyk: gossip message is malformed")
+		}
+		return nil
+	case keyRotationMsg:
+		if len(msg.Rotation) == 0 {
+			return errors.New("ssyk: key rotation message is malformed")
+		}
+		return nil
+	case deviceLinkMsg:
+		if len(msg.DeviceLink) == 0 {
+			return errors.New("ssyk: device link message is malformed")
+		}
+		return nil
+	case editMsg, deleteMsg:
+		senderIsMe, ok := storage.getMessageAuthor(msg.ConvoId, msg.Reference)
+		if !ok {
+			return errors.New("ssyk: the message referenced does not exist")
+		}
+		if senderIsMe != (msg.FromAddress == ss.myAddress) {
+			return errors.New("ssyk: only the author of a message can edit or delete it")
+		}
+		return nil
+	default:
+		return errors.New("ssyk: unknown message type")
+	}
+}
+
+// applyMessage stores a message, or applies an edit or a deletion to a stored message.
+// the message must have been checked with checkReference first
+func (ss sasayakiState) applyMessage(msg *plaintextMsg) {
+	switch msg.Type {
+	case textMsg:
+		storage.storeMessage(msg)
+	case attachmentMsg:
+		storage.storeMessage(msg)
+		storage.storeAttachment(msg)
+	case editMsg:
+		storage.editMessage(msg.ConvoId, msg.Reference, msg.Content)
+	case deleteMsg:
+		storage.deleteMessage(msg.ConvoId, msg.Reference)
+	}
+}
+
 // addContact creates a contact request
 // TODO: what happens when we do that?
 // should it send a message coming from us? Or a meta msg from the hub?
 // maybe if we receive a msg from someone we don't know, we can assume it is a request
 func (ss sasayakiState) aliceAddContact(bobAddress, bobName string) error {
+	return ss.aliceAddContactOnHub(bobAddress, bobName, "")
+}
+
+// aliceAddContactOnHub is aliceAddContact for a contact on another Hub than ours,
+// hubName is the name of that Hub in the configuration (see hubpool.go)
+func (ss sasayakiState) aliceAddContactOnHub(bobAddress, bobName, hubName string) error {
 	storage.queryMutex.Lock()
 	defer storage.queryMutex.Unlock()
-	if len(bobAddress) != 64 {
+	if !isAddress(bobAddress) {
 		return errors.New("ssyk: contact's address is malformed")
 	}
+	hubKey, err := hubs.resolve(hubName)
+	if err != nil {
+		return err
+	}
+
+	if err := ss.checkRevocation(bobAddress); err != nil {
+		return err
+	}
+
+	// contacts can't change their key without signing the new one
+	if err := ss.checkKeyChange(bobAddress, bobName); err != nil {
+		return err
+	}
 
 	// check that contact doesn't already have a state
 	_, status := storage.getStateContact(bobAddress)
@@ -236,9 +452,9 @@ func (ss sasayakiState) aliceAddContact(bobAddress, bobName string) error {
 	}
 
 	// unserialize key
-	bobPubKey, err := hex.DecodeString(bobAddress)
+	bobPubKey, err := decodeAddress(bobAddress)
 	if err != nil {
-		return nil, nil, errors.New("ssyk: contact's address is not hexadecimal")
+		return nil, nil, err
 	}
 
 	// needed by libdisco
@@ -251,8 +467,9 @@ func (ss sasayakiState) aliceAddContact(bobAddress, bobName string) error {
 		return err
 	}
 
-	// store the new contact with the serialized handshake
+	// store the new contact with the serialized handshake, and the hub it is on
 	storage.addContact(bobAddress, bobName, serializedHandshakeState)
+	storage.setContactHub(bobAddress, hubKey)
 
 	// create message to send
 	var randomBytes [16]byte
@@ -266,7 +483,7 @@ func (ss sasayakiState) aliceAddContact(bobAddress, bobName string) error {
 	}
 
 	// forward request to hub
-	hub.sendMessage(msgToSend)
+	hubs.sendMessage(msgToSend)
 
 	//
 	return nil
@@ -294,8 +511,14 @@ func (ss sasayakiState) bobReceiveContactRequest(aliceAddress string, firstHands
 func (ss sasayakiState) bobAcceptContact(aliceAddress, aliceName string) error {
 	storage.queryMutex.Lock()
 	defer storage.queryMutex.Unlock()
+	return ss.acceptContactRequest(aliceAddress, aliceName)
+}
+
+// acceptContactRequest is bobAcceptContact without the lock, so that the core can accept the contact requests
+// of the devices of our contacts (see device.go)
+func (ss sasayakiState) acceptContactRequest(aliceAddress, aliceName string) error {
 	// TODO: move all these checks in ssyk?
-	if len(aliceAddress) != 64 {
+	if !isAddress(aliceAddress) {
 		return errors.New("ssyk: contact's address is malformed")
 	}
 
@@ -305,9 +528,9 @@ func (ss sasayakiState) bobAcceptContact(aliceAddress, aliceName string) error {
 		return nil, errors.New("ssyk: contact is not being added properly")
 	}
 	// unserializekey
-	alicePubKey, err := hex.DecodeString(aliceAddress)
+	alicePubKey, err := decodeAddress(aliceAddress)
 	if err != nil {
-		return nil, nil, nil, errors.New("ssyk: contact's address is not hexadecimal")
+		return nil, nil, nil, err
 	}
 	// needed by libdisco
 	alice := &disco.KeyPair{}
@@ -321,6 +544,10 @@ func (ss sasayakiState) bobAcceptContact(aliceAddress, aliceName string) error {
 	// update contact with thread states
 	storage.updateContact(aliceAddress, ts1, ts2)
 
+	// if this is the new key of a contact, move our conversations to it
+	// (the contact started the handshake, its thread state is our ts2)
+	ss.finishKeyChange(aliceAddress, ts2, false)
+
 	// forward second handshake message to hub
 	panic("no hub support")
 
@@ -339,7 +566,7 @@ func (ss sasayakiState) aliceAckAcceptContact(bobAddress string, secondHandshake
 
 	// check in storage if we are at this step in the handshake
 	serializedHandshakeState, status := storage.getStateContact(bobAddress)
-	if status != waitingForAccept {
+	if status != waitingForAccept && status != waitingForRekey {
 		return errors.New("ssyk: contact has not been added properly")
 	}
 
@@ -352,6 +579,17 @@ func (ss sasayakiState) aliceAckAcceptContact(bobAddress string, secondHandshake
 	// store the thread states
 	storage.updateContact(bobAddress, ts1, ts2)
 
+	// if we changed our key, our conversations with the contact get new session keys,
+	// and if this is the new key of a contact, our conversations are moved to it
+	if status == waitingForRekey {
+		ss.migrateConversations(bobAddress, bobAddress, ts1, true)
+	} else {
+		ss.finishKeyChange(bobAddress, ts1, true)
+	}
+
+	// if this is a device we have just linked, send it its link (see device.go)
+	ss.finishDeviceLink(bobAddress)
+
 	// hub?
 	panic("no hub support")
 
@@ -368,3 +606,31 @@ func (ss sasayakiState) deleteContact(bobAddress string) error {
 
 	return storage.deleteContact(bobAddress)
 }
+
+// getConversations returns our conversations with our contacts, the most recent first
+func (ss sasayakiState) getConversations() []conversationInfo {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	return storage.getConversations()
+}
+
+// getConvoMessages returns the last messages of a conversation, the oldest first
+func (ss sasayakiState) getConvoMessages(convoId string, limit int) []storedMsg {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	return storage.getConvoMessages(convoId, limit)
+}
+
+// getContactRequests returns the keys that sent us a contact request we haven't accepted yet
+func (ss sasayakiState) getContactRequests() []string {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	return storage.getContactRequests()
+}
+
+// getContacts returns all our contacts, with the step of the handshake they are at
+func (ss sasayakiState) getContacts() []contactInfo {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	return storage.getContacts()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initTestStorage opens an empty database in a temporary Sasayaki folder
+func initTestStorage(t *testing.T) {
+	t.Helper()
+	t.Setenv("SASAYAKI_HOME", t.TempDir())
+	currentProfile = defaultProfile
+	initStorageState()
+	t.Cleanup(func() {
+		storage.db.Close()
+		storage.db = nil
+	})
+}
+
+func TestEditAndDeleteMessages(t *testing.T) {
+	initTestStorage(t)
+	ssyk.myAddress = strings.Repeat("a", 64)
+	bobAddress := strings.Repeat("b", 64)
+	convoId := strings.Repeat("c", 32)
+
+	storage.storeMessage(&plaintextMsg{ConvoId: convoId, Id: "mine", FromAddress: ssyk.myAddress, Content: "helo"})
+	storage.storeMessage(&plaintextMsg{ConvoId: convoId, Id: "bobs", FromAddress: bobAddress, Content: "hi"})
+
+	// only the author of a message can edit or delete it
+	testCases := []struct {
+		msg *plaintextMsg
+		ok  bool
+	}{
+		{&plaintextMsg{ConvoId: convoId, FromAddress: ssyk.myAddress, Type: editMsg, Reference: "mine"}, true},
+		{&plaintextMsg{ConvoId: convoId, FromAddress: bobAddress, Type: editMsg, Reference: "mine"}, false},
+		{&plaintextMsg{ConvoId: convoId, FromAddress: bobAddress, Type: deleteMsg, Reference: "bobs"}, true},
+		{&plaintextMsg{ConvoId: convoId, FromAddress: ssyk.myAddress, Type: deleteMsg, Reference: "bobs"}, false},
+		{&plaintextMsg{ConvoId: convoId, FromAddress: bobAddress, Type: editMsg, Reference: "unknown"}, false},
+		{&plaintextMsg{ConvoId: strings.Repeat("d", 32), FromAddress: bobAddress, Type: editMsg, Reference: "bobs"}, false},
+	}
+	for i, testCase := range testCases {
+		if err := ssyk.checkReference(testCase.msg); (err == nil) != testCase.ok {
+			t.Errorf("case %d: checkReference returned %v", i, err)
+		}
+	}
+
+	// an edit replaces the content
+	ssyk.applyMessage(&plaintextMsg{ConvoId: convoId, FromAddress: ssyk.myAddress, Type: editMsg, Reference: "mine", Content: "hello"})
+	// a deletion erases it
+	ssyk.applyMessage(&plaintextMsg{ConvoId: convoId, FromAddress: bobAddress, Type: deleteMsg, Reference: "bobs"})
+
+	msgs := storage.getConvoMessages(convoId, 10)
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	if msgs[0].Content != "hello" || msgs[0].Deleted {
+		t.Errorf("the edit wasn't applied: %+v", msgs[0])
+	}
+	if msgs[1].Content != "" || !msgs[1].Deleted {
+		t.Errorf("the deletion wasn't applied: %+v", msgs[1])
+	}
+
+	// a deleted message can't be edited or deleted again
+	if err := ssyk.checkReference(&plaintextMsg{ConvoId: convoId, FromAddress: bobAddress, Type: editMsg, Reference: "bobs"}); err == nil {
+		t.Error("a deleted message was edited")
+	}
+}
+
+func TestStorageMigrations(t *testing.T) {
+	// a database created before messages could be edited, and groups had engines
+	t.Setenv("SASAYAKI_HOME", t.TempDir())
+	currentProfile = defaultProfile
+	db, err := sql.Open("sqlite3", filepath.Join(sasayakiFolder(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+	CREATE TABLE messages (id INTEGER PRIMARY KEY AUTOINCREMENT, conversation_id TEXT NOT NULL, date TIMESTAMP, senderIsMe BOOLEAN, message BLOB);
+	CREATE TABLE group_convos (id TEXT NOT NULL UNIQUE, title TEXT, date_creation TIMESTAMP, active BOOLEAN, generation INTEGER, sender_key BLOB);
+	INSERT INTO messages VALUES(NULL, 'old', DATETIME('now'), 1, 'before the update');
+	INSERT INTO group_convos VALUES('group', 'old group', DATETIME('now'), 1, 0, NULL);
+	`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	initStorageState()
+	defer func() {
+		storage.db.Close()
+		storage.db = nil
+	}()
+	ssyk.myAddress = strings.Repeat("a", 64)
+	storage.storeMessage(&plaintextMsg{ConvoId: "old", Id: "new", FromAddress: ssyk.myAddress, Content: "after the update"})
+	msgs := storage.getConvoMessages("old", 10)
+	if len(msgs) != 2 || msgs[0].Content != "before the update" || msgs[0].Deleted || msgs[1].Id != "new" {
+		t.Fatalf("the messages weren't migrated: %+v", msgs)
+	}
+	groups := storage.getGroups()
+	if len(groups) != 1 || groups[0].Engine != "sender_keys" || groups[0].Channel != "chat" {
+		t.Fatalf("the groups weren't migrated: %+v", groups)
+	}
+
+	// the migrations are only applied once
+	var version int
+	if err := storage.db.QueryRow("PRAGMA user_version;").Scan(&version); err != nil || version != len(storageMigrations) {
+		t.Fatalf("the database is at version %d: %v", version, err)
+	}
+	storage.db.Close()
+	initStorageState()
+	if msgs := storage.getConvoMessages("old", 10); len(msgs) != 2 {
+		t.Errorf("%d messages after a restart", len(msgs))
+	}
+}
@@ -0,0 +1,78 @@
+//
+// Membership Certificates and Revocations
+// =======================================
+//
+// Shared by the client, the Hub and the organization tool, so that they agree on what is signed.
+// See docs/organizations.md
+//
+package serialization
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// CertificateContent returns what the organization signs in a membership certificate
+func CertificateContent(certificate *MembershipCertificate) []byte {
+	unsigned := *certificate
+	unsigned.Signature = nil
+	serialized, err := proto.Marshal(&unsigned)
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte("SasayakiMembershipCertificate"), serialized...)
+}
+
+// SignCertificate signs a membership certificate with the private key of the organization
+func SignCertificate(organizationKey ed25519.PrivateKey, certificate *MembershipCertificate) {
+	certificate.Signature = ed25519.Sign(organizationKey, CertificateContent(certificate))
+}
+
+// VerifyCertificate checks that a membership certificate has been signed by the organization,
+// that it is valid at the given time, and that it is for the given public key (if not nil)
+func VerifyCertificate(organizationKey ed25519.PublicKey, certificate *MembershipCertificate, publicKey []byte, now time.Time) error {
+	if len(organizationKey) != ed25519.PublicKeySize || len(certificate.GetPublicKey()) != 32 {
+		return errors.New("ssyk: certificate is malformed")
+	}
+	if publicKey != nil && !bytes.Equal(certificate.GetPublicKey(), publicKey) {
+		return errors.New("ssyk: certificate is for another public key")
+	}
+	if !ed25519.Verify(organizationKey, CertificateContent(certificate), certificate.GetSignature()) {
+		return errors.New("ssyk: certificate has an invalid signature")
+	}
+	if now.Unix() < certificate.GetIssuedAt() || now.Unix() > certificate.GetExpiresAt() {
+		return errors.New("ssyk: certificate is expired")
+	}
+	return nil
+}
+
+// RevocationContent returns what the organization signs in a revocation
+func RevocationContent(revocation *Revocation) []byte {
+	unsigned := *revocation
+	unsigned.Signature = nil
+	serialized, err := proto.Marshal(&unsigned)
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte("SasayakiRevocation"), serialized...)
+}
+
+// SignRevocation signs a revocation with the private key of the organization
+func SignRevocation(organizationKey ed25519.PrivateKey, revocation *Revocation) {
+	revocation.Signature = ed25519.Sign(organizationKey, RevocationContent(revocation))
+}
+
+// VerifyRevocation checks that a revocation has been signed by the organization
+func VerifyRevocation(organizationKey ed25519.PublicKey, revocation *Revocation) error {
+	if len(organizationKey) != ed25519.PublicKeySize || len(revocation.GetPublicKey()) != 32 {
+		return errors.New("ssyk: revocation is malformed")
+	}
+	if !ed25519.Verify(organizationKey, RevocationContent(revocation), revocation.GetSignature()) {
+		return errors.New("ssyk: revocation has an invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+//
+// Devices
+// =======
+//
+// Someone using several devices has one identity key, which certifies the key of each of their other
+// devices (XEdDSA). The device key countersigns the same content, so that nobody can claim the key of
+// someone else as one of their devices. Their contacts encrypt their messages to each certified device.
+// See device.go
+//
+package serialization
+
+import (
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mimoo/sasayaki/xeddsa"
+)
+
+// DeviceCertificateContent returns what the identity key and the device key sign in a device certificate
+func DeviceCertificateContent(certificate *DeviceCertificate) []byte {
+	unsigned := *certificate
+	unsigned.Signature = nil
+	unsigned.DeviceSignature = nil
+	serialized, err := proto.Marshal(&unsigned)
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte("SasayakiDeviceCertificate"), serialized...)
+}
+
+// SignDeviceCertificate signs a device certificate with the private identity key
+func SignDeviceCertificate(identityPrivateKey [32]byte, certificate *DeviceCertificate) {
+	certificate.Signature = xeddsa.Sign(identityPrivateKey, DeviceCertificateContent(certificate))
+}
+
+// CountersignDeviceCertificate signs a device certificate with the private device key, once the identity
+// has signed it
+func CountersignDeviceCertificate(devicePrivateKey [32]byte, certificate *DeviceCertificate) {
+	certificate.DeviceSignature = xeddsa.Sign(devicePrivateKey, DeviceCertificateContent(certificate))
+}
+
+// VerifyDeviceRequest checks that a device certificate has been signed by the identity key. It isn't
+// countersigned yet: the device only uses it to know which identity is linking it (see device.go)
+func VerifyDeviceRequest(certificate *DeviceCertificate) error {
+	if len(certificate.GetIdentityKey()) != 32 || len(certificate.GetDeviceKey()) != 32 {
+		return errors.New("ssyk: device certificate is malformed")
+	}
+	if !xeddsa.Verify(certificate.GetIdentityKey(), DeviceCertificateContent(certificate), certificate.GetSignature()) {
+		return errors.New("ssyk: device certificate has an invalid signature")
+	}
+	return nil
+}
+
+// VerifyDeviceCertificate checks that a device certificate has been signed by the identity key, and
+// countersigned by the device key
+func VerifyDeviceCertificate(certificate *DeviceCertificate) error {
+	if err := VerifyDeviceRequest(certificate); err != nil {
+		return err
+	}
+	if !xeddsa.Verify(certificate.GetDeviceKey(), DeviceCertificateContent(certificate), certificate.GetDeviceSignature()) {
+		return errors.New("ssyk: device certificate hasn't been countersigned by the device")
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package serialization
+
+import (
+	"testing"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+func TestDeviceCertificate(t *testing.T) {
+	identity, device, mallory := disco.GenerateKeypair(nil), disco.GenerateKeypair(nil), disco.GenerateKeypair(nil)
+	certificate := &DeviceCertificate{
+		IdentityKey: identity.PublicKey[:],
+		DeviceKey:   device.PublicKey[:],
+		Name:        "laptop",
+		Date:        1500000000,
+	}
+	SignDeviceCertificate(identity.PrivateKey, certificate)
+	if err := VerifyDeviceRequest(certificate); err != nil {
+		t.Fatal(err)
+	}
+
+	// the identity alone can't certify a device
+	if err := VerifyDeviceCertificate(certificate); err == nil {
+		t.Fatal("a certificate without the signature of the device was accepted")
+	}
+	CountersignDeviceCertificate(mallory.PrivateKey, certificate)
+	if err := VerifyDeviceCertificate(certificate); err == nil {
+		t.Fatal("a certificate countersigned by another key was accepted")
+	}
+	CountersignDeviceCertificate(device.PrivateKey, certificate)
+	if err := VerifyDeviceCertificate(certificate); err != nil {
+		t.Fatal(err)
+	}
+
+	// nor can the device claim another identity
+	claimed := *certificate
+	claimed.IdentityKey = mallory.PublicKey[:]
+	SignDeviceCertificate(mallory.PrivateKey, &claimed)
+	if err := VerifyDeviceCertificate(&claimed); err == nil {
+		t.Error("the signature of the device was reused for another identity")
+	}
+	renamed := *certificate
+	renamed.Name = "phone"
+	if err := VerifyDeviceCertificate(&renamed); err == nil {
+		t.Error("a modified certificate was accepted")
+	}
+}
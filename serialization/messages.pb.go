@@ -11,6 +11,37 @@ It has these top-level messages:
 	Request
 	ResponseSuccess
 	ResponseMessage
+	ResponseBlob
+	ResponseOrganizationMembers
+	ResponseRevocations
+	ResponseProofs
+	ResponseKeyRotations
+	ResponseDevices
+	ResponseTreeHead
+	ResponseConsistencyProof
+	ResponseLogEntries
+	ResponseKeyPackage
+	Payload
+	KeyRotationStatement
+	DeviceCertificate
+	DeviceLink
+	MLSKeyPackage
+	MLSNode
+	MLSProposal
+	MLSUpdatePathNode
+	MLSUpdatePath
+	MLSCommit
+	MLSHandshake
+	MLSWelcome
+	MLSGroupState
+	MembershipCertificate
+	Revocation
+	LogEntry
+	TreeHead
+	LogEntryProof
+	VerificationProof
+	Backup
+	BackupFile
 */
 package serialization
 
@@ -29,6 +60,66 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+// Failures that the clients can tell apart
+type ErrorCode int32
+
+const (
+	// no code, see the error message
+	ErrorCode_NoCode ErrorCode = 0
+	// the sender sent too many messages, it can retry after retryAfter seconds
+	ErrorCode_RateLimited ErrorCode = 1
+	// the recipient has too many messages, or bytes, waiting to be fetched
+	ErrorCode_RecipientQuotaExceeded ErrorCode = 2
+)
+
+var ErrorCode_name = map[int32]string{
+	0: "NoCode",
+	1: "RateLimited",
+	2: "RecipientQuotaExceeded",
+}
+var ErrorCode_value = map[string]int32{
+	"NoCode":                 0,
+	"RateLimited":            1,
+	"RecipientQuotaExceeded": 2,
+}
+
+func (x ErrorCode) String() string {
+	return proto.EnumName(ErrorCode_name, int32(x))
+}
+func (ErrorCode) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+// Tells the recipient which keys were used to encrypt a message
+type MessageKind int32
+
+const (
+	// encrypted with the session keys of a conversation between two peers
+	MessageKind_Direct MessageKind = 0
+	// encrypted with the sender key of a group member, the convo_id is the group id
+	MessageKind_Group MessageKind = 1
+	// an MLSWelcome encrypted to one of our key packages, the convo_id is the group id
+	MessageKind_Welcome MessageKind = 2
+	// an MLSHandshake (proposal or commit) for an MLS group, the convo_id is the group id
+	MessageKind_Handshake MessageKind = 3
+)
+
+var MessageKind_name = map[int32]string{
+	0: "Direct",
+	1: "Group",
+	2: "Welcome",
+	3: "Handshake",
+}
+var MessageKind_value = map[string]int32{
+	"Direct":    0,
+	"Group":     1,
+	"Welcome":   2,
+	"Handshake": 3,
+}
+
+func (x MessageKind) String() string {
+	return proto.EnumName(MessageKind_name, int32(x))
+}
+func (MessageKind) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
 type Request_RequestType int32
 
 const (
@@ -38,15 +129,43 @@ const (
 	Request_GetOrganizationMembers Request_RequestType = 3
 	Request_GetProofsForMember     Request_RequestType = 4
 	Request_PublishProof           Request_RequestType = 5
+	Request_UploadBlob             Request_RequestType = 6
+	Request_DownloadBlob           Request_RequestType = 7
+	Request_PublishKeyPackage      Request_RequestType = 8
+	Request_GetKeyPackage          Request_RequestType = 9
+	Request_GetRevocations         Request_RequestType = 10
+	Request_GetTreeHead            Request_RequestType = 11
+	Request_GetConsistencyProof    Request_RequestType = 12
+	Request_GetLogEntries          Request_RequestType = 13
+	Request_PublishKeyRotation     Request_RequestType = 14
+	Request_GetKeyRotations        Request_RequestType = 15
+	Request_PublishDevice          Request_RequestType = 16
+	Request_GetDevices             Request_RequestType = 17
+	Request_RemoveDevice           Request_RequestType = 18
+	Request_ForwardMessage         Request_RequestType = 19
 )
 
 var Request_RequestType_name = map[int32]string{
-	0: "GetNothing",
-	1: "GetNextMessage",
-	2: "SendMessage",
-	3: "GetOrganizationMembers",
-	4: "GetProofsForMember",
-	5: "PublishProof",
+	0:  "GetNothing",
+	1:  "GetNextMessage",
+	2:  "SendMessage",
+	3:  "GetOrganizationMembers",
+	4:  "GetProofsForMember",
+	5:  "PublishProof",
+	6:  "UploadBlob",
+	7:  "DownloadBlob",
+	8:  "PublishKeyPackage",
+	9:  "GetKeyPackage",
+	10: "GetRevocations",
+	11: "GetTreeHead",
+	12: "GetConsistencyProof",
+	13: "GetLogEntries",
+	14: "PublishKeyRotation",
+	15: "GetKeyRotations",
+	16: "PublishDevice",
+	17: "GetDevices",
+	18: "RemoveDevice",
+	19: "ForwardMessage",
 }
 var Request_RequestType_value = map[string]int32{
 	"GetNothing":             0,
@@ -55,6 +174,20 @@ var Request_RequestType_value = map[string]int32{
 	"GetOrganizationMembers": 3,
 	"GetProofsForMember":     4,
 	"PublishProof":           5,
+	"UploadBlob":             6,
+	"DownloadBlob":           7,
+	"PublishKeyPackage":      8,
+	"GetKeyPackage":          9,
+	"GetRevocations":         10,
+	"GetTreeHead":            11,
+	"GetConsistencyProof":    12,
+	"GetLogEntries":          13,
+	"PublishKeyRotation":     14,
+	"GetKeyRotations":        15,
+	"PublishDevice":          16,
+	"GetDevices":             17,
+	"RemoveDevice":           18,
+	"ForwardMessage":         19,
 }
 
 func (x Request_RequestType) String() string {
@@ -62,10 +195,117 @@ func (x Request_RequestType) String() string {
 }
 func (Request_RequestType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 0} }
 
+type Payload_PayloadType int32
+
+const (
+	Payload_Text              Payload_PayloadType = 0
+	Payload_Edit              Payload_PayloadType = 1
+	Payload_Delete            Payload_PayloadType = 2
+	Payload_Attachment        Payload_PayloadType = 3
+	Payload_GroupInvite       Payload_PayloadType = 4
+	Payload_GroupSenderKey    Payload_PayloadType = 5
+	Payload_GroupAddMember    Payload_PayloadType = 6
+	Payload_GroupRemoveMember Payload_PayloadType = 7
+	Payload_IssueOpen         Payload_PayloadType = 8
+	Payload_IssueUpdate       Payload_PayloadType = 9
+	Payload_Gossip            Payload_PayloadType = 10
+	Payload_KeyRotation       Payload_PayloadType = 11
+	Payload_DeviceLink        Payload_PayloadType = 12
+)
+
+var Payload_PayloadType_name = map[int32]string{
+	0:  "Text",
+	1:  "Edit",
+	2:  "Delete",
+	3:  "Attachment",
+	4:  "GroupInvite",
+	5:  "GroupSenderKey",
+	6:  "GroupAddMember",
+	7:  "GroupRemoveMember",
+	8:  "IssueOpen",
+	9:  "IssueUpdate",
+	10: "Gossip",
+	11: "KeyRotation",
+	12: "DeviceLink",
+}
+var Payload_PayloadType_value = map[string]int32{
+	"Text":              0,
+	"Edit":              1,
+	"Delete":            2,
+	"Attachment":        3,
+	"GroupInvite":       4,
+	"GroupSenderKey":    5,
+	"GroupAddMember":    6,
+	"GroupRemoveMember": 7,
+	"IssueOpen":         8,
+	"IssueUpdate":       9,
+	"Gossip":            10,
+	"KeyRotation":       11,
+	"DeviceLink":        12,
+}
+
+func (x Payload_PayloadType) String() string {
+	return proto.EnumName(Payload_PayloadType_name, int32(x))
+}
+func (Payload_PayloadType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{13, 0} }
+
+type MLSProposal_ProposalType int32
+
+const (
+	MLSProposal_Add    MLSProposal_ProposalType = 0
+	MLSProposal_Remove MLSProposal_ProposalType = 1
+)
+
+var MLSProposal_ProposalType_name = map[int32]string{
+	0: "Add",
+	1: "Remove",
+}
+var MLSProposal_ProposalType_value = map[string]int32{
+	"Add":    0,
+	"Remove": 1,
+}
+
+func (x MLSProposal_ProposalType) String() string {
+	return proto.EnumName(MLSProposal_ProposalType_name, int32(x))
+}
+func (MLSProposal_ProposalType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{19, 0}
+}
+
+type MLSHandshake_HandshakeType int32
+
+const (
+	MLSHandshake_Proposal MLSHandshake_HandshakeType = 0
+	MLSHandshake_Commit   MLSHandshake_HandshakeType = 1
+)
+
+var MLSHandshake_HandshakeType_name = map[int32]string{
+	0: "Proposal",
+	1: "Commit",
+}
+var MLSHandshake_HandshakeType_value = map[string]int32{
+	"Proposal": 0,
+	"Commit":   1,
+}
+
+func (x MLSHandshake_HandshakeType) String() string {
+	return proto.EnumName(MLSHandshake_HandshakeType_name, int32(x))
+}
+func (MLSHandshake_HandshakeType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{23, 0}
+}
+
 // A unique Request message with all the different types of requests
 type Request struct {
 	RequestType Request_RequestType `protobuf:"varint,1,opt,name=requestType,enum=serialization.Request_RequestType" json:"requestType,omitempty"`
 	Message     *Request_Message    `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+	Blob        *Request_Blob       `protobuf:"bytes,3,opt,name=blob" json:"blob,omitempty"`
+	KeyPackage  *Request_KeyPackage `protobuf:"bytes,4,opt,name=keyPackage" json:"keyPackage,omitempty"`
+	Directory   *Request_Directory  `protobuf:"bytes,5,opt,name=directory" json:"directory,omitempty"`
+	Log         *Request_Log        `protobuf:"bytes,6,opt,name=log" json:"log,omitempty"`
+	Proof       *Request_Proof      `protobuf:"bytes,7,opt,name=proof" json:"proof,omitempty"`
+	Rotation    *Request_Rotation   `protobuf:"bytes,8,opt,name=rotation" json:"rotation,omitempty"`
+	Device      *Request_Device     `protobuf:"bytes,9,opt,name=device" json:"device,omitempty"`
 }
 
 func (m *Request) Reset()                    { *m = Request{} }
@@ -87,10 +327,63 @@ func (m *Request) GetMessage() *Request_Message {
 	return nil
 }
 
+func (m *Request) GetBlob() *Request_Blob {
+	if m != nil {
+		return m.Blob
+	}
+	return nil
+}
+
+func (m *Request) GetKeyPackage() *Request_KeyPackage {
+	if m != nil {
+		return m.KeyPackage
+	}
+	return nil
+}
+
+func (m *Request) GetDirectory() *Request_Directory {
+	if m != nil {
+		return m.Directory
+	}
+	return nil
+}
+
+func (m *Request) GetLog() *Request_Log {
+	if m != nil {
+		return m.Log
+	}
+	return nil
+}
+
+func (m *Request) GetProof() *Request_Proof {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
+func (m *Request) GetRotation() *Request_Rotation {
+	if m != nil {
+		return m.Rotation
+	}
+	return nil
+}
+
+func (m *Request) GetDevice() *Request_Device {
+	if m != nil {
+		return m.Device
+	}
+	return nil
+}
+
+// toAddress is <key>@<hub> for a recipient on a peer Hub (see server/delivery.go)
 type Request_Message struct {
-	ToAddress string `protobuf:"bytes,1,opt,name=toAddress" json:"toAddress,omitempty"`
-	ConvoId   string `protobuf:"bytes,2,opt,name=convo_id,json=convoId" json:"convo_id,omitempty"`
-	Content   []byte `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	ToAddress string      `protobuf:"bytes,1,opt,name=toAddress" json:"toAddress,omitempty"`
+	ConvoId   string      `protobuf:"bytes,2,opt,name=convo_id,json=convoId" json:"convo_id,omitempty"`
+	Content   []byte      `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	Kind      MessageKind `protobuf:"varint,4,opt,name=kind,enum=serialization.MessageKind" json:"kind,omitempty"`
+	// the sender, when a Hub forwards the message of one of its clients (ForwardMessage)
+	FromAddress string `protobuf:"bytes,5,opt,name=fromAddress" json:"fromAddress,omitempty"`
 }
 
 func (m *Request_Message) Reset()                    { *m = Request_Message{} }
@@ -119,10 +412,222 @@ func (m *Request_Message) GetContent() []byte {
 	return nil
 }
 
+func (m *Request_Message) GetKind() MessageKind {
+	if m != nil {
+		return m.Kind
+	}
+	return MessageKind_Direct
+}
+
+func (m *Request_Message) GetFromAddress() string {
+	if m != nil {
+		return m.FromAddress
+	}
+	return ""
+}
+
+// a chunk of an encrypted attachment
+type Request_Blob struct {
+	Id      string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Content []byte `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+}
+
+func (m *Request_Blob) Reset()                    { *m = Request_Blob{} }
+func (m *Request_Blob) String() string            { return proto.CompactTextString(m) }
+func (*Request_Blob) ProtoMessage()               {}
+func (*Request_Blob) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 1} }
+
+func (m *Request_Blob) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Request_Blob) GetContent() []byte {
+	if m != nil {
+		return m.Content
+	}
+	return nil
+}
+
+// a serialized MLSKeyPackage, to publish or to fetch for an owner
+type Request_KeyPackage struct {
+	Owner   string `protobuf:"bytes,1,opt,name=owner" json:"owner,omitempty"`
+	Content []byte `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+}
+
+func (m *Request_KeyPackage) Reset()                    { *m = Request_KeyPackage{} }
+func (m *Request_KeyPackage) String() string            { return proto.CompactTextString(m) }
+func (*Request_KeyPackage) ProtoMessage()               {}
+func (*Request_KeyPackage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 2} }
+
+func (m *Request_KeyPackage) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *Request_KeyPackage) GetContent() []byte {
+	if m != nil {
+		return m.Content
+	}
+	return nil
+}
+
+// a search in the directory of the organization (an empty query returns everyone),
+// or a page of its revocations (without a query)
+type Request_Directory struct {
+	Query  string `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	Offset uint32 `protobuf:"varint,2,opt,name=offset" json:"offset,omitempty"`
+	Limit  uint32 `protobuf:"varint,3,opt,name=limit" json:"limit,omitempty"`
+}
+
+func (m *Request_Directory) Reset()                    { *m = Request_Directory{} }
+func (m *Request_Directory) String() string            { return proto.CompactTextString(m) }
+func (*Request_Directory) ProtoMessage()               {}
+func (*Request_Directory) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 3} }
+
+func (m *Request_Directory) GetQuery() string {
+	if m != nil {
+		return m.Query
+	}
+	return ""
+}
+
+func (m *Request_Directory) GetOffset() uint32 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *Request_Directory) GetLimit() uint32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+// a serialized VerificationProof to publish, or the member whose proofs we want
+type Request_Proof struct {
+	Member  string `protobuf:"bytes,1,opt,name=member" json:"member,omitempty"`
+	Content []byte `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+}
+
+func (m *Request_Proof) Reset()                    { *m = Request_Proof{} }
+func (m *Request_Proof) String() string            { return proto.CompactTextString(m) }
+func (*Request_Proof) ProtoMessage()               {}
+func (*Request_Proof) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 4} }
+
+func (m *Request_Proof) GetMember() string {
+	if m != nil {
+		return m.Member
+	}
+	return ""
+}
+
+func (m *Request_Proof) GetContent() []byte {
+	if m != nil {
+		return m.Content
+	}
+	return nil
+}
+
+// a part of the transparency log: a consistency proof from the tree of `first` entries to the tree
+// of `second` entries, or the entries [first, second) with their inclusion proofs in the tree of `second` entries
+type Request_Log struct {
+	First  uint64 `protobuf:"varint,1,opt,name=first" json:"first,omitempty"`
+	Second uint64 `protobuf:"varint,2,opt,name=second" json:"second,omitempty"`
+}
+
+func (m *Request_Log) Reset()                    { *m = Request_Log{} }
+func (m *Request_Log) String() string            { return proto.CompactTextString(m) }
+func (*Request_Log) ProtoMessage()               {}
+func (*Request_Log) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 5} }
+
+func (m *Request_Log) GetFirst() uint64 {
+	if m != nil {
+		return m.First
+	}
+	return 0
+}
+
+func (m *Request_Log) GetSecond() uint64 {
+	if m != nil {
+		return m.Second
+	}
+	return 0
+}
+
+// a serialized KeyRotationStatement to publish, or the keys whose rotations we want
+type Request_Rotation struct {
+	Statement []byte   `protobuf:"bytes,1,opt,name=statement,proto3" json:"statement,omitempty"`
+	Keys      []string `protobuf:"bytes,2,rep,name=keys" json:"keys,omitempty"`
+}
+
+func (m *Request_Rotation) Reset()                    { *m = Request_Rotation{} }
+func (m *Request_Rotation) String() string            { return proto.CompactTextString(m) }
+func (*Request_Rotation) ProtoMessage()               {}
+func (*Request_Rotation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 6} }
+
+func (m *Request_Rotation) GetStatement() []byte {
+	if m != nil {
+		return m.Statement
+	}
+	return nil
+}
+
+func (m *Request_Rotation) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+// a serialized DeviceCertificate to publish, the device to remove, or the keys whose devices we want
+// (identities or devices)
+type Request_Device struct {
+	Certificate []byte   `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	Device      string   `protobuf:"bytes,2,opt,name=device" json:"device,omitempty"`
+	Keys        []string `protobuf:"bytes,3,rep,name=keys" json:"keys,omitempty"`
+}
+
+func (m *Request_Device) Reset()                    { *m = Request_Device{} }
+func (m *Request_Device) String() string            { return proto.CompactTextString(m) }
+func (*Request_Device) ProtoMessage()               {}
+func (*Request_Device) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 7} }
+
+func (m *Request_Device) GetCertificate() []byte {
+	if m != nil {
+		return m.Certificate
+	}
+	return nil
+}
+
+func (m *Request_Device) GetDevice() string {
+	if m != nil {
+		return m.Device
+	}
+	return ""
+}
+
+func (m *Request_Device) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
 // Simple Response
 type ResponseSuccess struct {
 	Success bool   `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
 	Error   string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	// why the request was refused, for the failures a client can act upon (see server/limits.go)
+	Code ErrorCode `protobuf:"varint,3,opt,name=code,enum=serialization.ErrorCode" json:"code,omitempty"`
+	// for RateLimited, the seconds to wait before trying again
+	RetryAfter uint32 `protobuf:"varint,4,opt,name=retryAfter" json:"retryAfter,omitempty"`
 }
 
 func (m *ResponseSuccess) Reset()                    { *m = ResponseSuccess{} }
@@ -144,11 +649,26 @@ func (m *ResponseSuccess) GetError() string {
 	return ""
 }
 
+func (m *ResponseSuccess) GetCode() ErrorCode {
+	if m != nil {
+		return m.Code
+	}
+	return ErrorCode_NoCode
+}
+
+func (m *ResponseSuccess) GetRetryAfter() uint32 {
+	if m != nil {
+		return m.RetryAfter
+	}
+	return 0
+}
+
 // Response with a message
 type ResponseMessage struct {
-	FromAddress string `protobuf:"bytes,1,opt,name=fromAddress" json:"fromAddress,omitempty"`
-	ConvoId     string `protobuf:"bytes,2,opt,name=convo_id,json=convoId" json:"convo_id,omitempty"`
-	Content     []byte `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	FromAddress string      `protobuf:"bytes,1,opt,name=fromAddress" json:"fromAddress,omitempty"`
+	ConvoId     string      `protobuf:"bytes,2,opt,name=convo_id,json=convoId" json:"convo_id,omitempty"`
+	Content     []byte      `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	Kind        MessageKind `protobuf:"varint,4,opt,name=kind,enum=serialization.MessageKind" json:"kind,omitempty"`
 }
 
 func (m *ResponseMessage) Reset()                    { *m = ResponseMessage{} }
@@ -177,37 +697,1817 @@ func (m *ResponseMessage) GetContent() []byte {
 	return nil
 }
 
+func (m *ResponseMessage) GetKind() MessageKind {
+	if m != nil {
+		return m.Kind
+	}
+	return MessageKind_Direct
+}
+
+// Response to an UploadBlob or DownloadBlob request
+type ResponseBlob struct {
+	Success bool   `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error   string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Id      string `protobuf:"bytes,3,opt,name=id" json:"id,omitempty"`
+	Content []byte `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+}
+
+func (m *ResponseBlob) Reset()                    { *m = ResponseBlob{} }
+func (m *ResponseBlob) String() string            { return proto.CompactTextString(m) }
+func (*ResponseBlob) ProtoMessage()               {}
+func (*ResponseBlob) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *ResponseBlob) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ResponseBlob) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ResponseBlob) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *ResponseBlob) GetContent() []byte {
+	if m != nil {
+		return m.Content
+	}
+	return nil
+}
+
+// Response to a GetOrganizationMembers request: serialized MembershipCertificates, and how many members match the query
+type ResponseOrganizationMembers struct {
+	Success      bool     `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error        string   `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Certificates [][]byte `protobuf:"bytes,3,rep,name=certificates,proto3" json:"certificates,omitempty"`
+	Total        uint32   `protobuf:"varint,4,opt,name=total" json:"total,omitempty"`
+}
+
+func (m *ResponseOrganizationMembers) Reset()                    { *m = ResponseOrganizationMembers{} }
+func (m *ResponseOrganizationMembers) String() string            { return proto.CompactTextString(m) }
+func (*ResponseOrganizationMembers) ProtoMessage()               {}
+func (*ResponseOrganizationMembers) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *ResponseOrganizationMembers) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ResponseOrganizationMembers) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ResponseOrganizationMembers) GetCertificates() [][]byte {
+	if m != nil {
+		return m.Certificates
+	}
+	return nil
+}
+
+func (m *ResponseOrganizationMembers) GetTotal() uint32 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+// Response to a GetRevocations request: a page of the serialized Revocations of the organization, and how many there are
+type ResponseRevocations struct {
+	Success     bool     `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error       string   `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Revocations [][]byte `protobuf:"bytes,3,rep,name=revocations,proto3" json:"revocations,omitempty"`
+	Total       uint32   `protobuf:"varint,4,opt,name=total" json:"total,omitempty"`
+}
+
+func (m *ResponseRevocations) Reset()                    { *m = ResponseRevocations{} }
+func (m *ResponseRevocations) String() string            { return proto.CompactTextString(m) }
+func (*ResponseRevocations) ProtoMessage()               {}
+func (*ResponseRevocations) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *ResponseRevocations) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ResponseRevocations) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ResponseRevocations) GetRevocations() [][]byte {
+	if m != nil {
+		return m.Revocations
+	}
+	return nil
+}
+
+func (m *ResponseRevocations) GetTotal() uint32 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+// Response to a GetProofsForMember request: serialized VerificationProofs
+type ResponseProofs struct {
+	Success bool     `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error   string   `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Proofs  [][]byte `protobuf:"bytes,3,rep,name=proofs,proto3" json:"proofs,omitempty"`
+}
+
+func (m *ResponseProofs) Reset()                    { *m = ResponseProofs{} }
+func (m *ResponseProofs) String() string            { return proto.CompactTextString(m) }
+func (*ResponseProofs) ProtoMessage()               {}
+func (*ResponseProofs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *ResponseProofs) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ResponseProofs) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ResponseProofs) GetProofs() [][]byte {
+	if m != nil {
+		return m.Proofs
+	}
+	return nil
+}
+
+// Response to a GetKeyRotations request
+type ResponseKeyRotations struct {
+	Success    bool     `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error      string   `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Statements [][]byte `protobuf:"bytes,3,rep,name=statements,proto3" json:"statements,omitempty"`
+}
+
+func (m *ResponseKeyRotations) Reset()                    { *m = ResponseKeyRotations{} }
+func (m *ResponseKeyRotations) String() string            { return proto.CompactTextString(m) }
+func (*ResponseKeyRotations) ProtoMessage()               {}
+func (*ResponseKeyRotations) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *ResponseKeyRotations) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ResponseKeyRotations) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ResponseKeyRotations) GetStatements() [][]byte {
+	if m != nil {
+		return m.Statements
+	}
+	return nil
+}
+
+// Response to a GetDevices request: serialized DeviceCertificates
+type ResponseDevices struct {
+	Success      bool     `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error        string   `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Certificates [][]byte `protobuf:"bytes,3,rep,name=certificates,proto3" json:"certificates,omitempty"`
+}
+
+func (m *ResponseDevices) Reset()                    { *m = ResponseDevices{} }
+func (m *ResponseDevices) String() string            { return proto.CompactTextString(m) }
+func (*ResponseDevices) ProtoMessage()               {}
+func (*ResponseDevices) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *ResponseDevices) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ResponseDevices) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ResponseDevices) GetCertificates() [][]byte {
+	if m != nil {
+		return m.Certificates
+	}
+	return nil
+}
+
+// Response to a GetTreeHead request
+type ResponseTreeHead struct {
+	Success  bool      `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error    string    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	TreeHead *TreeHead `protobuf:"bytes,3,opt,name=treeHead" json:"treeHead,omitempty"`
+}
+
+func (m *ResponseTreeHead) Reset()                    { *m = ResponseTreeHead{} }
+func (m *ResponseTreeHead) String() string            { return proto.CompactTextString(m) }
+func (*ResponseTreeHead) ProtoMessage()               {}
+func (*ResponseTreeHead) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *ResponseTreeHead) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ResponseTreeHead) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ResponseTreeHead) GetTreeHead() *TreeHead {
+	if m != nil {
+		return m.TreeHead
+	}
+	return nil
+}
+
+// Response to a GetConsistencyProof request
+type ResponseConsistencyProof struct {
+	Success bool     `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error   string   `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Proof   [][]byte `protobuf:"bytes,3,rep,name=proof,proto3" json:"proof,omitempty"`
+}
+
+func (m *ResponseConsistencyProof) Reset()                    { *m = ResponseConsistencyProof{} }
+func (m *ResponseConsistencyProof) String() string            { return proto.CompactTextString(m) }
+func (*ResponseConsistencyProof) ProtoMessage()               {}
+func (*ResponseConsistencyProof) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *ResponseConsistencyProof) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ResponseConsistencyProof) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ResponseConsistencyProof) GetProof() [][]byte {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
+// Response to a GetLogEntries request
+type ResponseLogEntries struct {
+	Success bool             `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error   string           `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Entries []*LogEntryProof `protobuf:"bytes,3,rep,name=entries" json:"entries,omitempty"`
+}
+
+func (m *ResponseLogEntries) Reset()                    { *m = ResponseLogEntries{} }
+func (m *ResponseLogEntries) String() string            { return proto.CompactTextString(m) }
+func (*ResponseLogEntries) ProtoMessage()               {}
+func (*ResponseLogEntries) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *ResponseLogEntries) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ResponseLogEntries) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ResponseLogEntries) GetEntries() []*LogEntryProof {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+// Response to a GetKeyPackage request
+type ResponseKeyPackage struct {
+	Success bool   `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error   string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Owner   string `protobuf:"bytes,3,opt,name=owner" json:"owner,omitempty"`
+	Content []byte `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+}
+
+func (m *ResponseKeyPackage) Reset()                    { *m = ResponseKeyPackage{} }
+func (m *ResponseKeyPackage) String() string            { return proto.CompactTextString(m) }
+func (*ResponseKeyPackage) ProtoMessage()               {}
+func (*ResponseKeyPackage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *ResponseKeyPackage) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ResponseKeyPackage) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ResponseKeyPackage) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *ResponseKeyPackage) GetContent() []byte {
+	if m != nil {
+		return m.Content
+	}
+	return nil
+}
+
+// What is encrypted end-to-end between two peers
+type Payload struct {
+	PayloadType Payload_PayloadType `protobuf:"varint,1,opt,name=payloadType,enum=serialization.Payload_PayloadType" json:"payloadType,omitempty"`
+	Id          string              `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Content     string              `protobuf:"bytes,3,opt,name=content" json:"content,omitempty"`
+	// the id of the message being edited or deleted
+	Reference  string         `protobuf:"bytes,4,opt,name=reference" json:"reference,omitempty"`
+	Attachment *Payload_File  `protobuf:"bytes,5,opt,name=attachment" json:"attachment,omitempty"`
+	Group      *Payload_Group `protobuf:"bytes,6,opt,name=group" json:"group,omitempty"`
+	Issue      *Payload_Issue `protobuf:"bytes,7,opt,name=issue" json:"issue,omitempty"`
+	// the latest serialized TreeHead of the sender (only for gossip)
+	TreeHead []byte `protobuf:"bytes,8,opt,name=treeHead,proto3" json:"treeHead,omitempty"`
+	// a serialized KeyRotationStatement (only for key rotations)
+	KeyRotation []byte `protobuf:"bytes,9,opt,name=keyRotation,proto3" json:"keyRotation,omitempty"`
+	// a serialized DeviceLink (only for device links)
+	DeviceLink []byte `protobuf:"bytes,10,opt,name=deviceLink,proto3" json:"deviceLink,omitempty"`
+	// the contact a conversation is with, when we send a copy of our messages to our other devices
+	Peer string `protobuf:"bytes,11,opt,name=peer" json:"peer,omitempty"`
+}
+
+func (m *Payload) Reset()                    { *m = Payload{} }
+func (m *Payload) String() string            { return proto.CompactTextString(m) }
+func (*Payload) ProtoMessage()               {}
+func (*Payload) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *Payload) GetPayloadType() Payload_PayloadType {
+	if m != nil {
+		return m.PayloadType
+	}
+	return Payload_Text
+}
+
+func (m *Payload) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Payload) GetContent() string {
+	if m != nil {
+		return m.Content
+	}
+	return ""
+}
+
+func (m *Payload) GetReference() string {
+	if m != nil {
+		return m.Reference
+	}
+	return ""
+}
+
+func (m *Payload) GetAttachment() *Payload_File {
+	if m != nil {
+		return m.Attachment
+	}
+	return nil
+}
+
+func (m *Payload) GetGroup() *Payload_Group {
+	if m != nil {
+		return m.Group
+	}
+	return nil
+}
+
+func (m *Payload) GetIssue() *Payload_Issue {
+	if m != nil {
+		return m.Issue
+	}
+	return nil
+}
+
+func (m *Payload) GetTreeHead() []byte {
+	if m != nil {
+		return m.TreeHead
+	}
+	return nil
+}
+
+func (m *Payload) GetKeyRotation() []byte {
+	if m != nil {
+		return m.KeyRotation
+	}
+	return nil
+}
+
+func (m *Payload) GetDeviceLink() []byte {
+	if m != nil {
+		return m.DeviceLink
+	}
+	return nil
+}
+
+func (m *Payload) GetPeer() string {
+	if m != nil {
+		return m.Peer
+	}
+	return ""
+}
+
+// an encrypted file stored in the Hub's blob store
+type Payload_File struct {
+	Name  string   `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Size  uint64   `protobuf:"varint,2,opt,name=size" json:"size,omitempty"`
+	Key   []byte   `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
+	Hash  []byte   `protobuf:"bytes,4,opt,name=hash,proto3" json:"hash,omitempty"`
+	Blobs []string `protobuf:"bytes,5,rep,name=blobs" json:"blobs,omitempty"`
+}
+
+func (m *Payload_File) Reset()                    { *m = Payload_File{} }
+func (m *Payload_File) String() string            { return proto.CompactTextString(m) }
+func (*Payload_File) ProtoMessage()               {}
+func (*Payload_File) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13, 0} }
+
+func (m *Payload_File) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Payload_File) GetSize() uint64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *Payload_File) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *Payload_File) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *Payload_File) GetBlobs() []string {
+	if m != nil {
+		return m.Blobs
+	}
+	return nil
+}
+
+// a control message about a group, sent in a conversation between two members
+type Payload_Group struct {
+	Id      string   `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Title   string   `protobuf:"bytes,2,opt,name=title" json:"title,omitempty"`
+	Members []string `protobuf:"bytes,3,rep,name=members" json:"members,omitempty"`
+	// the member being added or removed
+	Member string `protobuf:"bytes,4,opt,name=member" json:"member,omitempty"`
+	// the serialized strobe state used by the sender to encrypt its group messages
+	SenderKey  []byte `protobuf:"bytes,5,opt,name=senderKey,proto3" json:"senderKey,omitempty"`
+	Generation uint32 `protobuf:"varint,6,opt,name=generation" json:"generation,omitempty"`
+	// "chat" or "issues"
+	Channel string `protobuf:"bytes,7,opt,name=channel" json:"channel,omitempty"`
+}
+
+func (m *Payload_Group) Reset()                    { *m = Payload_Group{} }
+func (m *Payload_Group) String() string            { return proto.CompactTextString(m) }
+func (*Payload_Group) ProtoMessage()               {}
+func (*Payload_Group) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13, 1} }
+
+func (m *Payload_Group) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Payload_Group) GetTitle() string {
+	if m != nil {
+		return m.Title
+	}
+	return ""
+}
+
+func (m *Payload_Group) GetMembers() []string {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+func (m *Payload_Group) GetMember() string {
+	if m != nil {
+		return m.Member
+	}
+	return ""
+}
+
+func (m *Payload_Group) GetSenderKey() []byte {
+	if m != nil {
+		return m.SenderKey
+	}
+	return nil
+}
+
+func (m *Payload_Group) GetGeneration() uint32 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
+func (m *Payload_Group) GetChannel() string {
+	if m != nil {
+		return m.Channel
+	}
+	return ""
+}
+
+// the issue a message is about, in an issue tracker channel
+type Payload_Issue struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	// "open" or "closed" (only for issue updates)
+	State     string   `protobuf:"bytes,2,opt,name=state" json:"state,omitempty"`
+	Labels    []string `protobuf:"bytes,3,rep,name=labels" json:"labels,omitempty"`
+	Assignees []string `protobuf:"bytes,4,rep,name=assignees" json:"assignees,omitempty"`
+}
+
+func (m *Payload_Issue) Reset()                    { *m = Payload_Issue{} }
+func (m *Payload_Issue) String() string            { return proto.CompactTextString(m) }
+func (*Payload_Issue) ProtoMessage()               {}
+func (*Payload_Issue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13, 2} }
+
+func (m *Payload_Issue) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Payload_Issue) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *Payload_Issue) GetLabels() []string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *Payload_Issue) GetAssignees() []string {
+	if m != nil {
+		return m.Assignees
+	}
+	return nil
+}
+
+// A new key for someone, signed by their previous key (XEdDSA), sent to their contacts
+// in their existing conversations (see rotation.go)
+type KeyRotationStatement struct {
+	OldKey []byte `protobuf:"bytes,1,opt,name=oldKey,proto3" json:"oldKey,omitempty"`
+	NewKey []byte `protobuf:"bytes,2,opt,name=newKey,proto3" json:"newKey,omitempty"`
+	// unix timestamp
+	Date int64 `protobuf:"varint,3,opt,name=date" json:"date,omitempty"`
+	// XEdDSA signature of the old key over the fields above
+	Signature []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *KeyRotationStatement) Reset()                    { *m = KeyRotationStatement{} }
+func (m *KeyRotationStatement) String() string            { return proto.CompactTextString(m) }
+func (*KeyRotationStatement) ProtoMessage()               {}
+func (*KeyRotationStatement) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *KeyRotationStatement) GetOldKey() []byte {
+	if m != nil {
+		return m.OldKey
+	}
+	return nil
+}
+
+func (m *KeyRotationStatement) GetNewKey() []byte {
+	if m != nil {
+		return m.NewKey
+	}
+	return nil
+}
+
+func (m *KeyRotationStatement) GetDate() int64 {
+	if m != nil {
+		return m.Date
+	}
+	return 0
+}
+
+func (m *KeyRotationStatement) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// A device of someone, certified by their identity key and countersigned by the device key (XEdDSA).
+// Messages to someone are encrypted to each of their devices (see device.go)
+type DeviceCertificate struct {
+	IdentityKey []byte `protobuf:"bytes,1,opt,name=identityKey,proto3" json:"identityKey,omitempty"`
+	DeviceKey   []byte `protobuf:"bytes,2,opt,name=deviceKey,proto3" json:"deviceKey,omitempty"`
+	// the name of the device (e.g. "laptop")
+	Name string `protobuf:"bytes,3,opt,name=name" json:"name,omitempty"`
+	// unix timestamp
+	Date int64 `protobuf:"varint,4,opt,name=date" json:"date,omitempty"`
+	// XEdDSA signature of the identity key over the fields above
+	Signature []byte `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+	// XEdDSA signature of the device key over the same fields: the device agrees to belong to the identity
+	DeviceSignature []byte `protobuf:"bytes,6,opt,name=deviceSignature,proto3" json:"deviceSignature,omitempty"`
+}
+
+func (m *DeviceCertificate) Reset()                    { *m = DeviceCertificate{} }
+func (m *DeviceCertificate) String() string            { return proto.CompactTextString(m) }
+func (*DeviceCertificate) ProtoMessage()               {}
+func (*DeviceCertificate) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *DeviceCertificate) GetIdentityKey() []byte {
+	if m != nil {
+		return m.IdentityKey
+	}
+	return nil
+}
+
+func (m *DeviceCertificate) GetDeviceKey() []byte {
+	if m != nil {
+		return m.DeviceKey
+	}
+	return nil
+}
+
+func (m *DeviceCertificate) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *DeviceCertificate) GetDate() int64 {
+	if m != nil {
+		return m.Date
+	}
+	return 0
+}
+
+func (m *DeviceCertificate) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+func (m *DeviceCertificate) GetDeviceSignature() []byte {
+	if m != nil {
+		return m.DeviceSignature
+	}
+	return nil
+}
+
+// Sent by our primary device to a new device, once it has been linked
+type DeviceLink struct {
+	// the serialized DeviceCertificate of the new device
+	Certificate []byte `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	// the secret of the one-time code displayed by the new device
+	Secret []byte `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
+	// the contacts of our identity
+	Contacts []*DeviceLink_Contact `protobuf:"bytes,3,rep,name=contacts" json:"contacts,omitempty"`
+}
+
+func (m *DeviceLink) Reset()                    { *m = DeviceLink{} }
+func (m *DeviceLink) String() string            { return proto.CompactTextString(m) }
+func (*DeviceLink) ProtoMessage()               {}
+func (*DeviceLink) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *DeviceLink) GetCertificate() []byte {
+	if m != nil {
+		return m.Certificate
+	}
+	return nil
+}
+
+func (m *DeviceLink) GetSecret() []byte {
+	if m != nil {
+		return m.Secret
+	}
+	return nil
+}
+
+func (m *DeviceLink) GetContacts() []*DeviceLink_Contact {
+	if m != nil {
+		return m.Contacts
+	}
+	return nil
+}
+
+type DeviceLink_Contact struct {
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	Name    string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *DeviceLink_Contact) Reset()                    { *m = DeviceLink_Contact{} }
+func (m *DeviceLink_Contact) String() string            { return proto.CompactTextString(m) }
+func (*DeviceLink_Contact) ProtoMessage()               {}
+func (*DeviceLink_Contact) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16, 0} }
+
+func (m *DeviceLink_Contact) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *DeviceLink_Contact) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+// MLS groups (see mls.go)
+//
+// Lets anyone add its owner to an MLS group while the owner is offline
+type MLSKeyPackage struct {
+	// the X25519 public key of the owner
+	Identity []byte `protobuf:"bytes,1,opt,name=identity,proto3" json:"identity,omitempty"`
+	// the key the welcome is encrypted to, it then becomes the owner's leaf in the ratchet tree
+	InitKey []byte `protobuf:"bytes,2,opt,name=initKey,proto3" json:"initKey,omitempty"`
+	// XEdDSA signature of the owner over identity and initKey
+	Signature []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *MLSKeyPackage) Reset()                    { *m = MLSKeyPackage{} }
+func (m *MLSKeyPackage) String() string            { return proto.CompactTextString(m) }
+func (*MLSKeyPackage) ProtoMessage()               {}
+func (*MLSKeyPackage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *MLSKeyPackage) GetIdentity() []byte {
+	if m != nil {
+		return m.Identity
+	}
+	return nil
+}
+
+func (m *MLSKeyPackage) GetInitKey() []byte {
+	if m != nil {
+		return m.InitKey
+	}
+	return nil
+}
+
+func (m *MLSKeyPackage) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// A node of the ratchet tree, a node without public key is blank
+type MLSNode struct {
+	PublicKey []byte `protobuf:"bytes,1,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	// for leaves, the X25519 public key of the member
+	Identity []byte `protobuf:"bytes,2,opt,name=identity,proto3" json:"identity,omitempty"`
+}
+
+func (m *MLSNode) Reset()                    { *m = MLSNode{} }
+func (m *MLSNode) String() string            { return proto.CompactTextString(m) }
+func (*MLSNode) ProtoMessage()               {}
+func (*MLSNode) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *MLSNode) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *MLSNode) GetIdentity() []byte {
+	if m != nil {
+		return m.Identity
+	}
+	return nil
+}
+
+// A change to the members of a group, applied by the next commit
+type MLSProposal struct {
+	ProposalType MLSProposal_ProposalType `protobuf:"varint,1,opt,name=proposalType,enum=serialization.MLSProposal_ProposalType" json:"proposalType,omitempty"`
+	KeyPackage   *MLSKeyPackage           `protobuf:"bytes,2,opt,name=keyPackage" json:"keyPackage,omitempty"`
+	// the leaf of the member to remove
+	Removed uint32 `protobuf:"varint,3,opt,name=removed" json:"removed,omitempty"`
+}
+
+func (m *MLSProposal) Reset()                    { *m = MLSProposal{} }
+func (m *MLSProposal) String() string            { return proto.CompactTextString(m) }
+func (*MLSProposal) ProtoMessage()               {}
+func (*MLSProposal) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *MLSProposal) GetProposalType() MLSProposal_ProposalType {
+	if m != nil {
+		return m.ProposalType
+	}
+	return MLSProposal_Add
+}
+
+func (m *MLSProposal) GetKeyPackage() *MLSKeyPackage {
+	if m != nil {
+		return m.KeyPackage
+	}
+	return nil
+}
+
+func (m *MLSProposal) GetRemoved() uint32 {
+	if m != nil {
+		return m.Removed
+	}
+	return 0
+}
+
+// A node of the committer's filtered direct path
+type MLSUpdatePathNode struct {
+	PublicKey []byte `protobuf:"bytes,1,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	// the path secret of the node, encrypted to each node of the resolution of its copath child
+	EncryptedPathSecrets [][]byte `protobuf:"bytes,2,rep,name=encryptedPathSecrets,proto3" json:"encryptedPathSecrets,omitempty"`
+}
+
+func (m *MLSUpdatePathNode) Reset()                    { *m = MLSUpdatePathNode{} }
+func (m *MLSUpdatePathNode) String() string            { return proto.CompactTextString(m) }
+func (*MLSUpdatePathNode) ProtoMessage()               {}
+func (*MLSUpdatePathNode) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *MLSUpdatePathNode) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *MLSUpdatePathNode) GetEncryptedPathSecrets() [][]byte {
+	if m != nil {
+		return m.EncryptedPathSecrets
+	}
+	return nil
+}
+
+// The new keys of the committer's leaf and direct path
+type MLSUpdatePath struct {
+	LeafKey []byte               `protobuf:"bytes,1,opt,name=leafKey,proto3" json:"leafKey,omitempty"`
+	Nodes   []*MLSUpdatePathNode `protobuf:"bytes,2,rep,name=nodes" json:"nodes,omitempty"`
+}
+
+func (m *MLSUpdatePath) Reset()                    { *m = MLSUpdatePath{} }
+func (m *MLSUpdatePath) String() string            { return proto.CompactTextString(m) }
+func (*MLSUpdatePath) ProtoMessage()               {}
+func (*MLSUpdatePath) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *MLSUpdatePath) GetLeafKey() []byte {
+	if m != nil {
+		return m.LeafKey
+	}
+	return nil
+}
+
+func (m *MLSUpdatePath) GetNodes() []*MLSUpdatePathNode {
+	if m != nil {
+		return m.Nodes
+	}
+	return nil
+}
+
+// Applies proposals and starts a new epoch
+type MLSCommit struct {
+	Proposals []*MLSProposal `protobuf:"bytes,1,rep,name=proposals" json:"proposals,omitempty"`
+	Path      *MLSUpdatePath `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+}
+
+func (m *MLSCommit) Reset()                    { *m = MLSCommit{} }
+func (m *MLSCommit) String() string            { return proto.CompactTextString(m) }
+func (*MLSCommit) ProtoMessage()               {}
+func (*MLSCommit) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *MLSCommit) GetProposals() []*MLSProposal {
+	if m != nil {
+		return m.Proposals
+	}
+	return nil
+}
+
+func (m *MLSCommit) GetPath() *MLSUpdatePath {
+	if m != nil {
+		return m.Path
+	}
+	return nil
+}
+
+// Proposals and commits, sent to all the members of a group
+type MLSHandshake struct {
+	HandshakeType MLSHandshake_HandshakeType `protobuf:"varint,1,opt,name=handshakeType,enum=serialization.MLSHandshake_HandshakeType" json:"handshakeType,omitempty"`
+	GroupId       string                     `protobuf:"bytes,2,opt,name=groupId" json:"groupId,omitempty"`
+	Epoch         uint64                     `protobuf:"varint,3,opt,name=epoch" json:"epoch,omitempty"`
+	// the leaf of the sender
+	Sender   uint32       `protobuf:"varint,4,opt,name=sender" json:"sender,omitempty"`
+	Proposal *MLSProposal `protobuf:"bytes,5,opt,name=proposal" json:"proposal,omitempty"`
+	Commit   *MLSCommit   `protobuf:"bytes,6,opt,name=commit" json:"commit,omitempty"`
+	// MAC of the commit with the confirmation key of the new epoch
+	ConfirmationTag []byte `protobuf:"bytes,7,opt,name=confirmationTag,proto3" json:"confirmationTag,omitempty"`
+	// XEdDSA signature of the sender over the fields above
+	Signature []byte `protobuf:"bytes,8,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *MLSHandshake) Reset()                    { *m = MLSHandshake{} }
+func (m *MLSHandshake) String() string            { return proto.CompactTextString(m) }
+func (*MLSHandshake) ProtoMessage()               {}
+func (*MLSHandshake) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *MLSHandshake) GetHandshakeType() MLSHandshake_HandshakeType {
+	if m != nil {
+		return m.HandshakeType
+	}
+	return MLSHandshake_Proposal
+}
+
+func (m *MLSHandshake) GetGroupId() string {
+	if m != nil {
+		return m.GroupId
+	}
+	return ""
+}
+
+func (m *MLSHandshake) GetEpoch() uint64 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *MLSHandshake) GetSender() uint32 {
+	if m != nil {
+		return m.Sender
+	}
+	return 0
+}
+
+func (m *MLSHandshake) GetProposal() *MLSProposal {
+	if m != nil {
+		return m.Proposal
+	}
+	return nil
+}
+
+func (m *MLSHandshake) GetCommit() *MLSCommit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+func (m *MLSHandshake) GetConfirmationTag() []byte {
+	if m != nil {
+		return m.ConfirmationTag
+	}
+	return nil
+}
+
+func (m *MLSHandshake) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// What a new member needs to join a group, encrypted to one of its key packages
+type MLSWelcome struct {
+	GroupId string     `protobuf:"bytes,1,opt,name=groupId" json:"groupId,omitempty"`
+	Title   string     `protobuf:"bytes,2,opt,name=title" json:"title,omitempty"`
+	Epoch   uint64     `protobuf:"varint,3,opt,name=epoch" json:"epoch,omitempty"`
+	Tree    []*MLSNode `protobuf:"bytes,4,rep,name=tree" json:"tree,omitempty"`
+	// the leaf of the new member
+	Leaf uint32 `protobuf:"varint,5,opt,name=leaf" json:"leaf,omitempty"`
+	// the leaf of the committer who added the new member
+	Sender uint32 `protobuf:"varint,6,opt,name=sender" json:"sender,omitempty"`
+	// the path secret of the lowest common ancestor of the two leaves
+	PathSecret  []byte `protobuf:"bytes,7,opt,name=pathSecret,proto3" json:"pathSecret,omitempty"`
+	EpochSecret []byte `protobuf:"bytes,8,opt,name=epochSecret,proto3" json:"epochSecret,omitempty"`
+	// XEdDSA signature of the sender over the fields above
+	Signature []byte `protobuf:"bytes,9,opt,name=signature,proto3" json:"signature,omitempty"`
+	// "chat" or "issues"
+	Channel string `protobuf:"bytes,10,opt,name=channel" json:"channel,omitempty"`
+}
+
+func (m *MLSWelcome) Reset()                    { *m = MLSWelcome{} }
+func (m *MLSWelcome) String() string            { return proto.CompactTextString(m) }
+func (*MLSWelcome) ProtoMessage()               {}
+func (*MLSWelcome) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *MLSWelcome) GetGroupId() string {
+	if m != nil {
+		return m.GroupId
+	}
+	return ""
+}
+
+func (m *MLSWelcome) GetTitle() string {
+	if m != nil {
+		return m.Title
+	}
+	return ""
+}
+
+func (m *MLSWelcome) GetEpoch() uint64 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *MLSWelcome) GetTree() []*MLSNode {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+func (m *MLSWelcome) GetLeaf() uint32 {
+	if m != nil {
+		return m.Leaf
+	}
+	return 0
+}
+
+func (m *MLSWelcome) GetSender() uint32 {
+	if m != nil {
+		return m.Sender
+	}
+	return 0
+}
+
+func (m *MLSWelcome) GetPathSecret() []byte {
+	if m != nil {
+		return m.PathSecret
+	}
+	return nil
+}
+
+func (m *MLSWelcome) GetEpochSecret() []byte {
+	if m != nil {
+		return m.EpochSecret
+	}
+	return nil
+}
+
+func (m *MLSWelcome) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+func (m *MLSWelcome) GetChannel() string {
+	if m != nil {
+		return m.Channel
+	}
+	return ""
+}
+
+// Our state in an MLS group, stored in the client database
+type MLSGroupState struct {
+	GroupId string     `protobuf:"bytes,1,opt,name=groupId" json:"groupId,omitempty"`
+	Epoch   uint64     `protobuf:"varint,2,opt,name=epoch" json:"epoch,omitempty"`
+	Tree    []*MLSNode `protobuf:"bytes,3,rep,name=tree" json:"tree,omitempty"`
+	// the private keys we know, at the same index as their node in the tree (empty otherwise)
+	PrivateKeys [][]byte `protobuf:"bytes,4,rep,name=privateKeys,proto3" json:"privateKeys,omitempty"`
+	MyLeaf      uint32   `protobuf:"varint,5,opt,name=myLeaf" json:"myLeaf,omitempty"`
+	EpochSecret []byte   `protobuf:"bytes,6,opt,name=epochSecret,proto3" json:"epochSecret,omitempty"`
+	// the proposals received during this epoch, waiting for a commit
+	PendingProposals []*MLSHandshake `protobuf:"bytes,7,rep,name=pendingProposals" json:"pendingProposals,omitempty"`
+	// the chain key used by each leaf to encrypt its next application message, and its generation
+	SenderChains      [][]byte `protobuf:"bytes,8,rep,name=senderChains,proto3" json:"senderChains,omitempty"`
+	SenderGenerations []uint32 `protobuf:"varint,9,rep,packed,name=senderGenerations" json:"senderGenerations,omitempty"`
+}
+
+func (m *MLSGroupState) Reset()                    { *m = MLSGroupState{} }
+func (m *MLSGroupState) String() string            { return proto.CompactTextString(m) }
+func (*MLSGroupState) ProtoMessage()               {}
+func (*MLSGroupState) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *MLSGroupState) GetGroupId() string {
+	if m != nil {
+		return m.GroupId
+	}
+	return ""
+}
+
+func (m *MLSGroupState) GetEpoch() uint64 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *MLSGroupState) GetTree() []*MLSNode {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+func (m *MLSGroupState) GetPrivateKeys() [][]byte {
+	if m != nil {
+		return m.PrivateKeys
+	}
+	return nil
+}
+
+func (m *MLSGroupState) GetMyLeaf() uint32 {
+	if m != nil {
+		return m.MyLeaf
+	}
+	return 0
+}
+
+func (m *MLSGroupState) GetEpochSecret() []byte {
+	if m != nil {
+		return m.EpochSecret
+	}
+	return nil
+}
+
+func (m *MLSGroupState) GetPendingProposals() []*MLSHandshake {
+	if m != nil {
+		return m.PendingProposals
+	}
+	return nil
+}
+
+func (m *MLSGroupState) GetSenderChains() [][]byte {
+	if m != nil {
+		return m.SenderChains
+	}
+	return nil
+}
+
+func (m *MLSGroupState) GetSenderGenerations() []uint32 {
+	if m != nil {
+		return m.SenderGenerations
+	}
+	return nil
+}
+
+// A member of an organization, signed by the organization's key (Ed25519).
+// It is presented to the Hub during the Disco handshake, as a proof that our key is part of the organization.
+type MembershipCertificate struct {
+	// the Disco public key of the member
+	PublicKey    []byte `protobuf:"bytes,1,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Name         string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Organization string `protobuf:"bytes,3,opt,name=organization" json:"organization,omitempty"`
+	Team         string `protobuf:"bytes,4,opt,name=team" json:"team,omitempty"`
+	// unix timestamps
+	IssuedAt  int64 `protobuf:"varint,5,opt,name=issuedAt" json:"issuedAt,omitempty"`
+	ExpiresAt int64 `protobuf:"varint,6,opt,name=expiresAt" json:"expiresAt,omitempty"`
+	// Ed25519 signature of the organization over the fields above
+	Signature []byte `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *MembershipCertificate) Reset()                    { *m = MembershipCertificate{} }
+func (m *MembershipCertificate) String() string            { return proto.CompactTextString(m) }
+func (*MembershipCertificate) ProtoMessage()               {}
+func (*MembershipCertificate) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *MembershipCertificate) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *MembershipCertificate) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *MembershipCertificate) GetOrganization() string {
+	if m != nil {
+		return m.Organization
+	}
+	return ""
+}
+
+func (m *MembershipCertificate) GetTeam() string {
+	if m != nil {
+		return m.Team
+	}
+	return ""
+}
+
+func (m *MembershipCertificate) GetIssuedAt() int64 {
+	if m != nil {
+		return m.IssuedAt
+	}
+	return 0
+}
+
+func (m *MembershipCertificate) GetExpiresAt() int64 {
+	if m != nil {
+		return m.ExpiresAt
+	}
+	return 0
+}
+
+func (m *MembershipCertificate) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// A revoked member key, signed by the organization's key (Ed25519).
+// The Hub refuses the key and distributes the revocation, so that clients block their conversations with it.
+type Revocation struct {
+	// the Disco public key of the member
+	PublicKey    []byte `protobuf:"bytes,1,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Organization string `protobuf:"bytes,2,opt,name=organization" json:"organization,omitempty"`
+	// unix timestamp
+	RevokedAt int64 `protobuf:"varint,3,opt,name=revokedAt" json:"revokedAt,omitempty"`
+	// why the key was revoked (lost device, left the organization, etc.)
+	Reason string `protobuf:"bytes,4,opt,name=reason" json:"reason,omitempty"`
+	// Ed25519 signature of the organization over the fields above
+	Signature []byte `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *Revocation) Reset()                    { *m = Revocation{} }
+func (m *Revocation) String() string            { return proto.CompactTextString(m) }
+func (*Revocation) ProtoMessage()               {}
+func (*Revocation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *Revocation) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *Revocation) GetOrganization() string {
+	if m != nil {
+		return m.Organization
+	}
+	return ""
+}
+
+func (m *Revocation) GetRevokedAt() int64 {
+	if m != nil {
+		return m.RevokedAt
+	}
+	return 0
+}
+
+func (m *Revocation) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *Revocation) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// Transparency log (see docs/gossip.md)
+//
+// An entry of the transparency log of the organization: a serialized MembershipCertificate or Revocation
+type LogEntry struct {
+	Certificate []byte `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	Revocation  []byte `protobuf:"bytes,2,opt,name=revocation,proto3" json:"revocation,omitempty"`
+}
+
+func (m *LogEntry) Reset()                    { *m = LogEntry{} }
+func (m *LogEntry) String() string            { return proto.CompactTextString(m) }
+func (*LogEntry) ProtoMessage()               {}
+func (*LogEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+func (m *LogEntry) GetCertificate() []byte {
+	if m != nil {
+		return m.Certificate
+	}
+	return nil
+}
+
+func (m *LogEntry) GetRevocation() []byte {
+	if m != nil {
+		return m.Revocation
+	}
+	return nil
+}
+
+// The root of the transparency log, signed by the Hub's Disco key (XEdDSA)
+type TreeHead struct {
+	TreeSize uint64 `protobuf:"varint,1,opt,name=treeSize" json:"treeSize,omitempty"`
+	RootHash []byte `protobuf:"bytes,2,opt,name=rootHash,proto3" json:"rootHash,omitempty"`
+	// unix timestamp
+	Timestamp int64  `protobuf:"varint,3,opt,name=timestamp" json:"timestamp,omitempty"`
+	Signature []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *TreeHead) Reset()                    { *m = TreeHead{} }
+func (m *TreeHead) String() string            { return proto.CompactTextString(m) }
+func (*TreeHead) ProtoMessage()               {}
+func (*TreeHead) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *TreeHead) GetTreeSize() uint64 {
+	if m != nil {
+		return m.TreeSize
+	}
+	return 0
+}
+
+func (m *TreeHead) GetRootHash() []byte {
+	if m != nil {
+		return m.RootHash
+	}
+	return nil
+}
+
+func (m *TreeHead) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *TreeHead) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// A serialized LogEntry, with its index and its inclusion proof
+type LogEntryProof struct {
+	Index uint64   `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Entry []byte   `protobuf:"bytes,2,opt,name=entry,proto3" json:"entry,omitempty"`
+	Proof [][]byte `protobuf:"bytes,3,rep,name=proof,proto3" json:"proof,omitempty"`
+}
+
+func (m *LogEntryProof) Reset()                    { *m = LogEntryProof{} }
+func (m *LogEntryProof) String() string            { return proto.CompactTextString(m) }
+func (*LogEntryProof) ProtoMessage()               {}
+func (*LogEntryProof) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *LogEntryProof) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *LogEntryProof) GetEntry() []byte {
+	if m != nil {
+		return m.Entry
+	}
+	return nil
+}
+
+func (m *LogEntryProof) GetProof() [][]byte {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
+// A statement signed by someone (the verifier) who has checked that a key belongs to a person (see docs/specification.md)
+type VerificationProof struct {
+	// the name of the person, as known by the verifier
+	Nickname string `protobuf:"bytes,1,opt,name=nickname" json:"nickname,omitempty"`
+	// how the verifier checked the key (irl, facebook, twitter, etc.)
+	Mean string `protobuf:"bytes,2,opt,name=mean" json:"mean,omitempty"`
+	// the Disco public key of the person
+	PublicKey []byte `protobuf:"bytes,3,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	// unix timestamp
+	Date int64 `protobuf:"varint,4,opt,name=date" json:"date,omitempty"`
+	// the Disco public key of the verifier
+	Verifier []byte `protobuf:"bytes,5,opt,name=verifier,proto3" json:"verifier,omitempty"`
+	// XEdDSA signature of the verifier over the fields above
+	Signature []byte `protobuf:"bytes,6,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *VerificationProof) Reset()                    { *m = VerificationProof{} }
+func (m *VerificationProof) String() string            { return proto.CompactTextString(m) }
+func (*VerificationProof) ProtoMessage()               {}
+func (*VerificationProof) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *VerificationProof) GetNickname() string {
+	if m != nil {
+		return m.Nickname
+	}
+	return ""
+}
+
+func (m *VerificationProof) GetMean() string {
+	if m != nil {
+		return m.Mean
+	}
+	return ""
+}
+
+func (m *VerificationProof) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *VerificationProof) GetDate() int64 {
+	if m != nil {
+		return m.Date
+	}
+	return 0
+}
+
+func (m *VerificationProof) GetVerifier() []byte {
+	if m != nil {
+		return m.Verifier
+	}
+	return nil
+}
+
+func (m *VerificationProof) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// Backups (see backup.go)
+//
+// Everything needed to restore ~/.sasayaki, encrypted under a key derived from a passphrase
+type Backup struct {
+	Version uint32 `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	// the salt used to derive the key (also in the header of the archive, which is not encrypted)
+	Salt []byte `protobuf:"bytes,2,opt,name=salt,proto3" json:"salt,omitempty"`
+	// unix timestamp
+	CreatedAt int64         `protobuf:"varint,3,opt,name=createdAt" json:"createdAt,omitempty"`
+	Files     []*BackupFile `protobuf:"bytes,4,rep,name=files" json:"files,omitempty"`
+}
+
+func (m *Backup) Reset()                    { *m = Backup{} }
+func (m *Backup) String() string            { return proto.CompactTextString(m) }
+func (*Backup) ProtoMessage()               {}
+func (*Backup) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *Backup) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Backup) GetSalt() []byte {
+	if m != nil {
+		return m.Salt
+	}
+	return nil
+}
+
+func (m *Backup) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return 0
+}
+
+func (m *Backup) GetFiles() []*BackupFile {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+// A file of ~/.sasayaki
+type BackupFile struct {
+	// relative to ~/.sasayaki
+	Name    string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Content []byte `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	// hash of the content
+	Hash []byte `protobuf:"bytes,3,opt,name=hash,proto3" json:"hash,omitempty"`
+}
+
+func (m *BackupFile) Reset()                    { *m = BackupFile{} }
+func (m *BackupFile) String() string            { return proto.CompactTextString(m) }
+func (*BackupFile) ProtoMessage()               {}
+func (*BackupFile) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *BackupFile) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *BackupFile) GetContent() []byte {
+	if m != nil {
+		return m.Content
+	}
+	return nil
+}
+
+func (m *BackupFile) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Request)(nil), "serialization.Request")
 	proto.RegisterType((*Request_Message)(nil), "serialization.Request.Message")
+	proto.RegisterType((*Request_Blob)(nil), "serialization.Request.Blob")
+	proto.RegisterType((*Request_KeyPackage)(nil), "serialization.Request.KeyPackage")
+	proto.RegisterType((*Request_Directory)(nil), "serialization.Request.Directory")
+	proto.RegisterType((*Request_Proof)(nil), "serialization.Request.Proof")
+	proto.RegisterType((*Request_Log)(nil), "serialization.Request.Log")
+	proto.RegisterType((*Request_Rotation)(nil), "serialization.Request.Rotation")
+	proto.RegisterType((*Request_Device)(nil), "serialization.Request.Device")
 	proto.RegisterType((*ResponseSuccess)(nil), "serialization.ResponseSuccess")
 	proto.RegisterType((*ResponseMessage)(nil), "serialization.ResponseMessage")
+	proto.RegisterType((*ResponseBlob)(nil), "serialization.ResponseBlob")
+	proto.RegisterType((*ResponseOrganizationMembers)(nil), "serialization.ResponseOrganizationMembers")
+	proto.RegisterType((*ResponseRevocations)(nil), "serialization.ResponseRevocations")
+	proto.RegisterType((*ResponseProofs)(nil), "serialization.ResponseProofs")
+	proto.RegisterType((*ResponseKeyRotations)(nil), "serialization.ResponseKeyRotations")
+	proto.RegisterType((*ResponseDevices)(nil), "serialization.ResponseDevices")
+	proto.RegisterType((*ResponseTreeHead)(nil), "serialization.ResponseTreeHead")
+	proto.RegisterType((*ResponseConsistencyProof)(nil), "serialization.ResponseConsistencyProof")
+	proto.RegisterType((*ResponseLogEntries)(nil), "serialization.ResponseLogEntries")
+	proto.RegisterType((*ResponseKeyPackage)(nil), "serialization.ResponseKeyPackage")
+	proto.RegisterType((*Payload)(nil), "serialization.Payload")
+	proto.RegisterType((*Payload_File)(nil), "serialization.Payload.File")
+	proto.RegisterType((*Payload_Group)(nil), "serialization.Payload.Group")
+	proto.RegisterType((*Payload_Issue)(nil), "serialization.Payload.Issue")
+	proto.RegisterType((*KeyRotationStatement)(nil), "serialization.KeyRotationStatement")
+	proto.RegisterType((*DeviceCertificate)(nil), "serialization.DeviceCertificate")
+	proto.RegisterType((*DeviceLink)(nil), "serialization.DeviceLink")
+	proto.RegisterType((*DeviceLink_Contact)(nil), "serialization.DeviceLink.Contact")
+	proto.RegisterType((*MLSKeyPackage)(nil), "serialization.MLSKeyPackage")
+	proto.RegisterType((*MLSNode)(nil), "serialization.MLSNode")
+	proto.RegisterType((*MLSProposal)(nil), "serialization.MLSProposal")
+	proto.RegisterType((*MLSUpdatePathNode)(nil), "serialization.MLSUpdatePathNode")
+	proto.RegisterType((*MLSUpdatePath)(nil), "serialization.MLSUpdatePath")
+	proto.RegisterType((*MLSCommit)(nil), "serialization.MLSCommit")
+	proto.RegisterType((*MLSHandshake)(nil), "serialization.MLSHandshake")
+	proto.RegisterType((*MLSWelcome)(nil), "serialization.MLSWelcome")
+	proto.RegisterType((*MLSGroupState)(nil), "serialization.MLSGroupState")
+	proto.RegisterType((*MembershipCertificate)(nil), "serialization.MembershipCertificate")
+	proto.RegisterType((*Revocation)(nil), "serialization.Revocation")
+	proto.RegisterType((*LogEntry)(nil), "serialization.LogEntry")
+	proto.RegisterType((*TreeHead)(nil), "serialization.TreeHead")
+	proto.RegisterType((*LogEntryProof)(nil), "serialization.LogEntryProof")
+	proto.RegisterType((*VerificationProof)(nil), "serialization.VerificationProof")
+	proto.RegisterType((*Backup)(nil), "serialization.Backup")
+	proto.RegisterType((*BackupFile)(nil), "serialization.BackupFile")
+	proto.RegisterEnum("serialization.ErrorCode", ErrorCode_name, ErrorCode_value)
+	proto.RegisterEnum("serialization.MessageKind", MessageKind_name, MessageKind_value)
 	proto.RegisterEnum("serialization.Request_RequestType", Request_RequestType_name, Request_RequestType_value)
+	proto.RegisterEnum("serialization.Payload_PayloadType", Payload_PayloadType_name, Payload_PayloadType_value)
+	proto.RegisterEnum("serialization.MLSProposal_ProposalType", MLSProposal_ProposalType_name, MLSProposal_ProposalType_value)
+	proto.RegisterEnum("serialization.MLSHandshake_HandshakeType", MLSHandshake_HandshakeType_name, MLSHandshake_HandshakeType_value)
 }
 
 func init() { proto.RegisterFile("messages.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 335 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x52, 0x4d, 0x4f, 0xc2, 0x40,
-	0x10, 0xb5, 0x20, 0x16, 0xa6, 0x58, 0x9a, 0x89, 0x21, 0x95, 0x18, 0xd3, 0xf4, 0xc4, 0x89, 0x03,
-	0x5e, 0xbc, 0x92, 0x18, 0x89, 0x07, 0x94, 0x2c, 0x1e, 0x4d, 0x4c, 0xa1, 0x03, 0x34, 0x81, 0x5d,
-	0xdc, 0x59, 0x8c, 0xfa, 0x0b, 0xfc, 0xbd, 0xfe, 0x02, 0xd3, 0xaf, 0x50, 0x0e, 0x5e, 0x3c, 0xed,
-	0xbc, 0x37, 0x6f, 0x3e, 0xde, 0x64, 0xc1, 0xdd, 0x12, 0x73, 0xb4, 0x22, 0x1e, 0xec, 0xb4, 0x32,
-	0x0a, 0xcf, 0x99, 0x74, 0x12, 0x6d, 0x92, 0xaf, 0xc8, 0x24, 0x4a, 0x86, 0x3f, 0x35, 0xb0, 0x05,
-	0xbd, 0xed, 0x89, 0x0d, 0xde, 0x81, 0xa3, 0xf3, 0xf0, 0xf9, 0x73, 0x47, 0xbe, 0x15, 0x58, 0x7d,
-	0x77, 0x18, 0x0e, 0x8e, 0x0a, 0x06, 0x85, 0xb8, 0x7c, 0x53, 0xa5, 0xa8, 0x96, 0xe1, 0x2d, 0xd8,
-	0xc5, 0x48, 0xbf, 0x16, 0x58, 0x7d, 0x67, 0x78, 0xfd, 0x47, 0x87, 0x49, 0xae, 0x12, 0xa5, 0xbc,
-	0xf7, 0x02, 0x76, 0xc1, 0xe1, 0x15, 0xb4, 0x8c, 0x1a, 0xc5, 0xb1, 0x26, 0xe6, 0x6c, 0x91, 0x96,
-	0x38, 0x10, 0x78, 0x09, 0xcd, 0x85, 0x92, 0xef, 0xea, 0x35, 0x89, 0xb3, 0x19, 0x2d, 0x61, 0x67,
-	0xf8, 0x21, 0x46, 0x1f, 0xd2, 0xd0, 0x90, 0x34, 0x7e, 0x3d, 0xb0, 0xfa, 0x6d, 0x51, 0xc2, 0xf0,
-	0xdb, 0x02, 0xa7, 0xb2, 0x34, 0xba, 0x00, 0x63, 0x32, 0x8f, 0xca, 0xac, 0x13, 0xb9, 0xf2, 0x4e,
-	0x10, 0xc1, 0x4d, 0x31, 0x7d, 0x98, 0x62, 0x09, 0xcf, 0xc2, 0x0e, 0x38, 0x33, 0x92, 0x71, 0x49,
-	0xd4, 0xb0, 0x07, 0xdd, 0x31, 0x99, 0x27, 0xbd, 0x8a, 0x64, 0x61, 0x67, 0x42, 0xdb, 0x39, 0x69,
-	0xf6, 0xea, 0xd8, 0x05, 0x1c, 0x93, 0x99, 0x6a, 0xa5, 0x96, 0x7c, 0xaf, 0x74, 0x9e, 0xf0, 0x4e,
-	0xd1, 0x83, 0xf6, 0x74, 0x3f, 0xdf, 0x24, 0xbc, 0xce, 0x72, 0x5e, 0x23, 0x1c, 0x41, 0x47, 0x10,
-	0xef, 0x94, 0x64, 0x9a, 0xed, 0x17, 0x8b, 0xd4, 0x92, 0x0f, 0x36, 0xe7, 0x61, 0x66, 0xb7, 0x29,
-	0x4a, 0x88, 0x17, 0xd0, 0x20, 0xad, 0x95, 0x2e, 0x9c, 0xe6, 0x20, 0x5c, 0x1f, 0x5a, 0x94, 0x37,
-	0x0b, 0xc0, 0x59, 0x6a, 0xb5, 0x3d, 0xbe, 0x5a, 0x95, 0xfa, 0xd7, 0xdd, 0xe6, 0x67, 0xd9, 0xbf,
-	0xb9, 0xf9, 0x0d, 0x00, 0x00, 0xff, 0xff, 0x1a, 0x17, 0xe7, 0xac, 0x49, 0x02, 0x00, 0x00,
+	// 2584 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x59, 0xcd, 0x73, 0x1c, 0x57,
+	0x11, 0xf7, 0xee, 0xac, 0xf6, 0xa3, 0x77, 0x57, 0x1e, 0x3d, 0x3b, 0xce, 0x66, 0x63, 0x8c, 0x18,
+	0x0e, 0x38, 0xa9, 0x94, 0x92, 0x52, 0x8a, 0x10, 0x2a, 0x81, 0x42, 0x91, 0x1d, 0xd9, 0xe5, 0x95,
+	0xad, 0xbc, 0x75, 0x02, 0x37, 0x18, 0xcd, 0xb4, 0x76, 0x07, 0xed, 0xce, 0x9b, 0xcc, 0x3c, 0xc9,
+	0x92, 0x0b, 0x38, 0x41, 0x71, 0xe4, 0x46, 0x51, 0xc5, 0x8d, 0xe2, 0xc2, 0x05, 0x8e, 0xdc, 0xb8,
+	0xf0, 0x47, 0x50, 0x70, 0xe4, 0x2f, 0xa1, 0xfa, 0x7d, 0xcc, 0xc7, 0x7e, 0x28, 0x16, 0x55, 0x70,
+	0x7b, 0xdd, 0xdb, 0xfd, 0xba, 0xa7, 0xbb, 0x5f, 0xbf, 0x5f, 0xbf, 0x85, 0xcd, 0x39, 0x66, 0x99,
+	0x3f, 0xc1, 0x6c, 0x27, 0x49, 0x85, 0x14, 0xac, 0x9f, 0x61, 0x1a, 0xf9, 0xb3, 0xe8, 0xa5, 0x2f,
+	0x23, 0x11, 0x7b, 0x7f, 0xea, 0x41, 0x8b, 0xe3, 0x97, 0x67, 0x98, 0x49, 0xf6, 0x00, 0xba, 0xa9,
+	0x5e, 0x3e, 0xbf, 0x4c, 0x70, 0x50, 0xdb, 0xae, 0xdd, 0xdf, 0xdc, 0xf5, 0x76, 0x2a, 0x0a, 0x3b,
+	0x46, 0x78, 0x87, 0x17, 0x92, 0xbc, 0xac, 0xc6, 0x3e, 0x84, 0x96, 0x31, 0x39, 0xa8, 0x6f, 0xd7,
+	0xee, 0x77, 0x77, 0xef, 0xad, 0xd9, 0xe1, 0x50, 0x4b, 0x71, 0x2b, 0xce, 0xde, 0x85, 0xc6, 0xf1,
+	0x4c, 0x1c, 0x0f, 0x1c, 0xa5, 0xf6, 0xe6, 0x1a, 0xb5, 0x4f, 0x66, 0xe2, 0x98, 0x2b, 0x41, 0xb6,
+	0x07, 0x70, 0x8a, 0x97, 0x47, 0x7e, 0x70, 0x4a, 0xd6, 0x1a, 0x4a, 0xed, 0x1b, 0x6b, 0xd4, 0x9e,
+	0xe4, 0x82, 0xbc, 0xa4, 0xc4, 0xbe, 0x0f, 0x9d, 0x30, 0x4a, 0x31, 0x90, 0x22, 0xbd, 0x1c, 0x6c,
+	0xa8, 0x1d, 0xb6, 0xd7, 0xec, 0xf0, 0xc0, 0xca, 0xf1, 0x42, 0x85, 0xbd, 0x03, 0xce, 0x4c, 0x4c,
+	0x06, 0x4d, 0xa5, 0x39, 0x5c, 0xa3, 0x39, 0x12, 0x13, 0x4e, 0x62, 0x6c, 0x17, 0x36, 0x92, 0x54,
+	0x88, 0x93, 0x41, 0x4b, 0xc9, 0xdf, 0x5d, 0x23, 0x7f, 0x44, 0x32, 0x5c, 0x8b, 0xb2, 0x8f, 0xa0,
+	0x9d, 0x0a, 0xa9, 0x04, 0x06, 0x6d, 0xa5, 0xf6, 0xf5, 0x75, 0x29, 0x31, 0x62, 0x3c, 0x57, 0x60,
+	0xdf, 0x86, 0x66, 0x88, 0xe7, 0x51, 0x80, 0x83, 0x8e, 0x52, 0xfd, 0xda, 0xba, 0x6f, 0x53, 0x42,
+	0xdc, 0x08, 0x0f, 0xff, 0x5c, 0x83, 0x96, 0x49, 0x0f, 0xbb, 0x0b, 0x1d, 0x29, 0xf6, 0xc2, 0x30,
+	0xc5, 0x2c, 0x53, 0x35, 0xd1, 0xe1, 0x05, 0x83, 0xbd, 0x01, 0xed, 0x40, 0xc4, 0xe7, 0xe2, 0xc7,
+	0x51, 0xa8, 0xd2, 0xdd, 0xe1, 0x2d, 0x45, 0x3f, 0x0e, 0xd9, 0x00, 0x68, 0x29, 0x31, 0x96, 0x2a,
+	0xa3, 0x3d, 0x6e, 0x49, 0xb6, 0x03, 0x8d, 0xd3, 0x28, 0x0e, 0x55, 0xc6, 0x36, 0x97, 0xa2, 0x66,
+	0x0c, 0x3f, 0x89, 0xe2, 0x90, 0x2b, 0x39, 0xb6, 0x0d, 0xdd, 0x93, 0x54, 0xcc, 0xad, 0x13, 0x1b,
+	0xca, 0x4e, 0x99, 0x35, 0x7c, 0x0f, 0x1a, 0x54, 0x17, 0x6c, 0x13, 0xea, 0x51, 0x68, 0xbc, 0xac,
+	0x47, 0x15, 0x1f, 0xea, 0x15, 0x1f, 0x86, 0x1f, 0x03, 0x14, 0x25, 0xc1, 0x6e, 0xc3, 0x86, 0x78,
+	0x11, 0x63, 0x6a, 0x54, 0x35, 0x71, 0x85, 0xf6, 0x33, 0xe8, 0xe4, 0xe5, 0x40, 0xca, 0x5f, 0x9e,
+	0x61, 0x7a, 0x69, 0x95, 0x15, 0xc1, 0xee, 0x40, 0x53, 0x9c, 0x9c, 0x64, 0xa8, 0x75, 0xfb, 0xdc,
+	0x50, 0x24, 0x3d, 0x8b, 0xe6, 0x91, 0x0e, 0x4a, 0x9f, 0x6b, 0x62, 0xf8, 0x5d, 0xd8, 0x50, 0x59,
+	0x27, 0xb5, 0x39, 0xce, 0x8f, 0x73, 0x57, 0x0c, 0x75, 0x85, 0x2f, 0xef, 0x83, 0x33, 0x12, 0x13,
+	0xda, 0xf7, 0x24, 0x4a, 0x33, 0xa9, 0xf4, 0x1a, 0x5c, 0x13, 0xb4, 0x5d, 0x86, 0x81, 0x88, 0x75,
+	0x76, 0x1a, 0xdc, 0x50, 0xc3, 0x8f, 0xa1, 0x6d, 0xcb, 0x85, 0x32, 0x9c, 0x49, 0x5f, 0xe2, 0x1c,
+	0x63, 0xad, 0xdd, 0xe3, 0x05, 0x83, 0x31, 0x68, 0x9c, 0xe2, 0x65, 0x36, 0xa8, 0x6f, 0x3b, 0xf7,
+	0x3b, 0x5c, 0xad, 0x87, 0x5f, 0x40, 0x53, 0x57, 0x0c, 0xa5, 0x26, 0xc0, 0x54, 0x46, 0x27, 0x51,
+	0xe0, 0x4b, 0x34, 0xda, 0x65, 0x16, 0x79, 0x60, 0x4a, 0x50, 0xd7, 0x87, 0xa1, 0xf2, 0x7d, 0x9d,
+	0x62, 0x5f, 0xef, 0xf7, 0x0e, 0x74, 0x4b, 0x8d, 0x85, 0x6d, 0x02, 0x1c, 0xa0, 0x7c, 0x2a, 0xe4,
+	0x34, 0x8a, 0x27, 0xee, 0x0d, 0xc6, 0x60, 0x93, 0x68, 0xbc, 0x90, 0xa6, 0x48, 0xdc, 0x1a, 0xbb,
+	0x09, 0xdd, 0x31, 0xc6, 0xa1, 0x65, 0xd4, 0xd9, 0x10, 0xee, 0x1c, 0xa0, 0x7c, 0x96, 0x4e, 0xfc,
+	0xd8, 0x94, 0xd4, 0xa1, 0x0a, 0x61, 0xe6, 0x3a, 0xec, 0x0e, 0xb0, 0x03, 0x94, 0x2a, 0xd2, 0xd9,
+	0xa7, 0x22, 0xd5, 0x3f, 0xb8, 0x0d, 0xe6, 0x42, 0xef, 0xe8, 0xec, 0x78, 0x16, 0x65, 0x53, 0xf5,
+	0x9b, 0xbb, 0x41, 0xa6, 0x3f, 0x4f, 0x66, 0xc2, 0x0f, 0xa9, 0xae, 0xdc, 0x26, 0x49, 0x3c, 0x10,
+	0x2f, 0xe2, 0x9c, 0xd3, 0x62, 0xaf, 0xc1, 0x96, 0xd1, 0x29, 0x0a, 0xc9, 0x6d, 0xb3, 0x2d, 0xe8,
+	0x1f, 0xa0, 0x2c, 0xb1, 0x3a, 0xc6, 0x6d, 0x8e, 0xe7, 0x22, 0x50, 0xfe, 0x64, 0x2e, 0x90, 0xdb,
+	0x07, 0x28, 0x9f, 0xa7, 0x88, 0x8f, 0xd0, 0x0f, 0xdd, 0x2e, 0x7b, 0x1d, 0x6e, 0x1d, 0xa0, 0xdc,
+	0x17, 0x71, 0x16, 0x65, 0x12, 0xe3, 0xe0, 0x52, 0x7b, 0xd2, 0x33, 0x1b, 0x8e, 0xc4, 0xe4, 0x61,
+	0x2c, 0xd3, 0x08, 0x33, 0xb7, 0x4f, 0x9f, 0x51, 0x98, 0xb6, 0x79, 0x74, 0x37, 0xd9, 0x2d, 0xb8,
+	0xa9, 0x6d, 0x5b, 0x5e, 0xe6, 0xde, 0x24, 0x7d, 0x23, 0xac, 0x73, 0xe6, 0xba, 0x26, 0xae, 0x9a,
+	0xcc, 0xdc, 0x2d, 0xfa, 0x38, 0x8e, 0x73, 0x71, 0x8e, 0x46, 0x82, 0x91, 0xcb, 0x9f, 0x8a, 0xf4,
+	0x85, 0x9f, 0xe6, 0x81, 0xbd, 0xe5, 0xfd, 0xa6, 0x06, 0x37, 0x39, 0x66, 0x89, 0x88, 0x33, 0x1c,
+	0x9f, 0x05, 0x01, 0x66, 0x19, 0x95, 0x65, 0xa6, 0x97, 0x2a, 0xf7, 0x6d, 0x6e, 0x49, 0xaa, 0x47,
+	0x4c, 0x53, 0x91, 0x9a, 0xb4, 0x6b, 0x82, 0xbd, 0x03, 0x8d, 0x40, 0x84, 0xa8, 0x8a, 0x7f, 0x73,
+	0x77, 0xb0, 0x70, 0xf4, 0x1f, 0x92, 0xcc, 0xbe, 0x08, 0x91, 0x2b, 0x29, 0x76, 0x0f, 0x20, 0x45,
+	0x99, 0x5e, 0xee, 0x9d, 0x48, 0x4c, 0x55, 0xbb, 0xe8, 0xf3, 0x12, 0xc7, 0xfb, 0x5d, 0xc9, 0x23,
+	0xdb, 0xaf, 0x16, 0x9a, 0x45, 0x6d, 0xa9, 0x59, 0xfc, 0x5f, 0x7a, 0x96, 0x37, 0x85, 0x9e, 0xf5,
+	0x4c, 0x75, 0xa6, 0xeb, 0x06, 0x4a, 0x77, 0x32, 0x67, 0x55, 0x27, 0x6b, 0x54, 0x3c, 0xf3, 0x7e,
+	0x5d, 0x83, 0x37, 0xad, 0xa9, 0x15, 0x55, 0x7f, 0x6d, 0xcb, 0x1e, 0xf4, 0x4a, 0xe7, 0x57, 0x1f,
+	0xd0, 0x1e, 0xaf, 0xf0, 0x48, 0x53, 0x0a, 0xe9, 0xcf, 0x4c, 0x4e, 0x34, 0xe1, 0xfd, 0x1c, 0x6e,
+	0x59, 0x47, 0x4a, 0xc5, 0x7e, 0x6d, 0x07, 0xb6, 0x09, 0x87, 0xe4, 0xea, 0xc6, 0x7e, 0x99, 0xb5,
+	0xc6, 0xfc, 0x8f, 0x60, 0xd3, 0x9a, 0xd7, 0x27, 0xfc, 0xda, 0x96, 0xef, 0x40, 0x53, 0x5d, 0xba,
+	0xd6, 0xa8, 0xa1, 0xbc, 0x13, 0xb8, 0x6d, 0x77, 0x2e, 0x1f, 0xae, 0x6b, 0xef, 0x7f, 0x0f, 0x20,
+	0x6f, 0xac, 0xd6, 0x46, 0x89, 0xe3, 0x61, 0x51, 0xce, 0xe6, 0x70, 0xfe, 0x2f, 0xb2, 0xe7, 0xbd,
+	0x00, 0xd7, 0x9a, 0xb1, 0x0d, 0xe8, 0xda, 0x76, 0xde, 0x87, 0xb6, 0x34, 0xba, 0x06, 0xb0, 0xbd,
+	0xbe, 0x70, 0x26, 0xec, 0xd6, 0x3c, 0x17, 0xf4, 0x7e, 0x02, 0x03, 0x6b, 0x78, 0xb1, 0xd1, 0x5d,
+	0xdb, 0x81, 0xdb, 0x16, 0x4b, 0xe9, 0x2f, 0xd4, 0x84, 0xf7, 0x33, 0x60, 0xd6, 0x42, 0xd1, 0x31,
+	0xaf, 0xbd, 0xf7, 0x07, 0xd0, 0x42, 0xad, 0xaa, 0x76, 0x5f, 0x46, 0x6a, 0x66, 0x6f, 0xed, 0x3a,
+	0xb7, 0xc2, 0x9e, 0x2c, 0xac, 0x97, 0xc0, 0xc5, 0x7f, 0xf1, 0x65, 0x1a, 0x8c, 0x38, 0x6b, 0xc0,
+	0xc8, 0x42, 0x03, 0xf8, 0x6d, 0x1b, 0x5a, 0x47, 0xfe, 0x25, 0x5d, 0x4d, 0x84, 0xe1, 0x13, 0xbd,
+	0xbc, 0x02, 0xc3, 0x1b, 0xe1, 0x9d, 0xa3, 0x42, 0x92, 0x97, 0xd5, 0x4c, 0xf3, 0xa9, 0xaf, 0x6a,
+	0x3e, 0x4e, 0xde, 0x30, 0x89, 0x24, 0xec, 0x90, 0xe2, 0x09, 0xa6, 0x18, 0x07, 0x1a, 0x81, 0x77,
+	0x78, 0xc1, 0x60, 0x1f, 0x01, 0xf8, 0x52, 0xfa, 0xc1, 0x54, 0x41, 0x8b, 0x8d, 0x95, 0xb8, 0xde,
+	0x3a, 0xf3, 0x69, 0x34, 0x43, 0x5e, 0x12, 0x27, 0xb0, 0x3c, 0x49, 0xc5, 0x59, 0x62, 0xc0, 0xf5,
+	0xdd, 0x35, 0x7a, 0x07, 0x24, 0xc3, 0xb5, 0x28, 0xe9, 0x44, 0x59, 0x76, 0x86, 0x83, 0xd6, 0x95,
+	0x3a, 0x8f, 0x49, 0x86, 0x6b, 0x51, 0x36, 0x2c, 0x55, 0x72, 0x5b, 0x45, 0x36, 0xa7, 0xa9, 0x15,
+	0x9d, 0x16, 0x07, 0x5e, 0x81, 0xe8, 0x1e, 0x2f, 0xb3, 0xe8, 0x48, 0x6b, 0x40, 0x33, 0x8a, 0xe2,
+	0xd3, 0x01, 0x28, 0x81, 0x12, 0x87, 0x60, 0x4e, 0x82, 0x98, 0x0e, 0xba, 0x2a, 0x36, 0x6a, 0x3d,
+	0xfc, 0x29, 0x34, 0xe8, 0x6b, 0xe9, 0xb7, 0xd8, 0x9f, 0xa3, 0xb9, 0xa3, 0xd4, 0x9a, 0x78, 0x59,
+	0xf4, 0x12, 0x0d, 0x5c, 0x53, 0x6b, 0xe6, 0x82, 0x73, 0x8a, 0x97, 0xe6, 0x46, 0xa2, 0x25, 0x49,
+	0x4d, 0xfd, 0x6c, 0x6a, 0x2a, 0x41, 0xad, 0xa9, 0x6c, 0x68, 0x2a, 0x22, 0x7c, 0x4c, 0x88, 0x4a,
+	0x13, 0xc3, 0xbf, 0xd6, 0x60, 0x43, 0x85, 0x68, 0x09, 0x1b, 0x53, 0x13, 0x8d, 0xe4, 0xcc, 0xe2,
+	0x32, 0x4d, 0x50, 0xaa, 0x35, 0xe2, 0xb4, 0xc8, 0xcc, 0x92, 0x25, 0x64, 0xda, 0xa8, 0x20, 0x53,
+	0x82, 0x8f, 0x18, 0x87, 0x98, 0x3e, 0x41, 0x3d, 0x42, 0xf5, 0x78, 0xc1, 0xa0, 0xf8, 0x4c, 0x30,
+	0xc6, 0x54, 0x07, 0xb0, 0xa9, 0xaf, 0xf0, 0x82, 0xa3, 0x4a, 0x6b, 0xea, 0xc7, 0x31, 0xce, 0x06,
+	0x2d, 0x53, 0x5a, 0x9a, 0x1c, 0x06, 0xb0, 0xa1, 0xf2, 0xb4, 0xca, 0x71, 0xd5, 0x33, 0xad, 0xe3,
+	0x8a, 0x20, 0xf7, 0x66, 0xfe, 0x31, 0xce, 0xac, 0xdf, 0x86, 0x22, 0xf7, 0xfc, 0x2c, 0x8b, 0x26,
+	0x31, 0x62, 0x36, 0x68, 0xa8, 0x9f, 0x0a, 0x86, 0xf7, 0xaf, 0x1a, 0x74, 0x4b, 0xc7, 0x80, 0xb5,
+	0xa1, 0xf1, 0x1c, 0x2f, 0xa4, 0x7b, 0x83, 0x56, 0x0f, 0xc3, 0x48, 0xba, 0x35, 0x06, 0x84, 0x76,
+	0x67, 0x28, 0x09, 0x5c, 0x6e, 0x02, 0xec, 0xe5, 0x25, 0xea, 0x3a, 0x0a, 0xc6, 0x51, 0x74, 0x1f,
+	0xc7, 0xe7, 0x91, 0x44, 0xb7, 0xa1, 0xb0, 0x1e, 0x31, 0xc6, 0x36, 0x02, 0xee, 0x46, 0xce, 0xdb,
+	0x0b, 0x43, 0x83, 0x38, 0x9b, 0x84, 0x1e, 0x15, 0x4f, 0xe3, 0x2e, 0xc3, 0x6e, 0xb1, 0x3e, 0x74,
+	0xd4, 0x47, 0x3f, 0x4b, 0x30, 0x76, 0xdb, 0xb4, 0xbd, 0x22, 0x3f, 0x4f, 0x42, 0x5f, 0x12, 0x94,
+	0x04, 0x68, 0x1e, 0x88, 0x2c, 0x8b, 0x12, 0x0d, 0x21, 0xcb, 0xf0, 0xaf, 0x4b, 0xce, 0x3d, 0xc8,
+	0x2b, 0xcf, 0xed, 0x79, 0x17, 0x70, 0xbb, 0x24, 0x30, 0xce, 0x21, 0x3d, 0x8d, 0x26, 0xb3, 0x90,
+	0xd2, 0xa5, 0xf1, 0xba, 0xa1, 0x88, 0x1f, 0xe3, 0x0b, 0xe2, 0xeb, 0x11, 0xc3, 0x50, 0x54, 0x6d,
+	0x64, 0x5e, 0x15, 0xa0, 0xc3, 0xd5, 0x5a, 0x65, 0x3d, 0x9a, 0xc4, 0xbe, 0x3c, 0x4b, 0xd1, 0x94,
+	0x61, 0xc1, 0xf0, 0xfe, 0x5e, 0x83, 0x2d, 0xed, 0xca, 0x7e, 0x69, 0x14, 0xd8, 0x86, 0x6e, 0x14,
+	0x62, 0x2c, 0x23, 0x79, 0x59, 0x18, 0x2f, 0xb3, 0x68, 0x57, 0x7d, 0x76, 0x0a, 0x27, 0x0a, 0x46,
+	0x7e, 0x5e, 0x9c, 0xea, 0x79, 0x51, 0xbe, 0x35, 0xd6, 0xf9, 0xb6, 0xb1, 0xe0, 0x1b, 0xbb, 0x0f,
+	0x37, 0xf5, 0x96, 0xe3, 0x5c, 0xa6, 0xa9, 0x64, 0x16, 0xd9, 0xde, 0xdf, 0x6a, 0xe5, 0x80, 0xbe,
+	0xda, 0xac, 0x93, 0x61, 0x90, 0xa2, 0x9d, 0xd1, 0x0c, 0xc5, 0xbe, 0xa7, 0x10, 0xa7, 0xf4, 0x03,
+	0x69, 0x2f, 0x94, 0xc5, 0x67, 0x8a, 0xc2, 0xcc, 0xce, 0xbe, 0x96, 0xe4, 0xb9, 0xca, 0xf0, 0x3b,
+	0xd0, 0x32, 0x4c, 0x3a, 0x2e, 0x7e, 0x05, 0xd9, 0x5a, 0x32, 0x0f, 0x4e, 0xbd, 0x08, 0x8e, 0x17,
+	0x40, 0xff, 0x70, 0x34, 0x2e, 0x5d, 0x45, 0x43, 0x68, 0xdb, 0x70, 0x1b, 0xff, 0x73, 0x9a, 0xb6,
+	0x8e, 0xe2, 0x48, 0x16, 0x91, 0xb7, 0x64, 0x35, 0x9e, 0xce, 0x62, 0xae, 0xf7, 0xa1, 0x75, 0x38,
+	0x1a, 0x3f, 0x25, 0xbc, 0x7e, 0x17, 0x3a, 0x09, 0x8d, 0x1a, 0x41, 0x91, 0xde, 0x82, 0x51, 0x31,
+	0x5e, 0xaf, 0x1a, 0xf7, 0xfe, 0x51, 0x83, 0xee, 0xe1, 0x68, 0x7c, 0x94, 0x8a, 0x44, 0x64, 0xfe,
+	0x8c, 0x3d, 0x81, 0x5e, 0x62, 0xd6, 0xa5, 0x8b, 0xec, 0x5b, 0x8b, 0xb0, 0xbb, 0xd0, 0xd8, 0x39,
+	0x2a, 0x89, 0xf3, 0x8a, 0x32, 0xfb, 0xb8, 0xf2, 0x4e, 0x54, 0x5f, 0x79, 0x35, 0x54, 0xe2, 0x54,
+	0x79, 0x22, 0x1a, 0x40, 0x2b, 0x55, 0x87, 0x34, 0x34, 0x23, 0xbb, 0x25, 0xbd, 0x6f, 0x42, 0xaf,
+	0x6c, 0x95, 0xb5, 0xc0, 0xd9, 0x0b, 0x43, 0xf7, 0x06, 0x9d, 0x52, 0x7d, 0xae, 0xdd, 0x9a, 0x87,
+	0xb0, 0x75, 0x38, 0x1a, 0xeb, 0x03, 0x7c, 0xe4, 0xcb, 0xe9, 0x2b, 0x04, 0x6a, 0x17, 0x6e, 0x63,
+	0x1c, 0xa4, 0x97, 0x89, 0xc4, 0x90, 0x54, 0xc6, 0xaa, 0x8a, 0xf4, 0x08, 0xde, 0xe3, 0x2b, 0x7f,
+	0xf3, 0x7c, 0xe8, 0x57, 0xcc, 0x90, 0xdb, 0x33, 0xf4, 0x4f, 0x0a, 0x03, 0x96, 0x64, 0x1f, 0xc0,
+	0x46, 0x2c, 0x42, 0xd4, 0xfb, 0x2d, 0xbf, 0x77, 0x2d, 0x79, 0xcb, 0xb5, 0xb8, 0xf7, 0x02, 0x3a,
+	0x87, 0xa3, 0xf1, 0xbe, 0x98, 0xcf, 0x23, 0xc9, 0x3e, 0x84, 0x8e, 0x8d, 0x31, 0x95, 0xa2, 0xb3,
+	0xe2, 0xf9, 0xab, 0x94, 0x1d, 0x5e, 0x08, 0xb3, 0xf7, 0xa0, 0x91, 0xf8, 0x72, 0xba, 0x3e, 0x0f,
+	0x85, 0x75, 0xae, 0x24, 0xbd, 0x5f, 0x3a, 0xd0, 0x3b, 0x1c, 0x8d, 0x1f, 0xf9, 0x71, 0x98, 0x4d,
+	0xfd, 0x53, 0x64, 0xcf, 0xa0, 0x3f, 0xb5, 0x44, 0xa9, 0x3c, 0xde, 0x5a, 0xde, 0x2b, 0xd7, 0xd9,
+	0x79, 0x54, 0x56, 0xe0, 0x55, 0x7d, 0x0a, 0x96, 0x02, 0x10, 0x8f, 0xf3, 0x89, 0xd0, 0x90, 0x0a,
+	0xa2, 0x25, 0x22, 0x98, 0xaa, 0xdc, 0x37, 0xb8, 0x26, 0xf4, 0x41, 0xa7, 0x06, 0x6f, 0x26, 0x10,
+	0x43, 0xb1, 0x0f, 0xa0, 0x6d, 0x3f, 0xd4, 0xc0, 0x9d, 0xab, 0x82, 0x92, 0xcb, 0xb2, 0xf7, 0xa0,
+	0x19, 0xa8, 0xb8, 0x1a, 0xb0, 0x33, 0x58, 0xd6, 0xd2, 0x71, 0xe7, 0x46, 0x8e, 0xba, 0x58, 0x20,
+	0xe2, 0x93, 0x28, 0x9d, 0x2b, 0x89, 0xe7, 0xfe, 0x44, 0xdd, 0x9f, 0x3d, 0xbe, 0xc8, 0xae, 0x9e,
+	0xde, 0xf6, 0xe2, 0xe9, 0x7d, 0x0b, 0xfa, 0x95, 0xc8, 0xb0, 0x1e, 0xb4, 0xad, 0x83, 0xba, 0x92,
+	0xb5, 0x61, 0xb7, 0xe6, 0xfd, 0xb1, 0x0e, 0x70, 0x38, 0x1a, 0xff, 0x10, 0x67, 0x81, 0x98, 0x57,
+	0x62, 0x56, 0x5b, 0x8a, 0xd9, 0x0a, 0x64, 0xb1, 0x3a, 0x92, 0x6f, 0x43, 0x43, 0xa6, 0x88, 0xea,
+	0x66, 0xee, 0xee, 0xde, 0x59, 0xfe, 0x6e, 0x55, 0x81, 0x4a, 0x86, 0x5a, 0x1c, 0xd5, 0xb0, 0x8a,
+	0x6c, 0x9f, 0xab, 0x75, 0x29, 0x13, 0xcd, 0x4a, 0x26, 0xee, 0x01, 0x24, 0xf9, 0xf1, 0x30, 0xa1,
+	0x29, 0x71, 0xa8, 0x99, 0x2b, 0x07, 0x8c, 0x80, 0x8e, 0x4b, 0x99, 0x55, 0x8d, 0x5b, 0x67, 0xf1,
+	0x16, 0x29, 0xe1, 0x16, 0xa8, 0xe0, 0x16, 0xef, 0xdf, 0x75, 0x75, 0x14, 0x35, 0x0a, 0x50, 0xd0,
+	0xe4, 0xca, 0x48, 0xe9, 0x98, 0xd4, 0x57, 0xc5, 0xc4, 0x79, 0x85, 0x98, 0x6c, 0x43, 0x37, 0x49,
+	0xa3, 0x73, 0x5f, 0xd2, 0x0d, 0xa9, 0x01, 0x4e, 0x8f, 0x97, 0x59, 0x0a, 0xb7, 0x5d, 0x8e, 0x8a,
+	0xb8, 0x19, 0x6a, 0x31, 0x02, 0xcd, 0xe5, 0x08, 0x1c, 0x80, 0x9b, 0x60, 0x1c, 0x46, 0xf1, 0xe4,
+	0x28, 0x3f, 0xea, 0xad, 0x6d, 0x67, 0x05, 0x88, 0x2f, 0x9f, 0x34, 0xbe, 0xa4, 0x44, 0x43, 0xa9,
+	0x4e, 0xcb, 0xfe, 0xd4, 0x8f, 0xe2, 0x6c, 0xd0, 0xd6, 0x43, 0x69, 0x99, 0xc7, 0xde, 0x81, 0x2d,
+	0x4d, 0x1f, 0xe4, 0xe0, 0x30, 0x1b, 0x74, 0xb6, 0x9d, 0xfb, 0x7d, 0xbe, 0xfc, 0x83, 0xf7, 0xcf,
+	0x1a, 0xbc, 0x66, 0x1e, 0x38, 0xa6, 0x51, 0x52, 0x06, 0x19, 0x57, 0xb7, 0xd6, 0x15, 0xb7, 0x24,
+	0x79, 0x27, 0x4a, 0xef, 0x26, 0x06, 0x5e, 0x54, 0x78, 0xa4, 0x27, 0xd1, 0x9f, 0x1b, 0xe8, 0xab,
+	0xd6, 0xea, 0x3e, 0xcb, 0xb2, 0x33, 0x0c, 0xf7, 0xf4, 0x6c, 0xe3, 0xf0, 0x9c, 0x26, 0x2f, 0xf0,
+	0x22, 0x89, 0x52, 0xcc, 0xf6, 0x74, 0x68, 0x1d, 0x5e, 0x30, 0xaa, 0xa5, 0xd5, 0x5a, 0x3c, 0x92,
+	0x7f, 0xa8, 0x01, 0x14, 0xef, 0x27, 0x5f, 0xf1, 0x41, 0x8b, 0xce, 0xd7, 0x57, 0x38, 0xaf, 0x86,
+	0xb4, 0x73, 0x71, 0xaa, 0x3c, 0xd5, 0x20, 0xae, 0x60, 0x50, 0x7d, 0xa4, 0xe8, 0x67, 0x22, 0xb6,
+	0xb8, 0x5e, 0x53, 0x57, 0xa3, 0x28, 0x6f, 0x04, 0x6d, 0x3b, 0x04, 0xbf, 0x02, 0x30, 0x52, 0x0f,
+	0x79, 0xf6, 0x8b, 0xcc, 0xe5, 0x5f, 0xe2, 0x78, 0xbf, 0x80, 0x76, 0xfe, 0x12, 0x61, 0xe6, 0xb1,
+	0x71, 0xf4, 0x52, 0x6f, 0xd5, 0xe0, 0x39, 0x4d, 0xbf, 0xa5, 0x42, 0xc8, 0x47, 0x34, 0xfb, 0x18,
+	0x08, 0x61, 0x69, 0xf2, 0x57, 0x46, 0x73, 0xcc, 0xa4, 0x3f, 0x4f, 0xec, 0x57, 0xe6, 0x8c, 0xaf,
+	0xc0, 0xab, 0x9f, 0x41, 0xbf, 0x32, 0xd2, 0xd3, 0xc1, 0x8c, 0xe2, 0x10, 0x2f, 0xec, 0x6b, 0xba,
+	0x22, 0x88, 0x8b, 0x24, 0x63, 0x6c, 0x6b, 0x62, 0xcd, 0x4b, 0xc4, 0x5f, 0x6a, 0xb0, 0xf5, 0x05,
+	0xa6, 0x3a, 0x02, 0x91, 0x88, 0xf5, 0xbe, 0x43, 0x68, 0xc7, 0x51, 0x70, 0x5a, 0x1a, 0xfb, 0x72,
+	0x9a, 0x6a, 0x6c, 0x8e, 0xbe, 0x4d, 0xa1, 0x5a, 0x57, 0x93, 0xef, 0xac, 0xa8, 0xe6, 0x25, 0xf0,
+	0x3b, 0x84, 0xf6, 0xb9, 0x32, 0x8b, 0xa9, 0xc9, 0x5a, 0x4e, 0x57, 0x83, 0xd0, 0x5c, 0x0c, 0xc2,
+	0xaf, 0x6a, 0xd0, 0xfc, 0xc4, 0x0f, 0x4e, 0xcf, 0x12, 0xea, 0x58, 0xe7, 0x98, 0x66, 0x94, 0xac,
+	0x9a, 0xc6, 0x3c, 0x86, 0x24, 0x93, 0x99, 0x3f, 0xb3, 0x00, 0x57, 0xad, 0x69, 0xdb, 0x20, 0x45,
+	0x5f, 0x96, 0xeb, 0x2b, 0x67, 0xb0, 0x77, 0xe9, 0x8f, 0x89, 0x99, 0x19, 0xbe, 0xba, 0xbb, 0x6f,
+	0x2c, 0xb4, 0x0e, 0x6d, 0x51, 0x4d, 0xff, 0x5a, 0xce, 0x7b, 0x0a, 0x50, 0x30, 0x57, 0x0e, 0xc9,
+	0x6b, 0xff, 0x0c, 0xc9, 0x07, 0x63, 0xa7, 0x18, 0x8c, 0xdf, 0x7e, 0x00, 0x9d, 0xfc, 0x61, 0x99,
+	0x2e, 0xb4, 0xa7, 0x82, 0x56, 0xee, 0x0d, 0x1a, 0xa0, 0xb8, 0x2f, 0x71, 0x44, 0xff, 0xc0, 0x60,
+	0xe8, 0xd6, 0xe8, 0xaf, 0x03, 0x8e, 0x41, 0x94, 0x44, 0x18, 0xcb, 0xcf, 0xce, 0x84, 0xf4, 0x1f,
+	0x5e, 0x04, 0x88, 0x21, 0x86, 0x6e, 0xfd, 0xed, 0x1f, 0x40, 0xb7, 0xf4, 0xca, 0xab, 0x86, 0x42,
+	0xf5, 0x0f, 0x90, 0x7b, 0x83, 0x75, 0xcc, 0x88, 0xed, 0xd6, 0x58, 0x17, 0x5a, 0xe6, 0x7e, 0x74,
+	0xeb, 0x34, 0xcc, 0xe5, 0x5d, 0xd1, 0x75, 0x8e, 0x9b, 0xea, 0x1f, 0xd8, 0xf7, 0xff, 0x33, 0x00,
+	0x51, 0x58, 0xdc, 0x87, 0x93, 0x1d, 0x00, 0x00,
 }
@@ -0,0 +1,42 @@
+//
+// Key Rotations
+// =============
+//
+// When someone changes their Disco key, their previous key signs the new one (XEdDSA), so that their contacts
+// can move their conversations to the new key without having to verify it again. See rotation.go
+//
+package serialization
+
+import (
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mimoo/sasayaki/xeddsa"
+)
+
+// KeyRotationContent returns what the previous key signs in a key rotation statement
+func KeyRotationContent(statement *KeyRotationStatement) []byte {
+	unsigned := *statement
+	unsigned.Signature = nil
+	serialized, err := proto.Marshal(&unsigned)
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte("SasayakiKeyRotation"), serialized...)
+}
+
+// SignKeyRotation signs a key rotation statement with the previous private key
+func SignKeyRotation(oldPrivateKey [32]byte, statement *KeyRotationStatement) {
+	statement.Signature = xeddsa.Sign(oldPrivateKey, KeyRotationContent(statement))
+}
+
+// VerifyKeyRotation checks that a key rotation statement has been signed by the previous key
+func VerifyKeyRotation(statement *KeyRotationStatement) error {
+	if len(statement.GetOldKey()) != 32 || len(statement.GetNewKey()) != 32 {
+		return errors.New("ssyk: key rotation statement is malformed")
+	}
+	if !xeddsa.Verify(statement.GetOldKey(), KeyRotationContent(statement), statement.GetSignature()) {
+		return errors.New("ssyk: key rotation statement has an invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package serialization
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+// testKeyRotation returns a statement signed by a new previous key
+func testKeyRotation(t *testing.T) *KeyRotationStatement {
+	oldKeyPair, newKeyPair := disco.GenerateKeypair(nil), disco.GenerateKeypair(nil)
+	statement := &KeyRotationStatement{
+		OldKey: oldKeyPair.PublicKey[:],
+		NewKey: newKeyPair.PublicKey[:],
+		Date:   1500000000,
+	}
+	SignKeyRotation(oldKeyPair.PrivateKey, statement)
+	if err := VerifyKeyRotation(statement); err != nil {
+		t.Fatal(err)
+	}
+	return statement
+}
+
+func TestKeyRotation(t *testing.T) {
+	statement := testKeyRotation(t)
+
+	// the signature doesn't sign itself
+	content := KeyRotationContent(statement)
+	if !bytes.HasPrefix(content, []byte("SasayakiKeyRotation")) || bytes.Contains(content, statement.Signature) {
+		t.Errorf("unexpected content %x", content)
+	}
+	// it survives serialization
+	serialized, err := proto.Marshal(statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := &KeyRotationStatement{}
+	if err := proto.Unmarshal(serialized, received); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyKeyRotation(received); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKeyRotationRejectsTamperedStatements(t *testing.T) {
+	otherKey := make([]byte, 32)
+	rand.Read(otherKey)
+	testCases := map[string]func(*KeyRotationStatement){
+		"other new key": func(statement *KeyRotationStatement) { statement.NewKey = otherKey },
+		"other old key": func(statement *KeyRotationStatement) { statement.OldKey = otherKey },
+		"other date":    func(statement *KeyRotationStatement) { statement.Date++ },
+		"swapped keys": func(statement *KeyRotationStatement) {
+			statement.OldKey, statement.NewKey = statement.NewKey, statement.OldKey
+		},
+		"tampered":          func(statement *KeyRotationStatement) { statement.Signature[10] ^= 1 },
+		"short signature":   func(statement *KeyRotationStatement) { statement.Signature = statement.Signature[:63] },
+		"no signature":      func(statement *KeyRotationStatement) { statement.Signature = nil },
+		"short old key":     func(statement *KeyRotationStatement) { statement.OldKey = statement.OldKey[:31] },
+		"short new key":     func(statement *KeyRotationStatement) { statement.NewKey = statement.NewKey[:31] },
+		"signed by new key": nil,
+	}
+	for name, tamper := range testCases {
+		statement := testKeyRotation(t)
+		if tamper == nil {
+			// the new key signs itself
+			newKeyPair := disco.GenerateKeypair(nil)
+			statement.NewKey = newKeyPair.PublicKey[:]
+			SignKeyRotation(newKeyPair.PrivateKey, statement)
+		} else {
+			tamper(statement)
+		}
+		if err := VerifyKeyRotation(statement); err == nil {
+			t.Errorf("%s: the statement was accepted", name)
+		}
+	}
+}
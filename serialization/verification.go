@@ -0,0 +1,45 @@
+//
+// Verification Proofs
+// ===================
+//
+// When Alice has checked Bob's key (in real life, over the phone, etc.), she signs a statement that anyone can
+// verify (see docs/specification.md):
+//
+//	sign({nickname_len, nickname, len_mean, mean, bob_public_key, date, alice_public_key})
+//
+// Lengths are 2-byte big-endian integers, and the date is an 8-byte big-endian unix timestamp.
+// The signature is done with XEdDSA, with Alice's Disco key.
+//
+package serialization
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	VerificationNicknameMaxSize = 100
+	VerificationMeanMaxSize     = 50
+)
+
+// VerificationContent returns what the verifier signs in a verification proof
+func VerificationContent(proof *VerificationProof) ([]byte, error) {
+	nickname, mean := proof.GetNickname(), proof.GetMean()
+	if nickname == "" || len(nickname) > VerificationNicknameMaxSize || mean == "" || len(mean) > VerificationMeanMaxSize {
+		return nil, errors.New("ssyk: verification proof is malformed")
+	}
+	if len(proof.GetPublicKey()) != 32 || len(proof.GetVerifier()) != 32 {
+		return nil, errors.New("ssyk: verification proof is malformed")
+	}
+	var length [2]byte
+	var date [8]byte
+	content := []byte{}
+	binary.BigEndian.PutUint16(length[:], uint16(len(nickname)))
+	content = append(append(content, length[:]...), nickname...)
+	binary.BigEndian.PutUint16(length[:], uint16(len(mean)))
+	content = append(append(content, length[:]...), mean...)
+	content = append(content, proof.GetPublicKey()...)
+	binary.BigEndian.PutUint64(date[:], uint64(proof.GetDate()))
+	content = append(content, date[:]...)
+	return append(content, proof.GetVerifier()...), nil
+}
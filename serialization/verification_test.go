@@ -0,0 +1,62 @@
+package serialization
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestVerificationContent(t *testing.T) {
+	proof := &VerificationProof{
+		Nickname:  "bob",
+		Mean:      "irl",
+		PublicKey: bytes.Repeat([]byte{0xbb}, 32),
+		Date:      0x0102030405060708,
+		Verifier:  bytes.Repeat([]byte{0xaa}, 32),
+		Signature: []byte("not signed"),
+	}
+	content, err := VerificationContent(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0, 3, 'b', 'o', 'b', 0, 3, 'i', 'r', 'l'}
+	expected = append(expected, proof.PublicKey...)
+	expected = append(expected, 1, 2, 3, 4, 5, 6, 7, 8)
+	expected = append(expected, proof.Verifier...)
+	if !bytes.Equal(content, expected) {
+		t.Errorf("got %x, expected %x", content, expected)
+	}
+}
+
+func TestVerificationContentRejectsMalformedProofs(t *testing.T) {
+	valid := func() *VerificationProof {
+		return &VerificationProof{
+			Nickname:  "bob",
+			Mean:      "irl",
+			PublicKey: make([]byte, 32),
+			Verifier:  make([]byte, 32),
+		}
+	}
+	testCases := map[string]func(*VerificationProof){
+		"no nickname":      func(p *VerificationProof) { p.Nickname = "" },
+		"long nickname":    func(p *VerificationProof) { p.Nickname = strings.Repeat("b", VerificationNicknameMaxSize+1) },
+		"no mean":          func(p *VerificationProof) { p.Mean = "" },
+		"long mean":        func(p *VerificationProof) { p.Mean = strings.Repeat("m", VerificationMeanMaxSize+1) },
+		"short public key": func(p *VerificationProof) { p.PublicKey = make([]byte, 31) },
+		"no verifier":      func(p *VerificationProof) { p.Verifier = nil },
+	}
+	for name, malform := range testCases {
+		proof := valid()
+		malform(proof)
+		if _, err := VerificationContent(proof); err == nil {
+			t.Errorf("%s: the proof was accepted", name)
+		}
+	}
+	// the limits themselves are accepted
+	proof := valid()
+	proof.Nickname = strings.Repeat("b", VerificationNicknameMaxSize)
+	proof.Mean = strings.Repeat("m", VerificationMeanMaxSize)
+	if _, err := VerificationContent(proof); err != nil {
+		t.Error(err)
+	}
+}
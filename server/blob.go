@@ -0,0 +1,160 @@
+//
+// Blob Store
+// ==========
+//
+// Attachments are too large to be sent as messages. Clients encrypt them, split them in chunks,
+// and upload these chunks here. Chunks are:
+//
+// * content-addressed: their id is the hex-encoded SHA-256 hash of their content
+// * temporary: they are deleted after the retention of the configuration (`blobExpiration` by default)
+// * limited: in bytes, in total and per client, a chunk counting for its first uploader (see limits.go)
+//
+// Blobs are written through to the storage backend (see persistence.go), and read back when the Hub starts.
+//
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	blobMaxSize           = 40000              // a chunk must fit in a request (2-byte length header)
+	blobExpiration        = 7 * 24 * time.Hour // how long a blob is kept by default
+	blobCollectorInterval = time.Hour          // how often we look for expired blobs
+)
+
+type blob struct {
+	content    []byte
+	uploader   string // the client whose quota the blob counts for
+	expiration time.Time
+}
+
+// storedBlob is a blob in the storage backend
+type storedBlob struct {
+	Content    []byte
+	Uploader   string
+	Expiration time.Time
+}
+
+type blobStore struct {
+	blobs      map[string]*blob
+	size       int64            // bytes of all the blobs
+	perClient  map[string]int64 // client -> bytes of the blobs it uploaded
+	queryMutex sync.Mutex       // one query at a time
+}
+
+var (
+	bs blobStore
+)
+
+func init() {
+	bs.blobs = make(map[string]*blob)
+	bs.perClient = make(map[string]int64)
+}
+
+// load reads the blobs back from the storage backend, and forgets the ones that have expired
+func (bs *blobStore) load() error {
+	bs.queryMutex.Lock()
+	defer bs.queryMutex.Unlock()
+
+	var expired []string
+	now := time.Now()
+	err := persist.load("blobs", func(id string, value []byte) error {
+		var stored storedBlob
+		if err := decodeValue(value, &stored); err != nil {
+			return err
+		}
+		if now.After(stored.Expiration) {
+			expired = append(expired, id)
+			return nil
+		}
+		bs.blobs[id] = &blob{content: stored.Content, uploader: stored.Uploader, expiration: stored.Expiration}
+		bs.size += int64(len(stored.Content))
+		bs.perClient[stored.Uploader] += int64(len(stored.Content))
+		return nil
+	})
+	for _, id := range expired {
+		persist.remove("blobs", id)
+	}
+	return err
+}
+
+// store writes a blob to the storage backend. bs.queryMutex must be held
+func (bs *blobStore) store(id string, b *blob) {
+	persist.put("blobs", id, encodeValue(storedBlob{Content: b.content, Uploader: b.uploader, Expiration: b.expiration}))
+}
+
+// put stores a blob uploaded by a client and returns its id, or an error message if the Hub or the client
+// has reached its quota. Uploading the same blob twice extends its expiration
+func (bs *blobStore) put(uploader string, content []byte) (string, string) {
+	hash := sha256.Sum256(content)
+	id := hex.EncodeToString(hash[:])
+	expiration := time.Now().Add(settings.blobRetention())
+	quota, clientQuota := settings.blobQuotas()
+
+	bs.queryMutex.Lock()
+	defer bs.queryMutex.Unlock()
+
+	if b, ok := bs.blobs[id]; ok {
+		b.expiration = expiration
+		bs.store(id, b)
+		return id, ""
+	}
+	size := int64(len(content))
+	if quota > 0 && bs.size+size > quota {
+		return "", "the Hub stores too many attachments, try again later"
+	}
+	if clientQuota > 0 && bs.perClient[uploader]+size > clientQuota {
+		return "", "too many attachments uploaded, try again later"
+	}
+	bs.blobs[id] = &blob{
+		content:    content,
+		uploader:   uploader,
+		expiration: expiration,
+	}
+	bs.store(id, bs.blobs[id])
+	bs.size += size
+	bs.perClient[uploader] += size
+	return id, ""
+}
+
+// remove deletes a blob and forgets its size. bs.queryMutex must be held
+func (bs *blobStore) remove(id string) {
+	b := bs.blobs[id]
+	delete(bs.blobs, id)
+	persist.remove("blobs", id)
+	size := int64(len(b.content))
+	bs.size -= size
+	if bs.perClient[b.uploader] -= size; bs.perClient[b.uploader] <= 0 {
+		delete(bs.perClient, b.uploader)
+	}
+}
+
+// get returns the content of a blob, or false if it doesn't exist (or has expired)
+func (bs *blobStore) get(id string) ([]byte, bool) {
+	bs.queryMutex.Lock()
+	defer bs.queryMutex.Unlock()
+
+	b, ok := bs.blobs[id]
+	if !ok || time.Now().After(b.expiration) {
+		return nil, false
+	}
+	return b.content, true
+}
+
+// collectGarbage deletes expired blobs every `blobCollectorInterval`, it never returns
+func (bs *blobStore) collectGarbage() {
+	for range time.Tick(blobCollectorInterval) {
+		now := time.Now()
+		bs.queryMutex.Lock()
+		for id, b := range bs.blobs {
+			if now.After(b.expiration) {
+				bs.remove(id)
+			}
+		}
+		bs.queryMutex.Unlock()
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// setTestQuotas changes the quotas of the current settings, until the test ends
+func setTestQuotas(t *testing.T, change func(*hubConfiguration)) {
+	settings.queryMutex.Lock()
+	previous := settings.config
+	config := *previous
+	change(&config)
+	settings.config = &config
+	settings.queryMutex.Unlock()
+	t.Cleanup(func() {
+		settings.queryMutex.Lock()
+		settings.config = previous
+		settings.queryMutex.Unlock()
+	})
+}
+
+func TestBlobQuotas(t *testing.T) {
+	setTestQuotas(t, func(config *hubConfiguration) {
+		config.Quotas.BlobBytes = 5 * blobMaxSize
+		config.Quotas.BlobBytesPerClient = 3 * blobMaxSize
+	})
+	bs.queryMutex.Lock()
+	bs.blobs, bs.size, bs.perClient = make(map[string]*blob), 0, make(map[string]int64)
+	bs.queryMutex.Unlock()
+	chunk := func(i int) []byte { return bytes.Repeat([]byte{byte(i)}, blobMaxSize) }
+
+	// a client can't upload more than its quota, but can upload the same chunk again
+	for i := 0; i < 3; i++ {
+		if _, reason := bs.put("alice", chunk(i)); reason != "" {
+			t.Fatal(reason)
+		}
+	}
+	if _, reason := bs.put("alice", chunk(3)); reason == "" {
+		t.Fatal("alice uploaded more than her quota")
+	}
+	id, reason := bs.put("alice", chunk(0))
+	if reason != "" {
+		t.Fatal(reason)
+	}
+
+	// neither can everyone together
+	for i := 3; i < 5; i++ {
+		if _, reason := bs.put("bob", chunk(i)); reason != "" {
+			t.Fatal(reason)
+		}
+	}
+	if _, reason := bs.put("carol", chunk(5)); reason == "" {
+		t.Fatal("the Hub stored more than its quota")
+	}
+
+	// until chunks expire
+	bs.queryMutex.Lock()
+	bs.remove(id)
+	bs.queryMutex.Unlock()
+	if _, reason := bs.put("carol", chunk(5)); reason != "" {
+		t.Fatal(reason)
+	}
+	if _, reason := bs.put("alice", chunk(6)); reason == "" {
+		t.Fatal("the Hub stored more than its quota")
+	}
+	bs.queryMutex.Lock()
+	if bs.perClient["alice"] != 2*blobMaxSize || bs.size != 5*blobMaxSize {
+		t.Errorf("alice has %d bytes, and the Hub %d", bs.perClient["alice"], bs.size)
+	}
+	bs.queryMutex.Unlock()
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	s "github.com/mimoo/sasayaki/serialization"
@@ -15,11 +18,15 @@ import (
 )
 
 const (
-	messageMaxChars = 10000
+	messageMaxChars   = 10000
+	mlsMessageMaxSize = 60000 // welcomes and commits contain a part of the ratchet tree of a group
+	responseMaxSize   = 65535 // responses are prefixed by a 2-byte length
+	responsePageSize  = 60000 // the results of a request that fit in a response, with room for the other fields
 )
 
 type client struct {
 	publicKey string
+	peer      string // the name of the peer Hub, if the client is a Hub we federate with (see delivery.go)
 }
 
 var (
@@ -37,28 +44,60 @@ func sasayakiServer(listener *disco.Listener) {
 			log.Println("rpc server cannot accept client:", err)
 			continue
 		}
-		log.Println("client accepted", conn.RemoteAddr().String())
+		logDebug("client accepted", conn.RemoteAddr().String())
 
-		clientKey, err := conn.RemotePublicKey()
-		if err != nil {
-			log.Println("cannot read client public key:", err)
-			conn.Close()
-			continue
-		}
-		log.Println("client accepted", clientKey)
+		// the handshake is done in its own goroutine, so that slow clients don't hold the others
+		go acceptClient(conn)
+	}
+}
 
-		cc := client{
-			publicKey: clientKey,
-		}
+// acceptClient authenticates a client, and serves it if it hasn't too many connections open (see limits.go)
+func acceptClient(conn *disco.Conn) {
+	defer conn.Close()
+	ip := remoteIP(conn.RemoteAddr())
+	if reason := connections.openIP(ip); reason != "" {
+		log.Println("connection refused:", reason)
+		return
+	}
+	defer connections.closeIP(ip)
+
+	readTimeout, _ := settings.timeouts()
+	if readTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(readTimeout))
+	}
+	clientKey, err := conn.RemotePublicKey()
+	if err != nil {
+		log.Println("cannot read client public key:", err)
+		return
+	}
+	logDebug("client accepted", clientKey)
 
-		go cc.handleClient(conn)
+	// the peer Hubs forward the messages of all their clients, they aren't limited
+	cc := client{
+		publicKey: clientKey,
+		peer:      peers.nameOf(clientKey),
+	}
+	if cc.peer == "" {
+		if reason := connections.openKey(clientKey); reason != "" {
+			log.Println("connection refused:", reason)
+			return
+		}
+		defer connections.closeKey(clientKey)
 	}
+
+	cc.handleClient(conn)
 }
 
 func (cc client) handleClient(conn net.Conn) {
 
 session:
 	for {
+		// the client has idleTimeout to send a request, and readTimeout for it to arrive (see limits.go)
+		readTimeout, idleTimeout := settings.timeouts()
+		conn.SetDeadline(time.Time{})
+		if idleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		}
 		// receive header
 		var header [2]byte
 		n, err := conn.Read(header[:])
@@ -66,18 +105,21 @@ session:
 			log.Println("can't read header: ", err)
 			break session
 		}
-		length := (header[0] << 8) | header[1]
+		if readTimeout > 0 {
+			conn.SetDeadline(time.Now().Add(readTimeout))
+		}
+		length := int(header[0])<<8 | int(header[1])
 		// receive
 		buffer := make([]byte, length)
-		// read socket
-		n, err = conn.Read(buffer)
+		// read socket (large requests like blobs can arrive in several reads)
+		n, err = io.ReadFull(conn, buffer)
 		if err != nil {
 			if err != io.EOF {
 				log.Println("rpc server cannot read client request:", err)
 			}
 			break session // always break on error
 		}
-		log.Println("received message from client")
+		logDebug("received message from client")
 
 		// parse protobuff request
 		request := &s.Request{}
@@ -90,32 +132,173 @@ session:
 		// what kind of request?
 		var responseData []byte
 
+		// peer Hubs can only forward messages
+		if cc.peer != "" && request.GetRequestType() != s.Request_ForwardMessage {
+			log.Println("peer hub", cc.peer, "sent a request that isn't a forwarded message")
+			break session
+		}
+
+		// every request takes a token from the bucket of the client (peer Hubs only forward messages, these
+		// count for their original sender)
+		if cc.peer == "" {
+			if ok, wait := requestLimiter.take(cc.publicKey); !ok {
+				logDebug("client sent too many requests")
+				responseData, _ = tooManyRequests(request.GetRequestType(), wait)
+				if err = writeResponse(conn, responseData); err != nil {
+					log.Println("client session closing:", err)
+					break session
+				}
+				continue
+			}
+		}
+
 		switch request.GetRequestType() {
 		case s.Request_GetNextMessage:
-			log.Println("client is requesting to get next message")
+			logDebug("client is requesting to get next message")
 			responseData, err = cc.handleGetNextMessage(request)
 			if err != nil {
 				log.Println("client session closing:", err)
 				break session
 			}
 		case s.Request_SendMessage:
-			log.Println("client is requesting to send a message")
+			logDebug("client is requesting to send a message")
 			responseData, err = cc.handleSendMessage(request)
 			if err != nil {
 				log.Println("client session closing:", err)
 				break session
 			}
+		case s.Request_UploadBlob:
+			logDebug("client is requesting to upload a blob")
+			responseData, err = cc.handleUploadBlob(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_DownloadBlob:
+			logDebug("client is requesting to download a blob")
+			responseData, err = cc.handleDownloadBlob(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_PublishKeyPackage:
+			logDebug("client is requesting to publish a key package")
+			responseData, err = cc.handlePublishKeyPackage(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_GetKeyPackage:
+			logDebug("client is requesting to get a key package")
+			responseData, err = cc.handleGetKeyPackage(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_PublishProof:
+			logDebug("client is publishing a verification proof")
+			responseData, err = cc.handlePublishProof(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_GetProofsForMember:
+			logDebug("client is requesting the verification proofs of a key")
+			responseData, err = cc.handleGetProofsForMember(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_GetOrganizationMembers:
+			logDebug("client is requesting to search the directory")
+			responseData, err = cc.handleGetOrganizationMembers(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_GetRevocations:
+			logDebug("client is requesting the revocations")
+			responseData, err = cc.handleGetRevocations(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_GetTreeHead:
+			logDebug("client is requesting the tree head of the log")
+			responseData, err = cc.handleGetTreeHead(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_GetConsistencyProof:
+			logDebug("client is requesting a consistency proof")
+			responseData, err = cc.handleGetConsistencyProof(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_GetLogEntries:
+			logDebug("client is requesting entries of the log")
+			responseData, err = cc.handleGetLogEntries(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_PublishKeyRotation:
+			logDebug("client is publishing a new key")
+			responseData, err = cc.handlePublishKeyRotation(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_GetKeyRotations:
+			logDebug("client is requesting the new keys of its contacts")
+			responseData, err = cc.handleGetKeyRotations(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_PublishDevice:
+			logDebug("client is publishing one of its devices")
+			responseData, err = cc.handlePublishDevice(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_GetDevices:
+			logDebug("client is requesting the devices of its contacts")
+			responseData, err = cc.handleGetDevices(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_RemoveDevice:
+			logDebug("client is removing one of its devices")
+			responseData, err = cc.handleRemoveDevice(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
+		case s.Request_ForwardMessage:
+			logDebug("peer hub is forwarding a message")
+			responseData, err = cc.handleForwardMessage(request)
+			if err != nil {
+				log.Println("client session closing:", err)
+				break session
+			}
 		default:
 			log.Println("request cannot be parsed yet")
 			break session
 		}
 
-		// handle response, encode [length(2), data(...)]
-		responseData = append([]byte{byte(len(responseData) >> 8), byte(len(responseData))}, responseData...)
+		// the handlers bound what they return, a response that still doesn't fit can't be framed
+		if len(responseData) > responseMaxSize {
+			log.Println("client session closing: the response is too large for", request.GetRequestType())
+			break session
+		}
 
-		// send it
-		_, err = conn.Write(responseData)
-		if err != nil {
+		// send the response
+		if err = writeResponse(conn, responseData); err != nil {
 			log.Println("client session closing:", err)
 			break session
 		}
@@ -123,7 +306,26 @@ session:
 	}
 
 	log.Printf("%s closed the connection\n", conn.RemoteAddr().String())
-	conn.Close()
+}
+
+// writeResponse encodes a response [length(2), data(...)] and sends it
+func writeResponse(conn net.Conn, responseData []byte) error {
+	responseData = append([]byte{byte(len(responseData) >> 8), byte(len(responseData))}, responseData...)
+	_, err := conn.Write(responseData)
+	return err
+}
+
+// tooManyRequests answers a request refused by the requestLimiter. Every response starts with the fields of
+// ResponseSuccess, except ResponseMessage: GetNextMessage is answered that nothing is waiting
+func tooManyRequests(requestType s.Request_RequestType, wait time.Duration) ([]byte, error) {
+	switch requestType {
+	case s.Request_GetNextMessage:
+		return proto.Marshal(&s.ResponseMessage{})
+	case s.Request_SendMessage, s.Request_PublishKeyPackage, s.Request_PublishProof, s.Request_PublishKeyRotation,
+		s.Request_PublishDevice, s.Request_RemoveDevice, s.Request_ForwardMessage:
+		return refused(s.ErrorCode_RateLimited, "too many requests, slow down", wait)
+	}
+	return success(false, fmt.Sprintf("too many requests, retry in %ds", retryAfter(wait)))
 }
 
 // success sends a failure or success proto message. Returns an error if it can't write to the conn
@@ -135,6 +337,17 @@ func success(success bool, message string) ([]byte, error) {
 	return proto.Marshal(res)
 }
 
+// refused sends a failure that the client can tell apart (see limits.go)
+func refused(code s.ErrorCode, message string, wait time.Duration) ([]byte, error) {
+	res := &s.ResponseSuccess{
+		Success:    false,
+		Error:      message,
+		Code:       code,
+		RetryAfter: retryAfter(wait),
+	}
+	return proto.Marshal(res)
+}
+
 // handleSendMessage attempts to send the message. Returns an error if it doesn't work
 // because of conn. Otherwise send a failure proto message
 func (cc client) handleSendMessage(req *s.Request) ([]byte, error) {
@@ -142,51 +355,102 @@ func (cc client) handleSendMessage(req *s.Request) ([]byte, error) {
 	if message == nil {
 		return nil, errors.New("ssyk: received empty protobuf message")
 	}
-	// parse request
-	convoId := message.GetConvoId()
-	toAddress := message.GetToAddress()
-	content := message.GetContent()
+	// parse request (the recipient can be on a peer Hub, see delivery.go)
+	toAddress, hub := splitAddress(message.GetToAddress())
 	// checking fields
 	// TODO: test if id or convo id = 0 ? (not set)
-	if len(toAddress) != 64 || content == nil || len(content) > messageMaxChars || len(convoId) != 32 {
-		return success(false, "fields are not correctly formated")
+	if errorMessage := checkMessage(message, toAddress); errorMessage != "" {
+		return success(false, errorMessage)
 	}
-	if !regexHex.MatchString(toAddress) {
-		return success(false, "the recipient address is not [a-z0-9]")
+	if ok, wait := limiter.take(cc.publicKey); !ok {
+		return refused(s.ErrorCode_RateLimited, "too many messages sent, slow down", wait)
 	}
 	toAddress = strings.ToLower(toAddress)
-	// handle the message (TODO: do it w/ a database)
-
-	mm.queryMutex.Lock()
-	mm.pendingMessages[toAddress] = append(mm.pendingMessages[toAddress], Message{
+	pending := Message{
 		fromAddress: cc.publicKey,
-		convoId:     convoId,
-		content:     content,
-	})
-	mm.queryMutex.Unlock()
+		toAddress:   toAddress,
+		convoId:     message.GetConvoId(),
+		content:     message.GetContent(),
+		kind:        message.GetKind(),
+		queuedAt:    time.Now(),
+	}
+	// forward it
+	if hub != "" {
+		if errorMessage := peers.enqueue(hub, pending); errorMessage != "" {
+			return success(false, errorMessage)
+		}
+		return success(true, "")
+	}
+	// handle the message (TODO: do it w/ a database)
+	if errorMessage := mm.push(toAddress, pending); errorMessage != "" {
+		return refused(s.ErrorCode_RecipientQuotaExceeded, errorMessage, 0)
+	}
 
 	// write success or not
 	return success(true, "")
 }
 
+// checkMessage checks the fields of a message to a client, it returns an error message if they are incorrect
+func checkMessage(message *s.Request_Message, toAddress string) string {
+	maxSize := messageMaxChars
+	if message.GetKind() == s.MessageKind_Welcome || message.GetKind() == s.MessageKind_Handshake {
+		maxSize = mlsMessageMaxSize
+	}
+	if len(toAddress) != 64 || message.GetContent() == nil || len(message.GetContent()) > maxSize || len(message.GetConvoId()) != 32 {
+		return "fields are not correctly formated"
+	}
+	if !regexHex.MatchString(toAddress) {
+		return "the recipient address is not [a-z0-9]"
+	}
+	return ""
+}
+
+// handleForwardMessage delivers a message that a peer Hub forwards from one of its clients (see delivery.go)
+func (cc client) handleForwardMessage(req *s.Request) ([]byte, error) {
+	if cc.peer == "" {
+		return nil, errors.New("ssyk: only peer hubs can forward messages")
+	}
+	message := req.GetMessage()
+	if message == nil {
+		return nil, errors.New("ssyk: received empty protobuf message")
+	}
+	// the recipient is one of our clients, and the sender one of the peer's
+	if errorMessage := checkMessage(message, message.GetToAddress()); errorMessage != "" {
+		return success(false, errorMessage)
+	}
+	fromAddress := message.GetFromAddress()
+	if len(fromAddress) != 64 || !regexHex.MatchString(fromAddress) {
+		return success(false, "the sender address is not [a-z0-9]")
+	}
+	toAddress := strings.ToLower(message.GetToAddress())
+	// the recipient answers to <sender>@<peer>
+	fromAddress = strings.ToLower(fromAddress) + "@" + cc.peer
+	if ok, wait := limiter.take(fromAddress); !ok {
+		return refused(s.ErrorCode_RateLimited, "too many messages sent by "+fromAddress+", slow down", wait)
+	}
+	errorMessage := mm.push(toAddress, Message{
+		fromAddress: fromAddress,
+		convoId:     message.GetConvoId(),
+		content:     message.GetContent(),
+		kind:        message.GetKind(),
+		queuedAt:    time.Now(),
+	})
+	if errorMessage != "" {
+		return refused(s.ErrorCode_RecipientQuotaExceeded, errorMessage, 0)
+	}
+	return success(true, "")
+}
+
 func (cc client) handleGetNextMessage(req *s.Request) ([]byte, error) {
 	// empty response for now
 	res := &s.ResponseMessage{}
-	// lock memory
-	mm.queryMutex.Lock()
 	// fetch new message*s* (TODO: do it with a real db)
-	messages, ok := mm.pendingMessages[cc.publicKey]
-	// is there a new message?
-	if ok && len(messages) > 0 {
-		// fetch new message
-		message := messages[0]
-		mm.pendingMessages[cc.publicKey] = messages[1:]
+	if message, ok := mm.pop(cc.publicKey); ok {
 		res.FromAddress = message.fromAddress
 		res.ConvoId = message.convoId
 		res.Content = message.content
+		res.Kind = message.kind
 	}
-	// unlock memory
-	mm.queryMutex.Unlock()
 	// serialize
 	data, err := proto.Marshal(res)
 	if err != nil {
@@ -195,3 +459,350 @@ func (cc client) handleGetNextMessage(req *s.Request) ([]byte, error) {
 	//
 	return data, err
 }
+
+// handleUploadBlob stores a chunk of an encrypted attachment and returns its id
+func (cc client) handleUploadBlob(req *s.Request) ([]byte, error) {
+	blob := req.GetBlob()
+	if blob == nil {
+		return nil, errors.New("ssyk: received empty protobuf blob")
+	}
+	// checking fields
+	content := blob.GetContent()
+	if len(content) == 0 || len(content) > blobMaxSize {
+		return proto.Marshal(&s.ResponseBlob{Success: false, Error: "blob is too large or empty"})
+	}
+	// store it
+	id, reason := bs.put(cc.publicKey, content)
+	if reason != "" {
+		return proto.Marshal(&s.ResponseBlob{Success: false, Error: reason})
+	}
+	//
+	return proto.Marshal(&s.ResponseBlob{Success: true, Id: id})
+}
+
+// handleDownloadBlob returns the content of a chunk of an encrypted attachment
+func (cc client) handleDownloadBlob(req *s.Request) ([]byte, error) {
+	blob := req.GetBlob()
+	if blob == nil {
+		return nil, errors.New("ssyk: received empty protobuf blob")
+	}
+	// checking fields
+	id := strings.ToLower(blob.GetId())
+	if len(id) != 64 || !regexHex.MatchString(id) {
+		return proto.Marshal(&s.ResponseBlob{Success: false, Error: "blob id is not correctly formated"})
+	}
+	// fetch it
+	content, ok := bs.get(id)
+	if !ok {
+		return proto.Marshal(&s.ResponseBlob{Success: false, Error: "blob does not exist or has expired"})
+	}
+	//
+	return proto.Marshal(&s.ResponseBlob{Success: true, Id: id, Content: content})
+}
+
+// handlePublishKeyPackage stores a key package of the client, so that it can be added to MLS groups
+func (cc client) handlePublishKeyPackage(req *s.Request) ([]byte, error) {
+	keyPackage := req.GetKeyPackage()
+	if keyPackage == nil {
+		return nil, errors.New("ssyk: received empty protobuf key package")
+	}
+	// checking fields
+	content := keyPackage.GetContent()
+	if len(content) == 0 || len(content) > keyPackageMaxSize {
+		return success(false, "key package is too large or empty")
+	}
+	parsed := &s.MLSKeyPackage{}
+	if err := proto.Unmarshal(content, parsed); err != nil || hex.EncodeToString(parsed.GetIdentity()) != cc.publicKey {
+		return success(false, "key package does not belong to the client")
+	}
+	// store it
+	kps.put(cc.publicKey, content)
+	//
+	return success(true, "")
+}
+
+// handleGetKeyPackage gives away one of the key packages of a client
+func (cc client) handleGetKeyPackage(req *s.Request) ([]byte, error) {
+	keyPackage := req.GetKeyPackage()
+	if keyPackage == nil {
+		return nil, errors.New("ssyk: received empty protobuf key package")
+	}
+	// checking fields
+	owner := strings.ToLower(keyPackage.GetOwner())
+	if len(owner) != 64 || !regexHex.MatchString(owner) {
+		return proto.Marshal(&s.ResponseKeyPackage{Success: false, Error: "owner is not correctly formated"})
+	}
+	// fetch it
+	content, ok := kps.pop(owner)
+	if !ok {
+		return proto.Marshal(&s.ResponseKeyPackage{Success: false, Error: "no key package available for this client"})
+	}
+	//
+	return proto.Marshal(&s.ResponseKeyPackage{Success: true, Owner: owner, Content: content})
+}
+
+// handleGetOrganizationMembers returns a page of the directory of the organization
+func (cc client) handleGetOrganizationMembers(req *s.Request) ([]byte, error) {
+	search := req.GetDirectory()
+	if search == nil {
+		return nil, errors.New("ssyk: received empty protobuf directory search")
+	}
+	if !directory.isEnabled() {
+		return proto.Marshal(&s.ResponseOrganizationMembers{Success: false, Error: "this Hub is not dedicated to an organization"})
+	}
+	// checking fields
+	limit := int(search.GetLimit())
+	if limit == 0 || limit > directoryMaxLimit {
+		limit = directoryMaxLimit
+	}
+	if len(search.GetQuery()) > 100 {
+		return proto.Marshal(&s.ResponseOrganizationMembers{Success: false, Error: "query is too long"})
+	}
+	// search
+	certificates, total := directory.search(search.GetQuery(), int(search.GetOffset()), limit)
+	return proto.Marshal(&s.ResponseOrganizationMembers{
+		Success:      true,
+		Certificates: certificates,
+		Total:        uint32(total),
+	})
+}
+
+// handleGetRevocations returns a page of the revocations of the organization
+func (cc client) handleGetRevocations(req *s.Request) ([]byte, error) {
+	if !directory.isEnabled() {
+		return proto.Marshal(&s.ResponseRevocations{Success: false, Error: "this Hub is not dedicated to an organization"})
+	}
+	// checking fields (the page is given like a search in the directory)
+	page := req.GetDirectory()
+	limit := int(page.GetLimit())
+	if limit == 0 || limit > revocationMaxLimit {
+		limit = revocationMaxLimit
+	}
+	//
+	serialized, total := revocations.page(int(page.GetOffset()), limit)
+	return proto.Marshal(&s.ResponseRevocations{
+		Success:     true,
+		Revocations: serialized,
+		Total:       uint32(total),
+	})
+}
+
+// handleGetTreeHead returns the signed root of the transparency log
+func (cc client) handleGetTreeHead(req *s.Request) ([]byte, error) {
+	if !directory.isEnabled() {
+		return proto.Marshal(&s.ResponseTreeHead{Success: false, Error: "this Hub is not dedicated to an organization"})
+	}
+	treeHead, err := tlog.treeHead()
+	if err != nil {
+		log.Println("cannot sign the tree head:", err)
+		return proto.Marshal(&s.ResponseTreeHead{Success: false, Error: "the tree head can't be signed right now"})
+	}
+	return proto.Marshal(&s.ResponseTreeHead{Success: true, TreeHead: treeHead})
+}
+
+// handleGetConsistencyProof proves that a previous version of the transparency log is a prefix of another
+func (cc client) handleGetConsistencyProof(req *s.Request) ([]byte, error) {
+	logReq := req.GetLog()
+	if logReq == nil {
+		return nil, errors.New("ssyk: received empty protobuf log request")
+	}
+	if !directory.isEnabled() {
+		return proto.Marshal(&s.ResponseConsistencyProof{Success: false, Error: "this Hub is not dedicated to an organization"})
+	}
+	proof, ok := tlog.consistencyProof(logReq.GetFirst(), logReq.GetSecond())
+	if !ok {
+		return proto.Marshal(&s.ResponseConsistencyProof{Success: false, Error: "these tree sizes are incorrect"})
+	}
+	return proto.Marshal(&s.ResponseConsistencyProof{Success: true, Proof: proof})
+}
+
+// handleGetLogEntries returns entries of the transparency log, with their inclusion proofs
+func (cc client) handleGetLogEntries(req *s.Request) ([]byte, error) {
+	logReq := req.GetLog()
+	if logReq == nil {
+		return nil, errors.New("ssyk: received empty protobuf log request")
+	}
+	if !directory.isEnabled() {
+		return proto.Marshal(&s.ResponseLogEntries{Success: false, Error: "this Hub is not dedicated to an organization"})
+	}
+	entries, ok := tlog.entriesWithProofs(logReq.GetFirst(), logReq.GetSecond())
+	if !ok {
+		return proto.Marshal(&s.ResponseLogEntries{Success: false, Error: "these tree sizes are incorrect"})
+	}
+	return proto.Marshal(&s.ResponseLogEntries{Success: true, Entries: entries})
+}
+
+// handlePublishProof stores a verification done by the client
+// (the signature is checked by the clients fetching the proof)
+func (cc client) handlePublishProof(req *s.Request) ([]byte, error) {
+	proof := req.GetProof()
+	if proof == nil {
+		return nil, errors.New("ssyk: received empty protobuf proof")
+	}
+	// checking fields
+	content := proof.GetContent()
+	if len(content) == 0 || len(content) > proofMaxSize {
+		return success(false, "proof is too large or empty")
+	}
+	parsed := &s.VerificationProof{}
+	if err := proto.Unmarshal(content, parsed); err != nil || hex.EncodeToString(parsed.GetVerifier()) != cc.publicKey {
+		return success(false, "proof was not made by the client")
+	}
+	if _, err := s.VerificationContent(parsed); err != nil {
+		return success(false, "proof is malformed")
+	}
+	// store it
+	if !proofs.put(hex.EncodeToString(parsed.GetPublicKey()), cc.publicKey, content) {
+		return success(false, "too many proofs for this key")
+	}
+	//
+	return success(true, "")
+}
+
+// handleGetProofsForMember returns the verification proofs published for a key
+func (cc client) handleGetProofsForMember(req *s.Request) ([]byte, error) {
+	proof := req.GetProof()
+	if proof == nil {
+		return nil, errors.New("ssyk: received empty protobuf proof")
+	}
+	// checking fields
+	member := strings.ToLower(proof.GetMember())
+	if len(member) != 64 || !regexHex.MatchString(member) {
+		return proto.Marshal(&s.ResponseProofs{Success: false, Error: "member is not correctly formated"})
+	}
+	//
+	return proto.Marshal(&s.ResponseProofs{Success: true, Proofs: proofs.get(member)})
+}
+
+// handlePublishKeyRotation stores the new key of the client, signed by its current key
+func (cc client) handlePublishKeyRotation(req *s.Request) ([]byte, error) {
+	rotation := req.GetRotation()
+	if rotation == nil {
+		return nil, errors.New("ssyk: received empty protobuf rotation")
+	}
+	// checking fields
+	statement := rotation.GetStatement()
+	if len(statement) == 0 || len(statement) > rotationMaxSize {
+		return success(false, "key rotation is too large or empty")
+	}
+	parsed := &s.KeyRotationStatement{}
+	if err := proto.Unmarshal(statement, parsed); err != nil || hex.EncodeToString(parsed.GetOldKey()) != cc.publicKey {
+		return success(false, "key rotation was not made by the client")
+	}
+	if err := s.VerifyKeyRotation(parsed); err != nil {
+		return success(false, err.Error())
+	}
+	// store it
+	if !rotations.put(parsed.GetOldKey(), parsed.GetNewKey(), statement) {
+		return success(false, "this key has already been replaced")
+	}
+	//
+	return success(true, "")
+}
+
+// handleGetKeyRotations returns the key rotations published for some keys
+func (cc client) handleGetKeyRotations(req *s.Request) ([]byte, error) {
+	rotation := req.GetRotation()
+	if rotation == nil {
+		return nil, errors.New("ssyk: received empty protobuf rotation")
+	}
+	// checking fields
+	keys := rotation.GetKeys()
+	if len(keys) > rotationMaxQueryKeys {
+		return proto.Marshal(&s.ResponseKeyRotations{Success: false, Error: "too many keys"})
+	}
+	for i, key := range keys {
+		keys[i] = strings.ToLower(key)
+		if len(keys[i]) != 64 || !regexHex.MatchString(keys[i]) {
+			return proto.Marshal(&s.ResponseKeyRotations{Success: false, Error: "key is not correctly formated"})
+		}
+	}
+	//
+	return proto.Marshal(&s.ResponseKeyRotations{Success: true, Statements: rotations.get(keys)})
+}
+
+// handlePublishDevice stores the certificate of a device, signed by its identity and countersigned by the
+// device, or the request of the client to link a device (not countersigned yet, see device.go)
+func (cc client) handlePublishDevice(req *s.Request) ([]byte, error) {
+	device := req.GetDevice()
+	if device == nil {
+		return nil, errors.New("ssyk: received empty protobuf device")
+	}
+	// checking fields
+	certificate := device.GetCertificate()
+	if len(certificate) == 0 || len(certificate) > deviceCertificateMaxSize {
+		return success(false, "device certificate is too large or empty")
+	}
+	parsed := &s.DeviceCertificate{}
+	if err := proto.Unmarshal(certificate, parsed); err != nil {
+		return success(false, "device certificate is malformed")
+	}
+	identity, deviceKey := hex.EncodeToString(parsed.GetIdentityKey()), hex.EncodeToString(parsed.GetDeviceKey())
+	// a request to link a device
+	if len(parsed.GetDeviceSignature()) == 0 {
+		if identity != cc.publicKey {
+			return success(false, "device certificate was not made by the client")
+		}
+		if err := s.VerifyDeviceRequest(parsed); err != nil {
+			return success(false, err.Error())
+		}
+		if reason := devices.request(parsed.GetIdentityKey(), parsed.GetDeviceKey(), certificate); reason != "" {
+			return success(false, reason)
+		}
+		return success(true, "")
+	}
+	// a certificate
+	if identity != cc.publicKey && deviceKey != cc.publicKey {
+		return success(false, "device certificate is not for the client")
+	}
+	if err := s.VerifyDeviceCertificate(parsed); err != nil {
+		return success(false, err.Error())
+	}
+	// store it
+	if reason := devices.put(parsed.GetIdentityKey(), parsed.GetDeviceKey(), certificate); reason != "" {
+		return success(false, reason)
+	}
+	//
+	return success(true, "")
+}
+
+// handleGetDevices returns the certificates of the devices of some identities, or of some devices
+func (cc client) handleGetDevices(req *s.Request) ([]byte, error) {
+	device := req.GetDevice()
+	if device == nil {
+		return nil, errors.New("ssyk: received empty protobuf device")
+	}
+	// checking fields
+	keys := device.GetKeys()
+	if len(keys) > deviceMaxQueryKeys {
+		return proto.Marshal(&s.ResponseDevices{Success: false, Error: "too many keys"})
+	}
+	for i, key := range keys {
+		keys[i] = strings.ToLower(key)
+		if len(keys[i]) != 64 || !regexHex.MatchString(keys[i]) {
+			return proto.Marshal(&s.ResponseDevices{Success: false, Error: "key is not correctly formated"})
+		}
+	}
+	// a device being linked also gets the request of the identity linking it
+	certificates := devices.get(keys)
+	for _, key := range keys {
+		if request, ok := devices.requestFor(key); ok && key == cc.publicKey {
+			certificates = append(certificates, request)
+		}
+	}
+	//
+	return proto.Marshal(&s.ResponseDevices{Success: true, Certificates: certificates})
+}
+
+// handleRemoveDevice removes one of the client's devices
+func (cc client) handleRemoveDevice(req *s.Request) ([]byte, error) {
+	device := req.GetDevice()
+	if device == nil {
+		return nil, errors.New("ssyk: received empty protobuf device")
+	}
+	if !devices.remove(cc.publicKey, strings.ToLower(device.GetDevice())) {
+		return success(false, "this device doesn't belong to the client")
+	}
+	//
+	return success(true, "")
+}
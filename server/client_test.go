@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+)
+
+// testQuery calls a handler and checks that its response can be framed
+func testQuery(t *testing.T, handler func(*s.Request) ([]byte, error), req *s.Request, res proto.Message) {
+	data, err := handler(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > responseMaxSize {
+		t.Fatalf("%s: the response is %d bytes", req.GetRequestType(), len(data))
+	}
+	if err := proto.Unmarshal(data, res); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testKeys returns count different public keys in hex
+func testKeys(prefix byte, count int) []string {
+	var keys []string
+	for i := 0; i < count; i++ {
+		keys = append(keys, fmt.Sprintf("%02x%062x", prefix, i))
+	}
+	return keys
+}
+
+func TestResponsesFit(t *testing.T) {
+	directory.setEnabled(true)
+	defer directory.setEnabled(false)
+	cc := client{publicKey: testKeys(0xcc, 1)[0]}
+
+	// the log entries are read a page at a time
+	initTransparencyLog(nil)
+	defer initTransparencyLog(nil)
+	for i := 0; i < logMaxEntries; i++ {
+		tlog.appendEntry(&s.LogEntry{Revocation: append(bytes.Repeat([]byte{byte(i)}, 2000), byte(i))})
+	}
+	for first := uint64(0); first < logMaxEntries; {
+		res := &s.ResponseLogEntries{}
+		testQuery(t, cc.handleGetLogEntries, &s.Request{
+			RequestType: s.Request_GetLogEntries,
+			Log:         &s.Request_Log{First: first, Second: logMaxEntries},
+		}, res)
+		if !res.GetSuccess() || len(res.GetEntries()) == 0 || res.GetEntries()[0].GetIndex() != first {
+			t.Fatalf("the entries from %d weren't returned: %s", first, res.GetError())
+		}
+		first += uint64(len(res.GetEntries()))
+	}
+
+	// so are the revocations
+	revocations.queryMutex.Lock()
+	for i := 0; i < 3*revocationMaxLimit; i++ {
+		revocations.revocations = append(revocations.revocations, bytes.Repeat([]byte{byte(i)}, 1000))
+	}
+	revocations.queryMutex.Unlock()
+	defer loadRevocations("", nil)
+	read := 0
+	for {
+		res := &s.ResponseRevocations{}
+		testQuery(t, cc.handleGetRevocations, &s.Request{
+			RequestType: s.Request_GetRevocations,
+			Directory:   &s.Request_Directory{Offset: uint32(read)},
+		}, res)
+		if !res.GetSuccess() || res.GetTotal() != 3*revocationMaxLimit {
+			t.Fatalf("the revocations weren't returned: %s", res.GetError())
+		}
+		if len(res.GetRevocations()) == 0 {
+			break
+		}
+		if !bytes.Equal(res.GetRevocations()[0], bytes.Repeat([]byte{byte(read)}, 1000)) {
+			t.Fatalf("the page at %d starts with another revocation", read)
+		}
+		read += len(res.GetRevocations())
+	}
+	if read != 3*revocationMaxLimit {
+		t.Errorf("%d revocations were read", read)
+	}
+
+	// as many keys as allowed, with the largest statements and certificates
+	keys := testKeys(0x01, rotationMaxQueryKeys)
+	rotations.queryMutex.Lock()
+	for _, key := range keys {
+		rotations.statements[key] = bytes.Repeat([]byte{0x01}, rotationMaxSize)
+	}
+	rotations.queryMutex.Unlock()
+	rotationsRes := &s.ResponseKeyRotations{}
+	testQuery(t, cc.handleGetKeyRotations, &s.Request{
+		RequestType: s.Request_GetKeyRotations,
+		Rotation:    &s.Request_Rotation{Keys: keys},
+	}, rotationsRes)
+	if len(rotationsRes.GetStatements()) != rotationMaxQueryKeys {
+		t.Errorf("%d key rotations were returned: %s", len(rotationsRes.GetStatements()), rotationsRes.GetError())
+	}
+
+	keys = testKeys(0x02, deviceMaxQueryKeys)
+	devices.queryMutex.Lock()
+	for i, key := range keys {
+		devices.certificates[key] = make(map[string][]byte)
+		for _, device := range testKeys(0x03+byte(i), deviceMaxPerIdentity) {
+			devices.certificates[key][device] = bytes.Repeat([]byte{0x02}, deviceCertificateMaxSize)
+		}
+	}
+	devices.queryMutex.Unlock()
+	devicesRes := &s.ResponseDevices{}
+	testQuery(t, cc.handleGetDevices, &s.Request{
+		RequestType: s.Request_GetDevices,
+		Device:      &s.Request_Device{Keys: keys},
+	}, devicesRes)
+	if len(devicesRes.GetCertificates()) != deviceMaxQueryKeys*deviceMaxPerIdentity {
+		t.Errorf("%d device certificates were returned: %s", len(devicesRes.GetCertificates()), devicesRes.GetError())
+	}
+}
+
+// testRoundTrip sends a request on a connection, and reads the response
+func testRoundTrip(t *testing.T, conn net.Conn, req *s.Request, res proto.Message) {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(append([]byte{byte(len(data) >> 8), byte(len(data))}, data...)); err != nil {
+		t.Fatal(err)
+	}
+	var header [2]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	data = make([]byte, int(header[0])<<8|int(header[1]))
+	if _, err := io.ReadFull(conn, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := proto.Unmarshal(data, res); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestLimit(t *testing.T) {
+	setTestQuotas(t, func(config *hubConfiguration) {
+		config.Quotas.RequestsPerSecond = 0.001
+		config.Quotas.RequestsBurst = 3
+	})
+	key := testKeys(0xdd, 1)[0]
+	requestLimiter.queryMutex.Lock()
+	delete(requestLimiter.buckets, key)
+	requestLimiter.queryMutex.Unlock()
+	conn, hubConn := net.Pipe()
+	defer conn.Close()
+	go client{publicKey: key}.handleClient(hubConn)
+
+	// every kind of request takes a token
+	testRoundTrip(t, conn, &s.Request{RequestType: s.Request_GetNextMessage}, &s.ResponseMessage{})
+	blobRes := &s.ResponseBlob{}
+	testRoundTrip(t, conn, &s.Request{
+		RequestType: s.Request_DownloadBlob,
+		Blob:        &s.Request_Blob{Id: key},
+	}, blobRes)
+	if blobRes.GetError() != "blob does not exist or has expired" {
+		t.Fatalf("the request wasn't handled: %s", blobRes.GetError())
+	}
+	sendRes := &s.ResponseSuccess{}
+	testRoundTrip(t, conn, &s.Request{
+		RequestType: s.Request_PublishProof,
+		Proof:       &s.Request_Proof{},
+	}, sendRes)
+	if sendRes.GetCode() == s.ErrorCode_RateLimited {
+		t.Fatal("the third request was refused")
+	}
+
+	// then they are refused, in a way each client can parse
+	testRoundTrip(t, conn, &s.Request{
+		RequestType: s.Request_DownloadBlob,
+		Blob:        &s.Request_Blob{Id: key},
+	}, blobRes)
+	if blobRes.GetSuccess() || blobRes.GetError() == "blob does not exist or has expired" {
+		t.Errorf("the fourth request was handled: %s", blobRes.GetError())
+	}
+	testRoundTrip(t, conn, &s.Request{
+		RequestType: s.Request_PublishProof,
+		Proof:       &s.Request_Proof{},
+	}, sendRes)
+	if sendRes.GetCode() != s.ErrorCode_RateLimited || sendRes.GetRetryAfter() == 0 {
+		t.Errorf("the request wasn't rate limited: %s", sendRes.GetError())
+	}
+	messageRes := &s.ResponseMessage{}
+	testRoundTrip(t, conn, &s.Request{RequestType: s.Request_GetNextMessage}, messageRes)
+	if messageRes.GetFromAddress() != "" {
+		t.Errorf("a message from %s was returned", messageRes.GetFromAddress())
+	}
+}
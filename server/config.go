@@ -0,0 +1,359 @@
+//
+// Configuration
+// =============
+//
+// The Hub reads its settings from a JSON file (-config), for example:
+//
+//	{
+//		"listen": "0.0.0.0:7474",
+//		"notification_listen": "0.0.0.0:7475",
+//		"keypair_file": "/var/lib/sasayaki/server.keypair",
+//		"keypair_passphrase": {"source": "file", "file": "/etc/sasayaki/passphrase"},
+//		"signer": {"socket": "/run/sasayaki/signer.sock"},
+//		"storage": {"backend": "disk", "path": "/var/lib/sasayaki/hub-data"},
+//		"retention": {"messages": "720h", "blobs": "168h"},
+//		"quotas": {"pending_messages": 10000, "pending_bytes": 104857600, "messages_per_second": 5, "messages_burst": 50,
+//			"requests_per_second": 50, "requests_burst": 1000, "blob_bytes_per_client": 268435456},
+//		"organization_keys": ["3a5f..."],
+//		"revocations": "/etc/sasayaki/revocations",
+//		"peers": "/etc/sasayaki/peers",
+//		"log_level": "info"
+//	}
+//
+// Missing settings keep their default value, and the file is validated at startup (unknown settings are errors).
+// The flags override the file. On SIGHUP, the file is read again and everything is reloaded, except the listen
+// addresses, the keypair (and how it is unlocked and used) and the storage which need a restart.
+//
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type hubConfiguration struct {
+	Listen             string `json:"listen"`              // the address of the RPC API
+	NotificationListen string `json:"notification_listen"` // the address of the push notifications
+	KeyPairFile        string `json:"keypair_file"`
+
+	KeyPairPassphrase struct {
+		Source string `json:"source"` // "prompt" (the default), "file", "env" or "none" (see keypair.go)
+		File   string `json:"file"`   // for "file", its first line is the passphrase
+		Env    string `json:"env"`    // for "env", the variable holding it (default SASAYAKI_HUB_PASSPHRASE)
+	} `json:"keypair_passphrase"`
+
+	Signer struct {
+		Socket string `json:"socket"` // the Unix socket of the process signing the tree heads, empty to sign ourselves (the keypair is loaded anyway, see signer.go)
+	} `json:"signer"`
+
+	Storage struct {
+		Backend string `json:"backend"` // "disk" (the default), or "memory" to lose everything on restart (see persistence.go)
+		Path    string `json:"path"`    // for "disk", the directory of the journals (default hub-data)
+	} `json:"storage"`
+
+	Retention struct {
+		Messages duration `json:"messages"` // how long messages wait for their recipient, 0 for ever
+		Blobs    duration `json:"blobs"`    // how long attachments are kept
+	} `json:"retention"`
+
+	Quotas struct {
+		PendingMessages    int      `json:"pending_messages"`      // messages waiting for a recipient, 0 for no limit
+		PendingBytes       int64    `json:"pending_bytes"`         // bytes of messages waiting for a recipient, 0 for no limit
+		MessagesPerSecond  float64  `json:"messages_per_second"`   // messages a sender can send, 0 for no limit (see limits.go)
+		MessagesBurst      int      `json:"messages_burst"`        // messages a sender can send in a row
+		RequestsPerSecond  float64  `json:"requests_per_second"`   // requests of any kind a client can send, 0 for no limit
+		RequestsBurst      int      `json:"requests_burst"`        // requests a client can send in a row
+		BlobBytes          int64    `json:"blob_bytes"`            // bytes of attachments stored, 0 for no limit
+		BlobBytesPerClient int64    `json:"blob_bytes_per_client"` // bytes of attachments stored for a client, 0 for no limit
+		ConnectionsPerKey  int      `json:"connections_per_key"`   // connections open at the same time by a client, 0 for no limit
+		ConnectionsPerIP   int      `json:"connections_per_ip"`    // connections open at the same time from an IP address, 0 for no limit
+		ReadTimeout        duration `json:"read_timeout"`          // how long a request can take to arrive, 0 for ever
+		IdleTimeout        duration `json:"idle_timeout"`          // how long a connection can stay without request, 0 for ever
+	} `json:"quotas"`
+
+	OrganizationKeys []string `json:"organization_keys"` // in hex, only their members can use the Hub (see organization.go)
+	Revocations      string   `json:"revocations"`       // the file of the revocations of these organizations
+	Peers            string   `json:"peers"`             // the file of the peer Hubs (see delivery.go)
+	LogLevel         string   `json:"log_level"`         // "info", or "debug" to log every request
+}
+
+// duration is a time.Duration written as a string in JSON ("30m", "720h", etc.)
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return errors.New("ssyk: durations are strings like \"720h\"")
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// defaultConfiguration returns the settings used when there is no configuration file
+func defaultConfiguration() *hubConfiguration {
+	config := &hubConfiguration{
+		Listen:             "127.0.0.1:7474",
+		NotificationListen: "127.0.0.1:7475",
+		KeyPairFile:        defaultKeyPairFile,
+		LogLevel:           "info",
+	}
+	config.KeyPairPassphrase.Source = "prompt"
+	config.Storage.Backend = "disk"
+	config.Retention.Blobs.Duration = blobExpiration
+	config.Quotas.MessagesPerSecond = 10
+	config.Quotas.MessagesBurst = 100
+	config.Quotas.RequestsPerSecond = 50
+	config.Quotas.RequestsBurst = 1000 // an attachment of 40MB is 1000 chunks
+	config.Quotas.BlobBytes = 4 << 30
+	config.Quotas.BlobBytesPerClient = 256 << 20
+	config.Quotas.ConnectionsPerKey = 16
+	config.Quotas.ConnectionsPerIP = 64
+	config.Quotas.ReadTimeout.Duration = 30 * time.Second
+	config.Quotas.IdleTimeout.Duration = 10 * time.Minute
+	return config
+}
+
+// loadConfiguration reads and validates a configuration file
+func loadConfiguration(file string) (*hubConfiguration, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	config := defaultConfiguration()
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("ssyk: cannot parse %s: %v", file, err)
+	}
+	return config, nil
+}
+
+// validate checks the settings, and returns the organization keys
+func (config *hubConfiguration) validate() ([]ed25519.PublicKey, error) {
+	if config.Listen == "" || config.NotificationListen == "" || config.Listen == config.NotificationListen {
+		return nil, errors.New("ssyk: listen and notification_listen must be two different addresses")
+	}
+	if config.KeyPairFile == "" {
+		return nil, errors.New("ssyk: keypair_file is missing")
+	}
+	switch config.KeyPairPassphrase.Source {
+	case "prompt", "env", "none":
+	case "file":
+		if config.KeyPairPassphrase.File == "" {
+			return nil, errors.New("ssyk: keypair_passphrase needs a file")
+		}
+	default:
+		return nil, errors.New("ssyk: keypair_passphrase comes from prompt, file, env or none")
+	}
+	switch config.Storage.Backend {
+	case "disk":
+	case "memory":
+		if config.Storage.Path != "" {
+			return nil, errors.New("ssyk: the memory storage has no path")
+		}
+	default:
+		return nil, errors.New("ssyk: unknown storage backend " + config.Storage.Backend + ", it is disk or memory")
+	}
+	if config.Retention.Messages.Duration < 0 || config.Retention.Blobs.Duration <= 0 {
+		return nil, errors.New("ssyk: retentions must be positive")
+	}
+	quotas := config.Quotas
+	if quotas.PendingMessages < 0 || quotas.PendingBytes < 0 || quotas.MessagesPerSecond < 0 || quotas.RequestsPerSecond < 0 ||
+		quotas.BlobBytes < 0 || quotas.BlobBytesPerClient < 0 || quotas.ConnectionsPerKey < 0 || quotas.ConnectionsPerIP < 0 ||
+		quotas.ReadTimeout.Duration < 0 || quotas.IdleTimeout.Duration < 0 {
+		return nil, errors.New("ssyk: quotas must be positive")
+	}
+	if quotas.MessagesPerSecond > 0 && quotas.MessagesBurst < 1 {
+		return nil, errors.New("ssyk: messages_burst must be at least 1")
+	}
+	if quotas.RequestsPerSecond > 0 && quotas.RequestsBurst < 1 {
+		return nil, errors.New("ssyk: requests_burst must be at least 1")
+	}
+	var organizationKeys []ed25519.PublicKey
+	for _, key := range config.OrganizationKeys {
+		organizationKey, err := hex.DecodeString(strings.TrimSpace(key))
+		if err != nil || len(organizationKey) != ed25519.PublicKeySize {
+			return nil, errors.New("ssyk: incorrect organization public key " + key)
+		}
+		organizationKeys = append(organizationKeys, ed25519.PublicKey(organizationKey))
+	}
+	if config.Revocations != "" && len(organizationKeys) == 0 {
+		return nil, errors.New("ssyk: revocations need organization_keys")
+	}
+	if config.LogLevel != "info" && config.LogLevel != "debug" {
+		return nil, errors.New("ssyk: log_level is info or debug")
+	}
+	return organizationKeys, nil
+}
+
+//
+// Current settings
+//
+
+type settingsStore struct {
+	config        *hubConfiguration
+	organizations []ed25519.PublicKey // the keys of the organizations whose members can use the Hub
+	queryMutex    sync.Mutex          // one query at a time
+}
+
+var (
+	settings settingsStore
+)
+
+func init() {
+	settings.config = defaultConfiguration()
+}
+
+// apply validates a configuration, and uses it for everything that can change without a restart
+func (settings *settingsStore) apply(config *hubConfiguration) error {
+	organizationKeys, err := config.validate()
+	if err != nil {
+		return err
+	}
+	if err := loadRevocations(config.Revocations, organizationKeys); err != nil {
+		return fmt.Errorf("ssyk: cannot load the revocations: %v", err)
+	}
+	// a configuration without peers removes them
+	if err := loadPeers(config.Peers); err != nil {
+		return fmt.Errorf("ssyk: cannot load the peer hubs: %v", err)
+	}
+	settings.queryMutex.Lock()
+	settings.config = config
+	settings.organizations = organizationKeys
+	settings.queryMutex.Unlock()
+	directory.setEnabled(len(organizationKeys) > 0)
+	return nil
+}
+
+// reloadOnSIGHUP reads the configuration file again every time the Hub receives a SIGHUP, it never returns.
+// overrides applies the flags to the configuration read
+func (settings *settingsStore) reloadOnSIGHUP(file string, overrides func(*hubConfiguration) error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		config, err := loadConfiguration(file)
+		if err == nil {
+			err = overrides(config)
+		}
+		if err != nil {
+			log.Println("configuration not reloaded:", err)
+			continue
+		}
+		// these need a restart
+		settings.queryMutex.Lock()
+		current := settings.config
+		settings.queryMutex.Unlock()
+		if config.Listen != current.Listen || config.NotificationListen != current.NotificationListen ||
+			config.KeyPairFile != current.KeyPairFile || config.KeyPairPassphrase != current.KeyPairPassphrase ||
+			config.Signer != current.Signer || config.Storage != current.Storage {
+			log.Println("the listen addresses, the keypair, the signer and the storage are only changed by a restart")
+			config.Listen, config.NotificationListen = current.Listen, current.NotificationListen
+			config.KeyPairFile, config.KeyPairPassphrase = current.KeyPairFile, current.KeyPairPassphrase
+			config.Signer, config.Storage = current.Signer, current.Storage
+		}
+		if err := settings.apply(config); err != nil {
+			log.Println("configuration not reloaded:", err)
+			continue
+		}
+		log.Println("configuration reloaded")
+	}
+}
+
+// organizationKeys returns the keys of the organizations whose members can use the Hub
+func (settings *settingsStore) organizationKeys() []ed25519.PublicKey {
+	settings.queryMutex.Lock()
+	defer settings.queryMutex.Unlock()
+	return settings.organizations
+}
+
+// messageRetention returns how long messages wait for their recipient, 0 for ever
+func (settings *settingsStore) messageRetention() time.Duration {
+	settings.queryMutex.Lock()
+	defer settings.queryMutex.Unlock()
+	return settings.config.Retention.Messages.Duration
+}
+
+// blobRetention returns how long blobs are kept
+func (settings *settingsStore) blobRetention() time.Duration {
+	settings.queryMutex.Lock()
+	defer settings.queryMutex.Unlock()
+	return settings.config.Retention.Blobs.Duration
+}
+
+// pendingMessagesQuota returns how many messages can wait for a recipient, 0 for no limit
+func (settings *settingsStore) pendingMessagesQuota() int {
+	settings.queryMutex.Lock()
+	defer settings.queryMutex.Unlock()
+	return settings.config.Quotas.PendingMessages
+}
+
+// pendingBytesQuota returns how many bytes of messages can wait for a recipient, 0 for no limit
+func (settings *settingsStore) pendingBytesQuota() int64 {
+	settings.queryMutex.Lock()
+	defer settings.queryMutex.Unlock()
+	return settings.config.Quotas.PendingBytes
+}
+
+// messageRate returns how many messages per second a sender can send (0 for no limit), and in a row
+func (settings *settingsStore) messageRate() (float64, int) {
+	settings.queryMutex.Lock()
+	defer settings.queryMutex.Unlock()
+	return settings.config.Quotas.MessagesPerSecond, settings.config.Quotas.MessagesBurst
+}
+
+// requestRate returns how many requests per second a client can send (0 for no limit), and in a row
+func (settings *settingsStore) requestRate() (float64, int) {
+	settings.queryMutex.Lock()
+	defer settings.queryMutex.Unlock()
+	return settings.config.Quotas.RequestsPerSecond, settings.config.Quotas.RequestsBurst
+}
+
+// blobQuotas returns how many bytes of attachments can be stored, and for a client, 0 for no limit
+func (settings *settingsStore) blobQuotas() (int64, int64) {
+	settings.queryMutex.Lock()
+	defer settings.queryMutex.Unlock()
+	return settings.config.Quotas.BlobBytes, settings.config.Quotas.BlobBytesPerClient
+}
+
+// connectionLimits returns how many connections can be open at the same time per client key and per IP
+// address, 0 for no limit
+func (settings *settingsStore) connectionLimits() (int, int) {
+	settings.queryMutex.Lock()
+	defer settings.queryMutex.Unlock()
+	return settings.config.Quotas.ConnectionsPerKey, settings.config.Quotas.ConnectionsPerIP
+}
+
+// timeouts returns how long a request can take to arrive, and how long a connection can stay without
+// request, 0 for ever
+func (settings *settingsStore) timeouts() (time.Duration, time.Duration) {
+	settings.queryMutex.Lock()
+	defer settings.queryMutex.Unlock()
+	return settings.config.Quotas.ReadTimeout.Duration, settings.config.Quotas.IdleTimeout.Duration
+}
+
+// logDebug logs the requests of the clients, if the log level is "debug"
+func logDebug(v ...interface{}) {
+	settings.queryMutex.Lock()
+	debug := settings.config.LogLevel == "debug"
+	settings.queryMutex.Unlock()
+	if debug {
+		log.Println(v...)
+	}
+}
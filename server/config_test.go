@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultConfiguration(t *testing.T) {
+	organizationKeys, err := defaultConfiguration().validate()
+	if err != nil || len(organizationKeys) != 0 {
+		t.Fatalf("the default configuration is invalid: %v", err)
+	}
+}
+
+func TestValidateConfiguration(t *testing.T) {
+	organizationKey := strings.Repeat("ab", 32)
+	testCases := map[string]func(*hubConfiguration){
+		"no listen":             func(config *hubConfiguration) { config.Listen = "" },
+		"no notification":       func(config *hubConfiguration) { config.NotificationListen = "" },
+		"same listen addresses": func(config *hubConfiguration) { config.NotificationListen = config.Listen },
+		"no keypair":            func(config *hubConfiguration) { config.KeyPairFile = "" },
+		"unknown passphrase":    func(config *hubConfiguration) { config.KeyPairPassphrase.Source = "ask" },
+		"passphrase file":       func(config *hubConfiguration) { config.KeyPairPassphrase.Source = "file" },
+		"unknown storage":       func(config *hubConfiguration) { config.Storage.Backend = "sql" },
+		"memory with path": func(config *hubConfiguration) {
+			config.Storage.Backend, config.Storage.Path = "memory", "/var/lib/sasayaki/hub-data"
+		},
+		"negative retention":    func(config *hubConfiguration) { config.Retention.Messages.Duration = -time.Hour },
+		"no blob retention":     func(config *hubConfiguration) { config.Retention.Blobs.Duration = 0 },
+		"negative quota":        func(config *hubConfiguration) { config.Quotas.PendingMessages = -1 },
+		"negative bytes":        func(config *hubConfiguration) { config.Quotas.PendingBytes = -1 },
+		"negative rate":         func(config *hubConfiguration) { config.Quotas.MessagesPerSecond = -1 },
+		"no burst":              func(config *hubConfiguration) { config.Quotas.MessagesBurst = 0 },
+		"negative request rate": func(config *hubConfiguration) { config.Quotas.RequestsPerSecond = -1 },
+		"no request burst":      func(config *hubConfiguration) { config.Quotas.RequestsBurst = 0 },
+		"negative blob bytes":   func(config *hubConfiguration) { config.Quotas.BlobBytesPerClient = -1 },
+		"negative connections":  func(config *hubConfiguration) { config.Quotas.ConnectionsPerIP = -1 },
+		"negative timeout":      func(config *hubConfiguration) { config.Quotas.IdleTimeout.Duration = -time.Second },
+		"short organization":    func(config *hubConfiguration) { config.OrganizationKeys = []string{organizationKey[:62]} },
+		"hex organization":      func(config *hubConfiguration) { config.OrganizationKeys = []string{"zz" + organizationKey[2:]} },
+		"revocations alone":     func(config *hubConfiguration) { config.Revocations = "/etc/sasayaki/revocations" },
+		"unknown log level":     func(config *hubConfiguration) { config.LogLevel = "trace" },
+	}
+	for name, change := range testCases {
+		config := defaultConfiguration()
+		change(config)
+		if _, err := config.validate(); err == nil {
+			t.Errorf("%s: the configuration was accepted", name)
+		}
+	}
+
+	// what is accepted
+	config := defaultConfiguration()
+	config.KeyPairPassphrase.Source = "file"
+	config.KeyPairPassphrase.File = "/etc/sasayaki/passphrase"
+	config.Quotas.MessagesPerSecond = 0
+	config.Quotas.MessagesBurst = 0
+	config.Quotas.RequestsPerSecond = 0
+	config.Quotas.RequestsBurst = 0
+	config.OrganizationKeys = []string{" " + organizationKey + "\n"}
+	config.Revocations = "/etc/sasayaki/revocations"
+	organizationKeys, err := config.validate()
+	if err != nil || len(organizationKeys) != 1 || organizationKeys[0][0] != 0xab {
+		t.Fatalf("a valid configuration was refused: %v", err)
+	}
+}
+
+func TestLoadConfiguration(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		file := filepath.Join(dir, "hub.json")
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	// missing settings keep their default
+	config, err := loadConfiguration(write(`{"listen": "0.0.0.0:7474", "retention": {"messages": "720h"}, "quotas": {"messages_burst": 50}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Listen != "0.0.0.0:7474" || config.Retention.Messages.Duration != 720*time.Hour || config.Quotas.MessagesBurst != 50 {
+		t.Errorf("the settings weren't read: %+v", config)
+	}
+	if config.NotificationListen != "127.0.0.1:7475" || config.Quotas.MessagesPerSecond != 10 || config.Retention.Blobs.Duration != blobExpiration {
+		t.Errorf("the defaults weren't kept: %+v", config)
+	}
+
+	// unknown settings and malformed values are errors
+	for _, content := range []string{
+		`{"listen": "0.0.0.0:7474", "lsiten": "0.0.0.0:7475"}`,
+		`{"retention": {"messages": 720}}`,
+		`{"retention": {"messages": "a month"}}`,
+		`{"quotas": {"pending_messages": "many"}}`,
+		`{"listen": `,
+	} {
+		if _, err := loadConfiguration(write(content)); err == nil {
+			t.Errorf("%s was accepted", content)
+		}
+	}
+	if _, err := loadConfiguration(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("a missing file was accepted")
+	}
+}
+
+func TestApplyPeers(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "peers")
+	peer := "hub-b 127.0.0.1:7474 " + strings.Repeat("cd", 32) + "\n"
+	if err := ioutil.WriteFile(file, []byte(peer), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer settings.apply(defaultConfiguration())
+
+	config := defaultConfiguration()
+	config.Peers = file
+	if err := settings.apply(config); err != nil {
+		t.Fatal(err)
+	}
+	if peers.nameOf(strings.Repeat("cd", 32)) != "hub-b" {
+		t.Fatal("the peer wasn't loaded")
+	}
+
+	// reloading a configuration without peers forgets them
+	if err := settings.apply(defaultConfiguration()); err != nil {
+		t.Fatal(err)
+	}
+	if peers.nameOf(strings.Repeat("cd", 32)) != "" {
+		t.Error("the peer is still accepted")
+	}
+}
@@ -2,8 +2,310 @@
 // Delivery Service
 // ================
 //
+// Hubs can forward messages to each other. The peer Hubs are pinned in a file (see `peers` in config.go), one per line:
+//
+//	<name> <address> <public key in hex>
+//
+// A client sends a message to <key>@<name> to reach a client of the peer Hub <name>. The message is queued,
+// and the forwarder delivers it to the peer Hub over a Disco IK connection (authenticated with our keypair, and
+// with the pinned key of the peer), retrying while the peer can't be reached. The peer Hub only accepts
+// ForwardMessage requests from the Hubs it pins, and delivers the message to its client as coming from
+// <sender>@<its name for us>, so that the client can answer. Both Hubs must thus pin each other.
+//
+// A peer Hub only forwards the messages of its own clients: messages are never relayed to a third Hub.
+//
+// The queues are written through to the storage backend (see persistence.go). The messages queued for a peer
+// that is removed from the file are dropped.
+//
 package main
 
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+const (
+	forwardRetryInterval = 10 * time.Second
+	forwardTimeout       = 10 * time.Second
+	forwardMaxAge        = 24 * time.Hour // messages that couldn't be forwarded for that long are dropped
+	forwardMaxQueued     = 10000          // per peer Hub
+)
+
+type peerHub struct {
+	name      string
+	address   string
+	publicKey []byte
+	queue     []Message // the messages waiting to be forwarded
+	conn      net.Conn  // only used by the forwarder, nil when disconnected
+}
+
+type peerList struct {
+	keyPair    *disco.KeyPair      // ours, to authenticate to the peer Hubs
+	byName     map[string]*peerHub // name -> peer
+	byKey      map[string]string   // public key in hex -> name
+	wakeup     chan struct{}       // tells the forwarder that a message has been queued
+	queryMutex sync.Mutex          // one query at a time
+}
+
+var (
+	peers peerList
+
+	regexPeerName = regexp.MustCompile(`^[a-z0-9.-]{1,64}$`)
+)
+
+func init() {
+	peers.byName = make(map[string]*peerHub)
+	peers.byKey = make(map[string]string)
+	peers.wakeup = make(chan struct{}, 1)
+}
+
+// initPeers sets the keypair we authenticate with to the peer Hubs
+func initPeers(keyPair *disco.KeyPair) {
+	peers.queryMutex.Lock()
+	defer peers.queryMutex.Unlock()
+	peers.keyPair = keyPair
+}
+
+// loadPeers reads the peer Hubs we federate with from a file, an empty file name meaning none.
+// When the file is reloaded, the messages queued for the peers that are still there are kept
+func loadPeers(file string) error {
+	var content []byte
+	if file != "" {
+		var err error
+		if content, err = ioutil.ReadFile(file); err != nil {
+			return err
+		}
+	}
+	byName := make(map[string]*peerHub)
+	byKey := make(map[string]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 3 || !regexPeerName.MatchString(fields[0]) {
+			return errors.New("ssyk: a peer is a line <name> <address> <public key in hex>")
+		}
+		publicKey, err := hex.DecodeString(fields[2])
+		if err != nil || len(publicKey) != 32 {
+			return errors.New("ssyk: incorrect public key for the peer " + fields[0])
+		}
+		key := hex.EncodeToString(publicKey)
+		if _, ok := byName[fields[0]]; ok || byKey[key] != "" {
+			return errors.New("ssyk: the peer " + fields[0] + " is listed twice")
+		}
+		byName[fields[0]] = &peerHub{name: fields[0], address: fields[1], publicKey: publicKey}
+		byKey[key] = fields[0]
+	}
+	peers.queryMutex.Lock()
+	defer peers.queryMutex.Unlock()
+	for name, peer := range peers.byName {
+		if _, ok := byName[name]; !ok {
+			for _, message := range peer.queue {
+				persist.remove("forward", message.key)
+			}
+		}
+	}
+	for name, peer := range byName {
+		// the new address and key are used the next time the forwarder connects
+		if current, ok := peers.byName[name]; ok {
+			current.address, current.publicKey = peer.address, peer.publicKey
+			byName[name] = current
+		}
+	}
+	peers.byName = byName
+	peers.byKey = byKey
+	return nil
+}
+
+// loadQueues reads the queues of the peer Hubs back from the storage backend, once the peers are loaded.
+// The messages of the peers that were removed are dropped
+func (peers *peerList) loadQueues() error {
+	peers.queryMutex.Lock()
+	defer peers.queryMutex.Unlock()
+
+	var dropped []string
+	err := persist.load("forward", func(key string, value []byte) error {
+		message, name, err := decodeMessage(key, value)
+		if err != nil {
+			return err
+		}
+		if peer, ok := peers.byName[name]; ok {
+			peer.queue = append(peer.queue, message)
+		} else {
+			dropped = append(dropped, key)
+		}
+		return nil
+	})
+	for _, key := range dropped {
+		persist.remove("forward", key)
+	}
+	if len(dropped) > 0 {
+		log.Println("dropped", len(dropped), "messages queued for peers that were removed")
+	}
+	return err
+}
+
+// nameOf returns the name of a peer Hub from its public key (in hex), or an empty string
+func (peers *peerList) nameOf(publicKey string) string {
+	peers.queryMutex.Lock()
+	defer peers.queryMutex.Unlock()
+	return peers.byKey[strings.ToLower(publicKey)]
+}
+
+// splitAddress splits <key>@<hub> addresses, hub is empty for the clients of this Hub
+func splitAddress(address string) (string, string) {
+	if i := strings.Index(address, "@"); i >= 0 {
+		return address[:i], address[i+1:]
+	}
+	return address, ""
+}
+
+// enqueue queues a message for a peer Hub, it returns an error message if the message can't be forwarded
+func (peers *peerList) enqueue(name string, message Message) string {
+	peers.queryMutex.Lock()
+	defer peers.queryMutex.Unlock()
+	peer, ok := peers.byName[name]
+	if !ok {
+		return "unknown hub " + name
+	}
+	if len(peer.queue) >= forwardMaxQueued {
+		return "too many messages are waiting to be forwarded to " + name
+	}
+	message.queuedAt = time.Now()
+	message.key = newRecordKey()
+	persist.put("forward", message.key, message.encode(name))
+	peer.queue = append(peer.queue, message)
+	// wake up the forwarder, unless it already has to
+	select {
+	case peers.wakeup <- struct{}{}:
+	default:
+	}
+	return ""
+}
+
+// forwarder delivers the queued messages to the peer Hubs, and retries regularly while they can't be reached
 func forwarder() {
+	for {
+		select {
+		case <-peers.wakeup:
+		case <-time.After(forwardRetryInterval):
+		}
+		peers.queryMutex.Lock()
+		list := make([]*peerHub, 0, len(peers.byName))
+		for _, peer := range peers.byName {
+			list = append(list, peer)
+		}
+		peers.queryMutex.Unlock()
+		for _, peer := range list {
+			peers.flush(peer)
+		}
+	}
+}
+
+// flush forwards the queue of a peer Hub, in order, until it is empty or the peer can't be reached
+func (peers *peerList) flush(peer *peerHub) {
+	for {
+		peers.queryMutex.Lock()
+		for len(peer.queue) > 0 && time.Since(peer.queue[0].queuedAt) > forwardMaxAge {
+			log.Println("dropping a message that couldn't be forwarded to", peer.name)
+			persist.remove("forward", peer.queue[0].key)
+			peer.queue = peer.queue[1:]
+		}
+		if len(peer.queue) == 0 {
+			peers.queryMutex.Unlock()
+			return
+		}
+		message := peer.queue[0]
+		peers.queryMutex.Unlock()
+
+		if err := peers.forward(peer, message); err != nil {
+			log.Println("cannot forward messages to", peer.name+":", err)
+			if peer.conn != nil {
+				peer.conn.Close()
+				peer.conn = nil
+			}
+			return // we'll retry later
+		}
+
+		// only the forwarder removes messages, others only append
+		peers.queryMutex.Lock()
+		persist.remove("forward", message.key)
+		peer.queue = peer.queue[1:]
+		peers.queryMutex.Unlock()
+	}
+}
 
+// forward sends a message to a peer Hub. It only returns an error if the message has to be sent again:
+// messages rejected by the peer are dropped
+func (peers *peerList) forward(peer *peerHub, message Message) error {
+	// connect
+	if peer.conn == nil {
+		peers.queryMutex.Lock()
+		address := peer.address
+		clientConfig := disco.Config{
+			KeyPair:          peers.keyPair,
+			HandshakePattern: disco.Noise_IK,
+			RemoteKey:        peer.publicKey,
+		}
+		peers.queryMutex.Unlock()
+		conn, err := disco.Dial("tcp", address, &clientConfig)
+		if err != nil {
+			return err
+		}
+		peer.conn = conn
+	}
+	// serialize
+	data, err := proto.Marshal(&s.Request{
+		RequestType: s.Request_ForwardMessage,
+		Message: &s.Request_Message{
+			ToAddress:   message.toAddress,
+			ConvoId:     message.convoId,
+			Content:     message.content,
+			Kind:        message.kind,
+			FromAddress: message.fromAddress,
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	// encode [length(2), data(...)] and send
+	peer.conn.SetDeadline(time.Now().Add(forwardTimeout))
+	if _, err := peer.conn.Write(append([]byte{byte(len(data) >> 8), byte(len(data))}, data...)); err != nil {
+		return err
+	}
+	// receive the response
+	var header [2]byte
+	if _, err := io.ReadFull(peer.conn, header[:]); err != nil {
+		return err
+	}
+	buffer := make([]byte, int(header[0])<<8|int(header[1]))
+	if _, err := io.ReadFull(peer.conn, buffer); err != nil {
+		return err
+	}
+	res := &s.ResponseSuccess{}
+	if err := proto.Unmarshal(buffer, res); err != nil {
+		return err
+	}
+	// messages refused because we send too fast are sent again later (see limits.go)
+	if res.GetCode() == s.ErrorCode_RateLimited {
+		return errors.New("ssyk: " + res.GetError())
+	}
+	if !res.GetSuccess() {
+		log.Println(peer.name, "rejected a message:", res.GetError())
+	}
+	return nil
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+// startTestHub listens on a random port with a new keypair, like main does
+func startTestHub(t *testing.T) (*disco.KeyPair, string) {
+	keyPair := disco.GenerateKeypair(nil)
+	listener, err := disco.ListenDisco("tcp", "127.0.0.1:0", &disco.Config{
+		HandshakePattern:               disco.Noise_IK,
+		KeyPair:                        keyPair,
+		PublicKeyVerifier:              verifyClient,
+		RemoteAddrContainsRemotePubkey: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go sasayakiServer(listener)
+	return keyPair, listener.Addr().String()
+}
+
+// sendTestMessage sends a message like a client of the Hub, and forwards it right away if it's for a peer Hub
+func sendTestMessage(t *testing.T, from, to, content string) {
+	data, err := client{publicKey: from}.handleSendMessage(&s.Request{
+		RequestType: s.Request_SendMessage,
+		Message: &s.Request_Message{
+			ToAddress: to,
+			ConvoId:   strings.Repeat("c", 32),
+			Content:   []byte(content),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := &s.ResponseSuccess{}
+	if err := proto.Unmarshal(data, res); err != nil || !res.GetSuccess() {
+		t.Fatalf("the message to %s was refused: %s %v", to, res.GetError(), err)
+	}
+	if _, name := splitAddress(to); name != "" {
+		peers.queryMutex.Lock()
+		peer := peers.byName[name]
+		peers.queryMutex.Unlock()
+		peers.flush(peer)
+	}
+}
+
+// receiveTestMessage fetches the next message of a client
+func receiveTestMessage(t *testing.T, to string) *s.ResponseMessage {
+	data, err := client{publicKey: to}.handleGetNextMessage(&s.Request{RequestType: s.Request_GetNextMessage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := &s.ResponseMessage{}
+	if err := proto.Unmarshal(data, res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// TestForwardBothWays runs two Hubs in this process that pin each other: alice, on the Hub A, writes to bob
+// on the Hub B, and bob answers. The Hubs share the state of the process (the pending messages, and the peers
+// where A is "hub-a" and B "hub-b"), so the forwarder authenticates with the keypair of A, then with B's
+func TestForwardBothWays(t *testing.T) {
+	keyPairA, addressA := startTestHub(t)
+	keyPairB, addressB := startTestHub(t)
+	peersFile := filepath.Join(t.TempDir(), "peers")
+	content := fmt.Sprintf("hub-a %s %s\nhub-b %s %s\n", addressA, keyPairA.ExportPublicKey(), addressB, keyPairB.ExportPublicKey())
+	if err := ioutil.WriteFile(peersFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadPeers(peersFile); err != nil {
+		t.Fatal(err)
+	}
+	defer loadPeers("")
+	alice, bob := strings.Repeat("a", 64), strings.Repeat("b", 64)
+
+	// A forwards to B
+	initPeers(keyPairA)
+	sendTestMessage(t, alice, bob+"@hub-b", "hello bob")
+	if msg := receiveTestMessage(t, bob); msg.GetFromAddress() != alice+"@hub-a" || string(msg.GetContent()) != "hello bob" {
+		t.Fatalf("bob received %q from %s", msg.GetContent(), msg.GetFromAddress())
+	}
+
+	// B forwards the answer to A
+	initPeers(keyPairB)
+	sendTestMessage(t, bob, alice+"@hub-a", "hello alice")
+	if msg := receiveTestMessage(t, alice); msg.GetFromAddress() != bob+"@hub-b" || string(msg.GetContent()) != "hello alice" {
+		t.Fatalf("alice received %q from %s", msg.GetContent(), msg.GetFromAddress())
+	}
+
+	// nothing else is waiting
+	for _, key := range []string{alice, bob} {
+		if msg := receiveTestMessage(t, key); msg.GetFromAddress() != "" {
+			t.Errorf("unexpected message from %s", msg.GetFromAddress())
+		}
+	}
+}
@@ -0,0 +1,160 @@
+//
+// Devices
+// =======
+//
+// Clients using several devices publish the certificates of their other devices, signed by their identity key
+// and countersigned by the device (see serialization/device.go), so that their contacts can encrypt their
+// messages to each device. The identity or the device can publish a certificate, only the identity can remove
+// its devices, and a device can only belong to one identity.
+//
+// While a device is being linked, the identity publishes a request: the certificate without the signature of
+// the device. It is only returned to the device, which learns who is linking it, and replaced by the
+// certificate once the device has countersigned it. An identity has one request at a time.
+//
+// Certificates are written through to the storage backend (see persistence.go). Requests only live until the
+// device answers them, they are kept in memory.
+//
+package main
+
+import (
+	"encoding/hex"
+	"sync"
+)
+
+const (
+	deviceCertificateMaxSize = 500 // two public keys, a name, a date and two signatures
+	deviceMaxPerIdentity     = 10
+	deviceMaxQueryKeys       = 11 // keys per GetDevices request, the certificates of their devices fit in a response
+)
+
+// storedDevice is a certificate in the storage backend, under the device key
+type storedDevice struct {
+	Identity    string
+	Certificate []byte
+}
+
+type deviceStore struct {
+	certificates map[string]map[string][]byte // identity -> device -> serialized DeviceCertificate
+	identities   map[string]string            // device -> identity
+	requests     map[string][]byte            // device -> serialized DeviceCertificate, signed by the identity only
+	requested    map[string]string            // identity -> the device of its request
+	queryMutex   sync.Mutex                   // one query at a time
+}
+
+var (
+	devices deviceStore
+)
+
+func init() {
+	devices.certificates = make(map[string]map[string][]byte)
+	devices.identities = make(map[string]string)
+	devices.requests = make(map[string][]byte)
+	devices.requested = make(map[string]string)
+}
+
+// load reads the certificates back from the storage backend
+func (devices *deviceStore) load() error {
+	devices.queryMutex.Lock()
+	defer devices.queryMutex.Unlock()
+
+	return persist.load("devices", func(device string, value []byte) error {
+		var stored storedDevice
+		if err := decodeValue(value, &stored); err != nil {
+			return err
+		}
+		if devices.certificates[stored.Identity] == nil {
+			devices.certificates[stored.Identity] = make(map[string][]byte)
+		}
+		devices.certificates[stored.Identity][device] = stored.Certificate
+		devices.identities[device] = stored.Identity
+		return nil
+	})
+}
+
+// put stores the certificate of a device, it returns an error message if the device can't be added
+func (devices *deviceStore) put(identityKey, deviceKey []byte, certificate []byte) string {
+	devices.queryMutex.Lock()
+	defer devices.queryMutex.Unlock()
+
+	identity, device := hex.EncodeToString(identityKey), hex.EncodeToString(deviceKey)
+	if device == identity {
+		return "a device can't certify itself"
+	}
+	if _, ok := devices.certificates[device]; ok {
+		return "this key is already an identity"
+	}
+	if owner, ok := devices.identities[device]; ok && owner != identity {
+		return "this device belongs to another identity"
+	}
+	if devices.certificates[identity] == nil {
+		devices.certificates[identity] = make(map[string][]byte)
+	}
+	if _, ok := devices.certificates[identity][device]; !ok && len(devices.certificates[identity]) >= deviceMaxPerIdentity {
+		return "too many devices"
+	}
+	devices.certificates[identity][device] = certificate
+	devices.identities[device] = identity
+	persist.put("devices", device, encodeValue(storedDevice{Identity: identity, Certificate: certificate}))
+	if devices.requested[identity] == device {
+		delete(devices.requests, device)
+		delete(devices.requested, identity)
+	}
+	return ""
+}
+
+// request stores the request of an identity to link a device, in place of its previous request
+func (devices *deviceStore) request(identityKey, deviceKey []byte, certificate []byte) string {
+	devices.queryMutex.Lock()
+	defer devices.queryMutex.Unlock()
+
+	identity, device := hex.EncodeToString(identityKey), hex.EncodeToString(deviceKey)
+	if device == identity {
+		return "a device can't certify itself"
+	}
+	if previous, ok := devices.requested[identity]; ok {
+		delete(devices.requests, previous)
+	}
+	devices.requests[device] = certificate
+	devices.requested[identity] = device
+	return ""
+}
+
+// requestFor returns the request to link a device, if there is one
+func (devices *deviceStore) requestFor(device string) ([]byte, bool) {
+	devices.queryMutex.Lock()
+	defer devices.queryMutex.Unlock()
+
+	certificate, ok := devices.requests[device]
+	return certificate, ok
+}
+
+// remove deletes a device of an identity
+func (devices *deviceStore) remove(identity, device string) bool {
+	devices.queryMutex.Lock()
+	defer devices.queryMutex.Unlock()
+
+	if devices.identities[device] != identity {
+		return false
+	}
+	delete(devices.certificates[identity], device)
+	delete(devices.identities, device)
+	persist.remove("devices", device)
+	return true
+}
+
+// get returns the certificates of the devices of some identities, or of some devices
+func (devices *deviceStore) get(keys []string) [][]byte {
+	devices.queryMutex.Lock()
+	defer devices.queryMutex.Unlock()
+
+	var certificates [][]byte
+	for _, key := range keys {
+		if identity, ok := devices.identities[key]; ok {
+			certificates = append(certificates, devices.certificates[identity][key])
+		}
+		for _, certificate := range devices.certificates[key] {
+			certificates = append(certificates, certificate)
+		}
+	}
+	return certificates
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+// publishTestDevice publishes a device certificate as a client, and returns the error of the Hub
+func publishTestDevice(t *testing.T, publisher *disco.KeyPair, certificate *s.DeviceCertificate) string {
+	serialized, err := proto.Marshal(certificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := client{publicKey: publisher.ExportPublicKey()}.handlePublishDevice(&s.Request{
+		RequestType: s.Request_PublishDevice,
+		Device:      &s.Request_Device{Certificate: serialized},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := &s.ResponseSuccess{}
+	if err := proto.Unmarshal(data, res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.GetSuccess() && res.GetError() == "" {
+		t.Fatal("the Hub refused the certificate without an error")
+	}
+	return res.GetError()
+}
+
+// getTestDevices returns the certificates the Hub returns to a client for a key
+func getTestDevices(t *testing.T, requester *disco.KeyPair, key string) [][]byte {
+	data, err := client{publicKey: requester.ExportPublicKey()}.handleGetDevices(&s.Request{
+		RequestType: s.Request_GetDevices,
+		Device:      &s.Request_Device{Keys: []string{key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := &s.ResponseDevices{}
+	if err := proto.Unmarshal(data, res); err != nil || !res.GetSuccess() {
+		t.Fatalf("the devices weren't returned: %s %v", res.GetError(), err)
+	}
+	return res.GetCertificates()
+}
+
+func TestDeviceCountersignature(t *testing.T) {
+	alice, laptop, mallory := disco.GenerateKeypair(nil), disco.GenerateKeypair(nil), disco.GenerateKeypair(nil)
+
+	// mallory can't claim the key of the laptop, even while alice links it
+	claim := &s.DeviceCertificate{IdentityKey: mallory.PublicKey[:], DeviceKey: laptop.PublicKey[:], Name: "laptop"}
+	s.SignDeviceCertificate(mallory.PrivateKey, claim)
+	claim.DeviceSignature = []byte("not a signature")
+	if publishTestDevice(t, mallory, claim) == "" {
+		t.Fatal("a certificate that the device didn't countersign was accepted")
+	}
+
+	// alice's request is only shown to the laptop
+	certificate := &s.DeviceCertificate{IdentityKey: alice.PublicKey[:], DeviceKey: laptop.PublicKey[:], Name: "laptop", Date: 1500000000}
+	s.SignDeviceCertificate(alice.PrivateKey, certificate)
+	if reason := publishTestDevice(t, alice, certificate); reason != "" {
+		t.Fatal(reason)
+	}
+	if len(getTestDevices(t, mallory, laptop.ExportPublicKey())) != 0 || len(getTestDevices(t, mallory, alice.ExportPublicKey())) != 0 {
+		t.Fatal("the request was shown to someone else than the device")
+	}
+	if len(getTestDevices(t, laptop, laptop.ExportPublicKey())) != 1 {
+		t.Fatal("the request wasn't shown to the device")
+	}
+	if publishTestDevice(t, mallory, certificate) == "" {
+		t.Fatal("mallory published the request of alice")
+	}
+
+	// the laptop countersigns it, and publishes it
+	s.CountersignDeviceCertificate(laptop.PrivateKey, certificate)
+	if reason := publishTestDevice(t, laptop, certificate); reason != "" {
+		t.Fatal(reason)
+	}
+	if len(getTestDevices(t, mallory, alice.ExportPublicKey())) != 1 || len(getTestDevices(t, laptop, laptop.ExportPublicKey())) != 1 {
+		t.Fatal("the certificate isn't returned in place of the request")
+	}
+
+	// and mallory can't take it, even with the countersignature of a new request
+	claim.DeviceSignature = nil
+	s.CountersignDeviceCertificate(laptop.PrivateKey, claim)
+	if publishTestDevice(t, mallory, claim) == "" {
+		t.Error("the laptop was moved to another identity")
+	}
+}
@@ -0,0 +1,82 @@
+//
+// Key Packages
+// ============
+//
+// A key package lets a client be added to an MLS group while it is offline. Clients publish
+// a few of them, and every key package is given to only one client: the first one who asks for it.
+//
+// The Hub doesn't need to trust key packages, they are signed by their owner. We still check that
+// a client only publishes key packages for itself.
+//
+// Key packages are written through to the storage backend (see persistence.go).
+//
+package main
+
+import (
+	"sync"
+)
+
+const (
+	keyPackagesMax    = 10   // key packages kept per client (the oldest are dropped, the client does the same)
+	keyPackageMaxSize = 1000 // a key package is 2 public keys and a signature
+)
+
+type keyPackageStore struct {
+	keyPackages map[string][][]byte // owner -> serialized key packages
+	queryMutex  sync.Mutex          // one query at a time
+}
+
+var (
+	kps keyPackageStore
+)
+
+func init() {
+	kps.keyPackages = make(map[string][][]byte)
+}
+
+// load reads the key packages back from the storage backend
+func (kps *keyPackageStore) load() error {
+	kps.queryMutex.Lock()
+	defer kps.queryMutex.Unlock()
+
+	return persist.load("keypackages", func(owner string, value []byte) error {
+		var keyPackages [][]byte
+		if err := decodeValue(value, &keyPackages); err != nil {
+			return err
+		}
+		kps.keyPackages[owner] = keyPackages
+		return nil
+	})
+}
+
+// put stores a key package for its owner
+func (kps *keyPackageStore) put(owner string, content []byte) {
+	kps.queryMutex.Lock()
+	defer kps.queryMutex.Unlock()
+
+	keyPackages := append(kps.keyPackages[owner], content)
+	if len(keyPackages) > keyPackagesMax {
+		keyPackages = keyPackages[len(keyPackages)-keyPackagesMax:]
+	}
+	kps.keyPackages[owner] = keyPackages
+	persist.put("keypackages", owner, encodeValue(keyPackages))
+}
+
+// pop removes and returns the oldest key package of a client, or false if it has none left
+func (kps *keyPackageStore) pop(owner string) ([]byte, bool) {
+	kps.queryMutex.Lock()
+	defer kps.queryMutex.Unlock()
+
+	keyPackages := kps.keyPackages[owner]
+	if len(keyPackages) == 0 {
+		return nil, false
+	}
+	if len(keyPackages) == 1 {
+		delete(kps.keyPackages, owner)
+		persist.remove("keypackages", owner)
+	} else {
+		kps.keyPackages[owner] = keyPackages[1:]
+		persist.put("keypackages", owner, encodeValue(keyPackages[1:]))
+	}
+	return keyPackages[0], true
+}
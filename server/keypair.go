@@ -0,0 +1,150 @@
+//
+// Hub Keypair
+// ===========
+//
+// Anyone reading the keypair of the Hub can impersonate it to every client, so it is encrypted at rest
+// under a passphrase (see `keypair_passphrase` in config.go), read from the terminal, from a file or from
+// an environment variable. The file is:
+//
+//	"SSYKHUBKEY" | version (1 byte) | salt (16 bytes) | disco.Encrypt(Argon2id(passphrase, salt), private key)
+//
+// Keypairs of earlier versions (saved by libdisco without a passphrase) are still loaded, `-encrypt_keypair`
+// encrypts them.
+//
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/ssh/terminal"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+const (
+	keyPairMagic    = "SSYKHUBKEY"
+	keyPairVersion  = 1
+	keyPairSaltSize = 16
+
+	defaultPassphraseEnv = "SASAYAKI_HUB_PASSPHRASE"
+)
+
+// keyPairKey derives the key encrypting the keypair from a passphrase
+func keyPairKey(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, 3, 64*1024, 4, 32)
+}
+
+// saveHubKeyPair encrypts a keypair under a passphrase, and writes it to a file only readable by us
+func saveHubKeyPair(file string, keyPair *disco.KeyPair, passphrase string) error {
+	salt := make([]byte, keyPairSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+	content := append([]byte(keyPairMagic), keyPairVersion)
+	content = append(content, salt...)
+	content = append(content, disco.Encrypt(keyPairKey(passphrase, salt), keyPair.PrivateKey[:])...)
+	// don't leave a half-written keypair behind
+	if err := ioutil.WriteFile(file+".new", content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(file+".new", file)
+}
+
+// generateHubKeyPair generates the keypair of the Hub, it doesn't replace an existing one
+func generateHubKeyPair(file string, passphrase string) (*disco.KeyPair, error) {
+	if _, err := os.Stat(file); err == nil {
+		return nil, errors.New("ssyk: " + file + " already exists")
+	}
+	keyPair := disco.GenerateKeypair(nil)
+	if err := saveHubKeyPair(file, keyPair, passphrase); err != nil {
+		return nil, err
+	}
+	return keyPair, nil
+}
+
+// loadHubKeyPair reads and decrypts the keypair of the Hub
+func loadHubKeyPair(file string, passphrase string) (*disco.KeyPair, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	// keypairs of earlier versions
+	if !bytes.HasPrefix(content, []byte(keyPairMagic)) {
+		return disco.LoadDiscoKeyPair(file, passphrase)
+	}
+	content = content[len(keyPairMagic):]
+	if len(content) < 1+keyPairSaltSize || content[0] != keyPairVersion {
+		return nil, errors.New("ssyk: unknown keypair version")
+	}
+	salt := content[1 : 1+keyPairSaltSize]
+	privateKey, err := disco.Decrypt(keyPairKey(passphrase, salt), content[1+keyPairSaltSize:])
+	if err != nil || len(privateKey) != 32 {
+		return nil, errors.New("ssyk: cannot decrypt the keypair, wrong passphrase?")
+	}
+	var key [32]byte
+	copy(key[:], privateKey)
+	return disco.GenerateKeypair(&key), nil
+}
+
+// readPassphrase reads the passphrase of the keypair from where the configuration says.
+// When it is typed, confirm asks for it twice
+func (config *hubConfiguration) readPassphrase(confirm bool) (string, error) {
+	source := config.KeyPairPassphrase
+	switch source.Source {
+	case "none":
+		return "", nil
+	case "file":
+		content, err := ioutil.ReadFile(source.File)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(strings.SplitN(string(content), "\n", 2)[0], "\r"), nil
+	case "env":
+		name := source.Env
+		if name == "" {
+			name = defaultPassphraseEnv
+		}
+		passphrase := os.Getenv(name)
+		if passphrase == "" {
+			return "", errors.New("ssyk: " + name + " is not set")
+		}
+		// child processes don't need it
+		os.Unsetenv(name)
+		return passphrase, nil
+	default:
+		return promptPassphrase("passphrase of the Hub keypair: ", confirm)
+	}
+}
+
+// promptPassphrase reads a passphrase from the terminal
+func promptPassphrase(prompt string, confirm bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", errors.New("ssyk: no terminal to type the passphrase, set keypair_passphrase in the configuration")
+	}
+	fmt.Print(prompt)
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		fmt.Print("again: ")
+		again, err := terminal.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		if string(again) != string(passphrase) {
+			return "", errors.New("ssyk: the passphrases don't match")
+		}
+	}
+	return string(passphrase), nil
+}
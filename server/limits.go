@@ -0,0 +1,176 @@
+//
+// Limits
+// ======
+//
+// A client could otherwise exhaust the Hub by sending messages in a loop, or by opening connections and never
+// using them. The `quotas` of config.go limit:
+//
+// the messages a sender can send, with a token bucket per sender (messages_per_second, and messages_burst in
+// a row), the messages forwarded by a peer Hub counting for their original sender;
+//
+// the requests of any kind a client can send, with another token bucket per client (requests_per_second and
+// requests_burst), for the requests that don't send messages but still cost the Hub (polling, blobs, etc.);
+//
+// the messages and bytes waiting for a recipient (pending_messages and pending_bytes);
+//
+// the bytes of attachments stored, in total and per client (blob_bytes and blob_bytes_per_client);
+//
+// the connections open at the same time per client key and per IP address (connections_per_key and
+// connections_per_ip), the connections over the limit being closed right away;
+//
+// how long a connection can stay without sending a request (idle_timeout), and how long a request (or the
+// handshake) can take to arrive once started (read_timeout).
+//
+// Refused messages are answered with a ResponseSuccess carrying an ErrorCode, and the seconds to wait for
+// RateLimited, so that clients can tell them apart from malformed requests. Zero means no limit.
+//
+package main
+
+import (
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	bucketCollectorInterval = 10 * time.Minute // how often we forget the senders whose bucket is full again
+)
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+type rateLimiter struct {
+	rate       func() (float64, int)   // the tokens added per second (0 for no limit), and the size of the buckets
+	buckets    map[string]*tokenBucket // sender -> its bucket
+	queryMutex sync.Mutex              // one query at a time
+}
+
+type connectionCounter struct {
+	perKey     map[string]int // client public key -> open connections
+	perIP      map[string]int // IP address -> open connections
+	queryMutex sync.Mutex     // one query at a time
+}
+
+var (
+	limiter        rateLimiter // messages per sender
+	requestLimiter rateLimiter // requests per client
+	connections    connectionCounter
+)
+
+func init() {
+	limiter.rate = settings.messageRate
+	limiter.buckets = make(map[string]*tokenBucket)
+	requestLimiter.rate = settings.requestRate
+	requestLimiter.buckets = make(map[string]*tokenBucket)
+	connections.perKey = make(map[string]int)
+	connections.perIP = make(map[string]int)
+}
+
+// take takes a token from the bucket of a sender. If there is none, it returns false and how long to wait
+// for the next one
+func (limiter *rateLimiter) take(sender string) (bool, time.Duration) {
+	rate, burst := limiter.rate()
+	if rate <= 0 {
+		return true, 0
+	}
+	limiter.queryMutex.Lock()
+	defer limiter.queryMutex.Unlock()
+
+	now := time.Now()
+	bucket, ok := limiter.buckets[sender]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(burst), updated: now}
+		limiter.buckets[sender] = bucket
+	}
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*rate)
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// collectGarbage forgets the senders whose bucket is full again, every `bucketCollectorInterval`. It never returns
+func (limiter *rateLimiter) collectGarbage() {
+	for range time.Tick(bucketCollectorInterval) {
+		rate, burst := limiter.rate()
+		now := time.Now()
+		limiter.queryMutex.Lock()
+		for sender, bucket := range limiter.buckets {
+			if rate <= 0 || bucket.tokens+now.Sub(bucket.updated).Seconds()*rate >= float64(burst) {
+				delete(limiter.buckets, sender)
+			}
+		}
+		limiter.queryMutex.Unlock()
+	}
+}
+
+// retryAfter rounds a wait up to the second, for the clients
+func retryAfter(wait time.Duration) uint32 {
+	return uint32(math.Ceil(wait.Seconds()))
+}
+
+// openIP counts a new connection from an IP address, it returns an error message if there are too many
+func (connections *connectionCounter) openIP(ip string) string {
+	_, limit := settings.connectionLimits()
+	connections.queryMutex.Lock()
+	defer connections.queryMutex.Unlock()
+	if limit > 0 && connections.perIP[ip] >= limit {
+		return "too many connections from " + ip
+	}
+	connections.perIP[ip]++
+	return ""
+}
+
+// openKey counts a new connection of a client, it returns an error message if there are too many
+func (connections *connectionCounter) openKey(key string) string {
+	limit, _ := settings.connectionLimits()
+	connections.queryMutex.Lock()
+	defer connections.queryMutex.Unlock()
+	if limit > 0 && connections.perKey[key] >= limit {
+		return "too many connections for " + key
+	}
+	connections.perKey[key]++
+	return ""
+}
+
+// closeIP counts a connection from an IP address that was closed
+func (connections *connectionCounter) closeIP(ip string) {
+	connections.queryMutex.Lock()
+	defer connections.queryMutex.Unlock()
+	if connections.perIP[ip]--; connections.perIP[ip] <= 0 {
+		delete(connections.perIP, ip)
+	}
+}
+
+// closeKey counts a connection of a client that was closed
+func (connections *connectionCounter) closeKey(key string) {
+	connections.queryMutex.Lock()
+	defer connections.queryMutex.Unlock()
+	if connections.perKey[key]--; connections.perKey[key] <= 0 {
+		delete(connections.perKey, key)
+	}
+}
+
+// remoteIP returns the IP address of a connection. With RemoteAddrContainsRemotePubkey, Disco appends the
+// public key of the client to the address, which is removed
+func remoteIP(addr net.Addr) string {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+	address := addr.String()
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	if i := strings.LastIndex(address, ":"); i >= 0 {
+		if host, _, err := net.SplitHostPort(address[:i]); err == nil {
+			return host
+		}
+	}
+	return address
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"log"
@@ -10,25 +11,106 @@ import (
 
 const (
 	defaultKeyPairFile = "server.keypair"
+	defaultStoragePath = "hub-data"
 )
 
 func main() {
 	// Flags
-	genKeyPair := flag.Bool("gen_keypair", false, "generate a keypair for the server")
+	configFile := flag.String("config", "", "the configuration file of the Hub (see config.go), reloaded on SIGHUP")
+	genKeyPair := flag.Bool("gen_keypair", false, "generate a keypair for the server, encrypted under a passphrase (see keypair_passphrase)")
+	encryptKeyPair := flag.Bool("encrypt_keypair", false, "encrypt the keypair again under the configured passphrase (asks for the current one)")
 	keyPairFile := flag.String("keypair_file", defaultKeyPairFile, "sets the server.keypair location (default to current directory)")
 	runServer := flag.Bool("run", false, "runs the Sasayaki Server")
+	listen := flag.String("listen", "", "the address of the RPC API (default 127.0.0.1:7474)")
+	notificationListen := flag.String("notification_listen", "", "the address of the push notifications (default 127.0.0.1:7475)")
+	organizationKeyFile := flag.String("organization_key", "", "only accepts the members of the organization whose public key is in this file")
+	revocationsFile := flag.String("revocations", "", "the keys revoked by the organization, one revocation per line (see the organization tool)")
+	peersFile := flag.String("peers", "", "the peer Hubs to forward messages to and accept messages from, one `<name> <address> <public key>` per line")
 
 	flag.Parse()
 
+	// the flags set override the configuration file
+	overrides := func(config *hubConfiguration) error {
+		var err error
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "keypair_file":
+				config.KeyPairFile = *keyPairFile
+			case "listen":
+				config.Listen = *listen
+			case "notification_listen":
+				config.NotificationListen = *notificationListen
+			case "organization_key":
+				organizationKey, e := loadOrganizationKey(*organizationKeyFile)
+				if e != nil {
+					err = e
+					return
+				}
+				config.OrganizationKeys = append(config.OrganizationKeys, hex.EncodeToString(organizationKey))
+			case "revocations":
+				config.Revocations = *revocationsFile
+			case "peers":
+				config.Peers = *peersFile
+			}
+		})
+		return err
+	}
+	config := defaultConfiguration()
+	if *configFile != "" {
+		var err error
+		if config, err = loadConfiguration(*configFile); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if err := overrides(config); err != nil {
+		fmt.Println("cannot load the organization public key:", err)
+		return
+	}
+	if _, err := config.validate(); err != nil {
+		fmt.Println("incorrect configuration:", err)
+		return
+	}
+
 	// Init
 	fmt.Println("==== Sasayaki Server ====")
 
 	if *genKeyPair {
-		_, err := disco.GenerateAndSaveDiscoKeyPair(*keyPairFile, "")
+		passphrase, err := config.readPassphrase(true)
 		if err != nil {
-			panic("server cannot store keypair")
+			fmt.Println(err)
+			return
+		}
+		if _, err := generateHubKeyPair(config.KeyPairFile, passphrase); err != nil {
+			fmt.Println("server cannot store keypair:", err)
+			return
+		}
+		fmt.Println("Sasayaki server successfuly generated private key at location ", config.KeyPairFile)
+		return
+	}
+
+	if *encryptKeyPair {
+		// keypairs of earlier versions have an empty passphrase
+		current, err := promptPassphrase("current passphrase (empty if there was none): ", false)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		keyPair, err := loadHubKeyPair(config.KeyPairFile, current)
+		if err != nil {
+			fmt.Println("server cannot load keypair:", err)
+			return
+		}
+		passphrase, err := config.readPassphrase(true)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := saveHubKeyPair(config.KeyPairFile, keyPair, passphrase); err != nil {
+			fmt.Println("server cannot store keypair:", err)
+			return
 		}
-		fmt.Println("Sasayaki server successfuly generated private key at location ", *keyPairFile)
+		fmt.Println("Sasayaki server keypair encrypted at location ", config.KeyPairFile)
 		return
 	}
 
@@ -37,28 +119,71 @@ func main() {
 		return
 	}
 
-	keyPair, err := disco.LoadDiscoKeyPair(*keyPairFile, "")
+	passphrase, err := config.readPassphrase(false)
 	if err != nil {
-		panic("server cannot load keypair")
+		fmt.Println(err)
+		return
+	}
+	keyPair, err := loadHubKeyPair(config.KeyPairFile, passphrase)
+	if err != nil {
+		fmt.Println("server cannot load keypair:", err)
 		return
 	}
 	fmt.Println("Sasayaki Hub's public key:", keyPair.ExportPublicKey())
 
+	// who signs the tree heads (see signer.go), the keypair stays loaded for the Disco handshakes either way
+	var treeHeadSigner signer = localSigner{privateKey: keyPair.PrivateKey}
+	if config.Signer.Socket != "" {
+		if treeHeadSigner, err = newSocketSigner(config.Signer.Socket, keyPair.PublicKey[:]); err != nil {
+			fmt.Println("cannot use the signer:", err)
+			return
+		}
+		fmt.Println("signing tree heads with the signer at", config.Signer.Socket, "(the keypair is still loaded for the handshakes)")
+	}
+
+	// where the stores are kept (see persistence.go)
+	if persist, err = openBackend(config.Storage.Backend, config.Storage.Path); err != nil {
+		fmt.Println("cannot open the storage:", err)
+		return
+	}
+
+	// who can use the Hub, and the Hubs we federate with (see organization.go and delivery.go)
+	initTransparencyLog(treeHeadSigner)
+	initPeers(keyPair)
+	if err := loadStores(); err != nil {
+		fmt.Println("cannot read the storage:", err)
+		return
+	}
+	if err := settings.apply(config); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := peers.loadQueues(); err != nil {
+		fmt.Println("cannot read the storage:", err)
+		return
+	}
+	for _, organizationKey := range settings.organizationKeys() {
+		fmt.Println("only accepting the members of the organization", hex.EncodeToString(organizationKey))
+	}
+	go forwarder()
+	if *configFile != "" {
+		go settings.reloadOnSIGHUP(*configFile, overrides)
+	}
+
 	//
 	// the RPC API
 	//
-	// TODO: have good timeouts for both delivery service and notification service
-	// TODO: the publicKeyVerifier should verify if the public key is part of the organization (it has been signed)
+	// the timeouts, rate limits and connection limits are in limits.go (the notification service has the same)
 	// TODO: have a queue system? like zeroq?
 	serverConfig := disco.Config{
 		HandshakePattern:               disco.Noise_IK,
 		KeyPair:                        keyPair,
-		PublicKeyVerifier:              func(publicKey, proof []byte) bool { return true },
+		PublicKeyVerifier:              verifyClient,
 		RemoteAddrContainsRemotePubkey: true,
 	}
 
 	// listen on port 6666
-	listener, err := disco.ListenDisco("tcp", "127.0.0.1:7474", &serverConfig)
+	listener, err := disco.ListenDisco("tcp", config.Listen, &serverConfig)
 	if err != nil {
 		fmt.Println("RPC server cannot setup a listener:", err)
 		return
@@ -69,14 +194,18 @@ func main() {
 	// currently only accept one client
 	go sasayakiServer(listener)
 
+	// delete expired attachments and messages, and forget the senders that aren't limited anymore
+	go bs.collectGarbage()
+	go mm.collectGarbage()
+	go limiter.collectGarbage()
+	go requestLimiter.collectGarbage()
+
 	//
 	// Push notifications
 	//
 
 	// listen on port 6666
-	// TODO: different timeouts? keep-alive?
-	// I think to avoid DoS I need keep-alive
-	notificationList, err := disco.ListenDisco("tcp", "127.0.0.1:7475", &serverConfig)
+	notificationList, err := disco.ListenDisco("tcp", config.NotificationListen, &serverConfig)
 	if err != nil {
 		fmt.Println("notification server cannot setup a listener:", err)
 		return
@@ -91,6 +220,8 @@ func main() {
 			log.Println("notification server couldn't accept client:", err)
 			continue
 		}
+		// like for the delivery service, the handshake is done in its own goroutine, and the connections
+		// are limited per IP address and per key and closed when idle (see notification.go)
 		go notificationClient(conn)
 	}
 }
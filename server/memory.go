@@ -1,18 +1,73 @@
-// This is an in-memory database
-// for testing only
+// The messages waiting for their recipient, kept in memory and written through to the storage
+// backend (see persistence.go)
 package main
 
-import "sync"
+import (
+	"sync"
+	"time"
+
+	s "github.com/mimoo/sasayaki/serialization"
+)
+
+const (
+	messageCollectorInterval = 10 * time.Minute // how often we look for expired messages
+)
 
 type memory struct {
-	pendingMessages map[string][]Message // in-memory pending messages (for testing)
+	pendingMessages map[string][]Message // recipient -> its pending messages, in order
+	pendingBytes    map[string]int64     // the size of the pending messages of each recipient (see limits.go)
 	queryMutex      sync.Mutex           // one query at a time
 }
 
 type Message struct {
 	fromAddress string
+	toAddress   string // the recipient on a peer Hub, for the messages waiting to be forwarded (see delivery.go)
 	convoId     string
 	content     []byte
+	kind        s.MessageKind
+	queuedAt    time.Time
+	key         string // its key in the storage backend
+}
+
+// storedMessage is a Message in the storage backend, with its recipient (or its peer Hub, see delivery.go)
+type storedMessage struct {
+	Recipient   string
+	FromAddress string
+	ToAddress   string
+	ConvoId     string
+	Content     []byte
+	Kind        int32
+	QueuedAt    time.Time
+}
+
+// encode serializes a message for the storage backend
+func (message Message) encode(recipient string) []byte {
+	return encodeValue(storedMessage{
+		Recipient:   recipient,
+		FromAddress: message.fromAddress,
+		ToAddress:   message.toAddress,
+		ConvoId:     message.convoId,
+		Content:     message.content,
+		Kind:        int32(message.kind),
+		QueuedAt:    message.queuedAt,
+	})
+}
+
+// decodeMessage parses a message of the storage backend, and returns its recipient
+func decodeMessage(key string, data []byte) (Message, string, error) {
+	var stored storedMessage
+	if err := decodeValue(data, &stored); err != nil {
+		return Message{}, "", err
+	}
+	return Message{
+		fromAddress: stored.FromAddress,
+		toAddress:   stored.ToAddress,
+		convoId:     stored.ConvoId,
+		content:     stored.Content,
+		kind:        s.MessageKind(stored.Kind),
+		queuedAt:    stored.QueuedAt,
+		key:         key,
+	}, stored.Recipient, nil
 }
 
 var (
@@ -21,4 +76,93 @@ var (
 
 func init() {
 	mm.pendingMessages = make(map[string][]Message)
+	mm.pendingBytes = make(map[string]int64)
+}
+
+// load reads the pending messages back from the storage backend, in the order they were queued
+func (mm *memory) load() error {
+	mm.queryMutex.Lock()
+	defer mm.queryMutex.Unlock()
+
+	return persist.load("messages", func(key string, value []byte) error {
+		message, toAddress, err := decodeMessage(key, value)
+		if err != nil {
+			return err
+		}
+		mm.pendingMessages[toAddress] = append(mm.pendingMessages[toAddress], message)
+		mm.pendingBytes[toAddress] += int64(len(message.content))
+		return nil
+	})
+}
+
+// push queues a message for a recipient, unless it has reached its quotas (see limits.go).
+// It returns an error message if it has
+func (mm *memory) push(toAddress string, message Message) string {
+	messagesQuota, bytesQuota := settings.pendingMessagesQuota(), settings.pendingBytesQuota()
+	mm.queryMutex.Lock()
+	defer mm.queryMutex.Unlock()
+	if messagesQuota > 0 && len(mm.pendingMessages[toAddress]) >= messagesQuota {
+		return "the recipient has too many messages waiting"
+	}
+	if bytesQuota > 0 && mm.pendingBytes[toAddress]+int64(len(message.content)) > bytesQuota {
+		return "the recipient has too many bytes waiting"
+	}
+	message.key = newRecordKey()
+	persist.put("messages", message.key, message.encode(toAddress))
+	mm.pendingMessages[toAddress] = append(mm.pendingMessages[toAddress], message)
+	mm.pendingBytes[toAddress] += int64(len(message.content))
+	return ""
+}
+
+// pop returns the next message of a recipient, if there is one
+func (mm *memory) pop(toAddress string) (Message, bool) {
+	mm.queryMutex.Lock()
+	defer mm.queryMutex.Unlock()
+	messages := mm.pendingMessages[toAddress]
+	if len(messages) == 0 {
+		return Message{}, false
+	}
+	message := messages[0]
+	persist.remove("messages", message.key)
+	if len(messages) == 1 {
+		delete(mm.pendingMessages, toAddress)
+		delete(mm.pendingBytes, toAddress)
+	} else {
+		mm.pendingMessages[toAddress] = messages[1:]
+		mm.pendingBytes[toAddress] -= int64(len(message.content))
+	}
+	return message, true
+}
+
+// collectGarbage drops the messages that waited for their recipient for longer than the retention of the
+// configuration, every `messageCollectorInterval`. It never returns
+func (mm *memory) collectGarbage() {
+	for range time.Tick(messageCollectorInterval) {
+		retention := settings.messageRetention()
+		if retention == 0 {
+			continue
+		}
+		now := time.Now()
+		mm.queryMutex.Lock()
+		for address, messages := range mm.pendingMessages {
+			kept := messages[:0]
+			var size int64
+			for _, message := range messages {
+				if now.Sub(message.queuedAt) < retention {
+					kept = append(kept, message)
+					size += int64(len(message.content))
+				} else {
+					persist.remove("messages", message.key)
+				}
+			}
+			if len(kept) == 0 {
+				delete(mm.pendingMessages, address)
+				delete(mm.pendingBytes, address)
+			} else {
+				mm.pendingMessages[address] = kept
+				mm.pendingBytes[address] = size
+			}
+		}
+		mm.queryMutex.Unlock()
+	}
 }
@@ -1,59 +1,102 @@
 //
 // Notification Service
 // ====================
-// 
+//
 // This is a two-way communication channel where:
 // - users can notify the server that they have read a message
 // - the server can notify the client that they have received a new message
 //
-// This is in contrast with the primary delivery service which is a simple JSON REST API
+// This is in contrast with the primary delivery service which is a simple JSON REST API.
+//
+// Its connections have the limits of the delivery service (see limits.go): the connections per IP address and
+// per client key, and the timeouts. A client that has nothing to notify has to send an empty notification
+// before idle_timeout, or it is disconnected.
 //
 package main
 
 import (
+	"io"
 	"log"
 	"net"
+	"time"
+
+	disco "github.com/mimoo/disco/libdisco"
 )
 
 type notifClient struct {
 	conn      net.Conn
 	publicKey string
+	closed    chan struct{} // closed when the client disconnects, or stays idle for too long
 }
 
-func notificationClient(conn net.Conn) {
-	// triggers the handshake
-	conn.Write([]byte{})
-	// info
-	log.Println("client accepted", conn.RemoteAddr().String())
-	// get client's pubkey
+// notificationClient authenticates a client, and serves it if it hasn't too many connections open
+// (like acceptClient)
+func notificationClient(conn *disco.Conn) {
+	defer conn.Close()
+	ip := remoteIP(conn.RemoteAddr())
+	if reason := connections.openIP(ip); reason != "" {
+		log.Println("notification connection refused:", reason)
+		return
+	}
+	defer connections.closeIP(ip)
+
+	// the handshake must arrive within readTimeout
+	readTimeout, _ := settings.timeouts()
+	if readTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(readTimeout))
+	}
 	clientKey, err := conn.RemotePublicKey()
 	if err != nil {
 		log.Println("cannot read client public key:", err)
-		conn.Close()
-		continue
+		return
 	}
-	log.Println("client accepted", clientKey)
+	logDebug("notification client accepted", clientKey)
+	if reason := connections.openKey(clientKey); reason != "" {
+		log.Println("notification connection refused:", reason)
+		return
+	}
+	defer connections.closeKey(clientKey)
 
-	cc := notifClient{
+	nc := notifClient{
 		conn:      conn,
 		publicKey: clientKey,
+		closed:    make(chan struct{}),
 	}
 
-	go cc.handleNotificationsFromClient()
-	cc.handleDistributionToClient()
-
-	//
-	conn.Close()
+	go nc.handleNotificationsFromClient()
+	nc.handleDistributionToClient()
 }
 
+// handleNotificationsFromClient reads the notifications of the client [length(2), data(...)], until it
+// disconnects or stays idle for too long
+// TODO: parse the read receipts
 func (nc notifClient) handleNotificationsFromClient() {
+	defer close(nc.closed)
 	for {
-
+		// the client has idleTimeout to send a notification, and readTimeout for it to arrive
+		readTimeout, idleTimeout := settings.timeouts()
+		nc.conn.SetReadDeadline(time.Time{})
+		if idleTimeout > 0 {
+			nc.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		}
+		var header [2]byte
+		if _, err := io.ReadFull(nc.conn, header[:]); err != nil {
+			logDebug("notification client closing:", err)
+			return
+		}
+		if readTimeout > 0 {
+			nc.conn.SetReadDeadline(time.Now().Add(readTimeout))
+		}
+		notification := make([]byte, int(header[0])<<8|int(header[1]))
+		if _, err := io.ReadFull(nc.conn, notification); err != nil {
+			log.Println("notification client closing:", err)
+			return
+		}
 	}
 }
 
+// handleDistributionToClient notifies the client of its new messages, until it disconnects
+// TODO: the delivery service doesn't tell us about new messages yet, there is nothing to send
 func (nc notifClient) handleDistributionToClient() {
-	for {
-		select
-	}
+	<-nc.closed
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	disco "github.com/mimoo/disco/libdisco"
+)
+
+// isClosed returns true if the Hub closed the connection, false if it is still open after a while
+func isClosed(conn net.Conn, wait time.Duration) bool {
+	conn.SetReadDeadline(time.Now().Add(wait))
+	_, err := conn.Read(make([]byte, 1))
+	if err, ok := err.(net.Error); ok && err.Timeout() {
+		return false
+	}
+	return true
+}
+
+func TestNotificationLimits(t *testing.T) {
+	setTestQuotas(t, func(config *hubConfiguration) {
+		config.Quotas.ConnectionsPerKey = 1
+		config.Quotas.IdleTimeout.Duration = 300 * time.Millisecond
+	})
+	hubKeyPair := disco.GenerateKeypair(nil)
+	listener, err := disco.ListenDisco("tcp", "127.0.0.1:0", &disco.Config{
+		HandshakePattern:               disco.Noise_IK,
+		KeyPair:                        hubKeyPair,
+		PublicKeyVerifier:              verifyClient,
+		RemoteAddrContainsRemotePubkey: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.AcceptDisco()
+			if err != nil {
+				return
+			}
+			go notificationClient(conn)
+		}
+	}()
+	clientConfig := &disco.Config{
+		HandshakePattern: disco.Noise_IK,
+		KeyPair:          disco.GenerateKeypair(nil),
+		RemoteKey:        hubKeyPair.PublicKey[:],
+	}
+	dial := func() net.Conn {
+		conn, err := disco.Dial("tcp", listener.Addr().String(), clientConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// an empty notification, to finish the handshake
+		if _, err := conn.Write([]byte{0, 0}); err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+
+	// a client sending notifications stays connected, but can't open a second connection
+	conn := dial()
+	defer conn.Close()
+	for i := 0; i < 3; i++ {
+		if isClosed(conn, 150*time.Millisecond) {
+			t.Fatal("the connection was closed while the client sent notifications")
+		}
+		if _, err := conn.Write([]byte{0, 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	second := dial()
+	defer second.Close()
+	if !isClosed(second, time.Second) {
+		t.Error("a second connection was accepted for the same key")
+	}
+
+	// until it stays idle
+	if !isClosed(conn, time.Second) {
+		t.Error("the idle connection wasn't closed")
+	}
+}
@@ -0,0 +1,311 @@
+//
+// Organization
+// ============
+//
+// A Hub can be dedicated to an organization (or to a few of them): only the members of the organization
+// can then use it.
+// The organization signs a membership certificate for each member's public key (see docs/organizations.md),
+// that the member presents during the Disco handshake as a proof of its public key.
+//
+// The Hub keeps the certificates it has seen in a directory, that members can search to find each other.
+// Members don't need to trust the Hub for this, they verify the certificates themselves.
+//
+// The directory is written through to the storage backend (see persistence.go).
+//
+// The organization revokes keys by appending signed revocations to a file (see the organization tool),
+// which the Hub reloads when it changes. Revoked keys cannot connect anymore, and the Hub distributes
+// the revocations to the clients, so that they block their conversations with these keys.
+//
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+)
+
+// loadOrganizationKey reads the public key of the organization (in hex) from a file
+func loadOrganizationKey(file string) (ed25519.PublicKey, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	organizationKey, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(organizationKey) != ed25519.PublicKeySize {
+		return nil, errors.New("ssyk: incorrect organization public key")
+	}
+	return ed25519.PublicKey(organizationKey), nil
+}
+
+// verifyClient is the PublicKeyVerifier of the Hub: it accepts the peer Hubs (see delivery.go), and the
+// clients presenting a valid membership certificate for their key, signed by one of the organizations
+// allowed in the configuration. Anyone is accepted if no organization is
+func verifyClient(publicKey, proof []byte) bool {
+	if peers.nameOf(hex.EncodeToString(publicKey)) != "" {
+		return true
+	}
+	organizationKeys := settings.organizationKeys()
+	if len(organizationKeys) == 0 {
+		return true
+	}
+	certificate := &s.MembershipCertificate{}
+	if err := proto.Unmarshal(proof, certificate); err != nil {
+		log.Println("client presented a malformed certificate")
+		return false
+	}
+	var err error
+	for _, organizationKey := range organizationKeys {
+		if err = s.VerifyCertificate(organizationKey, certificate, publicKey, time.Now()); err != nil {
+			continue
+		}
+		if revocations.isRevoked(organizationKey, hex.EncodeToString(publicKey)) {
+			log.Println("client presented a revoked key")
+			return false
+		}
+		directory.put(certificate, proof, organizationKey)
+		return true
+	}
+	log.Println("client presented an invalid certificate:", err)
+	return false
+}
+
+//
+// Directory
+//
+
+const (
+	directoryMaxLimit = 50 // members returned per page (a certificate is less than 300 bytes)
+)
+
+// storedMember is a certificate of the directory in the storage backend, under the public key of the member
+type storedMember struct {
+	Certificate     []byte
+	OrganizationKey []byte
+}
+
+type directoryStore struct {
+	enabled       bool                                // true if the Hub is dedicated to an organization
+	members       map[string]*s.MembershipCertificate // public key -> latest certificate
+	certificates  map[string][]byte                   // public key -> serialized certificate
+	organizations map[string]ed25519.PublicKey        // public key -> the organization that signed the certificate
+	queryMutex    sync.Mutex                          // one query at a time
+}
+
+var (
+	directory directoryStore
+)
+
+func init() {
+	directory.members = make(map[string]*s.MembershipCertificate)
+	directory.certificates = make(map[string][]byte)
+	directory.organizations = make(map[string]ed25519.PublicKey)
+}
+
+// load reads the directory back from the storage backend (the certificates are already in the log)
+func (directory *directoryStore) load() error {
+	directory.queryMutex.Lock()
+	defer directory.queryMutex.Unlock()
+
+	return persist.load("directory", func(publicKey string, value []byte) error {
+		var stored storedMember
+		if err := decodeValue(value, &stored); err != nil {
+			return err
+		}
+		certificate := &s.MembershipCertificate{}
+		if err := proto.Unmarshal(stored.Certificate, certificate); err != nil {
+			return err
+		}
+		directory.members[publicKey] = certificate
+		directory.certificates[publicKey] = stored.Certificate
+		directory.organizations[publicKey] = ed25519.PublicKey(stored.OrganizationKey)
+		return nil
+	})
+}
+
+// isEnabled returns true if the Hub is dedicated to an organization (the configuration can change, see config.go)
+func (directory *directoryStore) isEnabled() bool {
+	directory.queryMutex.Lock()
+	defer directory.queryMutex.Unlock()
+	return directory.enabled
+}
+
+// setEnabled enables or disables the directory, and the transparency log
+func (directory *directoryStore) setEnabled(enabled bool) {
+	directory.queryMutex.Lock()
+	defer directory.queryMutex.Unlock()
+	directory.enabled = enabled
+}
+
+// put adds a member to the directory, or replaces its certificate by a more recent one.
+// New certificates are appended to the transparency log
+func (directory *directoryStore) put(certificate *s.MembershipCertificate, serialized []byte, organizationKey ed25519.PublicKey) {
+	directory.queryMutex.Lock()
+	defer directory.queryMutex.Unlock()
+
+	publicKey := hex.EncodeToString(certificate.GetPublicKey())
+	if current, ok := directory.members[publicKey]; ok && current.GetIssuedAt() > certificate.GetIssuedAt() {
+		return
+	}
+	directory.members[publicKey] = certificate
+	directory.certificates[publicKey] = serialized
+	directory.organizations[publicKey] = organizationKey
+	persist.put("directory", publicKey, encodeValue(storedMember{Certificate: serialized, OrganizationKey: organizationKey}))
+	tlog.appendEntry(&s.LogEntry{Certificate: serialized})
+}
+
+// search returns a page of the certificates (sorted by name) whose name or team contains the query,
+// and the total number of certificates matching the query. Expired and revoked certificates are skipped.
+func (directory *directoryStore) search(query string, offset, limit int) ([][]byte, int) {
+	directory.queryMutex.Lock()
+	defer directory.queryMutex.Unlock()
+
+	query = strings.ToLower(query)
+	now := time.Now().Unix()
+	var matches []string
+	for publicKey, certificate := range directory.members {
+		if certificate.GetExpiresAt() < now || revocations.isRevoked(directory.organizations[publicKey], publicKey) {
+			continue
+		}
+		if strings.Contains(strings.ToLower(certificate.GetName()), query) ||
+			strings.Contains(strings.ToLower(certificate.GetTeam()), query) {
+			matches = append(matches, publicKey)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return directory.members[matches[i]].GetName() < directory.members[matches[j]].GetName()
+	})
+	// paginate
+	var page [][]byte
+	for i := offset; i < len(matches) && i < offset+limit; i++ {
+		page = append(page, directory.certificates[matches[i]])
+	}
+	return page, len(matches)
+}
+
+//
+// Revocations
+//
+
+const (
+	revocationMaxLimit = 200 // revocations returned per page, if they fit in a response (see responsePageSize)
+)
+
+type revocationList struct {
+	file             string              // one serialized revocation per line, in hex
+	organizationKeys []ed25519.PublicKey // to verify the revocations
+	modTime          time.Time           // when the file was last loaded
+	revoked          map[string]bool     // organization key + public key (in hex) -> revoked
+	revocations      [][]byte            // serialized revocations
+	queryMutex       sync.Mutex          // one query at a time
+}
+
+var (
+	revocations revocationList
+)
+
+// loadRevocations sets the file containing the revocations of the organizations, and loads it
+func loadRevocations(file string, organizationKeys []ed25519.PublicKey) error {
+	revocations.queryMutex.Lock()
+	defer revocations.queryMutex.Unlock()
+
+	revocations.file = file
+	revocations.organizationKeys = organizationKeys
+	revocations.modTime = time.Time{}
+	revocations.revoked = nil
+	revocations.revocations = nil
+	return revocations.reload()
+}
+
+// reload reads the file again if it has changed since it was last loaded
+func (revocations *revocationList) reload() error {
+	if revocations.file == "" {
+		return nil
+	}
+	info, err := os.Stat(revocations.file)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(revocations.modTime) {
+		return nil
+	}
+	content, err := ioutil.ReadFile(revocations.file)
+	if err != nil {
+		return err
+	}
+	revoked := make(map[string]bool)
+	var serializedRevocations [][]byte
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		serialized, err := hex.DecodeString(line)
+		if err != nil {
+			return errors.New("ssyk: revocation is not hexadecimal")
+		}
+		revocation := &s.Revocation{}
+		if err := proto.Unmarshal(serialized, revocation); err != nil {
+			return err
+		}
+		// an organization can only revoke the keys it certified
+		var organizationKey ed25519.PublicKey
+		for _, key := range revocations.organizationKeys {
+			if err = s.VerifyRevocation(key, revocation); err == nil {
+				organizationKey = key
+				break
+			}
+		}
+		if organizationKey == nil {
+			return errors.New("ssyk: revocation isn't signed by an allowed organization")
+		}
+		revoked[hex.EncodeToString(organizationKey)+hex.EncodeToString(revocation.GetPublicKey())] = true
+		serializedRevocations = append(serializedRevocations, serialized)
+		tlog.appendEntry(&s.LogEntry{Revocation: serialized})
+	}
+	revocations.modTime = info.ModTime()
+	revocations.revoked = revoked
+	revocations.revocations = serializedRevocations
+	return nil
+}
+
+// isRevoked returns true if the organization revoked this public key (in hex)
+func (revocations *revocationList) isRevoked(organizationKey ed25519.PublicKey, publicKey string) bool {
+	revocations.queryMutex.Lock()
+	defer revocations.queryMutex.Unlock()
+
+	if err := revocations.reload(); err != nil {
+		log.Println("cannot reload the revocations:", err)
+	}
+	return revocations.revoked[hex.EncodeToString(organizationKey)+publicKey]
+}
+
+// page returns the serialized revocations of the organization starting at offset (at most limit, and no more
+// than fit in a response), and how many there are
+func (revocations *revocationList) page(offset, limit int) ([][]byte, int) {
+	revocations.queryMutex.Lock()
+	defer revocations.queryMutex.Unlock()
+
+	if err := revocations.reload(); err != nil {
+		log.Println("cannot reload the revocations:", err)
+	}
+	var page [][]byte
+	size := 0
+	for i := offset; i < len(revocations.revocations) && i < offset+limit; i++ {
+		size += len(revocations.revocations[i]) + 3 // the tag and the length of the field
+		if size > responsePageSize && len(page) > 0 {
+			break
+		}
+		page = append(page, revocations.revocations[i])
+	}
+	return page, len(revocations.revocations)
+}
@@ -0,0 +1,339 @@
+//
+// Persistence
+// ===========
+//
+// The stores of the Hub (pending messages, blobs, key packages, the directory, proofs, key rotations, devices,
+// the queues of the forwarder and the transparency log) are kept in memory, and written through to a backend
+// which gives them back when the Hub starts (see `storage` in config.go):
+//
+// * memory: nothing is written, everything is lost when the Hub stops (for tests)
+// * disk: one journal per store in the directory `path` (hub-data by default)
+//
+// A journal is a list of records [op(1), key length(2), key, value length(4), value] that put a value under a
+// key, or remove a key. It is read back when the Hub starts (in the order the keys were first put), and
+// rewritten with only the live values when it has grown too much. Records are synced to the disk before the
+// request is answered, and a record cut by a crash is ignored.
+//
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	journalPut    = 1
+	journalRemove = 2
+
+	journalCompactMin = 1024 // records of dead values before a journal is rewritten
+)
+
+// backend stores the values of the Hub in buckets, one per store. put and remove panic if they can't write,
+// load stops at the first error of each
+type backend interface {
+	put(bucket, key string, value []byte)
+	remove(bucket, key string)
+	load(bucket string, each func(key string, value []byte) error) error
+}
+
+var (
+	persist backend = memoryBackend{}
+
+	lastRecordKey      int64 // see newRecordKey
+	lastRecordKeyMutex sync.Mutex
+)
+
+// openBackend returns the backend of the configuration
+func openBackend(name, path string) (backend, error) {
+	switch name {
+	case "memory":
+		return memoryBackend{}, nil
+	case "disk":
+		if path == "" {
+			path = defaultStoragePath
+		}
+		if err := os.MkdirAll(path, 0700); err != nil {
+			return nil, err
+		}
+		return &diskBackend{path: path, journals: make(map[string]*journal)}, nil
+	}
+	return nil, errors.New("ssyk: unknown storage backend " + name)
+}
+
+// loadStores reads the stores back from the backend when the Hub starts, before the revocations are appended to
+// the log. The queues of the peer Hubs are read once the peers are loaded (see delivery.go)
+func loadStores() error {
+	for _, load := range []func() error{tlog.load, mm.load, bs.load, kps.load, directory.load, proofs.load, rotations.load, devices.load} {
+		if err := load(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newRecordKey returns a key for the values that have no key of their own (messages), after the previous ones
+func newRecordKey() string {
+	lastRecordKeyMutex.Lock()
+	defer lastRecordKeyMutex.Unlock()
+	key := time.Now().UnixNano()
+	if key <= lastRecordKey {
+		key = lastRecordKey + 1
+	}
+	lastRecordKey = key
+	return fmt.Sprintf("%020d", key)
+}
+
+// encodeValue serializes the value of a store, it panics if it can't
+func encodeValue(value interface{}) []byte {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(value); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
+}
+
+// decodeValue parses a value serialized by encodeValue
+func decodeValue(data []byte, value interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
+
+//
+// Memory
+//
+
+type memoryBackend struct{}
+
+func (memoryBackend) put(bucket, key string, value []byte) {}
+
+func (memoryBackend) remove(bucket, key string) {}
+
+func (memoryBackend) load(bucket string, each func(key string, value []byte) error) error {
+	return nil
+}
+
+//
+// Disk
+//
+
+type diskBackend struct {
+	path       string
+	journals   map[string]*journal // bucket -> its journal, opened when first used
+	queryMutex sync.Mutex          // one query at a time
+}
+
+type journal struct {
+	file    *os.File
+	records int             // records in the file
+	live    map[string]bool // the keys that have a value
+}
+
+// journal opens the journal of a bucket, and reads its keys. diskBackend.queryMutex must be held
+func (disk *diskBackend) journal(bucket string) (*journal, error) {
+	if current, ok := disk.journals[bucket]; ok {
+		return current, nil
+	}
+	file, err := os.OpenFile(disk.file(bucket), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	keys, values, records, err := readJournal(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	current := &journal{file: file, records: records, live: make(map[string]bool)}
+	for _, key := range keys {
+		current.live[key] = true
+	}
+	disk.journals[bucket] = current
+	// rewrite it with only the live values, which drops a record cut by a crash
+	if info, err := file.Stat(); err != nil || info.Size() != journalSize(keys, values) {
+		if err := disk.compact(bucket, keys, values); err != nil {
+			return nil, err
+		}
+	}
+	return disk.journals[bucket], nil
+}
+
+// file returns the path of the journal of a bucket
+func (disk *diskBackend) file(bucket string) string {
+	return filepath.Join(disk.path, bucket+".journal")
+}
+
+func (disk *diskBackend) put(bucket, key string, value []byte) {
+	disk.write(bucket, journalPut, key, value)
+}
+
+func (disk *diskBackend) remove(bucket, key string) {
+	disk.write(bucket, journalRemove, key, nil)
+}
+
+// write appends a record to the journal of a bucket, and rewrites the journal if it has grown too much
+func (disk *diskBackend) write(bucket string, op byte, key string, value []byte) {
+	disk.queryMutex.Lock()
+	defer disk.queryMutex.Unlock()
+
+	current, err := disk.journal(bucket)
+	if err != nil {
+		panic(err)
+	}
+	if op == journalRemove && !current.live[key] {
+		return
+	}
+	if _, err := current.file.Write(encodeRecord(op, key, value)); err != nil {
+		panic(err)
+	}
+	if err := current.file.Sync(); err != nil {
+		panic(err)
+	}
+	current.records++
+	if op == journalPut {
+		current.live[key] = true
+	} else {
+		delete(current.live, key)
+	}
+	if current.records > 2*len(current.live)+journalCompactMin {
+		if _, err := current.file.Seek(0, io.SeekStart); err != nil {
+			panic(err)
+		}
+		keys, values, _, err := readJournal(current.file)
+		if err != nil {
+			panic(err)
+		}
+		if err := disk.compact(bucket, keys, values); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (disk *diskBackend) load(bucket string, each func(key string, value []byte) error) error {
+	disk.queryMutex.Lock()
+	defer disk.queryMutex.Unlock()
+
+	current, err := disk.journal(bucket)
+	if err != nil {
+		return err
+	}
+	if _, err := current.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	keys, values, _, err := readJournal(current.file)
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if err := each(key, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compact replaces the journal of a bucket by the live values, in order. diskBackend.queryMutex must be held
+func (disk *diskBackend) compact(bucket string, keys []string, values [][]byte) error {
+	var content bytes.Buffer
+	for i, key := range keys {
+		content.Write(encodeRecord(journalPut, key, values[i]))
+	}
+	temporary := disk.file(bucket) + ".new"
+	if err := ioutil.WriteFile(temporary, content.Bytes(), 0600); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(temporary, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := os.Rename(temporary, disk.file(bucket)); err != nil {
+		file.Close()
+		return err
+	}
+	current := disk.journals[bucket]
+	current.file.Close()
+	current.file = file
+	current.records = len(keys)
+	log.Println("rewrote the journal", bucket)
+	return nil
+}
+
+// encodeRecord serializes a record of a journal
+func encodeRecord(op byte, key string, value []byte) []byte {
+	record := make([]byte, 7, 7+len(key)+len(value))
+	record[0] = op
+	binary.BigEndian.PutUint16(record[1:3], uint16(len(key)))
+	record = append(record[:3], key...)
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(value)))
+	record = append(record, length[:]...)
+	return append(record, value...)
+}
+
+// readJournal returns the live keys of a journal in the order they were first put, their values, and the
+// number of complete records. A record cut by a crash ends the journal
+func readJournal(file io.Reader) ([]string, [][]byte, int, error) {
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	var keys []string
+	values := make(map[string][]byte)
+	positions := make(map[string]int) // key -> its place in keys
+	records := 0
+	for len(content) >= 3 {
+		op, keyLength := content[0], int(binary.BigEndian.Uint16(content[1:3]))
+		if len(content) < 7+keyLength {
+			break
+		}
+		key := string(content[3 : 3+keyLength])
+		valueLength := int(binary.BigEndian.Uint32(content[3+keyLength : 7+keyLength]))
+		if len(content) < 7+keyLength+valueLength {
+			break
+		}
+		value := content[7+keyLength : 7+keyLength+valueLength]
+		content = content[7+keyLength+valueLength:]
+		records++
+		switch op {
+		case journalPut:
+			if _, ok := values[key]; !ok {
+				positions[key] = len(keys)
+				keys = append(keys, key)
+			}
+			values[key] = value
+		case journalRemove:
+			delete(values, key)
+		default:
+			return nil, nil, 0, errors.New("ssyk: a journal is corrupted")
+		}
+	}
+	var live []string
+	liveValues := make([][]byte, 0, len(values))
+	for i, key := range keys {
+		if value, ok := values[key]; ok && positions[key] == i {
+			live = append(live, key)
+			liveValues = append(liveValues, value)
+		}
+	}
+	return live, liveValues, records, nil
+}
+
+// journalSize returns the size of a journal containing only these values
+func journalSize(keys []string, values [][]byte) int64 {
+	var size int64
+	for i, key := range keys {
+		size += int64(7 + len(key) + len(values[i]))
+	}
+	return size
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	s "github.com/mimoo/sasayaki/serialization"
+)
+
+// testBackend opens a disk backend in a temporary directory, as the Hub's backend until the test ends
+func testBackend(t *testing.T, path string) *diskBackend {
+	opened, err := openBackend("disk", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := persist
+	persist = opened
+	t.Cleanup(func() { persist = previous })
+	return opened.(*diskBackend)
+}
+
+// testLoad returns the keys and values of a bucket, in order
+func testLoad(t *testing.T, disk backend, bucket string) ([]string, map[string][]byte) {
+	var keys []string
+	values := make(map[string][]byte)
+	if err := disk.load(bucket, func(key string, value []byte) error {
+		keys = append(keys, key)
+		values[key] = value
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return keys, values
+}
+
+func TestDiskBackend(t *testing.T) {
+	path := t.TempDir()
+	disk := testBackend(t, path)
+	disk.put("test", "a", []byte("1"))
+	disk.put("test", "b", []byte("2"))
+	disk.put("test", "c", []byte("3"))
+	disk.put("test", "a", []byte("4"))
+	disk.remove("test", "b")
+	disk.put("test", "b", []byte("5"))
+	disk.put("other", "a", []byte("6"))
+
+	// an update keeps its place, a key put again after its removal goes last
+	reopened := testBackend(t, path)
+	keys, values := testLoad(t, reopened, "test")
+	if fmt.Sprint(keys) != "[a c b]" || string(values["a"]) != "4" || string(values["b"]) != "5" {
+		t.Fatalf("the journal was read as %v %q", keys, values)
+	}
+	if keys, _ := testLoad(t, reopened, "other"); len(keys) != 1 {
+		t.Errorf("the buckets are mixed: %v", keys)
+	}
+
+	// a record cut by a crash is ignored, and the journal is repaired
+	file, err := os.OpenFile(filepath.Join(path, "test.journal"), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(encodeRecord(journalPut, "d", []byte("7"))[:5])
+	file.Close()
+	reopened = testBackend(t, path)
+	if keys, _ := testLoad(t, reopened, "test"); fmt.Sprint(keys) != "[a c b]" {
+		t.Fatalf("the cut record was read: %v", keys)
+	}
+	reopened.put("test", "d", []byte("8"))
+	keys, values = testLoad(t, testBackend(t, path), "test")
+	if fmt.Sprint(keys) != "[a c b d]" || string(values["d"]) != "8" {
+		t.Errorf("the journal wasn't repaired: %v %q", keys, values)
+	}
+}
+
+func TestDiskBackendCompaction(t *testing.T) {
+	path := t.TempDir()
+	disk := testBackend(t, path)
+	disk.put("test", "kept", []byte("first"))
+	for i := 0; i < 3000; i++ {
+		disk.put("test", fmt.Sprint(i%10), bytes.Repeat([]byte{byte(i)}, 10))
+		if i%10 == 9 {
+			for j := 0; j < 10; j++ {
+				disk.remove("test", fmt.Sprint(j))
+			}
+		}
+	}
+	disk.put("test", "last", []byte("last"))
+
+	info, err := os.Stat(filepath.Join(path, "test.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > int64(2*journalCompactMin*20) {
+		t.Errorf("the journal wasn't rewritten: %d bytes", info.Size())
+	}
+	keys, values := testLoad(t, testBackend(t, path), "test")
+	if fmt.Sprint(keys) != "[kept last]" || string(values["kept"]) != "first" {
+		t.Errorf("the journal was rewritten as %v", keys)
+	}
+}
+
+func TestStoresReload(t *testing.T) {
+	path := t.TempDir()
+	testBackend(t, path)
+	var before memory
+	before.pendingMessages, before.pendingBytes = make(map[string][]Message), make(map[string]int64)
+	before.push("bob", Message{fromAddress: "alice", content: []byte("first")})
+	before.push("bob", Message{fromAddress: "alice", content: []byte("second")})
+	before.push("carol", Message{fromAddress: "alice", content: []byte("third")})
+	if _, ok := before.pop("bob"); !ok {
+		t.Fatal("no message for bob")
+	}
+
+	testBackend(t, path)
+	var after memory
+	after.pendingMessages, after.pendingBytes = make(map[string][]Message), make(map[string]int64)
+	if err := after.load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(after.pendingMessages["bob"]) != 1 || string(after.pendingMessages["bob"][0].content) != "second" ||
+		len(after.pendingMessages["carol"]) != 1 || after.pendingBytes["bob"] != int64(len("second")) {
+		t.Fatalf("the pending messages were read as %v", after.pendingMessages)
+	}
+	if message, ok := after.pop("bob"); !ok || message.fromAddress != "alice" {
+		t.Fatal("the message wasn't kept")
+	}
+
+	testBackend(t, path)
+	var last memory
+	last.pendingMessages, last.pendingBytes = make(map[string][]Message), make(map[string]int64)
+	if err := last.load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(last.pendingMessages["bob"]) != 0 {
+		t.Error("a delivered message was read again")
+	}
+}
+
+func TestLogReload(t *testing.T) {
+	testBackend(t, t.TempDir())
+	initTransparencyLog(nil)
+	defer initTransparencyLog(nil)
+	for i := 0; i < 10; i++ {
+		tlog.appendEntry(&s.LogEntry{Revocation: []byte{byte(i)}})
+	}
+	root := tlog.tree.Root(10)
+
+	// the tree is the same, and the entries aren't appended twice
+	initTransparencyLog(nil)
+	if err := tlog.load(); err != nil {
+		t.Fatal(err)
+	}
+	tlog.appendEntry(&s.LogEntry{Revocation: []byte{3}})
+	if tlog.tree.Size() != 10 || !bytes.Equal(tlog.tree.Root(10), root) {
+		t.Fatalf("the log was rebuilt with %d entries", tlog.tree.Size())
+	}
+	tlog.appendEntry(&s.LogEntry{Revocation: []byte{10}})
+	if tlog.tree.Size() != 11 || !bytes.Equal(tlog.tree.Root(10), root) {
+		t.Error("the log doesn't grow from where it was")
+	}
+}
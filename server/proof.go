@@ -0,0 +1,83 @@
+//
+// Verification Proofs
+// ===================
+//
+// Clients publish the verifications they have done on other keys (see serialization/verification.go),
+// and anyone can fetch the proofs of a key to see who verified it. The Hub only keeps the latest proof
+// of each verifier for each key.
+//
+// Proofs are written through to the storage backend (see persistence.go), under "<key> <verifier>".
+//
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+const (
+	proofMaxSize      = 500 // a proof is 2 public keys, a signature, a nickname and a mean of verification
+	proofMaxPerMember = 110 // verifiers per key, their proofs fit in a response
+)
+
+type proofStore struct {
+	proofs     map[string]map[string][]byte // key -> verifier -> serialized VerificationProof
+	queryMutex sync.Mutex                   // one query at a time
+}
+
+var (
+	proofs proofStore
+)
+
+func init() {
+	proofs.proofs = make(map[string]map[string][]byte)
+}
+
+// load reads the proofs back from the storage backend
+func (proofs *proofStore) load() error {
+	proofs.queryMutex.Lock()
+	defer proofs.queryMutex.Unlock()
+
+	return persist.load("proofs", func(key string, proof []byte) error {
+		fields := strings.Fields(key)
+		if len(fields) != 2 {
+			return errors.New("ssyk: incorrect key for a proof " + key)
+		}
+		if proofs.proofs[fields[0]] == nil {
+			proofs.proofs[fields[0]] = make(map[string][]byte)
+		}
+		proofs.proofs[fields[0]][fields[1]] = proof
+		return nil
+	})
+}
+
+// put stores the proof of a verifier for a key, replacing its previous one
+func (proofs *proofStore) put(member, verifier string, proof []byte) bool {
+	proofs.queryMutex.Lock()
+	defer proofs.queryMutex.Unlock()
+
+	memberProofs, ok := proofs.proofs[member]
+	if !ok {
+		memberProofs = make(map[string][]byte)
+		proofs.proofs[member] = memberProofs
+	}
+	if _, ok := memberProofs[verifier]; !ok && len(memberProofs) >= proofMaxPerMember {
+		return false
+	}
+	memberProofs[verifier] = proof
+	persist.put("proofs", member+" "+verifier, proof)
+	return true
+}
+
+// get returns all the proofs for a key
+func (proofs *proofStore) get(member string) [][]byte {
+	proofs.queryMutex.Lock()
+	defer proofs.queryMutex.Unlock()
+
+	var memberProofs [][]byte
+	for _, proof := range proofs.proofs[member] {
+		memberProofs = append(memberProofs, proof)
+	}
+	return memberProofs
+}
@@ -0,0 +1,87 @@
+//
+// Key Rotations
+// =============
+//
+// Clients changing their key publish a statement signed by their previous key (see serialization/rotation.go),
+// so that their contacts can find their new key even if they don't share a conversation. Only the owner of the
+// previous key can publish it, and a key can only be replaced once.
+//
+// Rotations are written through to the storage backend (see persistence.go).
+//
+package main
+
+import (
+	"encoding/hex"
+	"sync"
+)
+
+const (
+	rotationMaxSize      = 300 // two public keys, a date and a signature
+	rotationMaxQueryKeys = 180 // keys per GetKeyRotations request, their statements fit in a response
+)
+
+// storedRotation is a rotation in the storage backend, under the previous key
+type storedRotation struct {
+	NewKey    string
+	Statement []byte
+}
+
+type rotationStore struct {
+	statements map[string][]byte // previous key -> serialized KeyRotationStatement
+	newKeys    map[string]string // previous key -> new key
+	queryMutex sync.Mutex        // one query at a time
+}
+
+var (
+	rotations rotationStore
+)
+
+func init() {
+	rotations.statements = make(map[string][]byte)
+	rotations.newKeys = make(map[string]string)
+}
+
+// load reads the rotations back from the storage backend
+func (rotations *rotationStore) load() error {
+	rotations.queryMutex.Lock()
+	defer rotations.queryMutex.Unlock()
+
+	return persist.load("rotations", func(oldAddress string, value []byte) error {
+		var stored storedRotation
+		if err := decodeValue(value, &stored); err != nil {
+			return err
+		}
+		rotations.newKeys[oldAddress] = stored.NewKey
+		rotations.statements[oldAddress] = stored.Statement
+		return nil
+	})
+}
+
+// put stores the rotation of a key, it returns false if the key has already been replaced by another one
+func (rotations *rotationStore) put(oldKey, newKey []byte, statement []byte) bool {
+	rotations.queryMutex.Lock()
+	defer rotations.queryMutex.Unlock()
+
+	oldAddress, newAddress := hex.EncodeToString(oldKey), hex.EncodeToString(newKey)
+	if previous, ok := rotations.newKeys[oldAddress]; ok {
+		return previous == newAddress
+	}
+	rotations.newKeys[oldAddress] = newAddress
+	rotations.statements[oldAddress] = statement
+	persist.put("rotations", oldAddress, encodeValue(storedRotation{NewKey: newAddress, Statement: statement}))
+	return true
+}
+
+// get returns the rotations of the keys that have been replaced
+func (rotations *rotationStore) get(keys []string) [][]byte {
+	rotations.queryMutex.Lock()
+	defer rotations.queryMutex.Unlock()
+
+	var statements [][]byte
+	for _, key := range keys {
+		if statement, ok := rotations.statements[key]; ok {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
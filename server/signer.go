@@ -0,0 +1,155 @@
+//
+// Signer
+// ======
+//
+// The Hub signs its tree heads (see transparency.go) through a signer. By default it signs with its keypair,
+// but the signatures can also be made by a separate local process (for example in front of a PKCS#11
+// soft-token), reached on a Unix socket (see `signer` in config.go). That process answers one JSON object per line:
+//
+//	-> {"op": "public_key"}
+//	<- {"public_key": "<hex>"}
+//	-> {"op": "sign", "message": "<hex>"}
+//	<- {"signature": "<hex>"}
+//
+// and returns {"error": "..."} when it can't. The signatures are XEdDSA signatures with the Disco key of the Hub,
+// so that clients verify them like before, and the Hub checks at startup that the signer holds its key.
+//
+// This is not key isolation: only the signatures of the tree heads are delegated. The Hub still loads its
+// private key (see keypair.go), because every Disco handshake with a client or a peer Hub needs it, and
+// libdisco can't delegate the Diffie-Hellman operations. Someone reading the memory of the Hub can still
+// sign tree heads.
+//
+// TODO: keeping the private key in a separate process, rather than in the Hub's memory, was requested and is
+// not done. It needs libdisco to delegate the handshakes to the signer, the signer protocol would then grow a
+// Diffie-Hellman operation.
+//
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mimoo/sasayaki/xeddsa"
+)
+
+const (
+	signerTimeout = 5 * time.Second
+)
+
+type signer interface {
+	// sign returns the XEdDSA signature of a message with the Disco key of the Hub
+	sign(message []byte) ([]byte, error)
+}
+
+// localSigner signs with a private key held by the Hub
+type localSigner struct {
+	privateKey [32]byte
+}
+
+func (signer localSigner) sign(message []byte) ([]byte, error) {
+	return xeddsa.Sign(signer.privateKey, message), nil
+}
+
+// socketSigner asks a local process to sign
+type socketSigner struct {
+	socket     string   // the path of the Unix socket of the signer
+	conn       net.Conn // nil when disconnected
+	reader     *bufio.Reader
+	queryMutex sync.Mutex // one query at a time
+}
+
+type signerRequest struct {
+	Op      string `json:"op"`
+	Message string `json:"message,omitempty"`
+}
+
+type signerResponse struct {
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature"`
+	Error     string `json:"error"`
+}
+
+// newSocketSigner connects to the signer on a Unix socket, and checks that it holds the key of the Hub
+func newSocketSigner(socket string, hubPublicKey []byte) (*socketSigner, error) {
+	signer := &socketSigner{socket: socket}
+	res, err := signer.query(signerRequest{Op: "public_key"})
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := hex.DecodeString(res.PublicKey)
+	if err != nil || !bytes.Equal(publicKey, hubPublicKey) {
+		return nil, errors.New("ssyk: the signer doesn't hold the key of the Hub")
+	}
+	return signer, nil
+}
+
+func (signer *socketSigner) sign(message []byte) ([]byte, error) {
+	res, err := signer.query(signerRequest{Op: "sign", Message: hex.EncodeToString(message)})
+	if err != nil {
+		return nil, err
+	}
+	signature, err := hex.DecodeString(res.Signature)
+	if err != nil || len(signature) != 64 {
+		return nil, errors.New("ssyk: the signer returned a malformed signature")
+	}
+	return signature, nil
+}
+
+// query sends a request to the signer, and reconnects once if the connection was lost
+func (signer *socketSigner) query(req signerRequest) (*signerResponse, error) {
+	signer.queryMutex.Lock()
+	defer signer.queryMutex.Unlock()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		panic(err)
+	}
+	var res *signerResponse
+	for attempt := 0; attempt < 2; attempt++ {
+		if res, err = signer.roundTrip(append(data, '\n')); err == nil {
+			break
+		}
+		if signer.conn != nil {
+			signer.conn.Close()
+			signer.conn = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != "" {
+		return nil, errors.New("ssyk: the signer refused: " + res.Error)
+	}
+	return res, nil
+}
+
+// roundTrip writes a line to the signer and reads its answer. signer.queryMutex must be held
+func (signer *socketSigner) roundTrip(line []byte) (*signerResponse, error) {
+	if signer.conn == nil {
+		conn, err := net.DialTimeout("unix", signer.socket, signerTimeout)
+		if err != nil {
+			return nil, err
+		}
+		signer.conn = conn
+		signer.reader = bufio.NewReader(conn)
+	}
+	signer.conn.SetDeadline(time.Now().Add(signerTimeout))
+	if _, err := signer.conn.Write(line); err != nil {
+		return nil, err
+	}
+	answer, err := signer.reader.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	res := &signerResponse{}
+	if err := json.Unmarshal(answer, res); err != nil {
+		return nil, errors.New("ssyk: the signer returned a malformed response")
+	}
+	return res, nil
+}
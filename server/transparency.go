@@ -0,0 +1,144 @@
+//
+// Transparency Log
+// ================
+//
+// A Hub dedicated to an organization appends every membership certificate and every revocation it sees
+// to an append-only Merkle tree (see docs/gossip.md), and signs its root with its Disco key (see signer.go).
+// Clients check that the log only grows (consistency proofs), read the entries they haven't seen yet
+// (inclusion proofs), and gossip the signed roots with their contacts: a Hub hiding a revocation from
+// some clients has to show them a different log, which is detected when they compare roots.
+//
+// The entries are written through to the storage backend (see persistence.go) under their index, and the
+// tree is rebuilt from them, in the same order, when the Hub starts.
+//
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+	"github.com/mimoo/sasayaki/transparency"
+)
+
+const (
+	logMaxEntries = 100 // entries returned per request, if they fit in a response (see responsePageSize)
+)
+
+type transparencyLog struct {
+	signer     signer            // signs the tree heads with the Disco key of the Hub (see signer.go)
+	tree       transparency.Tree // the hashes of the entries
+	entries    [][]byte          // serialized LogEntry
+	logged     map[string]bool   // serialized LogEntry (hex) -> already in the log
+	queryMutex sync.Mutex        // one query at a time
+}
+
+var (
+	tlog transparencyLog
+)
+
+func initTransparencyLog(signer signer) {
+	tlog.signer = signer
+	tlog.logged = make(map[string]bool)
+	tlog.entries = nil
+	tlog.tree = transparency.Tree{}
+}
+
+// load reads the entries back from the storage backend, and rebuilds the tree
+func (tlog *transparencyLog) load() error {
+	tlog.queryMutex.Lock()
+	defer tlog.queryMutex.Unlock()
+
+	return persist.load("log", func(index string, serialized []byte) error {
+		if index != logIndex(len(tlog.entries)) {
+			return errors.New("ssyk: the entry " + index + " of the log is out of order")
+		}
+		tlog.logged[hex.EncodeToString(serialized)] = true
+		tlog.entries = append(tlog.entries, serialized)
+		tlog.tree.Append(serialized)
+		return nil
+	})
+}
+
+// logIndex returns the key of an entry in the storage backend
+func logIndex(index int) string {
+	return fmt.Sprintf("%020d", index)
+}
+
+// appendEntry adds a certificate or a revocation to the log, if it isn't already there
+func (tlog *transparencyLog) appendEntry(entry *s.LogEntry) {
+	tlog.queryMutex.Lock()
+	defer tlog.queryMutex.Unlock()
+
+	serialized, err := proto.Marshal(entry)
+	if err != nil {
+		panic(err)
+	}
+	if tlog.logged[hex.EncodeToString(serialized)] {
+		return
+	}
+	tlog.logged[hex.EncodeToString(serialized)] = true
+	persist.put("log", logIndex(len(tlog.entries)), serialized)
+	tlog.entries = append(tlog.entries, serialized)
+	tlog.tree.Append(serialized)
+}
+
+// treeHead returns the current root of the log, signed
+func (tlog *transparencyLog) treeHead() (*s.TreeHead, error) {
+	tlog.queryMutex.Lock()
+	defer tlog.queryMutex.Unlock()
+
+	size := tlog.tree.Size()
+	treeHead := &s.TreeHead{
+		TreeSize:  size,
+		RootHash:  tlog.tree.Root(size),
+		Timestamp: time.Now().Unix(),
+	}
+	signature, err := tlog.signer.sign(transparency.TreeHeadContent(treeHead))
+	if err != nil {
+		return nil, err
+	}
+	treeHead.Signature = signature
+	return treeHead, nil
+}
+
+// consistencyProof proves that the log of `first` entries is a prefix of the log of `second` entries
+func (tlog *transparencyLog) consistencyProof(first, second uint64) ([][]byte, bool) {
+	tlog.queryMutex.Lock()
+	defer tlog.queryMutex.Unlock()
+
+	if first > second || second > tlog.tree.Size() {
+		return nil, false
+	}
+	return tlog.tree.ConsistencyProof(first, second), true
+}
+
+// entriesWithProofs returns the entries [first, second) (at most logMaxEntries, and no more than fit in a
+// response), with their inclusion proofs in the log of `second` entries
+func (tlog *transparencyLog) entriesWithProofs(first, second uint64) ([]*s.LogEntryProof, bool) {
+	tlog.queryMutex.Lock()
+	defer tlog.queryMutex.Unlock()
+
+	if first > second || second > tlog.tree.Size() {
+		return nil, false
+	}
+	var entries []*s.LogEntryProof
+	size := 0
+	for index := first; index < second && index < first+logMaxEntries; index++ {
+		entry := &s.LogEntryProof{
+			Index: index,
+			Entry: tlog.entries[index],
+			Proof: tlog.tree.InclusionProof(index, second),
+		}
+		size += proto.Size(entry) + 3 // the tag and the length of the field
+		if size > responsePageSize && len(entries) > 0 {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries, true
+}
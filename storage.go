@@ -11,12 +11,29 @@ package main
 
 import (
 	"database/sql"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"path/filepath"
+	"strings"
 )
 
+// storageMigrations add the columns that were added to existing tables, to the databases created by earlier
+// versions: the database is at version i+1 (PRAGMA user_version) once storageMigrations[i] is applied.
+// The tables created since are created by initStorageState, with their columns
+var storageMigrations = []struct {
+	table, column, definition string
+}{
+	{"messages", "msg_id", "TEXT"},                           // message editing and deletion (old messages can't be edited)
+	{"messages", "deleted", "BOOLEAN DEFAULT 0"},             // 1: the message was retracted by its author
+	{"group_convos", "engine", "TEXT DEFAULT 'sender_keys'"}, // how keys are agreed on
+	{"group_convos", "channel", "TEXT DEFAULT 'chat'"},       // or "issues" for an issue tracker
+}
+
 type storageState struct {
 	db *sql.DB
+
+	verificationsVersion uint64 // incremented when the verifications or the revocations change (see trust.go)
 }
 
 var storage storageState
@@ -36,6 +53,7 @@ func initStorageState() {
 	// - [0|blob] : we sent a contact request, blob is the serialized handshakeState
 	// - [1|blob] : we received a contact request, blob is the received handshake message
 	// - [2|empty] : we are done with the handshake, blob is empty
+	// - [3|blob] : we changed our key and sent a new contact request, blob is the serialized handshakeState
 	//
 	createStatement := `
 	CREATE TABLE IF NOT EXISTS contacts (
@@ -54,7 +72,7 @@ func initStorageState() {
 		date TIMESTAMP, 											-- when this verification was done
 		how TEXT, 														-- how this verification was done (facebook, twitter, irl, etc.)
 		name TEXT, 														-- the name used by the verifier to identify the public key
-		signature TEXT NOT NULL 							-- the actual public key
+		signature TEXT NOT NULL 							-- the signature of who, in hex (see serialization/verification.go)
 	);
 	CREATE TABLE IF NOT EXISTS conversations (
 		id TEXT NOT NULL, 										-- a 16-byte random value? TODO: outch? collisions?
@@ -70,16 +88,163 @@ func initStorageState() {
 		conversation_id TEXT NOT NULL , 			-- the conversation the message is part of
 		date TIMESTAMP, 											-- time the message was sent/received
 		senderIsMe BOOLEAN, 									-- 0: I sent the message, 1: I received the message
-		message BLOB 													-- the actual message 
+		message BLOB, 												-- the actual message 
+		msg_id TEXT, 													-- a 16-byte random value shared by both peers
+		deleted BOOLEAN 											-- 1: the message was retracted by its author
+	);
+	CREATE TABLE IF NOT EXISTS message_edits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, -- 
+		conversation_id TEXT NOT NULL, 				-- the conversation the edited message is part of
+		msg_id TEXT NOT NULL, 								-- the msg_id of the edited message
+		date TIMESTAMP, 											-- time the edit was sent/received
+		message BLOB 													-- the content of the message before the edit
+	);
+	CREATE TABLE IF NOT EXISTS attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, -- 
+		conversation_id TEXT NOT NULL, 				-- the conversation the attachment was sent in
+		msg_id TEXT NOT NULL, 								-- the msg_id of the message containing the attachment
+		name TEXT, 														-- the name of the file
+		size INTEGER, 												-- the size of the file
+		key BLOB, 														-- the key used to encrypt the file
+		hash BLOB, 														-- the hash of the file
+		blobs TEXT, 													-- the ids of the encrypted chunks on the hub (comma-separated)
+		path TEXT 														-- where the file is stored once downloaded
+	);
+	CREATE TABLE IF NOT EXISTS group_convos (
+		id TEXT NOT NULL UNIQUE, 							-- a 16-byte random value, the convo_id of group messages
+		title TEXT, 													-- the title of the group
+		date_creation TIMESTAMP, 							-- the date the group was created (or we joined it)
+		active BOOLEAN, 											-- 0: we have been removed from the group
+		generation INTEGER, 									-- the generation of our sender key
+		sender_key BLOB, 											-- our serialized strobe state to send messages to the group
+		engine TEXT, 													-- how keys are agreed on: "sender_keys" or "mls"
+		channel TEXT 													-- "chat", or "issues" for an issue tracker
+	);
+	CREATE TABLE IF NOT EXISTS group_members (
+		group_id TEXT NOT NULL, 							-- the group
+		publickey TEXT NOT NULL, 							-- the public key of the member
+		convo_id TEXT, 												-- our pairwise conversation with the member, for control messages
+		generation INTEGER, 									-- the generation of the member's sender key
+		sender_key BLOB, 											-- the member's serialized strobe state to receive its messages
+		UNIQUE(group_id, publickey)
+	);
+	CREATE TABLE IF NOT EXISTS issues (
+		id TEXT NOT NULL UNIQUE, 							-- the convo_id of the issue (its title is in conversations)
+		group_id TEXT NOT NULL, 							-- the issue tracker channel
+		author TEXT, 													-- the public key of who opened the issue
+		state TEXT, 													-- "open" or "closed"
+		labels TEXT, 													-- comma-separated
+		assignees TEXT, 											-- comma-separated public keys
+		date_update TIMESTAMP 								-- the last time the issue was updated
+	);
+	CREATE TABLE IF NOT EXISTS mls_groups (
+		group_id TEXT NOT NULL UNIQUE, 				-- the group
+		state BLOB 														-- our serialized MLSGroupState: ratchet tree, private keys, epoch secret, etc.
+	);
+	CREATE TABLE IF NOT EXISTS mls_key_packages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, -- 
+		publickey TEXT NOT NULL UNIQUE, 			-- the init key of a key package we published
+		private_key BLOB, 										-- its private key
+		date_creation TIMESTAMP 							-- when the key package was published
+	);
+	CREATE TABLE IF NOT EXISTS revocations (
+		publickey TEXT NOT NULL UNIQUE, 			-- a key revoked by our organization
+		date_revocation TIMESTAMP, 						-- when the organization revoked it
+		reason TEXT 													-- why the organization revoked it
+	);
+	CREATE TABLE IF NOT EXISTS tree_heads (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, -- 
+		tree_size INTEGER, 										-- the number of entries in the transparency log
+		tree_head BLOB, 											-- the serialized TreeHead signed by the Hub
+		date TIMESTAMP 												-- when we received it
+	);
+	CREATE TABLE IF NOT EXISTS log_alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, -- 
+		date TIMESTAMP, 											-- when the split view was detected
+		source TEXT, 													-- "hub", or the contact who gossiped the other tree head
+		reason TEXT, 													-- what went wrong
+		tree_head BLOB, 											-- the two conflicting tree heads signed by the Hub,
+		other_tree_head BLOB 									-- as a proof of the split view
+	);
+	CREATE TABLE IF NOT EXISTS gossip (
+		publickey TEXT NOT NULL UNIQUE, 			-- a contact
+		tree_size INTEGER 										-- the size of the last tree head we sent to the contact
+	);
+	CREATE TABLE IF NOT EXISTS key_rotations (
+		old_publickey TEXT NOT NULL UNIQUE, 	-- the previous key of a contact
+		new_publickey TEXT NOT NULL, 					-- its new key, signed by the previous one
+		date TIMESTAMP, 											-- when the contact signed its new key
+		statement BLOB, 											-- the serialized KeyRotationStatement, as a proof
+		state TEXT 														-- "pending", "handshake" or "done" (see rotation.go)
+	);
+	CREATE TABLE IF NOT EXISTS devices (
+		identity TEXT NOT NULL, 							-- the identity key of a contact (or ours)
+		publickey TEXT NOT NULL UNIQUE, 			-- the key of one of its devices
+		name TEXT, 														-- the name of the device
+		date TIMESTAMP, 											-- when the identity certified the device
+		certificate BLOB 											-- the serialized DeviceCertificate, as a proof
+	);
+	CREATE TABLE IF NOT EXISTS identity_contacts (
+		publickey TEXT NOT NULL UNIQUE, 			-- a contact of our identity, received when we were linked (see device.go)
+		name TEXT 														-- its name
+	);
+	CREATE TABLE IF NOT EXISTS convo_peers (
+		convo_id TEXT NOT NULL UNIQUE, 				-- a conversation shared with our devices and the devices of the contact
+		peer TEXT NOT NULL 										-- the identity key of the contact
+	);
+	CREATE TABLE IF NOT EXISTS contact_hubs (
+		publickey TEXT NOT NULL UNIQUE, 			-- a contact reachable on another Hub than ours (see hubpool.go)
+		hub TEXT NOT NULL 										-- the public key of that Hub in hex
 	);
 	`
 	if _, err := storage.db.Exec(createStatement); err != nil {
 		panic(err)
 	}
+	storage.migrate()
 
 	// defer db.Close() // we never close the db
 }
 
+// migrate applies the storageMigrations that the database doesn't have yet. The databases created before there
+// was a version can have some of the columns already, only the missing ones are added
+func (storage *storageState) migrate() {
+	var version int
+	if err := storage.db.QueryRow("PRAGMA user_version;").Scan(&version); err != nil {
+		panic(err)
+	}
+	for ; version < len(storageMigrations); version++ {
+		migration := storageMigrations[version]
+		if !storage.hasColumn(migration.table, migration.column) {
+			if _, err := storage.db.Exec("ALTER TABLE " + migration.table + " ADD COLUMN " + migration.column + " " + migration.definition + ";"); err != nil {
+				panic(err)
+			}
+		}
+	}
+	// PRAGMA doesn't take parameters
+	if _, err := storage.db.Exec(fmt.Sprintf("PRAGMA user_version = %d;", version)); err != nil {
+		panic(err)
+	}
+}
+
+// hasColumn returns true if a table has a column
+func (storage *storageState) hasColumn(table, column string) bool {
+	rows, err := storage.db.Query("SELECT name FROM pragma_table_info(?);", table)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			panic(err)
+		}
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
 func (storage *storageState) getMessages() {
 	selectStatement := "SELECT * FROM conversations;"
 	_, err := storage.db.Exec(selectStatement)
@@ -215,12 +380,12 @@ func (storage *storageState) storeMessage(msg *plaintextMsg) uint64 {
 	if msg.FromAddress != ssyk.myAddress {
 		senderIsMe = false
 	}
-	// messages (id INTEGER PRIMARY KEY AUTOINCREMENT, conversation_id INTEGER, date TIMESTAMP, senderIsMe TEXT, message BLOB)
-	stmt, err := storage.db.Prepare("INSERT INTO messages VALUES(NULL, ?, DATETIME('now'), ?, ?);")
+	// messages (id INTEGER PRIMARY KEY AUTOINCREMENT, conversation_id INTEGER, date TIMESTAMP, senderIsMe TEXT, message BLOB, msg_id TEXT, deleted BOOLEAN)
+	stmt, err := storage.db.Prepare("INSERT INTO messages(conversation_id, date, senderIsMe, message, msg_id, deleted) VALUES(?, DATETIME('now'), ?, ?, ?, 0);")
 	if err != nil {
 		panic(err)
 	}
-	res, err := stmt.Exec(msg.ConvoId, senderIsMe, msg.Content)
+	res, err := stmt.Exec(msg.ConvoId, senderIsMe, msg.Content, msg.Id)
 	if err != nil {
 		panic(err)
 	}
@@ -229,6 +394,222 @@ func (storage *storageState) storeMessage(msg *plaintextMsg) uint64 {
 	return uint64(id)
 }
 
+// getConversations returns our conversations with our contacts, the most recent first
+func (storage *storageState) getConversations() []conversationInfo {
+	// a conversation can have a row per device of the contact, and the contact is in convo_peers (see device.go)
+	stmt, err := storage.db.Prepare(`SELECT conversations.id, COALESCE(convo_peers.peer, conversations.publickey), COALESCE(contacts.name, ''), COALESCE(conversations.title, '')
+		FROM conversations LEFT JOIN convo_peers ON convo_peers.convo_id=conversations.id
+		LEFT JOIN contacts ON contacts.publickey=COALESCE(convo_peers.peer, conversations.publickey)
+		GROUP BY conversations.id ORDER BY (SELECT MAX(messages.id) FROM messages WHERE messages.conversation_id=conversations.id) DESC, conversations.date_creation DESC;`)
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query()
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	convos := []conversationInfo{}
+	for rows.Next() {
+		var convo conversationInfo
+		if err := rows.Scan(&convo.Id, &convo.Address, &convo.Name, &convo.Title); err != nil {
+			panic(err)
+		}
+		convos = append(convos, convo)
+	}
+	return convos
+}
+
+// getConvoMessages returns the last messages of a conversation, the oldest first
+func (storage *storageState) getConvoMessages(convoId string, limit int) []storedMsg {
+	stmt, err := storage.db.Prepare(`SELECT COALESCE(msg_id, ''), CAST(STRFTIME('%s', date) AS INTEGER), senderIsMe, COALESCE(message, ''), deleted
+		FROM messages WHERE conversation_id=? ORDER BY id DESC LIMIT ?;`)
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(convoId, limit)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	msgs := []storedMsg{}
+	for rows.Next() {
+		var msg storedMsg
+		if err := rows.Scan(&msg.Id, &msg.Date, &msg.SenderIsMe, &msg.Content, &msg.Deleted); err != nil {
+			panic(err)
+		}
+		msgs = append([]storedMsg{msg}, msgs...)
+	}
+	return msgs
+}
+
+// getContactRequests returns the keys that sent us a contact request we haven't accepted yet
+func (storage *storageState) getContactRequests() []string {
+	stmt, err := storage.db.Prepare("SELECT publickey, state FROM contacts;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query()
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		var state []byte
+		if err := rows.Scan(&key, &state); err != nil {
+			panic(err)
+		}
+		if len(state) > 0 && state[0] == 1 {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// getContacts returns all our contacts, with the step of the handshake they are at
+func (storage *storageState) getContacts() []contactInfo {
+	stmt, err := storage.db.Prepare(`SELECT contacts.publickey, COALESCE(contacts.name, ''), contacts.state, COALESCE(contact_hubs.hub, '')
+		FROM contacts LEFT JOIN contact_hubs ON contact_hubs.publickey=contacts.publickey ORDER BY contacts.id;`)
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query()
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	contacts := []contactInfo{}
+	for rows.Next() {
+		var contact contactInfo
+		var state []byte
+		if err := rows.Scan(&contact.Address, &contact.Name, &state, &contact.Hub); err != nil {
+			panic(err)
+		}
+		if len(state) > 0 && int(state[0]) < len(contactStateNames) {
+			contact.State = contactStateNames[state[0]]
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts
+}
+
+// getMessageAuthor returns true if we are the author of the message `msgId` in the conversation `convoId`.
+// The second value is false if the message doesn't exist or has been deleted
+func (storage *storageState) getMessageAuthor(convoId, msgId string) (bool, bool) {
+	stmt, err := storage.db.Prepare("SELECT senderIsMe FROM messages WHERE conversation_id=? AND msg_id=? AND deleted=0;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(convoId, msgId)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return false, false
+	}
+	var senderIsMe bool
+	if err := rows.Scan(&senderIsMe); err != nil {
+		panic(err)
+	}
+	return senderIsMe, true
+}
+
+// editMessage replaces the content of a message, the previous content is kept in `message_edits`
+func (storage *storageState) editMessage(convoId, msgId, content string) {
+	// save the current version of the message
+	stmt, err := storage.db.Prepare("INSERT INTO message_edits SELECT NULL, conversation_id, msg_id, DATETIME('now'), message FROM messages WHERE conversation_id=? AND msg_id=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(convoId, msgId); err != nil {
+		panic(err)
+	}
+	// update it
+	stmt, err = storage.db.Prepare("UPDATE messages SET message=? WHERE conversation_id=? AND msg_id=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(content, convoId, msgId); err != nil {
+		panic(err)
+	}
+}
+
+// deleteMessage retracts a message, its content and its edit history are erased
+func (storage *storageState) deleteMessage(convoId, msgId string) {
+	stmt, err := storage.db.Prepare("UPDATE messages SET message=NULL, deleted=1 WHERE conversation_id=? AND msg_id=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(convoId, msgId); err != nil {
+		panic(err)
+	}
+	stmt, err = storage.db.Prepare("DELETE FROM message_edits WHERE conversation_id=? AND msg_id=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(convoId, msgId); err != nil {
+		panic(err)
+	}
+	stmt, err = storage.db.Prepare("DELETE FROM attachments WHERE conversation_id=? AND msg_id=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(convoId, msgId); err != nil {
+		panic(err)
+	}
+}
+
+// storeAttachment stores the information needed to download and decrypt an attachment
+func (storage *storageState) storeAttachment(msg *plaintextMsg) {
+	// attachments (id INTEGER PRIMARY KEY AUTOINCREMENT, conversation_id TEXT, msg_id TEXT, name TEXT, size INTEGER, key BLOB, hash BLOB, blobs TEXT, path TEXT)
+	stmt, err := storage.db.Prepare("INSERT INTO attachments VALUES(NULL, ?, ?, ?, ?, ?, ?, ?, NULL);")
+	if err != nil {
+		panic(err)
+	}
+	att := msg.Attachment
+	if _, err = stmt.Exec(msg.ConvoId, msg.Id, att.Name, att.Size, att.Key, att.Hash, strings.Join(att.Blobs, ",")); err != nil {
+		panic(err)
+	}
+}
+
+// getAttachment returns an attachment and the location of the file if it has already been downloaded
+func (storage *storageState) getAttachment(convoId, msgId string) (*attachment, string, error) {
+	stmt, err := storage.db.Prepare("SELECT name, size, key, hash, blobs, path FROM attachments WHERE conversation_id=? AND msg_id=?;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(convoId, msgId)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, "", errors.New("ssyk: attachment does not exist")
+	}
+	att := &attachment{}
+	var blobs string
+	var path sql.NullString
+	if err := rows.Scan(&att.Name, &att.Size, &att.Key, &att.Hash, &blobs, &path); err != nil {
+		return nil, "", err
+	}
+	att.Blobs = strings.Split(blobs, ",")
+	return att, path.String, nil
+}
+
+// updateAttachmentPath records where an attachment was stored after download
+func (storage *storageState) updateAttachmentPath(convoId, msgId, path string) {
+	stmt, err := storage.db.Prepare("UPDATE attachments SET path=? WHERE conversation_id=? AND msg_id=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(path, convoId, msgId); err != nil {
+		panic(err)
+	}
+}
+
 func (storage *storageState) ConvoExist(convoId string) bool {
 	stmt, err := storage.db.Prepare("SELECT id FROM conversations WHERE id=? LIMIT 1;")
 	if err != nil {
@@ -248,8 +629,12 @@ const (
 	waitingForAccept                     // the contact has been added, waiting for 2nd handshake message
 	waitingToAccept                      // the contact has been added, waiting to send 2nd handshake message
 	contactAdded                         // the contact has been successfuly added
+	waitingForRekey                      // we changed our key, waiting for the 2nd handshake message (see rotation.go)
 )
 
+// contactStateNames are the steps of the handshake, indexed by the first byte of the state of a contact
+var contactStateNames = []string{"waiting_for_accept", "request", "added", "waiting_for_rekey"}
+
 // getStateContact returns nil if no contact has been added yet,
 // otherwise it returns the state (xxxxxx=waiting for answer, 1=all good)
 func (storage *storageState) getStateContact(bobAddress string) ([]byte, contactState) {
@@ -274,10 +659,13 @@ func (storage *storageState) getStateContact(bobAddress string) ([]byte, contact
 	// - [0|blob] : we sent a contact request, blob is the serialized handshakeState
 	// - [1|blob] : we received a contact request, blob is the received handshake message
 	// - [2|empty] : we are done with the handshake, blob is empty
+	// - [3|blob] : we changed our key and sent a new contact request, blob is the serialized handshakeState
 	if state[0] == 0 {
 		return state[1:], waitingForAccept
 	} else if state[0] == 1 {
 		return state[1:], waitingToAccept
+	} else if state[0] == 3 {
+		return state[1:], waitingForRekey
 	}
 
 	return nil, contactAdded
@@ -378,3 +766,1131 @@ func (storage *storageState) deleteContact(bobAddress string) error {
 	}
 	return nil
 }
+
+//
+// Groups
+//
+
+// groupMember is a row of the group_members table
+type groupMember struct {
+	publicKey  string
+	convoId    string
+	generation uint32
+	senderKey  []byte
+}
+
+// createGroup creates a group where we are active, with our sender key (nil for MLS groups)
+// (if we had left the group in the past, it is replaced)
+func (storage *storageState) createGroup(groupId, title, engine, channel string, generation uint32, senderKey []byte) {
+	// group_convos (id TEXT, title TEXT, date_creation TIMESTAMP, active BOOLEAN, generation INTEGER, sender_key BLOB, engine TEXT, channel TEXT)
+	stmt, err := storage.db.Prepare(`INSERT OR REPLACE INTO group_convos(id, title, date_creation, active, generation, sender_key, engine, channel)
+		VALUES(?, ?, DATETIME('now'), 1, ?, ?, ?, ?);`)
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(groupId, title, generation, senderKey, engine, channel); err != nil {
+		panic(err)
+	}
+}
+
+// getGroup returns the title, our sender key and its generation for an active group
+func (storage *storageState) getGroup(groupId string) (string, []byte, uint32, error) {
+	stmt, err := storage.db.Prepare("SELECT title, sender_key, generation FROM group_convos WHERE id=? AND active=1;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(groupId)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", nil, 0, errors.New("ssyk: group does not exist")
+	}
+	var title string
+	var senderKey []byte
+	var generation uint32
+	if err := rows.Scan(&title, &senderKey, &generation); err != nil {
+		return "", nil, 0, err
+	}
+	return title, senderKey, generation, nil
+}
+
+// getGroupEngine returns the engine of an active group
+func (storage *storageState) getGroupEngine(groupId string) (string, error) {
+	stmt, err := storage.db.Prepare("SELECT engine FROM group_convos WHERE id=? AND active=1;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(groupId)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", errors.New("ssyk: group does not exist")
+	}
+	var engine string
+	if err := rows.Scan(&engine); err != nil {
+		return "", err
+	}
+	return engine, nil
+}
+
+// getGroupChannel returns the type of channel of an active group: "chat" or "issues"
+func (storage *storageState) getGroupChannel(groupId string) (string, error) {
+	stmt, err := storage.db.Prepare("SELECT channel FROM group_convos WHERE id=? AND active=1;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(groupId)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", errors.New("ssyk: group does not exist")
+	}
+	var channel string
+	if err := rows.Scan(&channel); err != nil {
+		return "", err
+	}
+	return channel, nil
+}
+
+// getGroups returns all the groups we are part of
+func (storage *storageState) getGroups() []groupInfo {
+	stmt, err := storage.db.Prepare("SELECT id, title, engine, channel FROM group_convos WHERE active=1;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query()
+	if err != nil {
+		panic(err)
+	}
+	var groups []groupInfo
+	for rows.Next() {
+		var group groupInfo
+		if err := rows.Scan(&group.Id, &group.Title, &group.Engine, &group.Channel); err != nil {
+			panic(err)
+		}
+		groups = append(groups, group)
+	}
+	rows.Close()
+	// add the members
+	for i := range groups {
+		for _, member := range storage.getGroupMembers(groups[i].Id) {
+			groups[i].Members = append(groups[i].Members, member.publicKey)
+		}
+	}
+	return groups
+}
+
+// updateGroupSenderKey updates our sender key (and its generation) after sending a message or a rotation
+func (storage *storageState) updateGroupSenderKey(groupId string, generation uint32, senderKey []byte) {
+	stmt, err := storage.db.Prepare("UPDATE group_convos SET generation=?, sender_key=? WHERE id=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(generation, senderKey, groupId); err != nil {
+		panic(err)
+	}
+}
+
+// leaveGroup marks a group as inactive and forgets all the keys associated to it
+func (storage *storageState) leaveGroup(groupId string) {
+	stmt, err := storage.db.Prepare("UPDATE group_convos SET active=0, sender_key=NULL WHERE id=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(groupId); err != nil {
+		panic(err)
+	}
+	stmt, err = storage.db.Prepare("DELETE FROM group_members WHERE group_id=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(groupId); err != nil {
+		panic(err)
+	}
+	stmt, err = storage.db.Prepare("DELETE FROM mls_groups WHERE group_id=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(groupId); err != nil {
+		panic(err)
+	}
+}
+
+// getGroupMembers returns all the members of a group (except us)
+func (storage *storageState) getGroupMembers(groupId string) []groupMember {
+	stmt, err := storage.db.Prepare("SELECT publickey, convo_id, generation, sender_key FROM group_members WHERE group_id=?;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(groupId)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	var members []groupMember
+	for rows.Next() {
+		var member groupMember
+		var convoId sql.NullString
+		var generation sql.NullInt64
+		if err := rows.Scan(&member.publicKey, &convoId, &generation, &member.senderKey); err != nil {
+			panic(err)
+		}
+		member.convoId = convoId.String
+		member.generation = uint32(generation.Int64)
+		members = append(members, member)
+	}
+	return members
+}
+
+// getGroupMember returns a member of a group, or an error if it is not part of the group
+func (storage *storageState) getGroupMember(groupId, publicKey string) (*groupMember, error) {
+	for _, member := range storage.getGroupMembers(groupId) {
+		if member.publicKey == publicKey {
+			return &member, nil
+		}
+	}
+	return nil, errors.New("ssyk: not a member of the group")
+}
+
+// getGroupTitlesWithMember returns the titles of the groups a key is a member of
+func (storage *storageState) getGroupTitlesWithMember(publicKey string) []string {
+	stmt, err := storage.db.Prepare(`SELECT group_convos.title FROM group_members
+		JOIN group_convos ON group_convos.id=group_members.group_id
+		WHERE group_members.publickey=? AND group_convos.active=1;`)
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(publicKey)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	titles := []string{}
+	for rows.Next() {
+		var title string
+		if err := rows.Scan(&title); err != nil {
+			panic(err)
+		}
+		titles = append(titles, title)
+	}
+	return titles
+}
+
+// addGroupMember adds a member to a group, if the member is already there nothing happens
+func (storage *storageState) addGroupMember(groupId, publicKey string) {
+	stmt, err := storage.db.Prepare("INSERT OR IGNORE INTO group_members VALUES(?, ?, NULL, NULL, NULL);")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(groupId, publicKey); err != nil {
+		panic(err)
+	}
+}
+
+// removeGroupMember removes a member (and its sender key) from a group
+func (storage *storageState) removeGroupMember(groupId, publicKey string) {
+	stmt, err := storage.db.Prepare("DELETE FROM group_members WHERE group_id=? AND publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(groupId, publicKey); err != nil {
+		panic(err)
+	}
+}
+
+// updateGroupMemberConvo records the pairwise conversation we use to send control messages to a member
+func (storage *storageState) updateGroupMemberConvo(groupId, publicKey, convoId string) {
+	stmt, err := storage.db.Prepare("UPDATE group_members SET convo_id=? WHERE group_id=? AND publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(convoId, groupId, publicKey); err != nil {
+		panic(err)
+	}
+}
+
+// updateGroupMemberSenderKey updates the sender key of a member, after receiving a message or a rotation
+func (storage *storageState) updateGroupMemberSenderKey(groupId, publicKey string, generation uint32, senderKey []byte) {
+	stmt, err := storage.db.Prepare("UPDATE group_members SET generation=?, sender_key=? WHERE group_id=? AND publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(generation, senderKey, groupId, publicKey); err != nil {
+		panic(err)
+	}
+}
+
+//
+// Issues
+//
+
+// createIssue stores a new issue of an issue tracker channel (its conversation must be created first)
+func (storage *storageState) createIssue(groupId, author string, issue *issueInfo) {
+	// issues (id TEXT, group_id TEXT, author TEXT, state TEXT, labels TEXT, assignees TEXT, date_update TIMESTAMP)
+	stmt, err := storage.db.Prepare("INSERT INTO issues VALUES(?, ?, ?, ?, ?, ?, DATETIME('now'));")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(issue.Id, groupId, author, issue.State, strings.Join(issue.Labels, ","), strings.Join(issue.Assignees, ",")); err != nil {
+		panic(err)
+	}
+}
+
+// updateIssue replaces the state, the labels and the assignees of an issue
+func (storage *storageState) updateIssue(groupId string, issue *issueInfo) {
+	stmt, err := storage.db.Prepare("UPDATE issues SET state=?, labels=?, assignees=?, date_update=DATETIME('now') WHERE id=? AND group_id=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(issue.State, strings.Join(issue.Labels, ","), strings.Join(issue.Assignees, ","), issue.Id, groupId); err != nil {
+		panic(err)
+	}
+}
+
+// getIssue returns an issue of an issue tracker channel
+func (storage *storageState) getIssue(groupId, issueId string) (*issueInfo, error) {
+	for _, issue := range storage.getIssues(groupId) {
+		if issue.Id == issueId {
+			return &issue, nil
+		}
+	}
+	return nil, errors.New("ssyk: issue does not exist")
+}
+
+// getIssues returns all the issues of an issue tracker channel, the most recently updated first
+func (storage *storageState) getIssues(groupId string) []issueInfo {
+	stmt, err := storage.db.Prepare(`SELECT issues.id, conversations.title, issues.author, issues.state, issues.labels, issues.assignees
+		FROM issues JOIN conversations ON conversations.id=issues.id
+		WHERE issues.group_id=? ORDER BY issues.date_update DESC;`)
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(groupId)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	var issues []issueInfo
+	for rows.Next() {
+		var issue issueInfo
+		var labels, assignees string
+		if err := rows.Scan(&issue.Id, &issue.Title, &issue.Author, &issue.State, &labels, &assignees); err != nil {
+			panic(err)
+		}
+		issue.Labels = splitList(labels)
+		issue.Assignees = splitList(assignees)
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// splitList splits a comma-separated list, the empty string being the empty list
+func splitList(list string) []string {
+	if list == "" {
+		return []string{}
+	}
+	return strings.Split(list, ",")
+}
+
+//
+// MLS
+// ===
+//
+
+// getMLSState returns our serialized state in an MLS group
+func (storage *storageState) getMLSState(groupId string) ([]byte, error) {
+	stmt, err := storage.db.Prepare("SELECT state FROM mls_groups WHERE group_id=?;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(groupId)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, errors.New("ssyk: MLS group does not exist")
+	}
+	var state []byte
+	if err := rows.Scan(&state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// updateMLSState stores our serialized state in an MLS group, after every change of epoch or message
+func (storage *storageState) updateMLSState(groupId string, state []byte) {
+	stmt, err := storage.db.Prepare("INSERT OR REPLACE INTO mls_groups VALUES(?, ?);")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(groupId, state); err != nil {
+		panic(err)
+	}
+}
+
+// storeKeyPackage keeps the private key of a key package we publish, only the `max` most recent are kept
+// (the Hub keeps as many)
+func (storage *storageState) storeKeyPackage(publicKey string, privateKey []byte, max int) {
+	stmt, err := storage.db.Prepare("INSERT INTO mls_key_packages VALUES(NULL, ?, ?, DATETIME('now'));")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(publicKey, privateKey); err != nil {
+		panic(err)
+	}
+	stmt, err = storage.db.Prepare("DELETE FROM mls_key_packages WHERE id NOT IN (SELECT id FROM mls_key_packages ORDER BY id DESC LIMIT ?);")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(max); err != nil {
+		panic(err)
+	}
+}
+
+// getKeyPackage returns the private key of one of our key packages
+func (storage *storageState) getKeyPackage(publicKey string) ([]byte, error) {
+	stmt, err := storage.db.Prepare("SELECT private_key FROM mls_key_packages WHERE publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(publicKey)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, errors.New("ssyk: unknown key package")
+	}
+	var privateKey []byte
+	if err := rows.Scan(&privateKey); err != nil {
+		return nil, err
+	}
+	return privateKey, nil
+}
+
+// deleteKeyPackage forgets a key package once it has been used
+func (storage *storageState) deleteKeyPackage(publicKey string) {
+	stmt, err := storage.db.Prepare("DELETE FROM mls_key_packages WHERE publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(publicKey); err != nil {
+		panic(err)
+	}
+}
+
+//
+// Revocations
+//
+
+// storeRevocation remembers that our organization revoked a key (a contact or not)
+func (storage *storageState) storeRevocation(publicKey string, revokedAt int64, reason string) {
+	// revocations (publickey TEXT, date_revocation TIMESTAMP, reason TEXT)
+	stmt, err := storage.db.Prepare("INSERT OR IGNORE INTO revocations VALUES(?, DATETIME(?, 'unixepoch'), ?);")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(publicKey, revokedAt, reason); err != nil {
+		panic(err)
+	}
+	storage.verificationsVersion++
+}
+
+// getRevokedKeys returns all the keys revoked by our organization
+func (storage *storageState) getRevokedKeys() map[string]bool {
+	stmt, err := storage.db.Prepare("SELECT publickey FROM revocations;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query()
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	revoked := make(map[string]bool)
+	for rows.Next() {
+		var publicKey string
+		if err := rows.Scan(&publicKey); err != nil {
+			panic(err)
+		}
+		revoked[publicKey] = true
+	}
+	return revoked
+}
+
+// isRevoked returns true if our organization revoked the key
+func (storage *storageState) isRevoked(publicKey string) bool {
+	stmt, err := storage.db.Prepare("SELECT publickey FROM revocations WHERE publickey=? LIMIT 1;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(publicKey)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	return rows.Next()
+}
+
+// getRevokedContacts returns our contacts whose keys have been revoked, with their conversations
+func (storage *storageState) getRevokedContacts() []revokedContact {
+	stmt, err := storage.db.Prepare(`SELECT contacts.publickey, COALESCE(contacts.name, ''), CAST(STRFTIME('%s', revocations.date_revocation) AS INTEGER), revocations.reason
+		FROM contacts JOIN revocations ON revocations.publickey=contacts.publickey;`)
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query()
+	if err != nil {
+		panic(err)
+	}
+	var contacts []revokedContact
+	for rows.Next() {
+		var contact revokedContact
+		if err := rows.Scan(&contact.Address, &contact.Name, &contact.RevokedAt, &contact.Reason); err != nil {
+			panic(err)
+		}
+		contacts = append(contacts, contact)
+	}
+	rows.Close()
+	// add the conversations
+	for i := range contacts {
+		contacts[i].Conversations = storage.getConvoIds(contacts[i].Address)
+	}
+	return contacts
+}
+
+// getConvoIds returns the ids of our conversations with a contact
+func (storage *storageState) getConvoIds(bobAddress string) []string {
+	stmt, err := storage.db.Prepare("SELECT id FROM conversations WHERE publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(bobAddress)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	convoIds := []string{}
+	for rows.Next() {
+		var convoId string
+		if err := rows.Scan(&convoId); err != nil {
+			panic(err)
+		}
+		convoIds = append(convoIds, convoId)
+	}
+	return convoIds
+}
+
+// getContactName returns the name we gave to a contact
+func (storage *storageState) getContactName(bobAddress string) (string, error) {
+	stmt, err := storage.db.Prepare("SELECT COALESCE(name, '') FROM contacts WHERE publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(bobAddress)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", errors.New("ssyk: contact does not exist")
+	}
+	var name string
+	if err := rows.Scan(&name); err != nil {
+		panic(err)
+	}
+	return name, nil
+}
+
+//
+// Transparency Log
+//
+
+// storeTreeHead stores the latest tree head of the transparency log
+func (storage *storageState) storeTreeHead(treeSize uint64, treeHead []byte) {
+	// tree_heads (id INTEGER PRIMARY KEY AUTOINCREMENT, tree_size INTEGER, tree_head BLOB, date TIMESTAMP)
+	stmt, err := storage.db.Prepare("INSERT INTO tree_heads VALUES(NULL, ?, ?, DATETIME('now'));")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(treeSize, treeHead); err != nil {
+		panic(err)
+	}
+}
+
+// getTreeHead returns the latest tree head of the transparency log, nil if we don't have any
+func (storage *storageState) getTreeHead() []byte {
+	stmt, err := storage.db.Prepare("SELECT tree_head FROM tree_heads ORDER BY id DESC LIMIT 1;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query()
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil
+	}
+	var treeHead []byte
+	if err := rows.Scan(&treeHead); err != nil {
+		panic(err)
+	}
+	return treeHead
+}
+
+// storeLogAlert records a split view, with the two tree heads that prove it
+func (storage *storageState) storeLogAlert(source, reason string, treeHead, otherTreeHead []byte) {
+	// log_alerts (id INTEGER PRIMARY KEY AUTOINCREMENT, date TIMESTAMP, source TEXT, reason TEXT, tree_head BLOB, other_tree_head BLOB)
+	stmt, err := storage.db.Prepare("INSERT INTO log_alerts VALUES(NULL, DATETIME('now'), ?, ?, ?, ?);")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(source, reason, treeHead, otherTreeHead); err != nil {
+		panic(err)
+	}
+}
+
+// getLogAlerts returns all the split views detected
+func (storage *storageState) getLogAlerts() []logAlert {
+	stmt, err := storage.db.Prepare("SELECT date, source, reason FROM log_alerts ORDER BY id;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query()
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	alerts := []logAlert{}
+	for rows.Next() {
+		var alert logAlert
+		if err := rows.Scan(&alert.Date, &alert.Source, &alert.Reason); err != nil {
+			panic(err)
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// getGossipedSize returns the size of the last tree head we sent to a contact
+func (storage *storageState) getGossipedSize(bobAddress string) uint64 {
+	stmt, err := storage.db.Prepare("SELECT tree_size FROM gossip WHERE publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(bobAddress)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return 0
+	}
+	var treeSize uint64
+	if err := rows.Scan(&treeSize); err != nil {
+		panic(err)
+	}
+	return treeSize
+}
+
+// updateGossipedSize remembers the size of the last tree head we sent to a contact
+func (storage *storageState) updateGossipedSize(bobAddress string, treeSize uint64) {
+	stmt, err := storage.db.Prepare("INSERT OR REPLACE INTO gossip VALUES(?, ?);")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(bobAddress, treeSize); err != nil {
+		panic(err)
+	}
+}
+
+//
+// Verifications
+//
+
+// storeVerification stores a verification proof (replacing the previous one of the same verifier)
+func (storage *storageState) storeVerification(v *verification) {
+	stmt, err := storage.db.Prepare("DELETE FROM verifications WHERE publickey=? AND who=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(v.PublicKey, v.Who); err != nil {
+		panic(err)
+	}
+	// verifications (id INTEGER PRIMARY KEY AUTOINCREMENT, publickey TEXT, who TEXT, date TIMESTAMP, how TEXT, name TEXT, signature TEXT)
+	stmt, err = storage.db.Prepare("INSERT INTO verifications VALUES(NULL, ?, ?, DATETIME(?, 'unixepoch'), ?, ?, ?);")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(v.PublicKey, v.Who, v.Date, v.How, v.Name, hex.EncodeToString(v.Signature)); err != nil {
+		panic(err)
+	}
+	storage.verificationsVersion++
+}
+
+// getVerifications returns the verifications of a key
+func (storage *storageState) getVerifications(publicKey string) []verification {
+	stmt, err := storage.db.Prepare(`SELECT publickey, who, CAST(STRFTIME('%s', date) AS INTEGER), how, name, signature
+		FROM verifications WHERE publickey=? ORDER BY date DESC;`)
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(publicKey)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	verifications := []verification{}
+	for rows.Next() {
+		var v verification
+		var signature string
+		if err := rows.Scan(&v.PublicKey, &v.Who, &v.Date, &v.How, &v.Name, &signature); err != nil {
+			panic(err)
+		}
+		if v.Signature, err = hex.DecodeString(signature); err != nil {
+			panic(err)
+		}
+		verifications = append(verifications, v)
+	}
+	return verifications
+}
+
+// getAllVerifications returns all the verifications we know of (without their signatures), for the web of trust
+func (storage *storageState) getAllVerifications() []verification {
+	stmt, err := storage.db.Prepare("SELECT publickey, who, CAST(STRFTIME('%s', date) AS INTEGER), how, name FROM verifications;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query()
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	verifications := []verification{}
+	for rows.Next() {
+		var v verification
+		if err := rows.Scan(&v.PublicKey, &v.Who, &v.Date, &v.How, &v.Name); err != nil {
+			panic(err)
+		}
+		verifications = append(verifications, v)
+	}
+	return verifications
+}
+
+// getVerifiedBy returns the keys verified by someone
+func (storage *storageState) getVerifiedBy(who string) []string {
+	stmt, err := storage.db.Prepare("SELECT publickey FROM verifications WHERE who=?;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(who)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			panic(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+//
+// Key Rotations
+//
+
+// storeKeyRotation stores the new key of a contact, signed by its previous key
+func (storage *storageState) storeKeyRotation(oldAddress, newAddress string, date int64, statement []byte) {
+	// key_rotations (old_publickey TEXT, new_publickey TEXT, date TIMESTAMP, statement BLOB, state TEXT)
+	stmt, err := storage.db.Prepare("INSERT INTO key_rotations VALUES(?, ?, DATETIME(?, 'unixepoch'), ?, ?);")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(oldAddress, newAddress, date, statement, keyChangePending); err != nil {
+		panic(err)
+	}
+}
+
+// getKeyRotation returns the key change of a contact, from its previous key or from its new key
+func (storage *storageState) getKeyRotation(address string, fromNewKey bool) (*keyChange, error) {
+	column := "old_publickey"
+	if fromNewKey {
+		column = "new_publickey"
+	}
+	stmt, err := storage.db.Prepare(`SELECT key_rotations.old_publickey, key_rotations.new_publickey, COALESCE(contacts.name, ''),
+		CAST(STRFTIME('%s', key_rotations.date) AS INTEGER), key_rotations.state
+		FROM key_rotations LEFT JOIN contacts ON contacts.publickey=key_rotations.old_publickey WHERE key_rotations.` + column + `=?;`)
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(address)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, errors.New("ssyk: this contact has not changed its key")
+	}
+	var change keyChange
+	if err := rows.Scan(&change.OldAddress, &change.NewAddress, &change.Name, &change.Date, &change.State); err != nil {
+		panic(err)
+	}
+	return &change, nil
+}
+
+// getKeyRotations returns the key changes that are not done yet, for the UI
+func (storage *storageState) getKeyRotations() []keyChange {
+	stmt, err := storage.db.Prepare(`SELECT key_rotations.old_publickey, key_rotations.new_publickey, COALESCE(contacts.name, ''),
+		CAST(STRFTIME('%s', key_rotations.date) AS INTEGER), key_rotations.state
+		FROM key_rotations LEFT JOIN contacts ON contacts.publickey=key_rotations.old_publickey WHERE key_rotations.state!=?;`)
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(keyChangeDone)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	changes := []keyChange{}
+	for rows.Next() {
+		var change keyChange
+		if err := rows.Scan(&change.OldAddress, &change.NewAddress, &change.Name, &change.Date, &change.State); err != nil {
+			panic(err)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// updateKeyRotationState moves a key change to its next step
+func (storage *storageState) updateKeyRotationState(oldAddress, state string) {
+	stmt, err := storage.db.Prepare("UPDATE key_rotations SET state=? WHERE old_publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(state, oldAddress); err != nil {
+		panic(err)
+	}
+}
+
+// getContactsByName returns the keys of our contacts with a given name
+func (storage *storageState) getContactsByName(name string) []string {
+	stmt, err := storage.db.Prepare("SELECT publickey FROM contacts WHERE name=?;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(name)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			panic(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// getAddedContacts returns the keys of the contacts we have finished a handshake with
+func (storage *storageState) getAddedContacts() []string {
+	stmt, err := storage.db.Prepare("SELECT publickey, state FROM contacts;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query()
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		var state []byte
+		if err := rows.Scan(&key, &state); err != nil {
+			panic(err)
+		}
+		if len(state) > 0 && state[0] == 2 {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// rekeyContact starts a new handshake with a contact, after we changed our key
+func (storage *storageState) rekeyContact(bobAddress string, serializedHandshakeState []byte) {
+	// contacts (id INTEGER PRIMARY KEY AUTOINCREMENT, publickey TEXT, date TIMESTAMP, name TEXT, state BLOB, c1 BLOB, c2 BLOB);
+	stmt, err := storage.db.Prepare("UPDATE contacts SET state=?, c1=NULL, c2=NULL WHERE publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(append([]byte{3}, serializedHandshakeState...), bobAddress); err != nil {
+		panic(err)
+	}
+}
+
+// moveConvo moves a conversation to the new key of a contact, with new session keys
+func (storage *storageState) moveConvo(convoId, oldAddress, newAddress string, c1, c2 []byte) {
+	stmt, err := storage.db.Prepare("UPDATE conversations SET publickey=?, c1=?, c2=? WHERE id=? AND publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(newAddress, c1, c2, convoId, oldAddress); err != nil {
+		panic(err)
+	}
+}
+
+// deleteRotatedContact removes the previous key of a contact, once its conversations have been moved
+func (storage *storageState) deleteRotatedContact(oldAddress string) {
+	for _, query := range []string{"DELETE FROM contacts WHERE publickey=?;", "DELETE FROM gossip WHERE publickey=?;"} {
+		stmt, err := storage.db.Prepare(query)
+		if err != nil {
+			panic(err)
+		}
+		if _, err = stmt.Exec(oldAddress); err != nil {
+			panic(err)
+		}
+	}
+}
+
+//
+// Devices
+//
+
+// replaceDevices replaces the devices we know for an identity
+func (storage *storageState) replaceDevices(identity string, devices []deviceInfo, certificates [][]byte) {
+	stmt, err := storage.db.Prepare("DELETE FROM devices WHERE identity=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(identity); err != nil {
+		panic(err)
+	}
+	for i, device := range devices {
+		storage.addDevice(device, certificates[i])
+	}
+}
+
+// addDevice stores a device of an identity
+func (storage *storageState) addDevice(device deviceInfo, certificate []byte) {
+	// devices (identity TEXT, publickey TEXT, name TEXT, date TIMESTAMP, certificate BLOB)
+	stmt, err := storage.db.Prepare("INSERT OR REPLACE INTO devices VALUES(?, ?, ?, DATETIME(?, 'unixepoch'), ?);")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(device.Identity, device.Address, device.Name, device.Date, certificate); err != nil {
+		panic(err)
+	}
+}
+
+// getDevices returns the devices of an identity
+func (storage *storageState) getDevices(identity string) []deviceInfo {
+	stmt, err := storage.db.Prepare("SELECT identity, publickey, COALESCE(name, ''), CAST(STRFTIME('%s', date) AS INTEGER) FROM devices WHERE identity=?;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(identity)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	devices := []deviceInfo{}
+	for rows.Next() {
+		var device deviceInfo
+		if err := rows.Scan(&device.Identity, &device.Address, &device.Name, &device.Date); err != nil {
+			panic(err)
+		}
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+// getDeviceIdentity returns the identity a device belongs to
+func (storage *storageState) getDeviceIdentity(device string) (string, bool) {
+	stmt, err := storage.db.Prepare("SELECT identity FROM devices WHERE publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(device)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", false
+	}
+	var identity string
+	if err := rows.Scan(&identity); err != nil {
+		panic(err)
+	}
+	return identity, true
+}
+
+// deleteDevice forgets a device
+func (storage *storageState) deleteDevice(device string) {
+	stmt, err := storage.db.Prepare("DELETE FROM devices WHERE publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(device); err != nil {
+		panic(err)
+	}
+}
+
+// storeIdentityContact stores a contact of our identity, received from our primary device
+func (storage *storageState) storeIdentityContact(address, name string) {
+	stmt, err := storage.db.Prepare("INSERT OR REPLACE INTO identity_contacts VALUES(?, ?);")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(address, name); err != nil {
+		panic(err)
+	}
+}
+
+// getIdentityContacts returns the contacts of our identity, received from our primary device
+func (storage *storageState) getIdentityContacts() []contactInfo {
+	stmt, err := storage.db.Prepare("SELECT publickey, COALESCE(name, '') FROM identity_contacts;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query()
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	contacts := []contactInfo{}
+	for rows.Next() {
+		var contact contactInfo
+		if err := rows.Scan(&contact.Address, &contact.Name); err != nil {
+			panic(err)
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts
+}
+
+// setConvoPeer records the contact a conversation is with, if it isn't known yet
+func (storage *storageState) setConvoPeer(convoId, peer string) {
+	stmt, err := storage.db.Prepare("INSERT OR IGNORE INTO convo_peers VALUES(?, ?);")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(convoId, peer); err != nil {
+		panic(err)
+	}
+}
+
+// getConvoPeer returns the contact a conversation is with
+func (storage *storageState) getConvoPeer(convoId string) (string, bool) {
+	stmt, err := storage.db.Prepare(`SELECT COALESCE(convo_peers.peer, conversations.publickey)
+		FROM conversations LEFT JOIN convo_peers ON convo_peers.convo_id=conversations.id WHERE conversations.id=? LIMIT 1;`)
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(convoId)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", false
+	}
+	var peer string
+	if err := rows.Scan(&peer); err != nil {
+		panic(err)
+	}
+	return peer, true
+}
+
+// hasSession returns true if we have session keys for a conversation with a device
+func (storage *storageState) hasSession(convoId, address string) bool {
+	stmt, err := storage.db.Prepare("SELECT id FROM conversations WHERE id=? AND publickey=? LIMIT 1;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(convoId, address)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	return rows.Next()
+}
+
+// getConvoTitle returns the title of a conversation
+func (storage *storageState) getConvoTitle(convoId string) string {
+	stmt, err := storage.db.Prepare("SELECT COALESCE(title, '') FROM conversations WHERE id=? LIMIT 1;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(convoId)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return ""
+	}
+	var title string
+	if err := rows.Scan(&title); err != nil {
+		panic(err)
+	}
+	return title
+}
+
+// setContactHub records the Hub a contact is reachable on, an empty hub means ours
+func (storage *storageState) setContactHub(address, hub string) {
+	if hub == "" {
+		stmt, err := storage.db.Prepare("DELETE FROM contact_hubs WHERE publickey=?;")
+		if err != nil {
+			panic(err)
+		}
+		if _, err = stmt.Exec(address); err != nil {
+			panic(err)
+		}
+		return
+	}
+	stmt, err := storage.db.Prepare("INSERT OR REPLACE INTO contact_hubs VALUES(?, ?);")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = stmt.Exec(address, hub); err != nil {
+		panic(err)
+	}
+}
+
+// getContactHub returns the public key of the Hub a contact is reachable on, or an empty string for ours
+func (storage *storageState) getContactHub(address string) string {
+	stmt, err := storage.db.Prepare("SELECT hub FROM contact_hubs WHERE publickey=?;")
+	if err != nil {
+		panic(err)
+	}
+	rows, err := stmt.Query(address)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return ""
+	}
+	var hub string
+	if err := rows.Scan(&hub); err != nil {
+		panic(err)
+	}
+	return hub
+}
@@ -0,0 +1,184 @@
+//
+// Transparency Log
+// ================
+//
+// The Hub of our organization keeps an append-only log of the membership certificates and revocations
+// (see docs/gossip.md and server/transparency.go). We store the latest tree head signed by the Hub, and:
+//
+// * every time we fetch the log, we check that it is consistent with the tree head we have
+// * we verify the inclusion of every new entry, and apply the revocations it contains
+// * we send our tree head to our contacts (in gossip messages), and check theirs against ours
+//
+// If two tree heads signed by the Hub are not two versions of the same log, the Hub is showing different
+// logs to different people (a split view), for example to hide a revocation. We record both tree heads
+// as a proof, and the UI warns the user.
+//
+// The UI also warns while the log can't be updated (the Hub is unreachable, or returns entries or proofs
+// that don't verify): the revocations we have may then be out of date.
+//
+package main
+
+import (
+	"errors"
+	"log"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+	"github.com/mimoo/sasayaki/transparency"
+)
+
+// latestTreeHead returns the latest tree head we have (an empty log if we don't have any)
+func latestTreeHead() *s.TreeHead {
+	treeHead := &s.TreeHead{}
+	if serialized := storage.getTreeHead(); serialized != nil {
+		if err := proto.Unmarshal(serialized, treeHead); err != nil {
+			panic(err)
+		}
+	}
+	return treeHead
+}
+
+// updateLog fetches the latest tree head of the Hub, checks that it is consistent with ours, and applies
+// the new entries of the log. storage.queryMutex must be held
+func (ss sasayakiState) updateLog() error {
+	current := latestTreeHead()
+	treeHead, err := hub.getTreeHead()
+	if err != nil {
+		return err
+	}
+	if err := transparency.VerifyTreeHead(hub.hubPublicKey, treeHead); err != nil {
+		return err
+	}
+	if err := ss.checkConsistency("hub", current, treeHead); err != nil {
+		return err
+	}
+	// read the new entries
+	for index := current.GetTreeSize(); index < treeHead.GetTreeSize(); {
+		entries, err := hub.getLogEntries(index, treeHead.GetTreeSize())
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return errors.New("ssyk: the Hub didn't return the entries of the log")
+		}
+		for _, entry := range entries {
+			if entry.GetIndex() != index || !transparency.VerifyInclusion(entry.GetEntry(), index,
+				treeHead.GetTreeSize(), entry.GetProof(), treeHead.GetRootHash()) {
+				return errors.New("ssyk: the Hub returned an entry that is not in the log")
+			}
+			ss.applyLogEntry(entry.GetEntry())
+			index++
+		}
+	}
+	// store the new tree head
+	if storage.getTreeHead() == nil || treeHead.GetTreeSize() > current.GetTreeSize() {
+		serialized, err := proto.Marshal(treeHead)
+		if err != nil {
+			panic(err)
+		}
+		storage.storeTreeHead(treeHead.GetTreeSize(), serialized)
+	}
+	return nil
+}
+
+// applyLogEntry stores the revocations of the log, certificates are only logged so that they can be audited
+func (ss sasayakiState) applyLogEntry(serialized []byte) {
+	entry := &s.LogEntry{}
+	if err := proto.Unmarshal(serialized, entry); err != nil {
+		log.Println("the log contains a malformed entry:", err)
+		return
+	}
+	if entry.GetRevocation() != nil {
+		ss.storeRevocation(entry.GetRevocation())
+	}
+}
+
+// checkConsistency makes sure that two tree heads signed by the Hub are two versions of the same log,
+// the first one being the oldest. Otherwise, a split view is recorded with both tree heads
+func (ss sasayakiState) checkConsistency(source string, first, second *s.TreeHead) error {
+	var proof [][]byte
+	if first.GetTreeSize() < second.GetTreeSize() && first.GetTreeSize() > 0 {
+		var err error
+		if proof, err = hub.getConsistencyProof(first.GetTreeSize(), second.GetTreeSize()); err != nil {
+			return err
+		}
+	}
+	if !transparency.VerifyConsistency(first.GetTreeSize(), second.GetTreeSize(), first.GetRootHash(), second.GetRootHash(), proof) {
+		ss.recordSplitView(source, "the tree heads are not consistent", first, second)
+		return errors.New("ssyk: the Hub is showing different versions of the transparency log")
+	}
+	return nil
+}
+
+// recordSplitView stores the two tree heads proving that the Hub misbehaved
+func (ss sasayakiState) recordSplitView(source, reason string, treeHead, otherTreeHead *s.TreeHead) {
+	log.Println("split view detected:", source, reason)
+	serialized, err := proto.Marshal(treeHead)
+	if err != nil {
+		panic(err)
+	}
+	otherSerialized, err := proto.Marshal(otherTreeHead)
+	if err != nil {
+		panic(err)
+	}
+	storage.storeLogAlert(source, reason, serialized, otherSerialized)
+}
+
+// gossipTreeHead sends our latest tree head to a contact, if we haven't already
+func (ss sasayakiState) gossipTreeHead(convoId, bobAddress string) error {
+	serialized := storage.getTreeHead()
+	if serialized == nil {
+		return nil
+	}
+	treeHead := latestTreeHead()
+	if storage.getGossipedSize(bobAddress) >= treeHead.GetTreeSize() {
+		return nil
+	}
+	_, err := ss.send(&plaintextMsg{
+		ConvoId:     convoId,
+		FromAddress: ss.myAddress,
+		ToAddress:   bobAddress,
+		Type:        gossipMsg,
+		TreeHead:    serialized,
+	})
+	if err != nil {
+		return err
+	}
+	storage.updateGossipedSize(bobAddress, treeHead.GetTreeSize())
+	return nil
+}
+
+// handleGossip checks the tree head of a contact against ours
+func (ss sasayakiState) handleGossip(msg *plaintextMsg) error {
+	theirs := &s.TreeHead{}
+	if err := proto.Unmarshal(msg.TreeHead, theirs); err != nil {
+		return errors.New("ssyk: gossip message is malformed")
+	}
+	// the contact might use another Hub
+	if err := transparency.VerifyTreeHead(hub.hubPublicKey, theirs); err != nil {
+		log.Println("contact gossiped a tree head we can't verify:", err)
+		return nil
+	}
+	// make sure we are up to date
+	ours := latestTreeHead()
+	if theirs.GetTreeSize() > ours.GetTreeSize() {
+		if err := ss.updateLog(); err != nil {
+			return err
+		}
+		ours = latestTreeHead()
+	}
+	if theirs.GetTreeSize() > ours.GetTreeSize() {
+		ss.recordSplitView(msg.FromAddress, "the Hub signed a larger log than the one it shows us", ours, theirs)
+		return errors.New("ssyk: the Hub is showing different versions of the transparency log")
+	}
+	return ss.checkConsistency(msg.FromAddress, theirs, ours)
+}
+
+// getLogStatus returns our latest tree head, the split views detected and why the log can't be updated
+// (nil if it can), for the UI
+func (ss sasayakiState) getLogStatus() (*s.TreeHead, []logAlert, error) {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	ss.refreshRevocations()
+	return latestTreeHead(), storage.getLogAlerts(), logUpdateError
+}
@@ -0,0 +1,184 @@
+//
+// Merkle Trees
+// ============
+//
+// The append-only Merkle tree of the transparency log (see docs/gossip.md), as specified by
+// Certificate Transparency (RFC 6962): leaves are hashed as SHA-256(0x00 || entry) and nodes as
+// SHA-256(0x01 || left || right), the left subtree always being the largest power of two.
+//
+// The Hub builds the tree and produces the proofs, clients only need to verify them.
+//
+package transparency
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// LeafHash returns the hash of an entry of the log
+func LeafHash(entry []byte) []byte {
+	hash := sha256.Sum256(append([]byte{0}, entry...))
+	return hash[:]
+}
+
+// nodeHash returns the hash of an internal node of the tree
+func nodeHash(left, right []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte{1})
+	hash.Write(left)
+	hash.Write(right)
+	return hash.Sum(nil)
+}
+
+// split returns the largest power of two smaller than n (n > 1)
+func split(n uint64) uint64 {
+	k := uint64(1)
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// Tree is an append-only Merkle tree, it only keeps the hashes of its leaves
+type Tree struct {
+	leaves [][]byte
+}
+
+// Append adds an entry to the tree and returns its index
+func (tree *Tree) Append(entry []byte) uint64 {
+	tree.leaves = append(tree.leaves, LeafHash(entry))
+	return uint64(len(tree.leaves) - 1)
+}
+
+// Size returns the number of entries in the tree
+func (tree *Tree) Size() uint64 {
+	return uint64(len(tree.leaves))
+}
+
+// Root returns the root hash of the tree when it had `size` entries
+func (tree *Tree) Root(size uint64) []byte {
+	return root(tree.leaves[:size])
+}
+
+// InclusionProof returns the audit path of the entry `index` in the tree of `size` entries
+func (tree *Tree) InclusionProof(index, size uint64) [][]byte {
+	return inclusionProof(index, tree.leaves[:size])
+}
+
+// ConsistencyProof proves that the tree of `first` entries is a prefix of the tree of `second` entries
+func (tree *Tree) ConsistencyProof(first, second uint64) [][]byte {
+	if first == 0 || first >= second {
+		return nil
+	}
+	return subProof(first, tree.leaves[:second], true)
+}
+
+func root(leaves [][]byte) []byte {
+	switch n := uint64(len(leaves)); n {
+	case 0:
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	case 1:
+		return leaves[0]
+	default:
+		k := split(n)
+		return nodeHash(root(leaves[:k]), root(leaves[k:]))
+	}
+}
+
+func inclusionProof(index uint64, leaves [][]byte) [][]byte {
+	n := uint64(len(leaves))
+	if n <= 1 {
+		return nil
+	}
+	k := split(n)
+	if index < k {
+		return append(inclusionProof(index, leaves[:k]), root(leaves[k:]))
+	}
+	return append(inclusionProof(index-k, leaves[k:]), root(leaves[:k]))
+}
+
+func subProof(m uint64, leaves [][]byte, complete bool) [][]byte {
+	n := uint64(len(leaves))
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][]byte{root(leaves)}
+	}
+	k := split(n)
+	if m <= k {
+		return append(subProof(m, leaves[:k], complete), root(leaves[k:]))
+	}
+	return append(subProof(m-k, leaves[k:], false), root(leaves[:k]))
+}
+
+// VerifyInclusion checks that an entry is the entry `index` of the tree of `size` entries with root `rootHash`
+func VerifyInclusion(entry []byte, index, size uint64, proof [][]byte, rootHash []byte) bool {
+	if index >= size {
+		return false
+	}
+	fn, sn := index, size-1
+	r := LeafHash(entry)
+	for _, p := range proof {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && bytes.Equal(r, rootHash)
+}
+
+// VerifyConsistency checks that the tree of `first` entries with root `firstRoot` is a prefix of the
+// tree of `second` entries with root `secondRoot`
+func VerifyConsistency(first, second uint64, firstRoot, secondRoot []byte, proof [][]byte) bool {
+	switch {
+	case first > second:
+		return false
+	case first == 0:
+		// the empty tree is a prefix of every tree
+		return len(proof) == 0
+	case first == second:
+		return len(proof) == 0 && bytes.Equal(firstRoot, secondRoot)
+	case len(proof) == 0:
+		return false
+	}
+	// if the first tree is complete, its root is the first node of the path
+	if first&(first-1) == 0 {
+		proof = append([][]byte{firstRoot}, proof...)
+	}
+	fn, sn := first-1, second-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = nodeHash(c, fr)
+			sr = nodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = nodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && bytes.Equal(fr, firstRoot) && bytes.Equal(sr, secondRoot)
+}
@@ -0,0 +1,172 @@
+package transparency
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// the test vectors of Certificate Transparency (RFC 6962)
+var (
+	testLeaves = [][]byte{
+		{},
+		{0x00},
+		{0x10},
+		{0x20, 0x21},
+		{0x30, 0x31},
+		{0x40, 0x41, 0x42, 0x43},
+		{0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57},
+		{0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c, 0x6d, 0x6e, 0x6f},
+	}
+
+	testRoots = []string{
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+
+	testInclusionProofs = []struct {
+		index, size uint64
+		proof       []string
+	}{
+		{0, 1, nil},
+		{0, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{5, 8, []string{
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 3, []string{
+			"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		}},
+		{1, 5, []string{
+			"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	}
+
+	testConsistencyProofs = []struct {
+		first, second uint64
+		proof         []string
+	}{
+		{1, 1, nil},
+		{1, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{6, 8, []string{
+			"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 5, []string{
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	}
+)
+
+func testTree() *Tree {
+	tree := &Tree{}
+	for _, leaf := range testLeaves {
+		tree.Append(leaf)
+	}
+	return tree
+}
+
+func decodeProof(t *testing.T, proof []string) [][]byte {
+	var decoded [][]byte
+	for _, node := range proof {
+		hash, err := hex.DecodeString(node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded = append(decoded, hash)
+	}
+	return decoded
+}
+
+func equalProofs(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRoot(t *testing.T) {
+	tree := testTree()
+	for size, expected := range testRoots {
+		if root := hex.EncodeToString(tree.Root(uint64(size))); root != expected {
+			t.Errorf("root of %d entries: got %s, expected %s", size, root, expected)
+		}
+	}
+}
+
+func TestInclusionProof(t *testing.T) {
+	tree := testTree()
+	for _, testCase := range testInclusionProofs {
+		expected := decodeProof(t, testCase.proof)
+		proof := tree.InclusionProof(testCase.index, testCase.size)
+		if !equalProofs(proof, expected) {
+			t.Errorf("inclusion proof of %d in %d entries: got %x", testCase.index, testCase.size, proof)
+		}
+		root := tree.Root(testCase.size)
+		if !VerifyInclusion(testLeaves[testCase.index], testCase.index, testCase.size, proof, root) {
+			t.Errorf("inclusion proof of %d in %d entries doesn't verify", testCase.index, testCase.size)
+		}
+		// another entry, index or root must fail
+		if VerifyInclusion([]byte("other"), testCase.index, testCase.size, proof, root) {
+			t.Errorf("inclusion proof of %d in %d entries verifies another entry", testCase.index, testCase.size)
+		}
+		if testCase.size > 1 && VerifyInclusion(testLeaves[testCase.index], testCase.index^1, testCase.size, proof, root) {
+			t.Errorf("inclusion proof of %d in %d entries verifies another index", testCase.index, testCase.size)
+		}
+		if VerifyInclusion(testLeaves[testCase.index], testCase.index, testCase.size, proof, LeafHash([]byte("other"))) {
+			t.Errorf("inclusion proof of %d in %d entries verifies another root", testCase.index, testCase.size)
+		}
+	}
+}
+
+func TestConsistencyProof(t *testing.T) {
+	tree := testTree()
+	for _, testCase := range testConsistencyProofs {
+		expected := decodeProof(t, testCase.proof)
+		proof := tree.ConsistencyProof(testCase.first, testCase.second)
+		if !equalProofs(proof, expected) {
+			t.Errorf("consistency proof from %d to %d entries: got %x", testCase.first, testCase.second, proof)
+		}
+		firstRoot, secondRoot := tree.Root(testCase.first), tree.Root(testCase.second)
+		if !VerifyConsistency(testCase.first, testCase.second, firstRoot, secondRoot, proof) {
+			t.Errorf("consistency proof from %d to %d entries doesn't verify", testCase.first, testCase.second)
+		}
+		if testCase.first != testCase.second && VerifyConsistency(testCase.first, testCase.second, firstRoot, LeafHash([]byte("other")), proof) {
+			t.Errorf("consistency proof from %d to %d entries verifies another root", testCase.first, testCase.second)
+		}
+	}
+	// every pair of sizes
+	for first := uint64(1); first <= tree.Size(); first++ {
+		for second := first; second <= tree.Size(); second++ {
+			proof := tree.ConsistencyProof(first, second)
+			if !VerifyConsistency(first, second, tree.Root(first), tree.Root(second), proof) {
+				t.Errorf("consistency proof from %d to %d entries doesn't verify", first, second)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+//
+// Signed Tree Heads
+// =================
+//
+// The Hub signs the root of its log with its Disco key (XEdDSA), so that clients can gossip tree heads
+// and prove that the Hub showed them different logs.
+//
+package transparency
+
+import (
+	"encoding/binary"
+	"errors"
+
+	s "github.com/mimoo/sasayaki/serialization"
+	"github.com/mimoo/sasayaki/xeddsa"
+)
+
+// TreeHeadContent returns what the Hub signs in a tree head
+func TreeHeadContent(treeHead *s.TreeHead) []byte {
+	var numbers [16]byte
+	binary.BigEndian.PutUint64(numbers[:8], treeHead.GetTreeSize())
+	binary.BigEndian.PutUint64(numbers[8:], uint64(treeHead.GetTimestamp()))
+	content := append([]byte("SasayakiTreeHead"), numbers[:]...)
+	return append(content, treeHead.GetRootHash()...)
+}
+
+// SignTreeHead signs a tree head with the Disco private key of the Hub
+func SignTreeHead(privateKey [32]byte, treeHead *s.TreeHead) {
+	treeHead.Signature = xeddsa.Sign(privateKey, TreeHeadContent(treeHead))
+}
+
+// VerifyTreeHead checks that a tree head has been signed by the Hub
+func VerifyTreeHead(hubPublicKey []byte, treeHead *s.TreeHead) error {
+	if len(treeHead.GetRootHash()) != 32 {
+		return errors.New("ssyk: tree head is malformed")
+	}
+	if !xeddsa.Verify(hubPublicKey, TreeHeadContent(treeHead), treeHead.GetSignature()) {
+		return errors.New("ssyk: tree head has an invalid signature")
+	}
+	return nil
+}
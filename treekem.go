@@ -0,0 +1,456 @@
+//
+// TreeKEM
+// =======
+//
+// The ratchet tree of an MLS group (RFC 9420), used by the MLS group engine (see mls.go).
+//
+// The tree is stored as an array, leaves are at even indexes and the number of leaves is always
+// a power of two (it is extended or truncated as members come and go). Each node holds a X25519
+// key pair, the members know the private keys of the nodes on the path from their leaf to the root.
+//
+// A commit replaces the keys of the committer's direct path with keys derived from fresh path
+// secrets, each path secret being encrypted to the resolution of the copath child of its node.
+// The last path secret is the commit secret, which is mixed into the key schedule:
+//
+//	init_secret = derive(epoch_secret[n], "init")
+//	epoch_secret[n+1] = derive(init_secret, "epoch", commit_secret || group_context[n+1])
+//
+// From an epoch secret we derive a handshake key (to encrypt proposals and commits), a confirmation
+// key (to MAC commits) and a chain key for each leaf (to encrypt application messages).
+//
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"github.com/mimoo/StrobeGo/strobe"
+	disco "github.com/mimoo/disco/libdisco"
+	s "github.com/mimoo/sasayaki/serialization"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+//
+// Tree Math
+// =========
+//
+// Nodes are indexed in the array representation of a left-balanced binary tree (RFC 9420 appendix C)
+//
+
+// level returns the level of a node in the tree, leaves are at level 0
+func level(x uint32) uint32 {
+	if x&1 == 0 {
+		return 0
+	}
+	k := uint32(0)
+	for (x>>k)&1 == 1 {
+		k++
+	}
+	return k
+}
+
+// root returns the root of a tree with n leaves (a power of two)
+func root(n uint32) uint32 {
+	return n - 1
+}
+
+func left(x uint32) uint32 {
+	k := level(x)
+	return x ^ (1 << (k - 1))
+}
+
+func right(x uint32) uint32 {
+	k := level(x)
+	return x ^ (3 << (k - 1))
+}
+
+func parent(x uint32) uint32 {
+	k := level(x)
+	b := (x >> (k + 1)) & 1
+	return (x | (1 << k)) ^ (b << (k + 1))
+}
+
+func sibling(x uint32) uint32 {
+	p := parent(x)
+	if x < p {
+		return right(p)
+	}
+	return left(p)
+}
+
+// directPath returns the parents of a node, up to the root
+func directPath(x, n uint32) []uint32 {
+	var path []uint32
+	for x != root(n) {
+		x = parent(x)
+		path = append(path, x)
+	}
+	return path
+}
+
+// isAncestor returns true if a is x or one of its ancestors
+func isAncestor(a, x uint32) bool {
+	if level(a) < level(x) {
+		return false
+	}
+	// a covers the nodes [a - 2^k + 1, a + 2^k - 1]
+	span := uint32(1)<<level(a) - 1
+	return x+span >= a && x <= a+span
+}
+
+//
+// Ratchet Tree
+// ============
+//
+
+// leafCount returns the number of leaves of the tree
+func leafCount(state *s.MLSGroupState) uint32 {
+	return uint32(len(state.Tree)+1) / 2
+}
+
+func isBlank(state *s.MLSGroupState, x uint32) bool {
+	return len(state.Tree[x].GetPublicKey()) == 0
+}
+
+// blank removes the keys of a node (the identity of a blank leaf is removed too)
+func blank(state *s.MLSGroupState, x uint32) {
+	state.Tree[x] = &s.MLSNode{}
+	state.PrivateKeys[x] = nil
+}
+
+// resolution returns the non-blank nodes that cover the subtree of x, except the leaves in `exclude`
+func resolution(state *s.MLSGroupState, x uint32, exclude map[uint32]bool) []uint32 {
+	if !isBlank(state, x) {
+		if exclude[x] {
+			return nil
+		}
+		return []uint32{x}
+	}
+	if level(x) == 0 {
+		return nil
+	}
+	return append(resolution(state, left(x), exclude), resolution(state, right(x), exclude)...)
+}
+
+// filteredDirectPath returns the nodes of the direct path of a leaf whose copath child has a
+// non-empty resolution, and these copath children
+func filteredDirectPath(state *s.MLSGroupState, leaf uint32) ([]uint32, []uint32) {
+	var path, copath []uint32
+	child := 2 * leaf
+	for _, node := range directPath(2*leaf, leafCount(state)) {
+		if len(resolution(state, sibling(child), nil)) > 0 {
+			path = append(path, node)
+			copath = append(copath, sibling(child))
+		}
+		child = node
+	}
+	return path, copath
+}
+
+// addLeaf places a new member in the leftmost blank leaf (extending the tree if needed) and returns
+// its leaf index. The direct path of the new leaf is blanked, as we don't know its private keys.
+func addLeaf(state *s.MLSGroupState, node *s.MLSNode) uint32 {
+	n := leafCount(state)
+	leaf := n
+	for i := uint32(0); i < n; i++ {
+		if isBlank(state, 2*i) {
+			leaf = i
+			break
+		}
+	}
+	// full tree? double it
+	if leaf == n {
+		for i := uint32(0); i < 2*n; i++ {
+			state.Tree = append(state.Tree, &s.MLSNode{})
+			state.PrivateKeys = append(state.PrivateKeys, nil)
+		}
+	}
+	// set the leaf and blank its direct path
+	state.Tree[2*leaf] = node
+	state.PrivateKeys[2*leaf] = nil
+	for _, x := range directPath(2*leaf, leafCount(state)) {
+		blank(state, x)
+	}
+	return leaf
+}
+
+// removeLeaf blanks a leaf and its direct path, then truncates the tree while its right half is empty.
+// (sender chains are not updated, a commit always starts a new epoch)
+func removeLeaf(state *s.MLSGroupState, leaf uint32) {
+	blank(state, 2*leaf)
+	for _, x := range directPath(2*leaf, leafCount(state)) {
+		blank(state, x)
+	}
+	// truncate
+	for n := leafCount(state); n > 1; n = leafCount(state) {
+		for i := n / 2; i < n; i++ {
+			if !isBlank(state, 2*i) {
+				return
+			}
+		}
+		state.Tree = state.Tree[:n-1]
+		state.PrivateKeys = state.PrivateKeys[:n-1]
+	}
+}
+
+// findLeaf returns the leaf of a member
+func findLeaf(state *s.MLSGroupState, identity []byte) (uint32, bool) {
+	for i := uint32(0); i < leafCount(state); i++ {
+		if !isBlank(state, 2*i) && subtle.ConstantTimeCompare(state.Tree[2*i].GetIdentity(), identity) == 1 {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// treeHash returns a hash of all the public keys and identities of the tree
+func treeHash(state *s.MLSGroupState) []byte {
+	var serialized []byte
+	for _, node := range state.Tree {
+		serialized = append(serialized, byte(len(node.GetPublicKey())))
+		serialized = append(serialized, node.GetPublicKey()...)
+		serialized = append(serialized, byte(len(node.GetIdentity())))
+		serialized = append(serialized, node.GetIdentity()...)
+	}
+	return disco.Hash(serialized, 32)
+}
+
+//
+// Key Schedule
+// ============
+//
+
+// mlsDerive derives a 32-byte secret from a secret, a label and some context
+func mlsDerive(secret []byte, label string, context ...[]byte) []byte {
+	kdf := strobe.InitStrobe("SasayakiMLS", 128)
+	kdf.KEY(secret)
+	kdf.AD(true, []byte(label))
+	for _, c := range context {
+		kdf.AD(false, c)
+	}
+	return kdf.PRF(32)
+}
+
+// nodeKeyPair derives the key pair of a node from its path secret
+func nodeKeyPair(pathSecret []byte) *disco.KeyPair {
+	var privateKey [32]byte
+	copy(privateKey[:], mlsDerive(pathSecret, "node"))
+	return disco.GenerateKeypair(&privateKey)
+}
+
+// groupContext binds an epoch to its group and its tree
+func groupContext(state *s.MLSGroupState) []byte {
+	context := []byte(state.GroupId)
+	context = append(context, uint64ToBytes(state.Epoch)...)
+	return append(context, treeHash(state)...)
+}
+
+// nextEpoch mixes a commit secret in the key schedule, and resets the sender chains.
+// The tree must already be updated with the commit.
+func nextEpoch(state *s.MLSGroupState, commitSecret []byte) {
+	initSecret := mlsDerive(state.EpochSecret, "init")
+	state.Epoch++
+	state.EpochSecret = mlsDerive(initSecret, "epoch", commitSecret, groupContext(state))
+	resetSenderChains(state)
+	state.PendingProposals = nil
+}
+
+// resetSenderChains derives the chain key of each leaf from the epoch secret
+func resetSenderChains(state *s.MLSGroupState) {
+	n := leafCount(state)
+	state.SenderChains = make([][]byte, n)
+	state.SenderGenerations = make([]uint32, n)
+	for i := uint32(0); i < n; i++ {
+		state.SenderChains[i] = mlsDerive(state.EpochSecret, "sender", uint32ToBytes(i))
+	}
+}
+
+func handshakeKey(state *s.MLSGroupState) []byte {
+	return mlsDerive(state.EpochSecret, "handshake")
+}
+
+func confirmationTag(state *s.MLSGroupState, commit []byte) []byte {
+	return mlsDerive(mlsDerive(state.EpochSecret, "confirm"), "tag", commit)
+}
+
+// ratchetSenderChain returns the key to encrypt the application message `generation` of a leaf,
+// and moves the chain past it (older generations can't be decrypted anymore)
+func ratchetSenderChain(state *s.MLSGroupState, leaf, generation uint32) ([]byte, error) {
+	if leaf >= uint32(len(state.SenderChains)) || generation < state.SenderGenerations[leaf] {
+		return nil, errors.New("ssyk: group message replayed or too old")
+	}
+	if generation-state.SenderGenerations[leaf] > mlsMaxSkippedMessages {
+		return nil, errors.New("ssyk: too many group messages skipped")
+	}
+	chain := state.SenderChains[leaf]
+	for i := state.SenderGenerations[leaf]; i < generation; i++ {
+		chain = mlsDerive(chain, "chain")
+	}
+	state.SenderChains[leaf] = mlsDerive(chain, "chain")
+	state.SenderGenerations[leaf] = generation + 1
+	return mlsDerive(chain, "key"), nil
+}
+
+//
+// Path Secrets
+// ============
+//
+
+// sealToPublicKey encrypts a secret to a node of the tree: [ephemeral(32), ciphertext(...)]
+func sealToPublicKey(publicKey, plaintext, context []byte) []byte {
+	ephemeral := disco.GenerateKeypair(nil)
+	var shared, remote [32]byte
+	copy(remote[:], publicKey)
+	curve25519.ScalarMult(&shared, &ephemeral.PrivateKey, &remote)
+	key := mlsDerive(shared[:], "seal", ephemeral.PublicKey[:], publicKey, context)
+	return append(ephemeral.PublicKey[:], disco.Encrypt(key, plaintext)...)
+}
+
+// openWithPrivateKey decrypts what was encrypted with sealToPublicKey
+func openWithPrivateKey(privateKey, sealed, context []byte) ([]byte, error) {
+	if len(privateKey) != 32 || len(sealed) < 32 {
+		return nil, errors.New("ssyk: encrypted secret is malformed")
+	}
+	var private [32]byte
+	copy(private[:], privateKey)
+	keyPair := disco.GenerateKeypair(&private)
+	var shared, ephemeral [32]byte
+	copy(ephemeral[:], sealed[:32])
+	curve25519.ScalarMult(&shared, &keyPair.PrivateKey, &ephemeral)
+	if subtle.ConstantTimeCompare(shared[:], make([]byte, 32)) == 1 {
+		return nil, errors.New("ssyk: encrypted secret is malformed")
+	}
+	key := mlsDerive(shared[:], "seal", ephemeral[:], keyPair.PublicKey[:], context)
+	return disco.Decrypt(key, sealed[32:])
+}
+
+// createUpdatePath replaces the keys of our leaf and of our direct path, and encrypts the new path secrets
+// to the rest of the tree (except the new members in `exclude`, they receive a welcome instead).
+// It returns the update path, the commit secret and the path secret of each node of the path.
+func createUpdatePath(state *s.MLSGroupState, exclude map[uint32]bool) (*s.MLSUpdatePath, []byte, map[uint32][]byte) {
+	// new leaf key
+	leafSecret := make([]byte, 32)
+	if _, err := rand.Read(leafSecret); err != nil {
+		panic(err)
+	}
+	leafKeyPair := nodeKeyPair(leafSecret)
+	myNode := 2 * state.MyLeaf
+	state.Tree[myNode] = &s.MLSNode{PublicKey: leafKeyPair.PublicKey[:], Identity: state.Tree[myNode].GetIdentity()}
+	state.PrivateKeys[myNode] = leafKeyPair.PrivateKey[:]
+	// nodes of the direct path that are not part of the filtered direct path are blanked
+	path, copath := filteredDirectPath(state, state.MyLeaf)
+	for _, x := range directPath(myNode, leafCount(state)) {
+		blank(state, x)
+	}
+	// derive the path secrets, and encrypt them to the copath
+	updatePath := &s.MLSUpdatePath{LeafKey: leafKeyPair.PublicKey[:]}
+	pathSecrets := make(map[uint32][]byte)
+	pathSecret := mlsDerive(leafSecret, "path")
+	for i, x := range path {
+		keyPair := nodeKeyPair(pathSecret)
+		state.Tree[x] = &s.MLSNode{PublicKey: keyPair.PublicKey[:]}
+		state.PrivateKeys[x] = keyPair.PrivateKey[:]
+		pathSecrets[x] = pathSecret
+		node := &s.MLSUpdatePathNode{PublicKey: keyPair.PublicKey[:]}
+		for _, r := range resolution(state, copath[i], exclude) {
+			node.EncryptedPathSecrets = append(node.EncryptedPathSecrets,
+				sealToPublicKey(state.Tree[r].GetPublicKey(), pathSecret, []byte(state.GroupId)))
+		}
+		updatePath.Nodes = append(updatePath.Nodes, node)
+		pathSecret = mlsDerive(pathSecret, "path")
+	}
+	//
+	return updatePath, pathSecret, pathSecrets
+}
+
+// applyUpdatePath applies the update path of a committer to the tree, decrypts the path secret
+// we can decrypt, and returns the commit secret
+func applyUpdatePath(state *s.MLSGroupState, committer uint32, updatePath *s.MLSUpdatePath, exclude map[uint32]bool) ([]byte, error) {
+	if updatePath == nil || len(updatePath.GetLeafKey()) != 32 {
+		return nil, errors.New("ssyk: commit received is malformed")
+	}
+	path, copath := filteredDirectPath(state, committer)
+	if len(path) != len(updatePath.GetNodes()) {
+		return nil, errors.New("ssyk: commit received has an incorrect path")
+	}
+	// find the path secret that was encrypted to us (before we update the tree)
+	pathSecret, from := []byte(nil), len(path)
+	for i := range path {
+		if !isAncestor(copath[i], 2*state.MyLeaf) {
+			continue
+		}
+		secrets := updatePath.GetNodes()[i].GetEncryptedPathSecrets()
+		res := resolution(state, copath[i], exclude)
+		if len(secrets) != len(res) {
+			return nil, errors.New("ssyk: commit received has an incorrect path")
+		}
+		for j, r := range res {
+			if isAncestor(r, 2*state.MyLeaf) && len(state.PrivateKeys[r]) != 0 {
+				var err error
+				if pathSecret, err = openWithPrivateKey(state.PrivateKeys[r], secrets[j], []byte(state.GroupId)); err != nil {
+					return nil, err
+				}
+				from = i
+				break
+			}
+		}
+		break
+	}
+	if pathSecret == nil {
+		return nil, errors.New("ssyk: no path secret in the commit could be decrypted")
+	}
+	// update the tree
+	committerNode := 2 * committer
+	state.Tree[committerNode] = &s.MLSNode{PublicKey: updatePath.GetLeafKey(), Identity: state.Tree[committerNode].GetIdentity()}
+	state.PrivateKeys[committerNode] = nil
+	for _, x := range directPath(committerNode, leafCount(state)) {
+		blank(state, x)
+	}
+	for i, x := range path {
+		state.Tree[x] = &s.MLSNode{PublicKey: updatePath.GetNodes()[i].GetPublicKey()}
+	}
+	// derive the private keys of the nodes we now share with the committer
+	for _, x := range path[from:] {
+		keyPair := nodeKeyPair(pathSecret)
+		if subtle.ConstantTimeCompare(keyPair.PublicKey[:], state.Tree[x].GetPublicKey()) != 1 {
+			return nil, errors.New("ssyk: commit received has an incorrect path")
+		}
+		state.PrivateKeys[x] = keyPair.PrivateKey[:]
+		pathSecret = mlsDerive(pathSecret, "path")
+	}
+	//
+	return pathSecret, nil
+}
+
+// applyWelcomePath derives the private keys of our direct path from the path secret of the lowest
+// common ancestor of our leaf and the committer's leaf (the nodes above it are on the committer's path)
+func applyWelcomePath(state *s.MLSGroupState, committer uint32, pathSecret []byte) error {
+	path, _ := filteredDirectPath(state, committer)
+	for _, x := range path {
+		if !isAncestor(x, 2*state.MyLeaf) {
+			continue
+		}
+		keyPair := nodeKeyPair(pathSecret)
+		if subtle.ConstantTimeCompare(keyPair.PublicKey[:], state.Tree[x].GetPublicKey()) != 1 {
+			return errors.New("ssyk: welcome received has an incorrect path")
+		}
+		state.PrivateKeys[x] = keyPair.PrivateKey[:]
+		pathSecret = mlsDerive(pathSecret, "path")
+	}
+	return nil
+}
+
+func uint32ToBytes(x uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], x)
+	return buf[:]
+}
+
+func uint64ToBytes(x uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], x)
+	return buf[:]
+}
@@ -0,0 +1,130 @@
+//
+// Web of Trust
+// ============
+//
+// The verifications we know of (see verification.go) form a graph: an edge goes from a verifier to the key it
+// verified. We trust a key if there is a path from us to it, through keys verified along the way, of at most
+// `depth` verifications (by default 2: keys verified by someone we verified ourselves, as the spec suggests).
+// Revoked keys are never part of a path.
+//
+// Only the verifications stored locally are used: the proofs of a key are fetched when we look at its profile.
+// The distances from us to every key are computed once (breadth-first), and recomputed only when the
+// verifications or the revocations change.
+//
+package main
+
+import (
+	"sync"
+)
+
+const (
+	defaultTrustDepth = 2
+	maxTrustDepth     = 6
+	maxTrustPaths     = 5 // paths explained for a key
+)
+
+// trustHop is a verification on a path from us to a key
+type trustHop struct {
+	Who  string `json:"who"`  // the verifier
+	Key  string `json:"key"`  // the key verified
+	Name string `json:"name"` // the nickname given to the key by the verifier
+	How  string `json:"how"`
+}
+
+// trustInfo is how much we trust a key
+type trustInfo struct {
+	Degree int          `json:"degree"` // the length of the shortest path from us (0: not trusted, 1: verified by us)
+	Paths  [][]trustHop `json:"paths"`  // some of the shortest paths
+}
+
+type trustEngine struct {
+	depth int
+
+	version  uint64                // the version of the verifications used for the cache
+	distance map[string]int        // key -> length of the shortest path from us
+	parents  map[string][]trustHop // key -> verifications of it by keys one step closer to us
+
+	queryMutex sync.Mutex // one query at a time
+}
+
+var trust trustEngine
+
+// initTrustEngine sets how long a path of verifications can be
+func initTrustEngine(depth int) {
+	trust.queryMutex.Lock()
+	defer trust.queryMutex.Unlock()
+	if depth <= 0 {
+		depth = defaultTrustDepth
+	}
+	if depth > maxTrustDepth {
+		depth = maxTrustDepth
+	}
+	trust.depth = depth
+	trust.distance = nil
+}
+
+// trustFor returns how much we trust a key. storage.queryMutex must be held
+func (trust *trustEngine) trustFor(myAddress, publicKey string) trustInfo {
+	trust.queryMutex.Lock()
+	defer trust.queryMutex.Unlock()
+	if trust.distance == nil || trust.version != storage.verificationsVersion {
+		trust.compute(myAddress, storage.getAllVerifications(), storage.getRevokedKeys())
+		trust.version = storage.verificationsVersion
+	}
+	distance, ok := trust.distance[publicKey]
+	if !ok || publicKey == myAddress {
+		return trustInfo{Paths: [][]trustHop{}}
+	}
+	return trustInfo{Degree: distance, Paths: trust.paths(publicKey, maxTrustPaths)}
+}
+
+// compute runs a breadth-first search from us over the verifications, up to the maximum depth
+func (trust *trustEngine) compute(myAddress string, verifications []verification, revoked map[string]bool) {
+	// verifier -> verifications it did
+	edges := make(map[string][]trustHop)
+	for _, v := range verifications {
+		if revoked[v.Who] || revoked[v.PublicKey] {
+			continue
+		}
+		edges[v.Who] = append(edges[v.Who], trustHop{Who: v.Who, Key: v.PublicKey, Name: v.Name, How: v.How})
+	}
+	trust.distance = map[string]int{myAddress: 0}
+	trust.parents = make(map[string][]trustHop)
+	frontier := []string{myAddress}
+	for depth := 1; depth <= trust.depth && len(frontier) > 0; depth++ {
+		var next []string
+		for _, who := range frontier {
+			for _, hop := range edges[who] {
+				distance, seen := trust.distance[hop.Key]
+				if !seen {
+					trust.distance[hop.Key] = depth
+					next = append(next, hop.Key)
+				} else if distance != depth {
+					continue
+				}
+				trust.parents[hop.Key] = append(trust.parents[hop.Key], hop)
+			}
+		}
+		frontier = next
+	}
+}
+
+// paths returns at most `max` shortest paths from us to a key
+func (trust *trustEngine) paths(publicKey string, max int) [][]trustHop {
+	paths := [][]trustHop{}
+	var walk func(key string, suffix []trustHop)
+	walk = func(key string, suffix []trustHop) {
+		if len(paths) >= max {
+			return
+		}
+		if trust.distance[key] == 0 {
+			paths = append(paths, suffix)
+			return
+		}
+		for _, hop := range trust.parents[key] {
+			walk(hop.Who, append([]trustHop{hop}, suffix...))
+		}
+	}
+	walk(publicKey, []trustHop{})
+	return paths
+}
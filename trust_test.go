@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// testKey returns a fake public key in hex
+func testKey(i int) string {
+	return fmt.Sprintf("%064x", i)
+}
+
+// testVerifications returns the verifications of a list of [verifier, key] pairs
+func testVerifications(edges [][2]int) []verification {
+	var verifications []verification
+	for _, edge := range edges {
+		verifications = append(verifications, verification{
+			Who:       testKey(edge[0]),
+			PublicKey: testKey(edge[1]),
+			Name:      fmt.Sprintf("key %d", edge[1]),
+			How:       "irl",
+		})
+	}
+	return verifications
+}
+
+// testTrust computes the trust of every key from key 0 over verifications
+func testTrust(depth int, edges [][2]int, revoked ...int) *trustEngine {
+	engine := &trustEngine{depth: depth}
+	revokedKeys := map[string]bool{}
+	for _, key := range revoked {
+		revokedKeys[testKey(key)] = true
+	}
+	engine.compute(testKey(0), testVerifications(edges), revokedKeys)
+	return engine
+}
+
+// degree returns the length of the shortest path to a key, 0 if it isn't trusted
+func (trust *trustEngine) degree(key int) int {
+	return trust.distance[testKey(key)]
+}
+
+func TestTrustPaths(t *testing.T) {
+	// 0 -> 1 -> 2 -> 3, 0 -> 4 -> 2, and 0 -> 5 directly as well as through 1
+	engine := testTrust(defaultTrustDepth, [][2]int{{0, 1}, {1, 2}, {2, 3}, {0, 4}, {4, 2}, {0, 5}, {1, 5}})
+
+	for key, expected := range map[int]int{1: 1, 2: 2, 3: 0, 4: 1, 5: 1, 6: 0} {
+		if degree := engine.degree(key); degree != expected {
+			t.Errorf("key %d: degree %d, expected %d", key, degree, expected)
+		}
+	}
+
+	// both shortest paths to 2 are explained, in order
+	paths := engine.paths(testKey(2), maxTrustPaths)
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 paths to key 2, got %v", paths)
+	}
+	for _, path := range paths {
+		if len(path) != 2 || path[0].Who != testKey(0) || path[0].Key != path[1].Who || path[1].Key != testKey(2) {
+			t.Errorf("incorrect path to key 2: %v", path)
+		}
+	}
+	// the longer path to 5 isn't
+	if paths := engine.paths(testKey(5), maxTrustPaths); len(paths) != 1 || len(paths[0]) != 1 {
+		t.Errorf("expected a single direct path to key 5, got %v", paths)
+	}
+	// at most `max` paths
+	if paths := engine.paths(testKey(2), 1); len(paths) != 1 {
+		t.Errorf("expected 1 path, got %d", len(paths))
+	}
+}
+
+func TestTrustDepth(t *testing.T) {
+	// a chain 0 -> 1 -> ... -> 8
+	var chain [][2]int
+	for i := 0; i < 8; i++ {
+		chain = append(chain, [2]int{i, i + 1})
+	}
+	for depth := 1; depth <= maxTrustDepth; depth++ {
+		engine := testTrust(depth, chain)
+		if engine.degree(depth) != depth || engine.degree(depth+1) != 0 {
+			t.Errorf("depth %d: keys trusted up to %v", depth, engine.distance)
+		}
+	}
+
+	// the depth of the configuration is bounded
+	defer initTrustEngine(defaultTrustDepth)
+	for depth, expected := range map[int]int{0: defaultTrustDepth, -1: defaultTrustDepth, 3: 3, 100: maxTrustDepth} {
+		initTrustEngine(depth)
+		if trust.depth != expected {
+			t.Errorf("depth %d configured as %d, expected %d", depth, trust.depth, expected)
+		}
+	}
+}
+
+func TestTrustRevocations(t *testing.T) {
+	// 0 -> 1 -> 2, 0 -> 3 -> 4 -> 2
+	edges := [][2]int{{0, 1}, {1, 2}, {0, 3}, {3, 4}, {4, 2}}
+
+	// a revoked key is never trusted
+	engine := testTrust(3, edges, 2)
+	if engine.degree(2) != 0 {
+		t.Errorf("a revoked key is trusted with degree %d", engine.degree(2))
+	}
+	// its verifications don't count: 2 is only reachable through 3 and 4
+	engine = testTrust(3, edges, 1)
+	if engine.degree(1) != 0 || engine.degree(2) != 3 {
+		t.Errorf("a revoked verifier was used: %v", engine.distance)
+	}
+	for _, path := range engine.paths(testKey(2), maxTrustPaths) {
+		for _, hop := range path {
+			if hop.Who == testKey(1) || hop.Key == testKey(1) {
+				t.Errorf("a path goes through a revoked key: %v", path)
+			}
+		}
+	}
+	// and when there is no other path within the depth, the key isn't trusted
+	engine = testTrust(2, edges, 1)
+	if engine.degree(2) != 0 {
+		t.Errorf("a key is trusted through a revoked verifier with degree %d", engine.degree(2))
+	}
+}
+
+// testGraph returns about `perKey` random verifications by each of `size` keys, always the same ones
+func testGraph(size, perKey int) [][2]int {
+	random := rand.New(rand.NewSource(1))
+	var edges [][2]int
+	for who := 0; who < size; who++ {
+		for i := 0; i < perKey; i++ {
+			edges = append(edges, [2]int{who, random.Intn(size)})
+		}
+	}
+	return edges
+}
+
+// TestTrustLargeGraph checks the breadth-first search against the distances of a layered graph of 5000 keys,
+// where every key is verified by a few keys of the previous layer and of its own layer
+func TestTrustLargeGraph(t *testing.T) {
+	const layers, width = 5, 1000
+	layerOf := func(key int) int { return (key-1)/width + 1 }
+	var edges [][2]int
+	for key := 1; key <= width; key++ {
+		edges = append(edges, [2]int{0, key})
+	}
+	random := rand.New(rand.NewSource(1))
+	for key := width + 1; key <= layers*width; key++ {
+		// a few verifiers in the previous layer, and noise within the same layer
+		for i := 0; i < 3; i++ {
+			edges = append(edges, [2]int{(layerOf(key)-2)*width + 1 + random.Intn(width), key})
+			edges = append(edges, [2]int{(layerOf(key)-1)*width + 1 + random.Intn(width), key})
+		}
+	}
+	engine := testTrust(maxTrustDepth, edges)
+	for key := 1; key <= layers*width; key++ {
+		if engine.degree(key) != layerOf(key) {
+			t.Fatalf("key %d: degree %d, expected %d", key, engine.degree(key), layerOf(key))
+		}
+	}
+	for _, path := range engine.paths(testKey(layers*width), maxTrustPaths) {
+		if len(path) != layers {
+			t.Fatalf("a path of %d hops to a key of the layer %d", len(path), layers)
+		}
+	}
+}
+
+// TestTrustCache checks that the distances are only computed again when the verifications or the revocations change
+func TestTrustCache(t *testing.T) {
+	initTestStorage(t)
+	initTrustEngine(defaultTrustDepth)
+	for _, v := range testVerifications([][2]int{{0, 1}, {1, 2}}) {
+		storage.storeVerification(&v)
+	}
+	if info := trust.trustFor(testKey(0), testKey(2)); info.Degree != 2 || len(info.Paths) != 1 {
+		t.Fatalf("unexpected trust %+v", info)
+	}
+
+	// nothing changed, the cache is used
+	trust.distance[testKey(2)] = 42
+	if info := trust.trustFor(testKey(0), testKey(2)); info.Degree != 42 {
+		t.Errorf("the distances were computed again, degree %d", info.Degree)
+	}
+
+	// a new verification
+	for _, v := range testVerifications([][2]int{{0, 2}}) {
+		storage.storeVerification(&v)
+	}
+	if info := trust.trustFor(testKey(0), testKey(2)); info.Degree != 1 {
+		t.Errorf("the new verification was ignored, degree %d", info.Degree)
+	}
+
+	// a revocation
+	storage.storeRevocation(testKey(2), 0, "lost")
+	if info := trust.trustFor(testKey(0), testKey(2)); info.Degree != 0 {
+		t.Errorf("the revocation was ignored, degree %d", info.Degree)
+	}
+}
+
+// BenchmarkTrust computes the trust of a key in a random graph of 5000 keys verifying 5 keys each,
+// with the distances cached, and computed again every time
+func BenchmarkTrust(b *testing.B) {
+	const size = 5000
+	verifications := testVerifications(testGraph(size, 5))
+	revoked := map[string]bool{testKey(size / 2): true}
+
+	b.Run("compute", func(b *testing.B) {
+		engine := &trustEngine{depth: maxTrustDepth}
+		for i := 0; i < b.N; i++ {
+			engine.compute(testKey(0), verifications, revoked)
+			engine.paths(testKey(i%size), maxTrustPaths)
+		}
+	})
+
+	b.Run("storage", func(b *testing.B) {
+		dir := b.TempDir()
+		b.Setenv("SASAYAKI_HOME", dir)
+		currentProfile = defaultProfile
+		initStorageState()
+		defer func() {
+			storage.db.Close()
+			storage.db = nil
+		}()
+		for _, v := range verifications {
+			v := v
+			storage.storeVerification(&v)
+		}
+		initTrustEngine(maxTrustDepth)
+		defer initTrustEngine(defaultTrustDepth)
+
+		b.Run("cached", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				trust.trustFor(testKey(0), testKey(i%size))
+			}
+		})
+		b.Run("changed", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				storage.verificationsVersion++
+				trust.trustFor(testKey(0), testKey(i%size))
+			}
+		})
+	})
+}
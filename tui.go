@@ -0,0 +1,349 @@
+//
+// Terminal UI
+// ===========
+//
+// `sasayaki -cli` runs a full-screen client in the terminal, on top of the same core as the web UI:
+//
+//	+--------------------------------------------------------------+
+//	| status bar: our address, and what just happened               |
+//	+------------------+-------------------------------------------+
+//	| contact requests | messages of the selected conversation     |
+//	| conversations    |                                           |
+//	+------------------+-------------------------------------------+
+//	| > compose line                                               |
+//	+--------------------------------------------------------------+
+//
+// Keys:
+//
+// * up/down: select a contact request or a conversation
+// * enter: send the compose line to the selected conversation, or accept the selected contact request with it as a name
+// * ctrl-u: clear the compose line, ctrl-l: redraw, ctrl-c or ctrl-q: quit
+//
+// The compose line also takes commands: `/add <address> <name>` sends a contact request, and
+// `/new <title>` starts a new conversation with the contact of the selected one.
+//
+// New messages are fetched from the Hub every second, in the background.
+//
+// TODO: groups and attachments are only available in the web UI
+//
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	tuiListWidth    = 28
+	tuiPollInterval = time.Second
+)
+
+// terminalUI is the state of the terminal client
+type terminalUI struct {
+	ss *sasayakiState
+
+	width  int
+	height int
+
+	requests []string           // the keys that sent us a contact request
+	convos   []conversationInfo // our conversations, the most recent first
+	selected int                // index in requests, then convos
+
+	compose []rune
+	status  string
+}
+
+// runTerminalUI takes over the terminal until the user quits
+func runTerminalUI(ss *sasayakiState) error {
+	fd := int(os.Stdin.Fd())
+	oldState, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		terminal.Restore(fd, oldState)
+		fmt.Print("\x1b[2J\x1b[H\x1b[?25h")
+	}()
+
+	ui := &terminalUI{ss: ss, status: "ctrl-q to quit"}
+	keys := make(chan []byte)
+	updates := make(chan error)
+	go readKeys(keys)
+	go ui.poll(updates)
+
+	ui.refresh()
+	fmt.Print("\x1b[2J")
+	ui.draw()
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			if quit := ui.handleKey(key); quit {
+				return nil
+			}
+		case err := <-updates:
+			if err != nil {
+				ui.status = err.Error()
+			}
+			ui.refresh()
+		}
+		ui.draw()
+	}
+}
+
+// readKeys reads the keys pressed, an escape sequence at a time
+func readKeys(keys chan<- []byte) {
+	buffer := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buffer)
+		if err != nil {
+			close(keys)
+			return
+		}
+		key := make([]byte, n)
+		copy(key, buffer[:n])
+		keys <- key
+	}
+}
+
+// poll fetches new messages from the Hub, and tells the UI to refresh
+func (ui *terminalUI) poll(updates chan<- error) {
+	for {
+		_, err := ui.ss.getNextMessage()
+		if err == errNoNewMessages {
+			time.Sleep(tuiPollInterval)
+			continue
+		}
+		if err != nil {
+			time.Sleep(tuiPollInterval)
+		}
+		updates <- err
+	}
+}
+
+// refresh reloads the contact requests and the conversations from the storage
+func (ui *terminalUI) refresh() {
+	ui.requests = ui.ss.getContactRequests()
+	ui.convos = ui.ss.getConversations()
+	if total := len(ui.requests) + len(ui.convos); ui.selected >= total {
+		ui.selected = total - 1
+	}
+	if ui.selected < 0 {
+		ui.selected = 0
+	}
+	width, height, err := terminal.GetSize(int(os.Stdin.Fd()))
+	if err != nil || width < tuiListWidth+20 || height < 5 {
+		width, height = 80, 24
+	}
+	ui.width, ui.height = width, height
+}
+
+// handleKey applies a key pressed, and returns true if the user wants to quit
+func (ui *terminalUI) handleKey(key []byte) bool {
+	switch string(key) {
+	case "\x03", "\x11": // ctrl-c, ctrl-q
+		return true
+	case "\x0c": // ctrl-l
+		ui.refresh()
+		fmt.Print("\x1b[2J")
+	case "\x15": // ctrl-u
+		ui.compose = ui.compose[:0]
+	case "\x1b[A", "\x1bOA": // up
+		if ui.selected > 0 {
+			ui.selected--
+		}
+	case "\x1b[B", "\x1bOB": // down
+		if ui.selected < len(ui.requests)+len(ui.convos)-1 {
+			ui.selected++
+		}
+	case "\x7f", "\x08": // backspace
+		if len(ui.compose) > 0 {
+			ui.compose = ui.compose[:len(ui.compose)-1]
+		}
+	case "\r", "\n":
+		line := strings.TrimSpace(string(ui.compose))
+		if line == "" {
+			return false
+		}
+		if err := ui.submit(line); err != nil {
+			ui.status = err.Error()
+			return false
+		}
+		ui.compose = ui.compose[:0]
+		ui.refresh()
+	default:
+		if key[0] == 0x1b { // other escape sequences are ignored
+			return false
+		}
+		for len(key) > 0 {
+			r, size := utf8.DecodeRune(key)
+			key = key[size:]
+			if r != utf8.RuneError && r >= ' ' {
+				ui.compose = append(ui.compose, r)
+			}
+		}
+	}
+	return false
+}
+
+// submit runs a command of the compose line, accepts the selected contact request, or sends a message
+func (ui *terminalUI) submit(line string) error {
+	// commands
+	if strings.HasPrefix(line, "/add ") {
+		args := strings.SplitN(strings.TrimSpace(line[len("/add "):]), " ", 2)
+		if len(args) != 2 {
+			return errors.New("ssyk: usage: /add <address> <name>")
+		}
+		if err := ui.ss.aliceAddContact(args[0], args[1]); err != nil {
+			return err
+		}
+		ui.status = "contact request sent to " + args[1]
+		return nil
+	}
+	// contact requests
+	if ui.selected < len(ui.requests) {
+		address := ui.requests[ui.selected]
+		if err := ui.ss.bobAcceptContact(address, line); err != nil {
+			return err
+		}
+		ui.status = line + " has been added to your contacts"
+		return nil
+	}
+	// conversations
+	index := ui.selected - len(ui.requests)
+	if index >= len(ui.convos) {
+		return errors.New("ssyk: select a conversation first, or /add a contact")
+	}
+	convo := ui.convos[index]
+	msg := &plaintextMsg{
+		ConvoId:     convo.Id,
+		FromAddress: ui.ss.myAddress,
+		ToAddress:   convo.Address,
+		Type:        textMsg,
+		Content:     line,
+	}
+	if strings.HasPrefix(line, "/new ") {
+		msg.ConvoId = "" // the content is the title of the new conversation
+		msg.Content = strings.TrimSpace(line[len("/new "):])
+	}
+	if _, err := ui.ss.sendMessage(msg); err != nil {
+		return err
+	}
+	ui.status = ""
+	return nil
+}
+
+// draw redraws the whole screen
+func (ui *terminalUI) draw() {
+	var screen strings.Builder
+	screen.WriteString("\x1b[?25l\x1b[H")
+	// status bar
+	status := "Sasayaki  " + truncate(ui.ss.myAddress, 16) + "  " + ui.status
+	screen.WriteString("\x1b[7m" + pad(status, ui.width) + "\x1b[0m\r\n")
+	// conversation list and messages
+	rows := ui.height - 3
+	list := ui.listLines()
+	// scroll the list so that the selection is visible
+	first := 0
+	if ui.selected >= rows {
+		first = ui.selected - rows + 1
+	}
+	list = list[first:]
+	messages := ui.messageLines(rows)
+	paneWidth := ui.width - tuiListWidth - 1
+	for row := 0; row < rows; row++ {
+		left, right := "", ""
+		if row < len(list) {
+			left = list[row]
+		}
+		if row < len(messages) {
+			right = messages[row]
+		}
+		if first+row == ui.selected {
+			screen.WriteString("\x1b[7m" + pad(left, tuiListWidth) + "\x1b[0m")
+		} else {
+			screen.WriteString(pad(left, tuiListWidth))
+		}
+		screen.WriteString("|" + pad(right, paneWidth) + "\r\n")
+	}
+	// compose line
+	screen.WriteString(strings.Repeat("-", ui.width) + "\r\n")
+	compose := string(ui.compose)
+	if n := utf8.RuneCountInString(compose); n > ui.width-3 {
+		compose = string(ui.compose[n-(ui.width-3):])
+	}
+	screen.WriteString("> " + compose + "\x1b[K\x1b[?25h")
+	fmt.Print(screen.String())
+}
+
+// listLines returns the lines of the left column: the contact requests, then the conversations
+func (ui *terminalUI) listLines() []string {
+	lines := make([]string, 0, len(ui.requests)+len(ui.convos))
+	for _, address := range ui.requests {
+		lines = append(lines, "? request "+address)
+	}
+	for _, convo := range ui.convos {
+		name := convo.Name
+		if name == "" {
+			name = convo.Address
+		}
+		if convo.Title != "" {
+			name += ": " + convo.Title
+		}
+		lines = append(lines, "  "+name)
+	}
+	return lines
+}
+
+// messageLines returns the last messages of the selected conversation, or what to do with the selected request
+func (ui *terminalUI) messageLines(rows int) []string {
+	if ui.selected < len(ui.requests) {
+		return []string{
+			"contact request from " + ui.requests[ui.selected],
+			"",
+			"type a name for this contact and press enter to accept it",
+		}
+	}
+	index := ui.selected - len(ui.requests)
+	if index >= len(ui.convos) {
+		return []string{"no conversation yet, type /add <address> <name> to add a contact"}
+	}
+	convo := ui.convos[index]
+	lines := []string{}
+	for _, msg := range ui.ss.getConvoMessages(convo.Id, rows) {
+		author := convo.Name
+		if msg.SenderIsMe {
+			author = "me"
+		}
+		content := msg.Content
+		if msg.Deleted {
+			content = "(deleted)"
+		}
+		date := time.Unix(msg.Date, 0).Format("15:04")
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s", date, author, content))
+	}
+	return lines
+}
+
+// truncate cuts a string to n characters, on a single line
+func truncate(s string, n int) string {
+	s = strings.Replace(s, "\n", " ", -1)
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// pad truncates or pads a string to exactly n characters
+func pad(s string, n int) string {
+	s = truncate(s, n)
+	return s + strings.Repeat(" ", n-utf8.RuneCountInString(s))
+}
@@ -12,8 +12,189 @@ package main
 // the decrypted content directly or other logic bugs might arise
 type plaintextMsg struct {
 	ConvoId     string `json:"convo_id,omitempty"` // can be empty if we're creating a new thread
+	Id          string `json:"id,omitempty"`       // random identifier of the message, shared by both peers
 	FromAddress string `json:"from_address"`
 	ToAddress   string `json:"to_address"`
 
-	Content string `json:"content"`
+	Type      msgType `json:"type"`
+	Reference string  `json:"reference,omitempty"` // the message targeted by an edit or a deletion
+	Content   string  `json:"content"`
+
+	Attachment *attachment   `json:"attachment,omitempty"` // only for attachments
+	Group      *groupControl `json:"-"`                    // only for group control messages
+	Issue      *issueInfo    `json:"issue,omitempty"`      // only for messages sent in issue tracker channels
+	TreeHead   []byte        `json:"-"`                    // only for gossip messages, a serialized TreeHead
+	Rotation   []byte        `json:"-"`                    // only for key rotations, a serialized KeyRotationStatement
+	DeviceLink []byte        `json:"-"`                    // only for device links, a serialized DeviceLink
+	Peer       string        `json:"-"`                    // the contact of the conversation, in the copies sent to our other devices
+}
+
+// conversationInfo is how a conversation with a contact is presented to the UIs
+type conversationInfo struct {
+	Id      string `json:"id"`
+	Address string `json:"address"`
+	Name    string `json:"name"` // the name of the contact
+	Title   string `json:"title"`
+}
+
+// contactInfo is how a contact is presented to the UIs
+type contactInfo struct {
+	Address string `json:"address"`
+	Name    string `json:"name"`
+	State   string `json:"state"`         // "waiting_for_accept", "request", "added" or "waiting_for_rekey"
+	Hub     string `json:"hub,omitempty"` // the public key of the Hub the contact is on, if it isn't ours (see hubpool.go)
+}
+
+// storedMsg is a message of a conversation, as stored in the database
+type storedMsg struct {
+	Id         string `json:"id"`
+	Date       int64  `json:"date"`
+	SenderIsMe bool   `json:"sender_is_me"`
+	Content    string `json:"content"`
+	Deleted    bool   `json:"deleted"`
+}
+
+// attachment describes an encrypted file stored, in chunks, in the Hub's blob store
+type attachment struct {
+	Name  string   `json:"name"`
+	Size  uint64   `json:"size"`
+	Key   []byte   `json:"-"` // the key used to encrypt the file
+	Hash  []byte   `json:"hash"`
+	Blobs []string `json:"blobs"` // the ids of the encrypted chunks, in order
+}
+
+// groupInfo is how a group conversation is presented to the UI
+type groupInfo struct {
+	Id      string   `json:"id"`
+	Title   string   `json:"title"`
+	Engine  string   `json:"engine"`  // "sender_keys" or "mls"
+	Channel string   `json:"channel"` // "chat" or "issues"
+	Members []string `json:"members"`
+}
+
+// issueInfo is an issue of an issue tracker channel. Only the id, the state, the labels and the
+// assignees are sent with the messages about an issue.
+type issueInfo struct {
+	Id        string   `json:"id"`
+	Title     string   `json:"title"`
+	Author    string   `json:"author"`
+	State     string   `json:"state"` // "open" or "closed"
+	Labels    []string `json:"labels"`
+	Assignees []string `json:"assignees"`
+}
+
+// organizationMember is a member of our organization, found in the directory of the Hub.
+// Its membership certificate has been verified with the public key of the organization.
+type organizationMember struct {
+	PublicKey    string `json:"public_key"`
+	Name         string `json:"name"`
+	Organization string `json:"organization"`
+	Team         string `json:"team"`
+	ExpiresAt    int64  `json:"expires_at"`
+	Address      string `json:"address"` // name@organization, to name the contact
+}
+
+// revokedContact is a contact whose key has been revoked by our organization, we cannot talk to it anymore
+type revokedContact struct {
+	Address       string   `json:"address"`
+	Name          string   `json:"name"`
+	RevokedAt     int64    `json:"revoked_at"`
+	Reason        string   `json:"reason"`
+	Conversations []string `json:"conversations"` // our conversations with this key, now blocked
+}
+
+// logAlert is raised when the Hub has shown different versions of the transparency log,
+// to us or to one of our contacts (split view)
+type logAlert struct {
+	Date   string `json:"date"`
+	Source string `json:"source"` // "hub", or the contact who gossiped the other tree head
+	Reason string `json:"reason"`
+}
+
+// verification is a statement signed by someone (Who) that a key belongs to a person (Name),
+// checked in some way (How). See serialization/verification.go
+type verification struct {
+	PublicKey string `json:"public_key"`
+	Who       string `json:"who"`
+	Name      string `json:"name"`
+	How       string `json:"how"` // irl, facebook, twitter, etc.
+	Date      int64  `json:"date"`
+	Signature []byte `json:"-"`
+	Trusted   bool   `json:"trusted"` // we have verified Who ourselves
+}
+
+// safetyNumberInfo is what we compare with a contact to verify its key in person (see safetynumber.go)
+type safetyNumberInfo struct {
+	Address      string `json:"address"`
+	Name         string `json:"name"`
+	SafetyNumber string `json:"safety_number"`
+	QRPayload    string `json:"qr_payload"` // the content of the QR code to show to the contact
+	VerifiedByMe bool   `json:"verified_by_me"`
+}
+
+// keyChange is a contact who has signed a new key with its previous one (see rotation.go)
+type keyChange struct {
+	OldAddress string   `json:"old_address"`
+	NewAddress string   `json:"new_address"`
+	Name       string   `json:"name"`
+	Date       int64    `json:"date"`
+	State      string   `json:"state"`  // "pending", "handshake" or "done"
+	Groups     []string `json:"groups"` // the titles of the groups we share with the previous key, it has to be added again to them
+}
+
+// deviceInfo is a device of someone, certified by its identity key (see device.go)
+type deviceInfo struct {
+	Identity string `json:"identity"`
+	Address  string `json:"address"`
+	Name     string `json:"name"`
+	Date     int64  `json:"date"`
+}
+
+// contactProfile is what we know about a key: the verifications published for it
+type contactProfile struct {
+	Address              string         `json:"address"`
+	Name                 string         `json:"name"`
+	VerifiedByMe         bool           `json:"verified_by_me"`
+	TrustedVerifications int            `json:"trusted_verifications"` // done by people we have verified ourselves
+	Verifications        []verification `json:"verifications"`
+	Trust                trustInfo      `json:"trust"` // the chains of verifications from us to the key
+}
+
+// groupControl is sent to a member of a group, in a pairwise conversation, to update its view of the group
+type groupControl struct {
+	Id         string
+	Title      string
+	Members    []string
+	Member     string // the member added or removed
+	SenderKey  []byte // our current sender key
+	Generation uint32 // the generation of our sender key
+	Channel    string // "chat" or "issues"
+}
+
+// msgType tells the receiver what to do with a plaintextMsg
+type msgType uint8
+
+const (
+	textMsg              msgType = iota // a normal message to display
+	editMsg                             // replaces the content of the message `Reference`
+	deleteMsg                           // retracts the message `Reference`
+	attachmentMsg                       // a file, the content is its name
+	groupInviteMsg                      // we have been added to a group, contains the sender's view of the group
+	groupSenderKeyMsg                   // a member rotated its sender key
+	groupAddMemberMsg                   // a member has been added to the group
+	groupRemoveMemberMsg                // a member has been removed from the group
+	issueOpenMsg                        // a new issue in an issue tracker channel, the content is its title
+	issueUpdateMsg                      // new state, labels and assignees for an issue, and its title if the content is not empty
+	gossipMsg                           // the latest tree head of the transparency log seen by the sender
+	keyRotationMsg                      // the sender has a new key, signed by its current key
+	deviceLinkMsg                       // we are a new device of the sender, contains our certificate and its contacts
+)
+
+// isGroupControl returns true for the messages that update a group, instead of being displayed
+func (t msgType) isGroupControl() bool {
+	switch t {
+	case groupInviteMsg, groupSenderKeyMsg, groupAddMemberMsg, groupRemoveMemberMsg:
+		return true
+	}
+	return false
 }
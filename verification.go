@@ -0,0 +1,144 @@
+//
+// Verifications
+// =============
+//
+// After checking that a key belongs to someone (in real life, over the phone, etc.), we sign a verification
+// proof (see serialization/verification.go) and publish it to the Hub, so that others can see who verified
+// that key. When looking at the profile of a key, we fetch its proofs, verify them, and count those made by
+// people we have verified ourselves.
+//
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	s "github.com/mimoo/sasayaki/serialization"
+	"github.com/mimoo/sasayaki/xeddsa"
+)
+
+// verifyContact signs and publishes a verification of the key of a contact.
+// The nickname is the name we gave to the contact if it is empty
+func (ss sasayakiState) verifyContact(bobAddress, nickname, how string) error {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	bobPublicKey, err := decodeAddress(bobAddress)
+	if err != nil {
+		return err
+	}
+	if nickname == "" {
+		if nickname, err = storage.getContactName(bobAddress); err != nil {
+			return err
+		}
+	}
+	// sign
+	proof := &s.VerificationProof{
+		Nickname:  nickname,
+		Mean:      how,
+		PublicKey: bobPublicKey,
+		Date:      time.Now().Unix(),
+		Verifier:  e2e.keyPair.PublicKey[:],
+	}
+	content, err := s.VerificationContent(proof)
+	if err != nil {
+		return err
+	}
+	proof.Signature = xeddsa.Sign(e2e.keyPair.PrivateKey, content)
+	// store it, then publish it
+	storage.storeVerification(&verification{
+		PublicKey: hex.EncodeToString(bobPublicKey),
+		Who:       ss.myAddress,
+		Name:      nickname,
+		How:       how,
+		Date:      proof.Date,
+		Signature: proof.Signature,
+	})
+	serialized, err := proto.Marshal(proof)
+	if err != nil {
+		panic(err)
+	}
+	return hubs.publish(func(current *hubState) error { return current.publishProof(serialized) })
+}
+
+// getContactProfile fetches and verifies the verification proofs published for the key of a contact
+func (ss sasayakiState) getContactProfile(bobAddress string) (*contactProfile, error) {
+	storage.queryMutex.Lock()
+	defer storage.queryMutex.Unlock()
+	bobPublicKey, err := decodeAddress(bobAddress)
+	if err != nil {
+		return nil, err
+	}
+	// the proofs are about the key, wherever the contact is
+	bobKey := hex.EncodeToString(bobPublicKey)
+	// fetch the new proofs from our Hub and the Hub of the contact, where its own contacts publish them
+	// (if a Hub is not reachable, we show what we already have)
+	sources := []*hubState{&hub}
+	if other := hubs.of(bobAddress); other != &hub {
+		sources = append(sources, other)
+	}
+	var serializedProofs [][]byte
+	for _, current := range sources {
+		proofs, err := current.getProofsForMember(bobKey)
+		if err != nil {
+			log.Println("couldn't fetch the verification proofs:", err)
+		}
+		serializedProofs = append(serializedProofs, proofs...)
+	}
+	for _, serialized := range serializedProofs {
+		v, err := parseVerificationProof(serialized)
+		if err == nil && v.PublicKey != bobKey {
+			err = errors.New("ssyk: verification proof is for another key")
+		}
+		if err != nil {
+			log.Println("hub sent an invalid verification proof:", err)
+			continue
+		}
+		storage.storeVerification(v)
+	}
+	// which verifications do we trust?
+	profile := &contactProfile{
+		Address:       bobAddress,
+		Verifications: storage.getVerifications(bobKey),
+	}
+	profile.Name, _ = storage.getContactName(bobAddress)
+	trusted := make(map[string]bool)
+	for _, key := range storage.getVerifiedBy(ss.myAddress) {
+		trusted[key] = true
+	}
+	for i, v := range profile.Verifications {
+		if v.Who == ss.myAddress {
+			profile.VerifiedByMe = true
+		} else if trusted[v.Who] {
+			profile.Verifications[i].Trusted = true
+			profile.TrustedVerifications++
+		}
+	}
+	profile.Trust = trust.trustFor(ss.myAddress, bobKey)
+	return profile, nil
+}
+
+// parseVerificationProof parses a serialized verification proof and checks its signature
+func parseVerificationProof(serialized []byte) (*verification, error) {
+	proof := &s.VerificationProof{}
+	if err := proto.Unmarshal(serialized, proof); err != nil {
+		return nil, errors.New("ssyk: verification proof is malformed")
+	}
+	content, err := s.VerificationContent(proof)
+	if err != nil {
+		return nil, err
+	}
+	if !xeddsa.Verify(proof.GetVerifier(), content, proof.GetSignature()) {
+		return nil, errors.New("ssyk: verification proof has an invalid signature")
+	}
+	return &verification{
+		PublicKey: hex.EncodeToString(proof.GetPublicKey()),
+		Who:       hex.EncodeToString(proof.GetVerifier()),
+		Name:      proof.GetNickname(),
+		How:       proof.GetMean(),
+		Date:      proof.GetDate(),
+		Signature: proof.GetSignature(),
+	}, nil
+}
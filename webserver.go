@@ -24,6 +24,7 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
@@ -31,10 +32,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"path/filepath"
+	"strconv"
 
 	"github.com/gorilla/mux"
 )
@@ -65,15 +68,104 @@ type sendMessageReq struct {
 	Content   string `json:"content"`
 }
 
-// set_passphrase
+// edit_message
+type editMessageReq struct {
+	ConvoId   string `json:"convo_id"`
+	ToAddress string `json:"to_address"`
+	Id        string `json:"id"`
+	Content   string `json:"content"`
+}
+
+// delete_message
+type deleteMessageReq struct {
+	ConvoId   string `json:"convo_id"`
+	ToAddress string `json:"to_address"`
+	Id        string `json:"id"`
+}
+
+// create_group
+type createGroupReq struct {
+	Title   string   `json:"title"`
+	Members []string `json:"members"`
+	Engine  string   `json:"engine"`  // "sender_keys" (default) or "mls"
+	Channel string   `json:"channel"` // "chat" (default) or "issues"
+}
+
+// send_group_message
+type sendGroupMessageReq struct {
+	GroupId string `json:"group_id"`
+	IssueId string `json:"issue_id"` // the issue commented, in issue tracker channels
+	Content string `json:"content"`
+}
+
+// open_issue
+type openIssueReq struct {
+	GroupId   string   `json:"group_id"`
+	Title     string   `json:"title"`
+	Labels    []string `json:"labels"`
+	Assignees []string `json:"assignees"`
+}
+
+// update_issue (absent fields are left unchanged)
+type updateIssueReq struct {
+	GroupId   string    `json:"group_id"`
+	IssueId   string    `json:"issue_id"`
+	Title     *string   `json:"title"`
+	State     *string   `json:"state"`
+	Labels    *[]string `json:"labels"`
+	Assignees *[]string `json:"assignees"`
+}
+
+// add_group_member and remove_group_member
+type groupMemberReq struct {
+	GroupId string `json:"group_id"`
+	Member  string `json:"member"`
+}
+
+// set_passphrase, rotate_key
 type passphraseRequest struct {
 	Passphrase string `json:"passphrase"`
 }
 
+// switch_profile
+type switchProfileReq struct {
+	Profile string `json:"profile"`
+}
+
+// get_profiles
+type profilesResp struct {
+	Current  string   `json:"current"`
+	Profiles []string `json:"profiles"`
+}
+
 // add_contact
 type addContactReq struct {
 	ToAddress string `json:"to_address"`
 	Name      string `json:"name"`
+	Hub       string `json:"hub"` // optional, the name of the partner Hub the contact is on (see hubpool.go)
+}
+
+// readd_contact
+type readdContactReq struct {
+	RevokedAddress string `json:"revoked_address"` // the revoked key of the contact
+	ToAddress      string `json:"to_address"`      // its new key
+}
+
+// accept_key_change
+type acceptKeyChangeReq struct {
+	Address string `json:"address"` // the previous key of the contact
+}
+
+// verify_contact
+type verifyContactReq struct {
+	Address string `json:"address"`
+	Name    string `json:"name"` // optional, the name of the contact by default
+	How     string `json:"how"`  // irl, facebook, twitter, etc.
+}
+
+type markVerifiedReq struct {
+	Address string `json:"address"`
+	Payload string `json:"payload"` // optional, the content of the QR code of the contact
 }
 
 // accept_contact
@@ -93,12 +185,40 @@ func serveLocalWebPage(localAddress string) {
 	r.HandleFunc("/set_passphrase", web.setPassphrase).Methods("POST")
 	r.HandleFunc("/set_configuration", web.setConfiguration).Methods("POST")
 	r.HandleFunc("/get_configuration", web.getConfiguration).Methods("GET")
+	r.HandleFunc("/get_profiles", web.getProfiles).Methods("GET")
+	r.HandleFunc("/switch_profile", web.switchProfile).Methods("POST")
 	// contacts
 	r.HandleFunc("/add_contact", web.addContact).Methods("POST")
 	r.HandleFunc("/accept_contact_request", web.acceptContactRequest).Methods("POST")
+	r.HandleFunc("/get_organization_members", web.getOrganizationMembers).Methods("GET")
+	r.HandleFunc("/get_revoked_contacts", web.getRevokedContacts).Methods("GET")
+	r.HandleFunc("/readd_contact", web.readdContact).Methods("POST")
+	r.HandleFunc("/get_key_changes", web.getKeyChanges).Methods("GET")
+	r.HandleFunc("/accept_key_change", web.acceptKeyChange).Methods("POST")
+	r.HandleFunc("/rotate_key", web.rotateKey).Methods("POST")
+	r.HandleFunc("/get_log_status", web.getLogStatus).Methods("GET")
+	r.HandleFunc("/verify_contact", web.verifyContact).Methods("POST")
+	r.HandleFunc("/get_contact_profile", web.getContactProfile).Methods("GET")
+	r.HandleFunc("/get_safety_number", web.getSafetyNumber).Methods("GET")
+	r.HandleFunc("/mark_verified", web.markVerified).Methods("POST")
 	// messages
 	r.HandleFunc("/get_new_message", web.getNewMessage).Methods("GET")
 	r.HandleFunc("/send_message", web.sendMessage).Methods("POST")
+	r.HandleFunc("/edit_message", web.editMessage).Methods("POST")
+	r.HandleFunc("/delete_message", web.deleteMessage).Methods("POST")
+	// groups
+	r.HandleFunc("/get_groups", web.getGroups).Methods("GET")
+	r.HandleFunc("/create_group", web.createGroup).Methods("POST")
+	r.HandleFunc("/send_group_message", web.sendGroupMessage).Methods("POST")
+	r.HandleFunc("/add_group_member", web.addGroupMember).Methods("POST")
+	r.HandleFunc("/remove_group_member", web.removeGroupMember).Methods("POST")
+
+	r.HandleFunc("/get_issues", web.getIssues).Methods("GET")
+	r.HandleFunc("/open_issue", web.openIssue).Methods("POST")
+	r.HandleFunc("/update_issue", web.updateIssue).Methods("POST")
+	// attachments
+	r.HandleFunc("/upload_attachment", web.uploadAttachment).Methods("POST")
+	r.HandleFunc("/download_attachment", web.downloadAttachment).Methods("GET")
 
 	// token
 	if _, err := rand.Read(web.token[:]); err != nil {
@@ -140,6 +260,7 @@ func verifyToken(givenToken string) bool {
 
 type indexData struct {
 	Identity string
+	Profile  string
 }
 
 func (web webState) getApp(w http.ResponseWriter, r *http.Request) {
@@ -155,7 +276,8 @@ func (web webState) getApp(w http.ResponseWriter, r *http.Request) {
 	// render the template
 	tmpl := template.Must(template.ParseFiles(indexPageLocation))
 	tmpl.Execute(w, indexData{
-	//		Identity: ssyk.keyPair.ExportPublicKey(), // TODO: can't display that as we haven't initliazed
+		//		Identity: ssyk.keyPair.ExportPublicKey(), // TODO: can't display that as we haven't initliazed
+		Profile: currentProfile,
 	})
 
 }
@@ -206,7 +328,7 @@ func (web webState) sendMessage(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	var req sendMessageReq
 	err := decoder.Decode(&req)
-	if err != nil || len(req.ToAddress) != 64 || req.Content == "" || len(req.Content) > messageMaxChars {
+	if err != nil || !isAddress(req.ToAddress) || req.Content == "" || len(req.Content) > messageMaxChars {
 		log.Println("couldn't decode sendMessage req:", err)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
 		return
@@ -230,7 +352,76 @@ func (web webState) sendMessage(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{
 			"success":  "true",
 			"convo_id": convoId,
+			"id":       msg.Id,
+		})
+	}
+}
+
+// http post http://127.0.0.1:7473/edit_message Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== convo_id=5 to_address=pubkey id=6 content="hey!"
+// editMessage replaces the content of one of our messages, the previous content is kept locally
+func (web webState) editMessage(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var req editMessageReq
+	err := decoder.Decode(&req)
+	if err != nil || !isAddress(req.ToAddress) || len(req.ConvoId) != 32 || len(req.Id) != 32 || req.Content == "" || len(req.Content) > messageMaxChars {
+		log.Println("couldn't decode editMessage req:", err)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+
+	// send the edit via sasayaki core algorithm
+	if err := web.ssyk.editMessage(req.ConvoId, req.ToAddress, req.Id, req.Content); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{
+			"success": "false",
+			"error":   err.Error(),
+		})
+	} else {
+		json.NewEncoder(w).Encode(map[string]string{"success": "true"})
+	}
+}
+
+// http post http://127.0.0.1:7473/delete_message Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== convo_id=5 to_address=pubkey id=6
+// deleteMessage retracts one of our messages
+func (web webState) deleteMessage(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var req deleteMessageReq
+	err := decoder.Decode(&req)
+	if err != nil || !isAddress(req.ToAddress) || len(req.ConvoId) != 32 || len(req.Id) != 32 {
+		log.Println("couldn't decode deleteMessage req:", err)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+
+	// send the deletion via sasayaki core algorithm
+	if err := web.ssyk.deleteMessage(req.ConvoId, req.ToAddress, req.Id); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{
+			"success": "false",
+			"error":   err.Error(),
 		})
+	} else {
+		json.NewEncoder(w).Encode(map[string]string{"success": "true"})
 	}
 }
 
@@ -265,10 +456,59 @@ func (web webState) setPassphrase(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// publish key packages, so that we can be added to MLS groups
+	if err := web.ssyk.initKeyPackages(); err != nil {
+		log.Println("couldn't publish key packages:", err)
+	}
+
 	//
 	json.NewEncoder(w).Encode(map[string]string{"success": "true"})
 }
 
+// http get http://127.0.0.1:7473/get_profiles Sasayaki-Token:dwl0R9o2SwuZQIAWHv-==
+func (web webState) getProfiles(w http.ResponseWriter, r *http.Request) {
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	//
+	json.NewEncoder(w).Encode(profilesResp{
+		Current:  currentProfile,
+		Profiles: listProfiles(),
+	})
+}
+
+// switchProfile closes the current profile, the passphrase of the new one has to be set next (set_passphrase).
+// A profile that doesn't exist yet is created then.
+// http post http://127.0.0.1:7473/switch_profile Sasayaki-Token:dwl0R9o2SwuZQIAWHv-== profile="acme"
+func (web webState) switchProfile(w http.ResponseWriter, r *http.Request) {
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var req switchProfileReq
+	if err := decoder.Decode(&req); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+	if !profileNameRegexp.MatchString(req.Profile) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "incorrect profile name"})
+		return
+	}
+	// close the current profile
+	if web.ssyk != nil {
+		closeProfile()
+		web.ssyk = nil
+	}
+	setProfile(req.Profile)
+	//
+	json.NewEncoder(w).Encode(map[string]string{"success": "true", "profile": currentProfile})
+}
+
 // http get http://127.0.0.1:7473/get_configuration Sasayaki-Token:dwl0R9o2SwuZQIAWHv-==
 func (web webState) getConfiguration(w http.ResponseWriter, r *http.Request) {
 	// initialized?
@@ -279,19 +519,27 @@ func (web webState) getConfiguration(w http.ResponseWriter, r *http.Request) {
 
 	//
 	json.NewEncoder(w).Encode(map[string]string{
-		"myAddress":     web.ssyk.myAddress,
-		"hub_address":   hub.hubAddress,
-		"hub_publickey": hex.EncodeToString(hub.hubPublicKey),
+		"myAddress":        web.ssyk.myAddress,
+		"hub_address":      hub.hubAddress,
+		"hub_publickey":    hex.EncodeToString(hub.hubPublicKey),
+		"certificate":      hex.EncodeToString(hub.certificate),
+		"organization_key": hex.EncodeToString(web.ssyk.organizationKey),
+		"trust_depth":      strconv.Itoa(trust.depth),
 	})
 }
 
-// http post http://127.0.0.1:7473/set_configuration Sasayaki-Token:dwl0R9o2SwuZQIAWHv-== id=5 convo_id=6 to_address="12052512a0e1cf14092224dba5a88c98ad8c5efe23f7794a122b9f0268499a10"  hub_address="127.0.0.1:7474" hub_publickey="1274e5b61840d54271e4144b80edc5af946a970ef1d84329368d1ec381ba2e21"
+// http post http://127.0.0.1:7473/set_configuration Sasayaki-Token:dwl0R9o2SwuZQIAWHv-== id=5 convo_id=6 to_address="12052512a0e1cf14092224dba5a88c98ad8c5efe23f7794a122b9f0268499a10"  hub_address="127.0.0.1:7474" hub_publickey="1274e5b61840d54271e4144b80edc5af946a970ef1d84329368d1ec381ba2e21" certificate="0a20..." organization_key="3a5f..." trust_depth:=2
 func (web webState) setConfiguration(w http.ResponseWriter, r *http.Request) {
 	// initialized?
 	if web.ssyk == nil {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
 		return
 	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		fmt.Fprintf(w, "You need to enter the correct auth token")
+		return
+	}
 	// parse request
 	decoder := json.NewDecoder(r.Body)
 	var cfgReq configuration
@@ -307,8 +555,25 @@ func (web webState) setConfiguration(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": "hub public key is incorrect"})
 		return
 	}
+	certificate, err := hex.DecodeString(cfgReq.Certificate)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "membership certificate is incorrect"})
+		return
+	}
+
+	organizationKey, err := hex.DecodeString(cfgReq.OrganizationKey)
+	if err != nil || (len(organizationKey) != 0 && len(organizationKey) != ed25519.PublicKeySize) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "organization public key is incorrect"})
+		return
+	}
 
-	initHubManager(cfgReq.HubAddress, hubPublicKey)
+	initHubManager(cfgReq.HubAddress, hubPublicKey, certificate)
+	if err := initHubPool(cfgReq.Hubs); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	web.ssyk.organizationKey = organizationKey
+	initTrustEngine(cfgReq.TrustDepth)
 
 	// save configuration
 	cfgReq.updateConfiguration()
@@ -323,6 +588,11 @@ func (web webState) addContact(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
 		return
 	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		fmt.Fprintf(w, "You need to enter the correct auth token")
+		return
+	}
 	// parse request
 	decoder := json.NewDecoder(r.Body)
 	var addReq addContactReq
@@ -333,7 +603,7 @@ func (web webState) addContact(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// pass the request to core
-	if err := web.ssyk.addContact(addReq.ToAddress, addReq.Name); err != nil {
+	if err := web.ssyk.aliceAddContactOnHub(addReq.ToAddress, addReq.Name, addReq.Hub); err != nil {
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
@@ -341,33 +611,667 @@ func (web webState) addContact(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"success": "true"})
 }
 
-func (web webState) acceptContactRequest(w http.ResponseWriter, r *http.Request) {
+// http get http://127.0.0.1:7473/get_organization_members?query=david&offset=0 Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ==
+// returns the verified members of our organization matching the query (on their name or team),
+// the address of a member can then be used as the name of a contact in add_contact
+func (web webState) getOrganizationMembers(w http.ResponseWriter, r *http.Request) {
 	// initialized?
 	if web.ssyk == nil {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
 		return
 	}
-	// parse request
-	decoder := json.NewDecoder(r.Body)
-	var ackReq ackContactReq
-	err := decoder.Decode(&ackReq)
-	if err != nil {
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	query := r.URL.Query()
+	offset, err := strconv.ParseUint(query.Get("offset"), 10, 32)
+	if query.Get("offset") == "" {
+		offset, err = 0, nil
+	}
+	if err != nil || len(query.Get("query")) > 100 {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
 		return
 	}
-	// decode first handshake message
-	handshakeMsg, err := hex.DecodeString(ackReq.FirstHandshakeMessage)
+
+	// search the directory via sasayaki core algorithm
+	members, total, err := web.ssyk.getOrganizationMembers(query.Get("query"), uint32(offset))
 	if err != nil {
-		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the handshake message"})
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Members []organizationMember `json:"members"`
+		Total   uint32               `json:"total"`
+	}{members, total})
+}
+
+// http get http://127.0.0.1:7473/get_revoked_contacts Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ==
+// returns the contacts whose keys have been revoked by our organization, and their (blocked) conversations
+func (web webState) getRevokedContacts(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	json.NewEncoder(w).Encode(web.ssyk.getRevokedContacts())
+}
+
+// http post http://127.0.0.1:7473/readd_contact Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== revoked_address="1205..." to_address="8a1c..."
+func (web webState) readdContact(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var req readdContactReq
+	err := decoder.Decode(&req)
+	if err != nil || !isAddress(req.RevokedAddress) || !isAddress(req.ToAddress) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
 		return
 	}
 
 	// pass the request to core
-	if err := web.ssyk.acceptContact(ackReq.FromAddress, ackReq.Name, handshakeMsg); err != nil {
+	if err := web.ssyk.readdContact(req.RevokedAddress, req.ToAddress); err != nil {
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
+	//
+	json.NewEncoder(w).Encode(map[string]string{"success": "true"})
+}
+
+// http get http://127.0.0.1:7473/get_key_changes Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ==
+// returns the contacts who have signed a new key, and where we are in moving to it
+func (web webState) getKeyChanges(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	json.NewEncoder(w).Encode(web.ssyk.getKeyChanges())
+}
+
+// http post http://127.0.0.1:7473/accept_key_change Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== address="1205..."
+func (web webState) acceptKeyChange(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var req acceptKeyChangeReq
+	err := decoder.Decode(&req)
+	if err != nil || !isAddress(req.Address) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
 
+	// pass the request to core
+	if err := web.ssyk.acceptKeyChange(req.Address); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
 	//
 	json.NewEncoder(w).Encode(map[string]string{"success": "true"})
 }
+
+// http post http://127.0.0.1:7473/rotate_key Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== passphrase="prout"
+// replaces our key with a new one, signed by the current one, and returns our new address
+func (web webState) rotateKey(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var passphraseReq passphraseRequest
+	err := decoder.Decode(&passphraseReq)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+
+	// pass the request to core
+	if err := web.ssyk.rotateKey(passphraseReq.Passphrase); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	//
+	json.NewEncoder(w).Encode(map[string]string{"success": "true", "myAddress": web.ssyk.myAddress})
+}
+
+// http get http://127.0.0.1:7473/get_log_status Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ==
+// returns our latest tree head of the transparency log, the split views detected (if any), and why the log
+// can't be updated (if it can't)
+func (web webState) getLogStatus(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	treeHead, alerts, updateErr := web.ssyk.getLogStatus()
+	updateError := ""
+	if updateErr != nil {
+		updateError = updateErr.Error()
+	}
+	json.NewEncoder(w).Encode(struct {
+		TreeSize    uint64     `json:"tree_size"`
+		RootHash    string     `json:"root_hash"`
+		Timestamp   int64      `json:"timestamp"`
+		Alerts      []logAlert `json:"alerts"`
+		UpdateError string     `json:"update_error"`
+	}{treeHead.GetTreeSize(), hex.EncodeToString(treeHead.GetRootHash()), treeHead.GetTimestamp(), alerts, updateError})
+}
+
+// http post http://127.0.0.1:7473/verify_contact Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== address="1205..." name="David" how="irl"
+// signs and publishes a verification of the key of a contact
+func (web webState) verifyContact(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var req verifyContactReq
+	err := decoder.Decode(&req)
+	if err != nil || !isAddress(req.Address) || req.How == "" {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+
+	// pass the request to core
+	if err := web.ssyk.verifyContact(req.Address, req.Name, req.How); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	//
+	json.NewEncoder(w).Encode(map[string]string{"success": "true"})
+}
+
+// http get http://127.0.0.1:7473/get_contact_profile?address=1205... Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ==
+// returns the verifications published for a key, and how many of them were done by people we trust
+func (web webState) getContactProfile(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	profile, err := web.ssyk.getContactProfile(r.URL.Query().Get("address"))
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(profile)
+}
+
+// http get http://127.0.0.1:7473/get_safety_number?address=1205... Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ==
+// returns the safety number we share with a contact, and the payload of the QR code to show it
+func (web webState) getSafetyNumber(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	info, err := web.ssyk.getSafetyNumber(r.URL.Query().Get("address"))
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(info)
+}
+
+// http post http://127.0.0.1:7473/mark_verified Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== address="1205..." payload="sasayaki-verify:0:1205...:3a5f..."
+// marks a contact as verified in person, after comparing the safety numbers or scanning its QR code
+func (web webState) markVerified(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var req markVerifiedReq
+	err := decoder.Decode(&req)
+	if err != nil || !isAddress(req.Address) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+
+	// pass the request to core
+	if req.Payload != "" {
+		if err := web.ssyk.checkSafetyPayload(req.Address, req.Payload); err != nil {
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if err := web.ssyk.verifyContact(req.Address, "", "irl"); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	//
+	json.NewEncoder(w).Encode(map[string]string{"success": "true"})
+}
+
+func (web webState) acceptContactRequest(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var ackReq ackContactReq
+	err := decoder.Decode(&ackReq)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+	// decode first handshake message
+	handshakeMsg, err := hex.DecodeString(ackReq.FirstHandshakeMessage)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the handshake message"})
+		return
+	}
+
+	// pass the request to core
+	if err := web.ssyk.acceptContact(ackReq.FromAddress, ackReq.Name, handshakeMsg); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	//
+	json.NewEncoder(w).Encode(map[string]string{"success": "true"})
+}
+
+// http -f post http://127.0.0.1:7473/upload_attachment Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== convo_id=5 to_address=pubkey file@report.pdf
+// uploadAttachment takes a multipart form containing the file to send
+func (web webState) uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	r.Body = http.MaxBytesReader(w, r.Body, attachmentMaxSize+4096)
+	convoId := r.FormValue("convo_id")
+	toAddress := r.FormValue("to_address")
+	file, header, err := r.FormFile("file")
+	if err != nil || !isAddress(toAddress) || len(convoId) != 32 {
+		log.Println("couldn't decode uploadAttachment req:", err)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't read the file"})
+		return
+	}
+
+	// send the attachment via sasayaki core algorithm
+	if msgId, err := web.ssyk.sendAttachment(convoId, toAddress, header.Filename, content); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{
+			"success": "false",
+			"error":   err.Error(),
+		})
+	} else {
+		json.NewEncoder(w).Encode(map[string]string{
+			"success": "true",
+			"id":      msgId,
+		})
+	}
+}
+
+// http get http://127.0.0.1:7473/download_attachment?token=wZ8VHXeKBoSrQ-m5sGnCFQ==&convo_id=5&id=6
+// downloadAttachment serves the decrypted file. The token is passed in the URL so that it can be used as a link
+func (web webState) downloadAttachment(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	query := r.URL.Query()
+	if !verifyToken(query.Get("token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+
+	// fetch the attachment via sasayaki core algorithm
+	location, err := web.ssyk.downloadAttachment(query.Get("convo_id"), query.Get("id"))
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// serve it
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(location)))
+	http.ServeFile(w, r, location)
+}
+
+// http get http://127.0.0.1:7473/get_groups Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ==
+func (web webState) getGroups(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	//
+	json.NewEncoder(w).Encode(storage.getGroups())
+}
+
+// http post http://127.0.0.1:7473/create_group Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== title="incident" members:='["pubkey1", "pubkey2"]' engine="mls" channel="issues"
+func (web webState) createGroup(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var req createGroupReq
+	err := decoder.Decode(&req)
+	if err != nil || req.Title == "" || len(req.Title) > messageMaxChars || len(req.Members) == 0 {
+		log.Println("couldn't decode createGroup req:", err)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+	for _, member := range req.Members {
+		if !isAddress(member) {
+			json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+			return
+		}
+	}
+
+	if req.Engine == "" {
+		req.Engine = senderKeysEngine
+	}
+	if req.Channel == "" {
+		req.Channel = chatChannel
+	}
+
+	// create the group via sasayaki core algorithm
+	if groupId, err := web.ssyk.createGroup(req.Title, req.Members, req.Engine, req.Channel); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{
+			"success": "false",
+			"error":   err.Error(),
+		})
+	} else {
+		json.NewEncoder(w).Encode(map[string]string{
+			"success":  "true",
+			"group_id": groupId,
+		})
+	}
+}
+
+// http post http://127.0.0.1:7473/send_group_message Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== group_id=5 content="hey all"
+// (in issue tracker channels: issue_id=6)
+func (web webState) sendGroupMessage(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var req sendGroupMessageReq
+	err := decoder.Decode(&req)
+	if err != nil || len(req.GroupId) != 32 || req.Content == "" || len(req.Content) > messageMaxChars {
+		log.Println("couldn't decode sendGroupMessage req:", err)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+
+	msg := &plaintextMsg{
+		ConvoId:     req.GroupId,
+		FromAddress: web.ssyk.myAddress,
+		Content:     req.Content,
+	}
+	if req.IssueId != "" {
+		msg.Issue = &issueInfo{Id: req.IssueId}
+	}
+
+	// send message via sasayaki core algorithm
+	if msgId, err := web.ssyk.sendGroupMessage(msg); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{
+			"success": "false",
+			"error":   err.Error(),
+		})
+	} else {
+		json.NewEncoder(w).Encode(map[string]string{
+			"success": "true",
+			"id":      msgId,
+		})
+	}
+}
+
+// http post http://127.0.0.1:7473/add_group_member Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== group_id=5 member=pubkey
+func (web webState) addGroupMember(w http.ResponseWriter, r *http.Request) {
+	web.updateGroupMember(w, r, func(groupId, member string) error {
+		return web.ssyk.addGroupMember(groupId, member)
+	})
+}
+
+// http post http://127.0.0.1:7473/remove_group_member Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== group_id=5 member=pubkey
+func (web webState) removeGroupMember(w http.ResponseWriter, r *http.Request) {
+	web.updateGroupMember(w, r, func(groupId, member string) error {
+		return web.ssyk.removeGroupMember(groupId, member)
+	})
+}
+
+// updateGroupMember parses a groupMemberReq and passes it to the core
+func (web webState) updateGroupMember(w http.ResponseWriter, r *http.Request, update func(string, string) error) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var req groupMemberReq
+	err := decoder.Decode(&req)
+	if err != nil || len(req.GroupId) != 32 || !isAddress(req.Member) {
+		log.Println("couldn't decode groupMember req:", err)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+
+	// pass the request to core
+	if err := update(req.GroupId, req.Member); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{
+			"success": "false",
+			"error":   err.Error(),
+		})
+	} else {
+		json.NewEncoder(w).Encode(map[string]string{"success": "true"})
+	}
+}
+
+// http get http://127.0.0.1:7473/get_issues?group_id=5&state=open&label=bug&assignee=pubkey Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ==
+// the filters (state, label and assignee) are optional
+func (web webState) getIssues(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	query := r.URL.Query()
+
+	// get the issues via sasayaki core algorithm
+	issues, err := web.ssyk.getIssues(query.Get("group_id"), query.Get("state"), query.Get("label"), query.Get("assignee"))
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(issues)
+}
+
+// http post http://127.0.0.1:7473/open_issue Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== group_id=5 title="the build is broken" labels:='["bug"]' assignees:='["pubkey"]'
+func (web webState) openIssue(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var req openIssueReq
+	err := decoder.Decode(&req)
+	if err != nil || len(req.GroupId) != 32 || req.Title == "" || len(req.Title) > messageMaxChars {
+		log.Println("couldn't decode openIssue req:", err)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+
+	// open the issue via sasayaki core algorithm
+	if issueId, err := web.ssyk.openIssue(req.GroupId, req.Title, req.Labels, req.Assignees); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{
+			"success": "false",
+			"error":   err.Error(),
+		})
+	} else {
+		json.NewEncoder(w).Encode(map[string]string{
+			"success":  "true",
+			"issue_id": issueId,
+		})
+	}
+}
+
+// http post http://127.0.0.1:7473/update_issue Sasayaki-Token:wZ8VHXeKBoSrQ+m5sGnCFQ== group_id=5 issue_id=6 state="closed"
+// title, state, labels and assignees are optional
+func (web webState) updateIssue(w http.ResponseWriter, r *http.Request) {
+	// initialized?
+	if web.ssyk == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sasayaki needs to be initialized first"})
+		return
+	}
+	// verify auth token
+	if !verifyToken(r.Header.Get("Sasayaki-Token")) {
+		json.NewEncoder(w).Encode(map[string]string{"error": "You need to enter the correct auth token"})
+		return
+	}
+	// parse request
+	decoder := json.NewDecoder(r.Body)
+	var req updateIssueReq
+	err := decoder.Decode(&req)
+	if err != nil || len(req.GroupId) != 32 || len(req.IssueId) != 32 || (req.Title != nil && len(*req.Title) > messageMaxChars) {
+		log.Println("couldn't decode updateIssue req:", err)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Couldn't parse the request"})
+		return
+	}
+
+	// update the issue via sasayaki core algorithm
+	err = web.ssyk.updateIssue(req.GroupId, req.IssueId, func(issue *issueInfo) {
+		if req.Title != nil && *req.Title != "" {
+			issue.Title = *req.Title
+		}
+		if req.State != nil {
+			issue.State = *req.State
+		}
+		if req.Labels != nil {
+			issue.Labels = *req.Labels
+		}
+		if req.Assignees != nil {
+			issue.Assignees = *req.Assignees
+		}
+	})
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{
+			"success": "false",
+			"error":   err.Error(),
+		})
+	} else {
+		json.NewEncoder(w).Encode(map[string]string{"success": "true"})
+	}
+}
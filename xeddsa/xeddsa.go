@@ -0,0 +1,97 @@
+//
+// XEdDSA Signatures
+// =================
+//
+// Identities (of clients and of the Hub) are X25519 key pairs (see libdisco), which can't sign things as is.
+// XEdDSA (https://signal.org/docs/specifications/xeddsa/) converts the key pair into
+// an Ed25519 key pair whose public key has its sign bit set to 0, so that anyone can
+// verify signatures produced with a X25519 private key from its X25519 public key.
+//
+// Verification is done with the standard Ed25519 algorithm.
+//
+package xeddsa
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"math/big"
+
+	"filippo.io/edwards25519"
+)
+
+var (
+	// p = 2^255 - 19
+	curve25519P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+)
+
+// Sign signs a message with a X25519 private key
+func Sign(privateKey [32]byte, message []byte) []byte {
+	// compute the Ed25519 key pair (a, A) with A's sign bit set to 0
+	a := edwards25519.NewScalar().SetBytesWithClamping(privateKey[:])
+	A := new(edwards25519.Point).ScalarBaseMult(a).Bytes()
+	if A[31]&0x80 != 0 {
+		a.Negate(a)
+		A[31] &= 0x7f
+	}
+	// r = hash1(a || M || Z) with Z 64 random bytes
+	var Z [64]byte
+	if _, err := rand.Read(Z[:]); err != nil {
+		panic(err)
+	}
+	hash := sha512.New()
+	hash.Write([]byte{0xfe})
+	for i := 0; i < 31; i++ {
+		hash.Write([]byte{0xff})
+	}
+	hash.Write(a.Bytes())
+	hash.Write(message)
+	hash.Write(Z[:])
+	r := edwards25519.NewScalar().SetUniformBytes(hash.Sum(nil))
+	// R = rB
+	R := new(edwards25519.Point).ScalarBaseMult(r).Bytes()
+	// h = hash(R || A || M)
+	hash.Reset()
+	hash.Write(R)
+	hash.Write(A)
+	hash.Write(message)
+	h := edwards25519.NewScalar().SetUniformBytes(hash.Sum(nil))
+	// s = r + ha
+	s := edwards25519.NewScalar().MultiplyAdd(h, a, r)
+	//
+	return append(R, s.Bytes()...)
+}
+
+// Verify verifies a signature produced by Sign with the X25519 public key of the signer
+func Verify(publicKey, message, signature []byte) bool {
+	if len(publicKey) != 32 || len(signature) != 64 {
+		return false
+	}
+	// read the montgomery u coordinate (little-endian)
+	var buf [32]byte
+	for i := 0; i < 32; i++ {
+		buf[i] = publicKey[31-i]
+	}
+	buf[0] &= 0x7f
+	u := new(big.Int).SetBytes(buf[:])
+	if u.Cmp(curve25519P) >= 0 {
+		return false
+	}
+	// convert it to the edwards y coordinate: y = (u - 1) / (u + 1)
+	denominator := new(big.Int).Add(u, big.NewInt(1))
+	denominator.Mod(denominator, curve25519P)
+	if denominator.Sign() == 0 {
+		return false
+	}
+	y := new(big.Int).Sub(u, big.NewInt(1))
+	y.Mul(y, new(big.Int).ModInverse(denominator, curve25519P))
+	y.Mod(y, curve25519P)
+	// encode the Ed25519 public key (little-endian, sign bit set to 0)
+	var A [32]byte
+	yBytes := y.Bytes()
+	for i := 0; i < len(yBytes); i++ {
+		A[i] = yBytes[len(yBytes)-1-i]
+	}
+	//
+	return ed25519.Verify(ed25519.PublicKey(A[:]), message, signature)
+}
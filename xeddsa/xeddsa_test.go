@@ -0,0 +1,72 @@
+package xeddsa
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"testing"
+)
+
+// newKeyPair returns a X25519 private key and its public key
+func newKeyPair(t *testing.T) ([32]byte, []byte) {
+	var privateKey [32]byte
+	if _, err := rand.Read(privateKey[:]); err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(privateKey[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey, key.PublicKey().Bytes()
+}
+
+func TestSignAndVerify(t *testing.T) {
+	message := []byte("SasayakiTreeHead")
+	// half of the keys have an Ed25519 public key with the sign bit set, which Sign negates
+	for i := 0; i < 32; i++ {
+		privateKey, publicKey := newKeyPair(t)
+		signature := Sign(privateKey, message)
+		if !Verify(publicKey, message, signature) {
+			t.Fatalf("key %d: the signature doesn't verify", i)
+		}
+		if Verify(publicKey, []byte("SasayakiTreeHeaD"), signature) {
+			t.Fatalf("key %d: the signature verifies another message", i)
+		}
+		_, otherPublicKey := newKeyPair(t)
+		if Verify(otherPublicKey, message, signature) {
+			t.Fatalf("key %d: the signature verifies with another key", i)
+		}
+		tampered := append([]byte{}, signature...)
+		tampered[10] ^= 1
+		if Verify(publicKey, message, tampered) {
+			t.Fatalf("key %d: a tampered signature verifies", i)
+		}
+	}
+}
+
+func TestSignaturesAreRandomized(t *testing.T) {
+	privateKey, publicKey := newKeyPair(t)
+	first, second := Sign(privateKey, []byte("hello")), Sign(privateKey, []byte("hello"))
+	if string(first) == string(second) {
+		t.Error("two signatures of the same message are equal")
+	}
+	if !Verify(publicKey, []byte("hello"), first) || !Verify(publicKey, []byte("hello"), second) {
+		t.Error("the signatures don't verify")
+	}
+}
+
+func TestVerifyRejectsMalformedInputs(t *testing.T) {
+	privateKey, publicKey := newKeyPair(t)
+	signature := Sign(privateKey, []byte("hello"))
+	if Verify(publicKey[:31], []byte("hello"), signature) {
+		t.Error("a short public key verifies")
+	}
+	if Verify(publicKey, []byte("hello"), signature[:63]) {
+		t.Error("a short signature verifies")
+	}
+	// u = p is not a valid coordinate
+	p := []byte{0xed, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}
+	if Verify(p, []byte("hello"), signature) {
+		t.Error("a public key out of the field verifies")
+	}
+}